@@ -0,0 +1,28 @@
+package apptest
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SequenceIDGenerator generates the ids prefix-n, prefix-n+1, ... starting from a seed n.
+// It satisfies app.IDGenerator and is safe for concurrent use.
+type SequenceIDGenerator struct {
+	mu     sync.Mutex
+	prefix string
+	next   int
+}
+
+// NewSequenceIDGenerator returns a SequenceIDGenerator whose first id is prefix-seed.
+func NewSequenceIDGenerator(prefix string, seed int) *SequenceIDGenerator {
+	return &SequenceIDGenerator{prefix: prefix, next: seed}
+}
+
+// NewID returns the next id of the sequence.
+func (g *SequenceIDGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	id := fmt.Sprintf("%s-%d", g.prefix, g.next)
+	g.next++
+	return id
+}
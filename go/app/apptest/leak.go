@@ -0,0 +1,146 @@
+package apptest
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// バックグラウンドのgoroutine (tickerやwriterなど) をテストで止め忘れていないかを確認する
+// テストの前後でgoroutineの一覧を比べ、テスト中に起動されて残っているものをリークとして報告する
+
+// leakIgnoredFuncs are functions of long-lived goroutines that are not leaks.
+// A goroutine is ignored when any frame of its stack starts with one of them.
+var leakIgnoredFuncs = []string{
+	// signal.Notify / signal.NotifyContext が起動する、プロセスの間ずっと動くgoroutine
+	"os/signal.loop",
+	"os/signal.signal_recv",
+	"runtime.ensureSigM",
+	// テストフレームワーク自身
+	"testing.(*M).",
+	"testing.(*T).Run",
+	"testing.runTests",
+}
+
+// leakTimeout is how long VerifyNoLeaks waits for goroutines that are still stopping.
+const leakTimeout = 5 * time.Second
+
+// goroutine is a goroutine in the output of runtime.Stack.
+type goroutine struct {
+	id    string
+	stack string
+}
+
+// goroutines returns the goroutines other than the calling one.
+func goroutines() []goroutine {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	var gs []goroutine
+	// 最初のブロックは呼び出したgoroutine自身
+	for i, block := range strings.Split(string(buf), "\n\n") {
+		if i == 0 {
+			continue
+		}
+		header, _, _ := strings.Cut(block, "\n")
+		// "goroutine 12 [chan receive]:"
+		id, _, _ := strings.Cut(strings.TrimPrefix(header, "goroutine "), " ")
+		gs = append(gs, goroutine{id: id, stack: block})
+	}
+	return gs
+}
+
+// ignored reports whether the goroutine is a known long-lived one.
+func (g goroutine) ignored() bool {
+	for _, line := range strings.Split(g.stack, "\n") {
+		for _, fn := range leakIgnoredFuncs {
+			if strings.HasPrefix(line, fn) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// leaked returns the goroutines that are neither in before nor ignored.
+func leaked(before []string) []goroutine {
+	var leaks []goroutine
+	for _, g := range goroutines() {
+		if !slices.Contains(before, g.id) && !g.ignored() {
+			leaks = append(leaks, g)
+		}
+	}
+	return leaks
+}
+
+// waitLeaks waits up to timeout for the goroutines started after before to stop, and returns the ones still running.
+func waitLeaks(before []string, timeout time.Duration) []goroutine {
+	deadline := time.Now().Add(timeout)
+	wait := time.Millisecond
+	for {
+		leaks := leaked(before)
+		if len(leaks) == 0 || time.Now().After(deadline) {
+			return leaks
+		}
+		time.Sleep(wait)
+		wait = min(2*wait, 100*time.Millisecond)
+	}
+}
+
+// goroutineIDs returns the ids of the current goroutines.
+func goroutineIDs() []string {
+	var ids []string
+	for _, g := range goroutines() {
+		ids = append(ids, g.id)
+	}
+	return ids
+}
+
+// VerifyNoLeaks runs the tests of m and fails them if goroutines started by the tests are still running afterwards.
+// It is meant to be called from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(apptest.VerifyNoLeaks(m))
+//	}
+func VerifyNoLeaks(m *testing.M) int {
+	before := goroutineIDs()
+	code := m.Run()
+	if code != 0 {
+		return code
+	}
+	if leaks := waitLeaks(before, leakTimeout); len(leaks) > 0 {
+		fmt.Fprintf(os.Stderr, "found %d leaked goroutine(s):\n", len(leaks))
+		for _, g := range leaks {
+			fmt.Fprintf(os.Stderr, "\n%s\n", g.stack)
+		}
+		return 1
+	}
+	return 0
+}
+
+// CheckLeaks fails t if goroutines started during the test are still running when it finishes.
+// Tests calling it must not run in parallel, since goroutines of other tests would be reported.
+func CheckLeaks(t testing.TB) {
+	t.Helper()
+	before := goroutineIDs()
+	t.Cleanup(func() {
+		if leaks := waitLeaks(before, leakTimeout); len(leaks) > 0 {
+			var b strings.Builder
+			for _, g := range leaks {
+				fmt.Fprintf(&b, "\n%s\n", g.stack)
+			}
+			t.Errorf("found %d leaked goroutine(s):\n%s", len(leaks), b.String())
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package apptest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeaked(t *testing.T) {
+	before := goroutineIDs()
+
+	stop := make(chan struct{})
+	go func() {
+		<-stop
+	}()
+	if leaks := waitLeaks(before, 10*time.Millisecond); len(leaks) != 1 {
+		t.Fatalf("expected 1 leaked goroutine, got %d", len(leaks))
+	}
+
+	// 止めたgoroutineは報告しない
+	close(stop)
+	if leaks := waitLeaks(before, leakTimeout); len(leaks) != 0 {
+		t.Errorf("expected no leaked goroutines after stopping, got %v", leaks)
+	}
+}
+
+func TestGoroutineIgnored(t *testing.T) {
+	cases := map[string]struct {
+		stack string
+		want  bool
+	}{
+		"ignored: signal loop": {
+			stack: "goroutine 5 [syscall]:\nos/signal.signal_recv()\n\t/usr/local/go/src/runtime/sigqueue.go:152 +0x29\nos/signal.loop()\n\t/usr/local/go/src/os/signal/signal_unix.go:23 +0x13",
+			want:  true,
+		},
+		"not ignored: writer": {
+			stack: "goroutine 9 [select]:\nmercari-build-training/app.(*serializedItemRepository).run(0xc000120000)\n\t/app/writer.go:56 +0x8d",
+			want:  false,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := (goroutine{stack: tt.stack}).ignored(); got != tt.want {
+				t.Errorf("expected ignored %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// APIキーによる認証
+// API_KEY が設定されていれば、書き込みのリクエスト (POST/PUT/PATCH/DELETE) と /admin/ 以下の全てのリクエストにキーを求める
+// それ以外の読み取りは公開のままで、API_KEY がなければ (ローカルでの開発など) 認証しない
+
+const (
+	// apiKeyEnv is the environment variable holding the API key. It is not a flag, so that GET /admin/flags never shows it.
+	apiKeyEnv = "API_KEY"
+	// apiKeyHeader carries the API key for clients that cannot set Authorization.
+	apiKeyHeader = "X-API-Key"
+	// adminPathPrefix is the prefix of the admin endpoints, which need the API key whatever the method.
+	adminPathPrefix = "/admin/"
+)
+
+// apiKeyMethods are the methods that need the API key.
+var apiKeyMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// needsAPIKey reports whether the request must carry the API key.
+func needsAPIKey(r *http.Request) bool {
+	return slices.Contains(apiKeyMethods, r.Method) || strings.HasPrefix(r.URL.Path, adminPathPrefix)
+}
+
+// requestAPIKey returns the key of "Authorization: Bearer <key>", or of apiKeyHeader.
+func requestAPIKey(r *http.Request) (string, bool) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, key, ok := strings.Cut(auth, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return "", false
+		}
+		return strings.TrimSpace(key), true
+	}
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key, true
+	}
+	return "", false
+}
+
+// apiKeyMiddleware rejects write and admin requests without apiKey with 401. An empty apiKey disables the check.
+func apiKeyMiddleware(next http.Handler, apiKey string) http.Handler {
+	if apiKey == "" {
+		return next
+	}
+	// ハッシュ同士を比べて、一致するまでの時間からもキーの長さからも推測されないようにする
+	want := sha256.Sum256([]byte(apiKey))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !needsAPIKey(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key, ok := requestAPIKey(r)
+		got := sha256.Sum256([]byte(key))
+		if !ok || subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "missing or invalid API key", Status: http.StatusUnauthorized})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
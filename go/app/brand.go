@@ -0,0 +1,15 @@
+package app
+
+import "strings"
+
+// ブランド (任意)
+// 表記ゆれを減らすため、前後の空白を除き、連続する空白を1つにまとめてから保存・検索する
+// 大文字小文字は区別せずに絞り込む ("nike" で "Nike" も見つかる)
+
+// maxBrandChars bounds the length of a brand in characters.
+const maxBrandChars = 50
+
+// normalizeBrand trims the brand and collapses runs of whitespace into a single space.
+func normalizeBrand(brand string) string {
+	return strings.Join(strings.Fields(brand), " ")
+}
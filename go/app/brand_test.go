@@ -0,0 +1,123 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalizeBrand(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		brand string
+		want  string
+	}{
+		"as is":               {brand: "Nike", want: "Nike"},
+		"trimmed":             {brand: "  Nike\t", want: "Nike"},
+		"collapsed":           {brand: "New   Balance", want: "New Balance"},
+		"full-width space":    {brand: "ユニ　　クロ", want: "ユニ クロ"},
+		"empty":               {brand: "", want: ""},
+		"only whitespace":     {brand: " \t ", want: ""},
+		"case is not changed": {brand: "adidas", want: "adidas"},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := normalizeBrand(tt.brand); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBrandE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: repo}
+
+	for _, body := range []string{
+		`{"name":"running shoes","category":"shoes","image_name":"default.jpg","price":8000,"brand":"  Nike "}`,
+		`{"name":"sneakers","category":"shoes","image_name":"default.jpg","price":9000,"brand":"New   Balance"}`,
+		`{"name":"sandals","category":"shoes","image_name":"default.jpg","price":2000}`,
+	} {
+		req := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.AddItem(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+	}
+
+	item, err := repo.GetItemById(t.Context(), "2")
+	if err != nil {
+		t.Fatalf("failed to get item: %v", err)
+	}
+	if item.Brand != "New Balance" {
+		t.Errorf("expected the normalized brand %q, got %q", "New Balance", item.Brand)
+	}
+
+	cases := map[string]struct {
+		target string
+		code   int
+		names  []string
+	}{
+		"ok: brand ignoring case": {target: "/items?brand=nike", code: http.StatusOK, names: []string{"running shoes"}},
+		"ok: normalized filter":   {target: "/items?brand=new%20%20balance", code: http.StatusOK, names: []string{"sneakers"}},
+		"ok: unknown brand":       {target: "/items?brand=adidas", code: http.StatusOK, names: []string{}},
+		"ng: empty brand":         {target: "/items?brand=", code: http.StatusBadRequest},
+		"ng: blank brand":         {target: "/items?brand=%20", code: http.StatusBadRequest},
+		"ok: search by brand":     {target: "/search?keyword=nike", code: http.StatusOK, names: []string{"running shoes"}},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if strings.HasPrefix(tt.target, "/search") {
+				h.SearchItemsByKeyword(rr, req)
+			} else {
+				h.GetItems(rr, req)
+			}
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Items []Item `json:"items"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			names := []string{}
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
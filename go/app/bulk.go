@@ -0,0 +1,210 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	// defaultMaxImportRows is used when neither Handlers.MaxBulkItems nor MAX_IMPORT_ROWS is set.
+	defaultMaxImportRows = 1000
+	// maxBulkItemBytes is the allowance per element for the request body of POST /items/bulk.
+	maxBulkItemBytes = 4 << 10 // 4KB
+)
+
+var errTooManyBulkItems = errors.New("too many items")
+
+// maxBulkItems returns the maximum number of rows of POST /items/bulk and POST /items/import.
+// 1回のインポートで書き込み用の接続を長く占有しないように、トランザクションの大きさを抑える
+func (s *Handlers) maxBulkItems() int {
+	if s.MaxBulkItems > 0 {
+		return s.MaxBulkItems
+	}
+	if n := s.flags.Int(flagMaxImportRows); n > 0 {
+		return n
+	}
+	return defaultMaxImportRows
+}
+
+// writeTooManyRows responds 400 for an import over the row limit, suggesting to split it.
+func writeTooManyRows(w http.ResponseWriter, maxRows int) {
+	http.Error(w, fmt.Sprintf("import exceeds maximum of %d rows; split it into chunks of at most %d rows", maxRows, maxRows), http.StatusBadRequest)
+}
+
+// BulkItem is an element of the request body of POST /items/bulk.
+type BulkItem struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	// ImageName is the name of an image already stored. Empty means the default image.
+	ImageName string `json:"image_name"`
+	// Price is the price in yen, as bulk items are always in JPY. Omitted means 0.
+	Price *int `json:"price"`
+
+	// decodeErr is set when the element is valid JSON but has a field of the wrong type or an unknown field.
+	decodeErr error
+}
+
+// BulkItemResult is the outcome of an element of POST /items/bulk, in the order of the request.
+type BulkItemResult struct {
+	// ID is the assigned id when the item was added.
+	ID int `json:"id,omitempty"`
+	// Error is the reason when the item was rejected.
+	Error string `json:"error,omitempty"`
+	// Fields are the problems with the fields when the item failed validation.
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+type AddItemsBulkResponse struct {
+	// IDs are the assigned ids of the added items in the order of the request.
+	IDs []int `json:"ids"`
+	// Results has an entry for each element of the request.
+	Results []BulkItemResult `json:"results"`
+	// Failed is the number of rejected elements.
+	Failed int `json:"failed"`
+}
+
+// parseAddItemsBulkRequest decodes the JSON array of items, which must have at most maxItems elements.
+func parseAddItemsBulkRequest(r *http.Request, maxItems int) ([]BulkItem, error) {
+	limits := defaultJSONLimits
+	limits.MaxBytes = int64(maxItems) * maxBulkItemBytes
+	var elems []json.RawMessage
+	if err := decodeJSONBody(r, limits, &elems); err != nil {
+		return nil, err
+	}
+	if len(elems) > maxItems {
+		return nil, fmt.Errorf("%w: the maximum is %d", errTooManyBulkItems, maxItems)
+	}
+	if len(elems) == 0 {
+		return nil, errors.New("items are required")
+	}
+
+	items := make([]BulkItem, len(elems))
+	for i, elem := range elems {
+		// 型が違う要素や知らないフィールドを持つ要素は、その要素だけ失敗にして続ける
+		if err := decodeJSONValue(elem, limits, &items[i]); err != nil {
+			items[i] = BulkItem{decodeErr: fmt.Errorf("failed to decode: %w", err)}
+		}
+	}
+
+	return items, nil
+}
+
+// validateBulkItem converts an element of POST /items/bulk to an Item.
+// The fields are checked like those of POST /items, and their problems are returned as a *ValidationError.
+func (s *Handlers) validateBulkItem(b BulkItem) (*Item, error) {
+	if b.decodeErr != nil {
+		return nil, b.decodeErr
+	}
+	if err := checkParamLen("name", b.Name, maxNameLen); err != nil {
+		return nil, err
+	}
+	if err := checkParamLen("category", b.Category, maxCategoryLen); err != nil {
+		return nil, err
+	}
+	if err := checkParamLen("image_name", b.ImageName, maxImageNameLen); err != nil {
+		return nil, err
+	}
+
+	// 1件ずつの追加と同じく、最初の1つで止めずに全ての項目の問題を返す
+	var v validator
+	v.text("name", b.Name, maxItemNameChars)
+	v.text("category", normalizeCategory(b.Category), maxCategoryChars)
+	item := &Item{Name: b.Name, Category: b.Category, Status: itemStatusOnSale, Image: defaultImageName}
+	if b.Price != nil {
+		if *b.Price < 0 {
+			v.add("price", "must be a non-negative integer", fmt.Errorf("%w: %d is negative", errInvalidPrice, *b.Price))
+		}
+		item.Price.Amount = *b.Price
+	}
+	if b.ImageName != "" {
+		// 保存済みの画像しか指定できない
+		if _, err := s.buildImagePath(b.ImageName); err != nil {
+			v.add("image_name", err.Error(), err)
+		}
+		item.Image = b.ImageName
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// validationFields returns the field errors of err from validateBulkItem, or nil if it is not a *ValidationError.
+func validationFields(err error) []FieldError {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Errors
+	}
+	return nil
+}
+
+// AddItemsBulk is a handler to add items at once for POST /items/bulk .
+// Elements failing validation are reported in the results and the others are still added.
+// The valid items are inserted in a single transaction, so a database error adds none of them.
+func (s *Handlers) AddItemsBulk(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	maxItems := s.maxBulkItems()
+	bulk, err := parseAddItemsBulkRequest(r, maxItems)
+	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			writeValidationError(w, validationErr)
+			return
+		}
+		if errors.Is(err, errTooManyBulkItems) {
+			writeTooManyRows(w, maxItems)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	actor, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(ctx, "parse")
+
+	resp := AddItemsBulkResponse{IDs: []int{}, Results: make([]BulkItemResult, len(bulk))}
+	items := make([]*Item, 0, len(bulk))
+	// indexes[i] is the index in the request of items[i].
+	indexes := make([]int, 0, len(bulk))
+	for idx, b := range bulk {
+		item, err := s.validateBulkItem(b)
+		if err != nil {
+			// 不正な要素は黙って捨てずに、理由を返す
+			resp.Results[idx].Error, resp.Results[idx].Fields = err.Error(), validationFields(err)
+			resp.Failed++
+			continue
+		}
+		items = append(items, item)
+		indexes = append(indexes, idx)
+	}
+
+	if len(items) > 0 {
+		if err := s.itemRepo.InsertMany(withActor(ctx, actor), items); err != nil {
+			slog.Error("failed to store items: ", "error", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		s.setCategoriesVersionHeader(ctx, w)
+	}
+	checkpoint(ctx, "db")
+
+	for i, item := range items {
+		resp.Results[indexes[i]].ID = item.ID
+		resp.IDs = append(resp.IDs, item.ID)
+	}
+	slog.Info("items received", "count", len(items), "failed", resp.Failed)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(ctx, "encode")
+}
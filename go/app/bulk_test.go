@@ -0,0 +1,245 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAddItemsBulk(t *testing.T) {
+	t.Parallel()
+
+	type wants struct {
+		code int
+		body string
+	}
+	cases := map[string]struct {
+		body     string
+		injector func(m *MockItemRepository)
+		wants
+	}{
+		"ok: all valid": {
+			body: `[{"name":"jacket","category":"fashion","price":3000},{"name":"iPhone","category":"phone","image_name":"default.jpg"}]`,
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().InsertMany(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, items []*Item) error {
+					for i, item := range items {
+						item.ID = 10 + i
+					}
+					return nil
+				})
+				m.EXPECT().CategoriesVersion(gomock.Any()).Return(int64(1), nil)
+			},
+			wants: wants{
+				code: http.StatusOK,
+				body: `{"ids":[10,11],"results":[{"id":10},{"id":11}],"failed":0}` + "\n",
+			},
+		},
+		"ok: some invalid": {
+			body: `[{"name":"","category":"fashion"},{"name":"jacket","category":"fashion"},{"name":"hat","category":"fashion","price":-1}]`,
+			injector: func(m *MockItemRepository) {
+				// 正しい要素だけが挿入される
+				m.EXPECT().InsertMany(gomock.Any(), gomock.Len(1)).DoAndReturn(func(_ context.Context, items []*Item) error {
+					items[0].ID = 10
+					return nil
+				})
+				m.EXPECT().CategoriesVersion(gomock.Any()).Return(int64(1), nil)
+			},
+			wants: wants{
+				code: http.StatusOK,
+				body: `{"ids":[10],"results":[{"error":"name: required","fields":[{"field":"name","message":"required"}]},{"id":10},{"error":"price: must be a non-negative integer","fields":[{"field":"price","message":"must be a non-negative integer"}]}],"failed":2}` + "\n",
+			},
+		},
+		"ok: all invalid": {
+			body:     `[{"name":"jacket","category":"fashion","image_name":"missing.jpg"},{"name":"hat","category":"fashion","price":"free"}]`,
+			injector: func(m *MockItemRepository) {},
+			wants: wants{
+				code: http.StatusOK,
+				body: `{"ids":[],"results":[{"error":"image_name: image not found","fields":[{"field":"image_name","message":"image not found"}]},{"error":"failed to decode: json: cannot unmarshal string into Go struct field BulkItem.price of type int"}],"failed":2}` + "\n",
+			},
+		},
+		"ok: names rejected like POST /items": {
+			// 1件ずつの追加と同じ検証を通る
+			body:     `[{"name":"` + strings.Repeat("a", maxItemNameChars+1) + `","category":"fashion"},{"name":"jack\u0000et","category":"  "}]`,
+			injector: func(m *MockItemRepository) {},
+			wants: wants{
+				code: http.StatusOK,
+				body: `{"ids":[],"results":[{"error":"name: must be at most 120 characters","fields":[{"field":"name","message":"must be at most 120 characters"}]},` +
+					`{"error":"name: must not contain control characters; category: required","fields":[{"field":"name","message":"must not contain control characters"},{"field":"category","message":"required"}]}],"failed":2}` + "\n",
+			},
+		},
+		"ok: unknown field": {
+			body:     `[{"name":"jacket","category":"fashion","color":"red"}]`,
+			injector: func(m *MockItemRepository) {},
+			wants: wants{
+				code: http.StatusOK,
+				body: `{"ids":[],"results":[{"error":"failed to decode: json: unknown field \"color\""}],"failed":1}` + "\n",
+			},
+		},
+		"ng: empty array": {
+			body:     `[]`,
+			injector: func(m *MockItemRepository) {},
+			wants: wants{
+				code: http.StatusBadRequest,
+				body: "items are required\n",
+			},
+		},
+		"ng: too many items": {
+			body:     `[` + strings.TrimSuffix(strings.Repeat(`{"name":"a","category":"b"},`, 4), ",") + `]`,
+			injector: func(m *MockItemRepository) {},
+			wants: wants{
+				code: http.StatusBadRequest,
+				body: "import exceeds maximum of 3 rows; split it into chunks of at most 3 rows\n",
+			},
+		},
+		"ng: not an array": {
+			body:     `{"name":"jacket","category":"fashion"}`,
+			injector: func(m *MockItemRepository) {},
+			wants: wants{
+				code: http.StatusBadRequest,
+			},
+		},
+		"ng: failed to insert": {
+			body: `[{"name":"jacket","category":"fashion"}]`,
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().InsertMany(gomock.Any(), gomock.Any()).Return(errors.New("failed to insert"))
+			},
+			wants: wants{
+				code: http.StatusInternalServerError,
+				body: "failed to insert\n",
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			tt.injector(mockIR)
+			h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: mockIR, MaxBulkItems: 3}
+
+			req := httptest.NewRequest("POST", "/items/bulk", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			h.AddItemsBulk(rr, req)
+
+			if rr.Code != tt.wants.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.wants.code, rr.Code, rr.Body.String())
+			}
+			if tt.wants.body != "" && rr.Body.String() != tt.wants.body {
+				t.Errorf("expected response body %q, got %q", tt.wants.body, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestInsertManyRollsBackE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+	repo := &itemRepository{db: db}
+
+	// 2件目が不正なので、1件目も含めて何も挿入されない
+	err = repo.InsertMany(t.Context(), []*Item{
+		{Name: "jacket", Category: "fashion", Image: "default.jpg"},
+		{Name: "iPhone", Category: "phone", Image: "default.jpg", Status: "reserved"},
+	})
+	if !errors.Is(err, errInvalidItemStatus) {
+		t.Fatalf("expected %v, got %v", errInvalidItemStatus, err)
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM items`).Scan(&count); err != nil {
+		t.Fatalf("failed to count items: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected no items after rollback, got %d", count)
+	}
+
+	items := make([]*Item, 3)
+	for i := range items {
+		items[i] = &Item{Name: fmt.Sprintf("item %d", i), Category: "fashion", Image: "default.jpg"}
+	}
+	if err := repo.InsertMany(t.Context(), items); err != nil {
+		t.Fatalf("failed to insert items: %v", err)
+	}
+	for i := 1; i < len(items); i++ {
+		if items[i].ID <= items[i-1].ID {
+			t.Errorf("expected increasing ids, got %d after %d", items[i].ID, items[i-1].ID)
+		}
+	}
+}
+
+func TestAddItemsBulkE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+	h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: &itemRepository{db: db}}
+
+	// 不正な要素があっても、正しい要素は挿入される
+	body := `[{"name":"jacket","category":"fashion"},{"name":"","category":"fashion"},{"name":"iPhone","category":" phone "}]`
+	req := httptest.NewRequest("POST", "/items/bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	h.AddItemsBulk(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var resp AddItemsBulkResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Failed != 1 || resp.Results[1].Error == "" {
+		t.Errorf("expected items[1] to fail, got %+v", resp)
+	}
+
+	got := map[int]string{}
+	rows, err := db.Query(`SELECT items.id, categories.name FROM items INNER JOIN categories ON items.category_id = categories.id`)
+	if err != nil {
+		t.Fatalf("failed to query items: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id       int
+			category string
+		)
+		if err := rows.Scan(&id, &category); err != nil {
+			t.Fatalf("failed to scan item: %v", err)
+		}
+		got[id] = category
+	}
+	want := map[int]string{resp.Results[0].ID: "fashion", resp.Results[2].ID: "phone"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// 画像のファイル名から商品を引く (GET /items/by-image?filename=)
+// 保存されている画像のファイル名 (ハッシュ) しか分からないときに使う
+// GET /items/by-image/{filename} にすると GET /items/{item_id}/history などとパターンが衝突するので、クエリパラメータで受け取る
+
+// errInvalidImageName is returned for a filename that cannot be the name of a stored image.
+var errInvalidImageName = errors.New("invalid image filename")
+
+type GetItemByImageRequest struct {
+	// ImageName is the name the image is stored with, e.g. "<sha256>.jpg".
+	ImageName string
+}
+
+// normalizeImageName returns the name an image is stored with from a filename that may have
+// a directory prefix, such as "images/", and a .jpg or .jpeg extension or none.
+// storeImage always saves images as "<sha256>.jpg".
+func normalizeImageName(filename string) (string, error) {
+	name := path.Base(strings.ReplaceAll(strings.TrimSpace(filename), `\`, "/"))
+	for _, ext := range []string{".jpg", ".jpeg"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			name = name[:len(name)-len(ext)]
+			break
+		}
+	}
+	if name == "" || name == "." || name == "/" || name == ".." {
+		return "", errInvalidImageName
+	}
+	return name + ".jpg", nil
+}
+
+func parseGetItemByImageRequest(r *http.Request) (*GetItemByImageRequest, error) {
+	q, err := parseQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	filename, err := queryParam(q, "filename", maxImageNameLen)
+	if err != nil {
+		return nil, err
+	}
+	if filename == "" {
+		return nil, errors.New("filename is required")
+	}
+	name, err := normalizeImageName(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &GetItemByImageRequest{ImageName: name}, nil
+}
+
+// GetItemByImage is a handler to return the item using a stored image for GET /items/by-image?filename= .
+// When several items share the image, the oldest one is returned.
+func (s *Handlers) GetItemByImage(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGetItemByImageRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	item, err := s.itemRepo.GetItemByImage(r.Context(), req.ImageName)
+	if err != nil {
+		if errors.Is(err, errItemNotFound) {
+			slog.Warn("item not exist: ", "error", err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get item by image: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(item); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestNormalizeImageName(t *testing.T) {
+	t.Parallel()
+
+	const hash = "ad55d25f2c10c56522147b214aeed7ad13319808d7ce999787ac8c239b24f71d"
+	cases := map[string]struct {
+		filename string
+		want     string
+		err      error
+	}{
+		"ok: stored name":       {filename: hash + ".jpg", want: hash + ".jpg"},
+		"ok: without extension": {filename: hash, want: hash + ".jpg"},
+		"ok: jpeg extension":    {filename: hash + ".jpeg", want: hash + ".jpg"},
+		"ok: upper extension":   {filename: hash + ".JPG", want: hash + ".jpg"},
+		"ok: directory prefix":  {filename: "images/" + hash + ".jpg", want: hash + ".jpg"},
+		"ok: windows prefix":    {filename: `images\` + hash, want: hash + ".jpg"},
+		"ng: extension only":    {filename: ".jpg", err: errInvalidImageName},
+		"ok: trailing slash":    {filename: "images/", want: "images.jpg"},
+		"ng: parent directory":  {filename: "..", err: errInvalidImageName},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := normalizeImageName(tt.filename)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetItemByImage(t *testing.T) {
+	t.Parallel()
+
+	type wants struct {
+		code int
+		item Item
+	}
+	cases := map[string]struct {
+		target   string
+		injector func(m *MockItemRepository)
+		wants
+	}{
+		"ok: found": {
+			target: "/items/by-image?filename=images/abc.jpeg",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().GetItemByImage(gomock.Any(), "abc.jpg").Return(Item{ID: 1, Name: "jacket", Image: "abc.jpg"}, nil)
+			},
+			wants: wants{code: http.StatusOK, item: Item{ID: 1, Name: "jacket", Image: "abc.jpg"}},
+		},
+		"ng: no item": {
+			target: "/items/by-image?filename=abc",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().GetItemByImage(gomock.Any(), "abc.jpg").Return(Item{}, errItemNotFound)
+			},
+			wants: wants{code: http.StatusNotFound},
+		},
+		"ng: missing filename": {
+			target:   "/items/by-image",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{code: http.StatusBadRequest},
+		},
+		"ng: invalid filename": {
+			target:   "/items/by-image?filename=.jpg",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{code: http.StatusBadRequest},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			tt.injector(mockIR)
+			h := &Handlers{itemRepo: mockIR}
+
+			rr := httptest.NewRecorder()
+			// /items/{item_id} ではなくこのルートに届くことも確かめる
+			newMux(h.routes()).ServeHTTP(rr, httptest.NewRequest("GET", tt.target, nil))
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var got Item
+			if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if got.ID != tt.item.ID || got.Image != tt.item.Image {
+				t.Errorf("expected item %d with image %q, got %d with %q", tt.item.ID, tt.item.Image, got.ID, got.Image)
+			}
+		})
+	}
+}
+
+func TestGetItemByImageE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, item := range []Item{
+		{Name: "jacket", Category: "fashion", Image: "aaa.jpg"},
+		{Name: "coat", Category: "fashion", Image: "bbb.jpg"},
+		{Name: "coat again", Category: "fashion", Image: "bbb.jpg"},
+		{Name: "hat", Category: "fashion", Image: "ccc.jpg"},
+	} {
+		if err := repo.Insert(t.Context(), &item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	if err := repo.SoftDelete(t.Context(), "4"); err != nil {
+		t.Fatalf("failed to delete item: %v", err)
+	}
+
+	cases := map[string]struct {
+		image string
+		want  string
+		err   error
+	}{
+		"ok: found":          {image: "aaa.jpg", want: "jacket"},
+		"ok: oldest of many": {image: "bbb.jpg", want: "coat"},
+		"ng: deleted item":   {image: "ccc.jpg", err: errItemNotFound},
+		"ng: unknown image":  {image: "ddd.jpg", err: errItemNotFound},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			item, err := repo.GetItemByImage(t.Context(), tt.image)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if item.Name != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, item.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+)
+
+// 商品一覧のキャッシュ
+// トップページが GET /items を何度も呼ぶので、直近の結果をしばらく使い回してSQLiteの負荷を減らす
+// 商品を変更したら、すぐにキャッシュを捨てる
+
+// defaultItemCacheTTL is the default of the item_cache_ttl flag.
+const defaultItemCacheTTL = 30 * time.Second
+
+// cachedItemRepository is an ItemRepository that keeps the last result of GetAll and of GetPage for a TTL.
+// Writes clear the cache, except AddViews: view counts in a cached list may be stale for up to the TTL,
+// since the views are flushed too often to keep the cache otherwise.
+// Write methods added to ItemRepository must also be overridden here, otherwise they leave the cache stale.
+type cachedItemRepository struct {
+	ItemRepository
+	// ttl returns the current TTL. 0 or less disables the cache.
+	ttl   func() time.Duration
+	clock Clock
+
+	mu   sync.RWMutex
+	all  *listCacheEntry
+	page *listCacheEntry
+	// generation is incremented by each write, so that a read started before a write does not store its result.
+	generation uint64
+}
+
+// listCacheEntry is a cached list of items and the options it was read with.
+type listCacheEntry struct {
+	opts      ItemListOptions
+	items     []Item
+	total     int
+	fetchedAt time.Time
+}
+
+// newCachedItemRepository wraps repo so that GetAll and GetPage are served from memory for ttl().
+func newCachedItemRepository(repo ItemRepository, ttl func() time.Duration, clock Clock) *cachedItemRepository {
+	return &cachedItemRepository{ItemRepository: repo, ttl: ttl, clock: clock}
+}
+
+// lookup returns the entry if it was read with opts and is younger than the TTL.
+func (c *cachedItemRepository) lookup(entry **listCacheEntry, opts ItemListOptions) (*listCacheEntry, uint64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e := *entry
+	if e == nil || !reflect.DeepEqual(e.opts, opts) || !c.clock.Now().Before(e.fetchedAt.Add(c.ttl())) {
+		return nil, c.generation, false
+	}
+	return e, c.generation, true
+}
+
+// store caches the result unless a write happened since generation was read.
+func (c *cachedItemRepository) store(entry **listCacheEntry, generation uint64, e *listCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+	*entry = e
+}
+
+// invalidate clears the cache after a write.
+func (c *cachedItemRepository) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.all = nil
+	c.page = nil
+	c.generation++
+}
+
+func (c *cachedItemRepository) GetAll(ctx context.Context, opts ItemListOptions) ([]Item, error) {
+	if c.ttl() <= 0 {
+		return c.ItemRepository.GetAll(ctx, opts)
+	}
+	e, generation, ok := c.lookup(&c.all, opts)
+	if ok {
+		// 呼び出し元が書き換えてもキャッシュが壊れないように、コピーを返す
+		return slices.Clone(e.items), nil
+	}
+	now := c.clock.Now()
+	items, err := c.ItemRepository.GetAll(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.store(&c.all, generation, &listCacheEntry{opts: opts, items: slices.Clone(items), fetchedAt: now})
+	return items, nil
+}
+
+func (c *cachedItemRepository) GetPage(ctx context.Context, opts ItemListOptions) ([]Item, int, error) {
+	if c.ttl() <= 0 {
+		return c.ItemRepository.GetPage(ctx, opts)
+	}
+	e, generation, ok := c.lookup(&c.page, opts)
+	if ok {
+		return slices.Clone(e.items), e.total, nil
+	}
+	now := c.clock.Now()
+	items, total, err := c.ItemRepository.GetPage(ctx, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.store(&c.page, generation, &listCacheEntry{opts: opts, items: slices.Clone(items), total: total, fetchedAt: now})
+	return items, total, nil
+}
+
+func (c *cachedItemRepository) Insert(ctx context.Context, item *Item) error {
+	defer c.invalidate()
+	return c.ItemRepository.Insert(ctx, item)
+}
+
+func (c *cachedItemRepository) InsertMany(ctx context.Context, items []*Item) error {
+	defer c.invalidate()
+	return c.ItemRepository.InsertMany(ctx, items)
+}
+
+func (c *cachedItemRepository) SoftDelete(ctx context.Context, item_id string) error {
+	defer c.invalidate()
+	return c.ItemRepository.SoftDelete(ctx, item_id)
+}
+
+func (c *cachedItemRepository) Restore(ctx context.Context, item_id string) error {
+	defer c.invalidate()
+	return c.ItemRepository.Restore(ctx, item_id)
+}
+
+func (c *cachedItemRepository) Publish(ctx context.Context, item_id string) error {
+	defer c.invalidate()
+	return c.ItemRepository.Publish(ctx, item_id)
+}
+
+func (c *cachedItemRepository) Purge(ctx context.Context, item_id string) (string, error) {
+	defer c.invalidate()
+	return c.ItemRepository.Purge(ctx, item_id)
+}
+
+func (c *cachedItemRepository) Purchase(ctx context.Context, item_id string) error {
+	defer c.invalidate()
+	return c.ItemRepository.Purchase(ctx, item_id)
+}
+
+func (c *cachedItemRepository) Update(ctx context.Context, item_id string, patch ItemPatch) (Item, Item, error) {
+	defer c.invalidate()
+	return c.ItemRepository.Update(ctx, item_id, patch)
+}
+
+func (c *cachedItemRepository) AddFavorite(ctx context.Context, item_id string, clientToken string) error {
+	defer c.invalidate()
+	return c.ItemRepository.AddFavorite(ctx, item_id, clientToken)
+}
+
+func (c *cachedItemRepository) RemoveFavorite(ctx context.Context, item_id string, clientToken string) error {
+	defer c.invalidate()
+	return c.ItemRepository.RemoveFavorite(ctx, item_id, clientToken)
+}
+
+func (c *cachedItemRepository) Reorder(ctx context.Context, ids []int) error {
+	defer c.invalidate()
+	return c.ItemRepository.Reorder(ctx, ids)
+}
+
+func (c *cachedItemRepository) Swap(ctx context.Context, a, b int) error {
+	defer c.invalidate()
+	return c.ItemRepository.Swap(ctx, a, b)
+}
+
+func (c *cachedItemRepository) DeleteCategory(ctx context.Context, id int, fallback string) (int, error) {
+	defer c.invalidate()
+	return c.ItemRepository.DeleteCategory(ctx, id, fallback)
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
+
+	"mercari-build-training/app/apptest"
+)
+
+func TestCachedItemRepositoryGetAll(t *testing.T) {
+	t.Parallel()
+
+	const ttl = 30 * time.Second
+	items := []Item{{ID: 1, Name: "jacket"}}
+	onSale := ItemListOptions{Status: itemStatusOnSale}
+
+	cases := map[string]struct {
+		// setup is called between the first and the second GetAll.
+		setup func(t *testing.T, c *cachedItemRepository, mock *MockItemRepository, clock *apptest.FakeClock)
+		opts  ItemListOptions
+		ttl   time.Duration
+		// calls is the number of GetAll calls expected on the underlying repository.
+		calls int
+	}{
+		"hit: within the TTL": {
+			setup: func(t *testing.T, c *cachedItemRepository, mock *MockItemRepository, clock *apptest.FakeClock) {
+				clock.Advance(ttl - time.Second)
+			},
+			ttl:   ttl,
+			calls: 1,
+		},
+		"miss: expired": {
+			setup: func(t *testing.T, c *cachedItemRepository, mock *MockItemRepository, clock *apptest.FakeClock) {
+				clock.Advance(ttl)
+			},
+			ttl:   ttl,
+			calls: 2,
+		},
+		"miss: other options": {
+			setup: func(t *testing.T, c *cachedItemRepository, mock *MockItemRepository, clock *apptest.FakeClock) {},
+			opts:  onSale,
+			ttl:   ttl,
+			calls: 2,
+		},
+		"miss: busted by insert": {
+			setup: func(t *testing.T, c *cachedItemRepository, mock *MockItemRepository, clock *apptest.FakeClock) {
+				mock.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil)
+				if err := c.Insert(t.Context(), &Item{Name: "coat"}); err != nil {
+					t.Fatalf("failed to insert item: %v", err)
+				}
+			},
+			ttl:   ttl,
+			calls: 2,
+		},
+		"miss: busted by update": {
+			setup: func(t *testing.T, c *cachedItemRepository, mock *MockItemRepository, clock *apptest.FakeClock) {
+				mock.EXPECT().Update(gomock.Any(), "1", gomock.Any()).Return(Item{}, Item{}, nil)
+				if _, _, err := c.Update(t.Context(), "1", ItemPatch{}); err != nil {
+					t.Fatalf("failed to update item: %v", err)
+				}
+			},
+			ttl:   ttl,
+			calls: 2,
+		},
+		"miss: busted by failed delete": {
+			// 失敗しても一部が書き込まれているかもしれないので、キャッシュは捨てる
+			setup: func(t *testing.T, c *cachedItemRepository, mock *MockItemRepository, clock *apptest.FakeClock) {
+				mock.EXPECT().SoftDelete(gomock.Any(), "1").Return(errors.New("disk I/O error"))
+				if err := c.SoftDelete(t.Context(), "1"); err == nil {
+					t.Fatal("expected an error")
+				}
+			},
+			ttl:   ttl,
+			calls: 2,
+		},
+		"miss: disabled": {
+			setup: func(t *testing.T, c *cachedItemRepository, mock *MockItemRepository, clock *apptest.FakeClock) {},
+			ttl:   0,
+			calls: 2,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mock := NewMockItemRepository(ctrl)
+			mock.EXPECT().GetAll(gomock.Any(), gomock.Any()).Return(items, nil).Times(tt.calls)
+			clock := apptest.NewFakeClock(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+			c := newCachedItemRepository(mock, func() time.Duration { return tt.ttl }, clock)
+
+			if _, err := c.GetAll(t.Context(), ItemListOptions{}); err != nil {
+				t.Fatalf("failed to get items: %v", err)
+			}
+			tt.setup(t, c, mock, clock)
+			got, err := c.GetAll(t.Context(), tt.opts)
+			if err != nil {
+				t.Fatalf("failed to get items: %v", err)
+			}
+			if diff := cmp.Diff(items, got); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCachedItemRepositoryGetPage(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mock := NewMockItemRepository(ctrl)
+	items := []Item{{ID: 1, Name: "jacket"}}
+	opts := ItemListOptions{Limit: 50}
+	mock.EXPECT().GetPage(gomock.Any(), opts).Return(items, 7, nil).Times(2)
+	mock.EXPECT().Purchase(gomock.Any(), "1").Return(nil)
+	clock := apptest.NewFakeClock(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	c := newCachedItemRepository(mock, func() time.Duration { return time.Minute }, clock)
+
+	for i, step := range []string{"miss", "hit", "purchase", "miss"} {
+		if step == "purchase" {
+			if err := c.Purchase(t.Context(), "1"); err != nil {
+				t.Fatalf("failed to purchase item: %v", err)
+			}
+			continue
+		}
+		got, total, err := c.GetPage(t.Context(), opts)
+		if err != nil {
+			t.Fatalf("step %d: failed to get items: %v", i, err)
+		}
+		if total != 7 || len(got) != 1 {
+			t.Errorf("step %d: expected 1 item of 7, got %d of %d", i, len(got), total)
+		}
+	}
+}
+
+func TestCachedItemRepositoryReturnsCopies(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mock := NewMockItemRepository(ctrl)
+	mock.EXPECT().GetAll(gomock.Any(), gomock.Any()).Return([]Item{{ID: 1, Name: "jacket"}}, nil)
+	clock := apptest.NewFakeClock(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	c := newCachedItemRepository(mock, func() time.Duration { return time.Minute }, clock)
+
+	first, err := c.GetAll(t.Context(), ItemListOptions{})
+	if err != nil {
+		t.Fatalf("failed to get items: %v", err)
+	}
+	first[0].Name = "changed"
+	second, err := c.GetAll(t.Context(), ItemListOptions{})
+	if err != nil {
+		t.Fatalf("failed to get items: %v", err)
+	}
+	if second[0].Name != "jacket" {
+		t.Errorf("expected the cache not to be changed by the caller, got %q", second[0].Name)
+	}
+}
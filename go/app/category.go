@@ -0,0 +1,151 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// カテゴリ一覧はほとんど変わらないので、バージョン番号からETagを作って304を返せるようにする
+
+// categoriesVersionHeader tells the frontend the current categories version on item mutation responses,
+// so that it knows when to refetch GET /categories.
+const categoriesVersionHeader = "X-Categories-Version"
+
+// normalizeCategory returns the stored form of a category name, trimmed and in lower case,
+// so that "Shoes", "shoes" and " shoes " are the same category.
+func normalizeCategory(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+type GetCategoriesResponse struct {
+	Categories []Category `json:"categories"`
+}
+
+// categoriesETag returns the strong ETag for a categories version.
+func categoriesETag(version int64) string {
+	return `"categories-v` + strconv.FormatInt(version, 10) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header value matches etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, v := range strings.Split(ifNoneMatch, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCategories is a handler to return all categories for GET /categories .
+// The response has an ETag derived from the categories version. If If-None-Match matches the current version,
+// it returns 304 without reading the categories.
+func (s *Handlers) GetCategories(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		version, err := s.itemRepo.CategoriesVersion(ctx)
+		if err != nil {
+			slog.Error("failed to get categories version: ", "error", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		if etag := categoriesETag(version); etagMatches(inm, etag) {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	categories, version, err := s.itemRepo.GetCategories(ctx)
+	if err != nil {
+		slog.Error("failed to get categories: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(ctx, "db")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", categoriesETag(version))
+	// キャッシュしてよいが、使う前に必ず再検証させる
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := json.NewEncoder(w).Encode(GetCategoriesResponse{Categories: categories}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(ctx, "encode")
+}
+
+// setCategoriesVersionHeader sets X-Categories-Version on the response of an item mutation.
+// A failure is only logged, since the mutation itself has already succeeded.
+func (s *Handlers) setCategoriesVersionHeader(ctx context.Context, w http.ResponseWriter) {
+	version, err := s.itemRepo.CategoriesVersion(ctx)
+	if err != nil {
+		slog.Warn("failed to get categories version: ", "error", err)
+		return
+	}
+	w.Header().Set(categoriesVersionHeader, strconv.FormatInt(version, 10))
+}
+
+// defaultOrphanCategory receives the items of a deleted category unless ORPHAN_CATEGORY is set.
+const defaultOrphanCategory = "uncategorized"
+
+type DeleteCategoryResponse struct {
+	// MovedItems is the number of items moved to the fallback category.
+	MovedItems       int    `json:"moved_items"`
+	FallbackCategory string `json:"fallback_category"`
+}
+
+// orphanCategory returns the category receiving the items of a deleted category.
+func (s *Handlers) orphanCategory() string {
+	if name := normalizeCategory(s.flags.String(flagOrphanCategory)); name != "" {
+		return name
+	}
+	return defaultOrphanCategory
+}
+
+// DeleteCategory is a handler to delete a category for DELETE /categories/{category_id} .
+// Its items are moved to the orphan_category flag's category, which is created if needed, so that no item is lost.
+func (s *Handlers) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	raw := r.PathValue("category_id")
+	if err := checkParamLen("category_id", raw, maxShortParamLen); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id <= 0 {
+		http.Error(w, "category_id must be a positive integer", http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	fallback := s.orphanCategory()
+	moved, err := s.itemRepo.DeleteCategory(r.Context(), id, fallback)
+	if err != nil {
+		switch {
+		case errors.Is(err, errCategoryNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, errFallbackCategory):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			slog.Error("failed to delete category: ", "error", err)
+			http.Error(w, err.Error(), errorStatus(err))
+		}
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	slog.Info("category deleted", "id", id, "moved_items", moved, "fallback", fallback)
+	s.setCategoriesVersionHeader(r.Context(), w)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(DeleteCategoryResponse{MovedItems: moved, FallbackCategory: fallback}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
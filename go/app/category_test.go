@@ -0,0 +1,360 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalizeCategory(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		name string
+		want string
+	}{
+		"ok: lower case":  {name: "shoes", want: "shoes"},
+		"ok: title case":  {name: "Shoes", want: "shoes"},
+		"ok: whitespace":  {name: " shoes ", want: "shoes"},
+		"ok: both":        {name: "\tSHOES ", want: "shoes"},
+		"ok: inner space": {name: "Home Appliances", want: "home appliances"},
+		"ok: japanese":    {name: " 家電 ", want: "家電"},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := normalizeCategory(tt.name); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInsertNormalizesCategoryE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+	repo := &itemRepository{db: db}
+
+	for _, category := range []string{"Shoes", "shoes", " shoes "} {
+		item := &Item{Name: "sneakers", Category: category, Image: "default.jpg"}
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+		if item.Category != "shoes" {
+			t.Errorf("expected the inserted item to have category %q, got %q", "shoes", item.Category)
+		}
+	}
+	// 更新でも同じカテゴリになる
+	category := "SHOES"
+	if _, _, err := repo.Update(t.Context(), "1", ItemPatch{Category: &category}); err != nil {
+		t.Fatalf("failed to update item: %v", err)
+	}
+
+	var names []string
+	rows, err := db.Query(`SELECT name FROM categories`)
+	if err != nil {
+		t.Fatalf("failed to query categories: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("failed to scan: %v", err)
+		}
+		names = append(names, name)
+	}
+	if diff := cmp.Diff([]string{"shoes"}, names); diff != "" {
+		t.Errorf("unexpected categories (-want +got):\n%s", diff)
+	}
+
+	// UNIQUE制約で、正規化された名前の重複はDBでも拒否される
+	if _, err := db.Exec(`INSERT INTO categories (name) VALUES ('shoes')`); err == nil {
+		t.Error("expected the duplicate category to violate the UNIQUE constraint")
+	}
+	// 正規化していない名前でも、既にあるカテゴリのidを返す
+	tx, err := db.BeginTx(t.Context(), nil)
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(t.Context(), `INSERT INTO categories (name) VALUES ('bags')`); err != nil {
+		t.Fatalf("failed to insert category: %v", err)
+	}
+	id, err := categoryIDTx(t.Context(), tx, " Bags")
+	if err != nil {
+		t.Fatalf("failed to get category id: %v", err)
+	}
+	var want int64
+	if err := tx.QueryRowContext(t.Context(), `SELECT id FROM categories WHERE name = 'bags'`).Scan(&want); err != nil {
+		t.Fatalf("failed to get category: %v", err)
+	}
+	if id != want {
+		t.Errorf("expected category id %d, got %d", want, id)
+	}
+}
+
+func TestGetCategoriesETagE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+	repo := &itemRepository{db: db}
+	h := &Handlers{itemRepo: repo}
+
+	// get sends GET /categories with the given If-None-Match.
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/categories", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		h.GetCategories(rr, req)
+		return rr
+	}
+	insert := func(category string) {
+		if err := repo.Insert(t.Context(), &Item{Name: "item", Category: category, Image: "default.jpg"}); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+
+	rr := get("")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	initial := rr.Header().Get("ETag")
+	if initial == "" {
+		t.Fatal("expected ETag to be set")
+	}
+	if rr := get(initial); rr.Code != http.StatusNotModified {
+		t.Errorf("expected status code %d for matching ETag, got %d", http.StatusNotModified, rr.Code)
+	}
+
+	// 新しいカテゴリができるとバージョンが変わる
+	insert("fashion")
+	rr = get(initial)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d after a new category, got %d", http.StatusOK, rr.Code)
+	}
+	updated := rr.Header().Get("ETag")
+	if updated == initial {
+		t.Errorf("expected ETag to change, got %s", updated)
+	}
+	var resp GetCategoriesResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Categories) != 1 || resp.Categories[0].Name != "fashion" {
+		t.Errorf("expected categories [fashion], got %v", resp.Categories)
+	}
+
+	// 既存のカテゴリに追加してもバージョンは変わらない
+	insert("fashion")
+	if rr := get(updated); rr.Code != http.StatusNotModified {
+		t.Errorf("expected status code %d for an existing category, got %d", http.StatusNotModified, rr.Code)
+	}
+}
+
+func TestCategoriesVersionConsistencyE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+	repo := &itemRepository{db: db}
+
+	// カテゴリが1つ増えるごとにバージョンが1つ増えるので、
+	// 書き込みと並行して読んでもカテゴリの数とバージョンは常に一致する
+	const writers = 20
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range writers {
+			if err := repo.Insert(t.Context(), &Item{Name: "item", Category: fmt.Sprintf("category %d", i), Image: "default.jpg"}); err != nil {
+				t.Errorf("failed to insert item: %v", err)
+				return
+			}
+		}
+	}()
+
+	for {
+		categories, version, err := repo.GetCategories(t.Context())
+		if err != nil {
+			t.Fatalf("failed to get categories: %v", err)
+		}
+		if int64(len(categories)) != version {
+			t.Fatalf("expected version %d for %d categories, got %d", len(categories), len(categories), version)
+		}
+		select {
+		case <-done:
+			if version != writers {
+				t.Errorf("expected final version %d, got %d", writers, version)
+			}
+			return
+		default:
+		}
+	}
+}
+
+func TestDeleteCategoryE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	cases := map[string]struct {
+		// orphanCategory is the value of the orphan_category flag. Empty means the default.
+		orphanCategory string
+		wantFallback   string
+	}{
+		"ok: default fallback":    {wantFallback: defaultOrphanCategory},
+		"ok: configured fallback": {orphanCategory: `"misc"`, wantFallback: "misc"},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, closers, err := setupDB(t)
+			if err != nil {
+				t.Fatalf("failed to set up database: %v", err)
+			}
+			t.Cleanup(func() {
+				for _, c := range closers {
+					c()
+				}
+			})
+
+			repo := &itemRepository{db: db}
+			for _, item := range []*Item{
+				{Name: "jacket", Category: "fashion", Image: "default.jpg"},
+				{Name: "hat", Category: "fashion", Image: "default.jpg"},
+				{Name: "iPhone", Category: "phone", Image: "default.jpg"},
+			} {
+				if err := repo.Insert(t.Context(), item); err != nil {
+					t.Fatalf("failed to insert item: %v", err)
+				}
+			}
+			// 論理削除された商品と、既にカテゴリがなくなっている商品も移される
+			if err := repo.SoftDelete(t.Context(), "2"); err != nil {
+				t.Fatalf("failed to delete item: %v", err)
+			}
+			if _, err := db.Exec(`INSERT INTO items (id, name, category_id, image_name) VALUES (100, 'orphan', 999, 'default.jpg')`); err != nil {
+				t.Fatalf("failed to insert orphaned item: %v", err)
+			}
+
+			flags := NewFlags(flagSpecs)
+			if tt.orphanCategory != "" {
+				if _, _, err := flags.Set(flagOrphanCategory, json.RawMessage(tt.orphanCategory)); err != nil {
+					t.Fatalf("failed to set flag: %v", err)
+				}
+			}
+			h := &Handlers{itemRepo: repo, flags: flags}
+
+			del := func(id string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest("DELETE", "/categories/"+id, nil)
+				req.SetPathValue("category_id", id)
+				rr := httptest.NewRecorder()
+				h.DeleteCategory(rr, req)
+				return rr
+			}
+
+			// fashionのid (1) を削除する
+			rr := del("1")
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			var resp DeleteCategoryResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if diff := cmp.Diff(DeleteCategoryResponse{MovedItems: 3, FallbackCategory: tt.wantFallback}, resp); diff != "" {
+				t.Errorf("unexpected response (-want +got):\n%s", diff)
+			}
+			if rr.Header().Get(categoriesVersionHeader) == "" {
+				t.Errorf("expected %s to be set", categoriesVersionHeader)
+			}
+
+			// 一覧から商品が消えない
+			items, err := repo.GetAll(t.Context(), ItemListOptions{})
+			if err != nil {
+				t.Fatalf("failed to get items: %v", err)
+			}
+			got := map[string]string{}
+			for _, item := range items {
+				got[item.Name] = item.Category
+			}
+			want := map[string]string{"jacket": tt.wantFallback, "iPhone": "phone", "orphan": tt.wantFallback}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected item categories (-want +got):\n%s", diff)
+			}
+			if err := repo.Restore(t.Context(), "2"); err != nil {
+				t.Fatalf("failed to restore item: %v", err)
+			}
+			if item, err := repo.GetItemById(t.Context(), "2"); err != nil || item.Category != tt.wantFallback {
+				t.Errorf("expected the restored item in %s, got %+v (err: %v)", tt.wantFallback, item, err)
+			}
+			issues, err := repo.CheckCategoryHealth(t.Context())
+			if err != nil {
+				t.Fatalf("failed to check category health: %v", err)
+			}
+			for _, issue := range issues {
+				if issue.Kind == healthIssueOrphanedItem {
+					t.Errorf("expected no orphaned items, got %+v", issue)
+				}
+			}
+
+			// 移し先のカテゴリ自体は削除できない
+			categories, _, err := repo.GetCategories(t.Context())
+			if err != nil {
+				t.Fatalf("failed to get categories: %v", err)
+			}
+			for _, c := range categories {
+				if c.Name == "fashion" {
+					t.Errorf("expected fashion to be deleted, got %v", categories)
+				}
+				if c.Name == tt.wantFallback {
+					if rr := del(fmt.Sprint(c.ID)); rr.Code != http.StatusConflict {
+						t.Errorf("expected status code %d for the fallback category, got %d", http.StatusConflict, rr.Code)
+					}
+				}
+			}
+			if rr := del("1"); rr.Code != http.StatusNotFound {
+				t.Errorf("expected status code %d for a deleted category, got %d", http.StatusNotFound, rr.Code)
+			}
+			if rr := del("abc"); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for an invalid id, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
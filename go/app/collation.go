@@ -0,0 +1,74 @@
+package app
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// SQLiteの名前の比較はバイト順なので、カタカナが全てASCIIの後ろに来るなど日本語として不自然に並ぶ
+// 日本語の照合順序 (COLLATE ja) を登録したドライバを用意し、使えない場合はGo側で並べ替える
+
+const (
+	// collationJa is the name of the Japanese collation registered on SQLite connections.
+	collationJa = "ja"
+	// sqliteDriverJa is the sqlite3 driver whose connections have the ja collation.
+	sqliteDriverJa = "sqlite3_ja"
+)
+
+func init() {
+	sql.Register(sqliteDriverJa, &sqlite3.SQLiteDriver{ConnectHook: registerJaCollation})
+}
+
+// registerJaCollation registers the ja collation on a new connection.
+// A collator is not safe for concurrent use, so each connection gets its own.
+func registerJaCollation(conn *sqlite3.SQLiteConn) error {
+	c := collate.New(language.Japanese)
+	return conn.RegisterCollation(collationJa, func(a, b string) int {
+		return c.CompareString(a, b)
+	})
+}
+
+// hasJaCollation reports whether the connections of db have the ja collation, i.e. it was opened with sqliteDriverJa.
+func hasJaCollation(db *sql.DB) (bool, error) {
+	if _, err := db.Exec(`SELECT 'a' < 'b' COLLATE ` + collationJa); err != nil {
+		if strings.Contains(err.Error(), "no such collation sequence") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check the %s collation: %w", collationJa, err)
+	}
+	return true, nil
+}
+
+// sortItemsByNameJa sorts items by name in the Japanese order, and by id for the same name.
+func sortItemsByNameJa(items []Item) {
+	c := collate.New(language.Japanese)
+	slices.SortStableFunc(items, func(a, b Item) int {
+		if n := c.CompareString(a.Name, b.Name); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
+
+// sortItemsByCategoryJa sorts items by category in the Japanese order, keeping the order within a category.
+func sortItemsByCategoryJa(items []Item) {
+	c := collate.New(language.Japanese)
+	slices.SortStableFunc(items, func(a, b Item) int {
+		return c.CompareString(a.Category, b.Category)
+	})
+}
+
+// sortCategoriesByNameJa sorts categories by name in the Japanese order.
+func sortCategoriesByNameJa(categories []Category) {
+	c := collate.New(language.Japanese)
+	slices.SortStableFunc(categories, func(a, b Category) int {
+		return c.CompareString(a.Name, b.Name)
+	})
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// jaNames mixes hiragana, katakana, kanji and ASCII, whose byte order is far from the Japanese order.
+var jaNames = []string{"りんご", "カメラ", "apple", "漢字", "あめ", "Banana", "ばなな", "バナナ", "アイス", "机", "123", "ごま"}
+
+// openCollationDB opens a database with the schema on the driver.
+func openCollationDB(t *testing.T, driver string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open(driver, filepath.Join(t.TempDir(), "collation.sqlite3"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	schema, err := os.ReadFile("../db/items.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if err := initSchema(db, string(schema), time.Now()); err != nil {
+		t.Fatalf("failed to set up schema: %v", err)
+	}
+	return db
+}
+
+func TestSortByNameJaE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	// 期待値はcollatorでそのまま並べたもの
+	want := slices.Clone(jaNames)
+	collate.New(language.Japanese).SortStrings(want)
+	if slices.IsSorted(want) {
+		t.Fatalf("expected the Japanese order to differ from the byte order: %v", want)
+	}
+
+	cases := map[string]struct {
+		driver    string
+		collation bool
+	}{
+		"sql: COLLATE ja":      {driver: sqliteDriverJa, collation: true},
+		"fallback: sort in Go": {driver: "sqlite3", collation: false},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			db := openCollationDB(t, tt.driver)
+			collation, err := hasJaCollation(db)
+			if err != nil {
+				t.Fatalf("failed to check collation: %v", err)
+			}
+			if collation != tt.collation {
+				t.Fatalf("expected collation %v, got %v", tt.collation, collation)
+			}
+			repo := &itemRepository{db: db, collation: collation}
+			for _, name := range jaNames {
+				// カテゴリにも同じ名前を使う
+				if err := repo.Insert(t.Context(), &Item{Name: name, Category: name, Image: "default.jpg"}); err != nil {
+					t.Fatalf("failed to insert item: %v", err)
+				}
+			}
+
+			items, err := repo.GetAll(t.Context(), ItemListOptions{Sort: sortByNameJa})
+			if err != nil {
+				t.Fatalf("failed to get items: %v", err)
+			}
+			var got []string
+			for _, item := range items {
+				got = append(got, item.Name)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected item order (-want +got):\n%s", diff)
+			}
+
+			// ページで切り出しても同じ順番
+			items, err = repo.GetAll(t.Context(), ItemListOptions{Sort: sortByNameJa, Limit: 3, Offset: 2})
+			if err != nil {
+				t.Fatalf("failed to get items: %v", err)
+			}
+			got = nil
+			for _, item := range items {
+				got = append(got, item.Name)
+			}
+			if diff := cmp.Diff(want[2:5], got); diff != "" {
+				t.Errorf("unexpected page (-want +got):\n%s", diff)
+			}
+
+			categories, _, err := repo.GetCategories(t.Context())
+			if err != nil {
+				t.Fatalf("failed to get categories: %v", err)
+			}
+			got = nil
+			for _, c := range categories {
+				got = append(got, c.Name)
+			}
+			// カテゴリ名は小文字に正規化される
+			wantCategories := make([]string, len(jaNames))
+			for i, name := range jaNames {
+				wantCategories[i] = normalizeCategory(name)
+			}
+			collate.New(language.Japanese).SortStrings(wantCategories)
+			if diff := cmp.Diff(wantCategories, got); diff != "" {
+				t.Errorf("unexpected category order (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSampleByCategoryJaE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	// カテゴリ名は小文字に正規化される
+	want := make([]string, len(jaNames))
+	for i, name := range jaNames {
+		want[i] = normalizeCategory(name)
+	}
+	collate.New(language.Japanese).SortStrings(want)
+
+	cases := map[string]struct {
+		driver    string
+		collation bool
+	}{
+		"sql: COLLATE ja":      {driver: sqliteDriverJa, collation: true},
+		"fallback: sort in Go": {driver: "sqlite3", collation: false},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			db := openCollationDB(t, tt.driver)
+			collation, err := hasJaCollation(db)
+			if err != nil {
+				t.Fatalf("failed to check collation: %v", err)
+			}
+			if collation != tt.collation {
+				t.Fatalf("expected collation %v, got %v", tt.collation, collation)
+			}
+			repo := &itemRepository{db: db, collation: collation}
+			// カテゴリごとに2件ずつ
+			for _, name := range jaNames {
+				for range 2 {
+					if err := repo.Insert(t.Context(), &Item{Name: name, Category: name, Image: "default.jpg"}); err != nil {
+						t.Fatalf("failed to insert item: %v", err)
+					}
+				}
+			}
+			h := &Handlers{itemRepo: repo}
+
+			rr := httptest.NewRecorder()
+			h.SampleItems(rr, httptest.NewRequest("GET", "/items/sample?per_category=2", nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			var resp SampleItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var got []string
+			for _, c := range resp.Categories {
+				got = append(got, c.Category)
+				if len(c.Items) != 2 {
+					t.Errorf("expected 2 items of %s, got %d", c.Category, len(c.Items))
+				}
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected category order (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
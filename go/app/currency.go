@@ -0,0 +1,36 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// 価格の通貨
+// 金額は通貨の最小単位の整数で保存する (円なら円、ドルならセント)
+// 通貨を指定しなければ円 (通貨の導入前のクライアントのため)
+
+// errInvalidCurrency is returned for a currency code not in currencies.
+var errInvalidCurrency = errors.New("invalid currency")
+
+// defaultCurrency is the currency of items added without one.
+const defaultCurrency = "JPY"
+
+// currencies are the ISO 4217 codes accepted for a price.
+var currencies = []string{"JPY", "USD", "EUR"}
+
+// Price is an amount of money in the minor unit of the currency, e.g. 1500 JPY or 1500 USD cents.
+type Price struct {
+	Amount   int    `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// parseCurrency returns the currency code in upper case, or errInvalidCurrency unless it is one of currencies.
+func parseCurrency(v string) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(v))
+	if slices.Contains(currencies, code) {
+		return code, nil
+	}
+	return "", fmt.Errorf("%w: %q (must be one of %s)", errInvalidCurrency, v, strings.Join(currencies, ", "))
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseCurrency(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		currency string
+		want     string
+		err      error
+	}{
+		"ok: JPY":        {currency: "JPY", want: "JPY"},
+		"ok: lower case": {currency: "usd", want: "USD"},
+		"ok: trimmed":    {currency: " EUR ", want: "EUR"},
+		"ng: unknown":    {currency: "BTC", err: errInvalidCurrency},
+		"ng: empty":      {currency: "", err: errInvalidCurrency},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseCurrency(tt.currency)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCurrencyE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: repo}
+
+	for _, body := range []string{
+		`{"name":"jacket","category":"fashion","image_name":"default.jpg","price":1500}`,
+		`{"name":"jeans","category":"fashion","image_name":"default.jpg","price":1500,"currency":"USD"}`,
+	} {
+		req := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.AddItem(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+	}
+
+	// 価格は金額と通貨のオブジェクトで返す
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/items/2", nil)
+	req.SetPathValue("item_id", "2")
+	h.GetItemById(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(rr.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got, want := string(raw["price"]), `{"amount":1500,"currency":"USD"}`; got != want {
+		t.Errorf("expected price %s, got %s", want, got)
+	}
+
+	cases := map[string]struct {
+		target string
+		code   int
+		names  []string
+	}{
+		"ok: JPY":              {target: "/items?currency=JPY", code: http.StatusOK, names: []string{"jacket"}},
+		"ok: lower case":       {target: "/items?currency=usd", code: http.StatusOK, names: []string{"jeans"}},
+		"ok: no items":         {target: "/items?currency=EUR", code: http.StatusOK, names: []string{}},
+		"ng: unknown currency": {target: "/items?currency=BTC", code: http.StatusBadRequest},
+		"ng: empty currency":   {target: "/items?currency=", code: http.StatusBadRequest},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.GetItems(rr, httptest.NewRequest("GET", tt.target, nil))
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Items []Item `json:"items"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			names := []string{}
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
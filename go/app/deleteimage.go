@@ -0,0 +1,98 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+)
+
+// 画像ファイルの削除 (DELETE /images/{filename})
+// どの商品も使っていない画像だけを消す。論理削除された商品も復元されれば画像を使うので、参照として数える
+// 数えてから消すまでの間に同じ画像が出品されると画像が消えてしまうが、GET /images はデフォルト画像を返すので壊れはしない
+
+var (
+	// errImageInUse is returned when an item still references the image. It is 409.
+	errImageInUse = errors.New("image is used by an item")
+	// errDefaultImage is returned for default.jpg, which is the fallback of every missing image. It is 409.
+	errDefaultImage = errors.New("the default image cannot be deleted")
+)
+
+type DeleteImageRequest struct {
+	FileName string // path value
+}
+
+func parseDeleteImageRequest(r *http.Request) (*DeleteImageRequest, error) {
+	req := &DeleteImageRequest{
+		FileName: r.PathValue("filename"),
+	}
+	if req.FileName == "" {
+		return nil, errors.New("filename is required")
+	}
+	if err := checkParamLen("filename", req.FileName, maxImageNameLen); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// deleteImage deletes the stored image and its cached variants if no item references it.
+// It returns errImageInUse or errDefaultImage when the image must be kept.
+func (s *Handlers) deleteImage(ctx context.Context, name string) error {
+	if name == defaultImageName {
+		return errDefaultImage
+	}
+	refs, err := s.itemRepo.CountItemsUsingImage(ctx, name)
+	if err != nil {
+		return err
+	}
+	checkpoint(ctx, "db")
+	if refs > 0 {
+		return fmt.Errorf("%w: %d items reference %s", errImageInUse, refs, name)
+	}
+	return s.removeImage(name)
+}
+
+// DeleteImage is a handler to delete an image for DELETE /images/{filename} .
+// The image is deleted with its scaled versions only if no item, including soft-deleted ones, references it.
+// It returns 409 for an image in use and for default.jpg, and 404 for a missing image.
+func (s *Handlers) DeleteImage(w http.ResponseWriter, r *http.Request) {
+	req, err := parseDeleteImageRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	imgPath, err := s.buildImagePath(req.FileName)
+	if err != nil {
+		if errors.Is(err, errImageNotFound) {
+			slog.Warn("image not exist: ", "filename", req.FileName)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.Warn("failed to build image path: ", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 縮小版などサブディレクトリのファイルは、元の画像と一緒にしか消さない
+	if filepath.Dir(imgPath) != filepath.Clean(s.imgDirPath) {
+		http.Error(w, "invalid image path: "+req.FileName, http.StatusBadRequest)
+		return
+	}
+	name := filepath.Base(imgPath)
+	checkpoint(r.Context(), "parse")
+
+	if err := s.deleteImage(r.Context(), name); err != nil {
+		if errors.Is(err, errImageInUse) || errors.Is(err, errDefaultImage) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		slog.Error("failed to delete image: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	slog.Info("image deleted", "filename", name)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,179 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestDeleteImage(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		filename string
+		// refs is the number of items using the image, or -1 when the repository must not be asked.
+		refs     int
+		countErr error
+		wantCode int
+		// kept reports whether the image and its variant are left in place.
+		kept bool
+	}{
+		"ok: orphaned": {
+			filename: "orphan.jpg",
+			refs:     0,
+			wantCode: http.StatusNoContent,
+			kept:     false,
+		},
+		"ng: in use": {
+			filename: "orphan.jpg",
+			refs:     2,
+			wantCode: http.StatusConflict,
+			kept:     true,
+		},
+		"ng: default image": {
+			filename: defaultImageName,
+			refs:     -1,
+			wantCode: http.StatusConflict,
+			kept:     true,
+		},
+		"ng: missing image": {
+			filename: "missing.jpg",
+			refs:     -1,
+			wantCode: http.StatusNotFound,
+			kept:     true,
+		},
+		"ng: directory traversal": {
+			filename: "../orphan.jpg",
+			refs:     -1,
+			wantCode: http.StatusBadRequest,
+			kept:     true,
+		},
+		"ng: variant": {
+			filename: filepath.Join(variantDirName, "orphan_w100.jpg"),
+			refs:     -1,
+			wantCode: http.StatusBadRequest,
+			kept:     true,
+		},
+		"ng: not an image": {
+			filename: "orphan.txt",
+			refs:     -1,
+			wantCode: http.StatusBadRequest,
+			kept:     true,
+		},
+		"ng: count fails": {
+			filename: "orphan.jpg",
+			refs:     0,
+			countErr: errors.New("db error"),
+			wantCode: http.StatusInternalServerError,
+			kept:     true,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := setupImageDir(t)
+			files := []string{"orphan.jpg", filepath.Join(variantDirName, "orphan_w100.jpg")}
+			for _, f := range files {
+				if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, f)), 0755); err != nil {
+					t.Fatalf("failed to create directory: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, f), []byte("image"), 0644); err != nil {
+					t.Fatalf("failed to write image: %v", err)
+				}
+			}
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			if tt.refs >= 0 {
+				mockIR.EXPECT().CountItemsUsingImage(gomock.Any(), filepath.Base(tt.filename)).Return(tt.refs, tt.countErr)
+			}
+			h := &Handlers{imgDirPath: dir, itemRepo: mockIR}
+
+			req := httptest.NewRequest("DELETE", "/images/x", nil)
+			req.SetPathValue("filename", tt.filename)
+			rr := httptest.NewRecorder()
+			h.DeleteImage(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status code %d, got %d: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+			for _, f := range append(files, defaultImageName) {
+				_, err := os.Stat(filepath.Join(dir, f))
+				want := tt.kept || f == defaultImageName
+				if got := err == nil; got != want {
+					t.Errorf("expected %s to exist %v, got %v", f, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteImageE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	dir := setupImageDir(t)
+	for _, name := range []string{"used.jpg", "deleted.jpg", "orphan.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("image"), 0644); err != nil {
+			t.Fatalf("failed to write image: %v", err)
+		}
+	}
+
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "jacket", Category: "fashion", Image: "used.jpg"},
+		{Name: "coat", Category: "fashion", Image: "deleted.jpg"},
+		{Name: "pen", Category: "stationery", Image: defaultImageName},
+	} {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	// 論理削除された商品も画像を使っている
+	if err := repo.SoftDelete(t.Context(), "2"); err != nil {
+		t.Fatalf("failed to delete item: %v", err)
+	}
+	h := &Handlers{imgDirPath: dir, itemRepo: repo}
+	mux := newMux(h.routes())
+
+	steps := []struct {
+		filename string
+		code     int
+		exists   bool
+	}{
+		{filename: "used.jpg", code: http.StatusConflict, exists: true},
+		{filename: "deleted.jpg", code: http.StatusConflict, exists: true},
+		{filename: defaultImageName, code: http.StatusConflict, exists: true},
+		{filename: "orphan.jpg", code: http.StatusNoContent, exists: false},
+		{filename: "orphan.jpg", code: http.StatusNotFound, exists: false},
+	}
+	for _, step := range steps {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("DELETE", "/images/"+step.filename, nil))
+		if rr.Code != step.code {
+			t.Fatalf("delete %s: expected status code %d, got %d: %s", step.filename, step.code, rr.Code, rr.Body.String())
+		}
+		_, err := os.Stat(filepath.Join(dir, step.filename))
+		if got := err == nil; got != step.exists {
+			t.Errorf("delete %s: expected the file to exist %v, got %v", step.filename, step.exists, got)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// 下書きの公開 (POST /items/{item_id}/publish)
+// published=false で出品した商品は、公開するまで一覧と検索に出ない (GET /items/{item_id} では出品者が確認できる)
+
+// PublishItem is a handler to publish a draft item for POST /items/{item_id}/publish .
+// It responds with the item, and does nothing to an item already published.
+func (s *Handlers) PublishItem(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGetItemByIdRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	actor, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	if err := s.itemRepo.Publish(withActor(r.Context(), actor), req.Id); err != nil {
+		if errors.Is(err, errItemNotFound) {
+			slog.Warn("item not exist: ", "error", err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to publish item: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	item, err := s.itemRepo.GetItemById(r.Context(), req.Id)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(item); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
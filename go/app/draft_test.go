@@ -0,0 +1,170 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDraftItemsE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: repo}
+
+	addCases := map[string]struct {
+		body string
+		code int
+	}{
+		"ok: published by default": {body: `{"name":"jacket","category":"fashion","image_name":"default.jpg","price":3000}`, code: http.StatusCreated},
+		"ng: invalid published":    {body: `{"name":"coat","category":"fashion","image_name":"default.jpg","price":3000,"published":"maybe"}`, code: http.StatusBadRequest},
+	}
+	for name, tt := range addCases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/items", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			h.AddItem(rr, req)
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+		})
+	}
+	// フォームでも published=false で下書きになる
+	form := "name=jacket+draft&category=fashion&price=5000&published=false"
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	h.AddItem(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	// 下書きでもidを指定すれば取得できる
+	draft, err := repo.GetItemById(t.Context(), "2")
+	if err != nil {
+		t.Fatalf("failed to get draft: %v", err)
+	}
+	if !draft.Draft {
+		t.Errorf("expected item 2 to be a draft")
+	}
+
+	listNames := func(t *testing.T, target string) []string {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", target, nil)
+		if strings.HasPrefix(target, "/search") {
+			h.SearchItemsByKeyword(rr, req)
+		} else {
+			h.GetItems(rr, req)
+		}
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp struct {
+			Items []Item `json:"items"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		names := []string{}
+		for _, item := range resp.Items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+
+	listCases := map[string]struct {
+		target string
+		names  []string
+	}{
+		"list without drafts":   {target: "/items", names: []string{"jacket"}},
+		"list with drafts":      {target: "/items?include_drafts=true", names: []string{"jacket", "jacket draft"}},
+		"search without drafts": {target: "/search?keyword=jacket", names: []string{"jacket"}},
+		"search with drafts":    {target: "/search?keyword=jacket&include_drafts=true", names: []string{"jacket", "jacket draft"}},
+	}
+	for name, tt := range listCases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.names, listNames(t, tt.target)); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+	for _, target := range []string{"/items?include_drafts=maybe", "/search?keyword=jacket&include_drafts=maybe"} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", target, nil)
+		if strings.HasPrefix(target, "/search") {
+			h.SearchItemsByKeyword(rr, req)
+		} else {
+			h.GetItems(rr, req)
+		}
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", target, http.StatusBadRequest, rr.Code)
+		}
+	}
+
+	publishCases := []struct {
+		name string
+		id   string
+		code int
+	}{
+		{name: "publish draft", id: "2", code: http.StatusOK},
+		{name: "already published", id: "2", code: http.StatusOK},
+		{name: "unknown item", id: "99", code: http.StatusNotFound},
+	}
+	for _, step := range publishCases {
+		req := httptest.NewRequest("POST", "/items/"+step.id+"/publish", nil)
+		req.SetPathValue("item_id", step.id)
+		req.Header.Set(actorHeader, "alice")
+		rr := httptest.NewRecorder()
+		h.PublishItem(rr, req)
+		if rr.Code != step.code {
+			t.Fatalf("%s: expected status code %d, got %d: %s", step.name, step.code, rr.Code, rr.Body.String())
+		}
+		if step.code != http.StatusOK {
+			continue
+		}
+		var got Item
+		if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", step.name, err)
+		}
+		if got.Draft {
+			t.Errorf("%s: expected the item to be published", step.name)
+		}
+	}
+
+	if diff := cmp.Diff([]string{"jacket", "jacket draft"}, listNames(t, "/items")); diff != "" {
+		t.Errorf("unexpected items after publishing (-want +got):\n%s", diff)
+	}
+
+	// 公開は一度だけ履歴に残る
+	events, err := repo.GetItemHistory(t.Context(), "2")
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	var published []string
+	for _, e := range events {
+		if e.Field == itemEventPublished {
+			published = append(published, e.Actor)
+		}
+	}
+	if diff := cmp.Diff([]string{"alice"}, published); diff != "" {
+		t.Errorf("unexpected publish events (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 同じ商品の二重登録を防ぐ (フォームを2回送信してしまった場合など)
+// 名前・カテゴリ・画像 (画像のファイル名はハッシュ値) が同じ商品が直近に登録されていたら、登録しない
+// リクエストで ?dedupe=true を指定したら、いつ登録されたかによらず、同じ商品があれば登録しない
+
+var errDuplicateItem = errors.New("duplicate item")
+
+// DuplicateItemError is returned by Insert when the same item was added within the duplicate window,
+// or at any time when the context is from withDedupe.
+// It matches errDuplicateItem with errors.Is.
+type DuplicateItemError struct {
+	// ExistingID is the id of the item added before.
+	ExistingID int
+}
+
+func (e *DuplicateItemError) Error() string {
+	return fmt.Sprintf("%s: the same item was added as %d", errDuplicateItem, e.ExistingID)
+}
+
+func (e *DuplicateItemError) Unwrap() error {
+	return errDuplicateItem
+}
+
+// dedupeKey is the context key set by withDedupe.
+type dedupeKey struct{}
+
+// withDedupe returns a context that makes Insert reject an item identical to any existing one,
+// regardless of the duplicate window.
+func withDedupe(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dedupeKey{}, true)
+}
+
+// duplicateSince returns the creation time from which an identical item is a duplicate,
+// and false if Insert should not check for duplicates.
+func duplicateSince(ctx context.Context, now time.Time, window time.Duration) (time.Time, bool) {
+	if dedupe, _ := ctx.Value(dedupeKey{}).(bool); dedupe {
+		return time.Time{}, true
+	}
+	if window > 0 {
+		return now.Add(-window), true
+	}
+	return time.Time{}, false
+}
+
+// DuplicateItemResponse is the body of the 409 of POST /items for a duplicate.
+type DuplicateItemResponse struct {
+	Error      string `json:"error"`
+	ExistingID int    `json:"existing_id"`
+}
+
+// findDuplicateTx returns a *DuplicateItemError if an item with the same name, category and image
+// was created at or after since, and nil otherwise. Soft-deleted items are not duplicates.
+func findDuplicateTx(ctx context.Context, tx *sql.Tx, item *Item, since time.Time) error {
+	var id int
+	err := tx.QueryRowContext(ctx, `
+		SELECT items.id FROM items
+		INNER JOIN categories ON items.category_id = categories.id
+		WHERE items.name = ? AND categories.name = ? AND items.image_name = ?
+			AND items.created_at >= ? AND items.deleted_at IS NULL
+		ORDER BY items.id DESC
+		LIMIT 1`,
+		item.Name, normalizeCategory(item.Category), item.Image, formatTimestamp(since)).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	return &DuplicateItemError{ExistingID: id}
+}
@@ -0,0 +1,195 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
+
+	"mercari-build-training/app/apptest"
+)
+
+func TestInsertDuplicateE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	const window = 10 * time.Minute
+	created := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		window  time.Duration
+		elapsed time.Duration
+		item    Item
+		wantDup bool
+	}{
+		"dup: just inside the window": {
+			window:  window,
+			elapsed: window - time.Second,
+			item:    Item{Name: "jacket", Category: "fashion", Image: "abc.jpg"},
+			wantDup: true,
+		},
+		"dup: at the edge of the window": {
+			window:  window,
+			elapsed: window,
+			item:    Item{Name: "jacket", Category: "fashion", Image: "abc.jpg"},
+			wantDup: true,
+		},
+		"ok: just outside the window": {
+			window:  window,
+			elapsed: window + time.Second,
+			item:    Item{Name: "jacket", Category: "fashion", Image: "abc.jpg"},
+		},
+		"ok: another image": {
+			window:  window,
+			elapsed: time.Second,
+			item:    Item{Name: "jacket", Category: "fashion", Image: "def.jpg"},
+		},
+		"ok: another category": {
+			window:  window,
+			elapsed: time.Second,
+			item:    Item{Name: "jacket", Category: "outer", Image: "abc.jpg"},
+		},
+		"ok: check disabled": {
+			window:  0,
+			elapsed: time.Second,
+			item:    Item{Name: "jacket", Category: "fashion", Image: "abc.jpg"},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, closers, err := setupDB(t)
+			if err != nil {
+				t.Fatalf("failed to set up database: %v", err)
+			}
+			t.Cleanup(func() {
+				for _, c := range closers {
+					c()
+				}
+			})
+
+			clock := apptest.NewFakeClock(created)
+			repo := &itemRepository{db: db, clock: clock, duplicateWindow: tt.window}
+			if err := repo.Insert(t.Context(), &Item{Name: "jacket", Category: "fashion", Image: "abc.jpg"}); err != nil {
+				t.Fatalf("failed to insert item: %v", err)
+			}
+
+			clock.Advance(tt.elapsed)
+			item := tt.item
+			err = repo.Insert(t.Context(), &item)
+			if !tt.wantDup {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			var dupErr *DuplicateItemError
+			if !errors.As(err, &dupErr) || !errors.Is(err, errDuplicateItem) {
+				t.Fatalf("expected a duplicate error, got %v", err)
+			}
+			if dupErr.ExistingID != 1 {
+				t.Errorf("expected existing id 1, got %d", dupErr.ExistingID)
+			}
+			if n, err := repo.Count(t.Context(), ItemListOptions{}); err != nil || n != 1 {
+				t.Errorf("expected the duplicate not to be inserted, got %d items (err %v)", n, err)
+			}
+		})
+	}
+}
+
+func TestAddItemDuplicate(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockIR := NewMockItemRepository(ctrl)
+	mockIR.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(&DuplicateItemError{ExistingID: 7})
+	h := &Handlers{imgDirPath: "../images", itemRepo: mockIR}
+
+	values := url.Values{"name": {"jacket"}, "category": {"fashion"}, "price": {"3000"}}
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	h.AddItem(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+	var got DuplicateItemResponse
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := DuplicateItemResponse{Error: "duplicate item: the same item was added as 7", ExistingID: 7}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected response (-want +got):\n%s", diff)
+	}
+}
+
+func TestAddItemDedupeE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	// 時間の窓は使わないので、ずっと前に登録した商品も重複になる
+	clock := apptest.NewFakeClock(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	repo := &itemRepository{db: db, clock: clock}
+	h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: repo}
+
+	const jacket = `{"name":"jacket","category":"fashion","image_name":"default.jpg","price":3000}`
+	steps := []struct {
+		name       string
+		target     string
+		body       string
+		code       int
+		existingID int
+	}{
+		{name: "first", target: "/items", body: jacket, code: http.StatusCreated},
+		{name: "dedupe disabled", target: "/items", body: jacket, code: http.StatusCreated},
+		{name: "dedupe=false", target: "/items?dedupe=false", body: jacket, code: http.StatusCreated},
+		{name: "duplicate detected", target: "/items?dedupe=true", body: jacket, code: http.StatusConflict, existingID: 3},
+		{name: "another item", target: "/items?dedupe=true", body: `{"name":"coat","category":"fashion","image_name":"default.jpg","price":3000}`, code: http.StatusCreated},
+		{name: "invalid dedupe", target: "/items?dedupe=maybe", body: jacket, code: http.StatusUnprocessableEntity},
+	}
+
+	for _, step := range steps {
+		clock.Advance(24 * time.Hour)
+		req := httptest.NewRequest("POST", step.target, strings.NewReader(step.body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.AddItem(rr, req)
+		if rr.Code != step.code {
+			t.Fatalf("%s: expected status code %d, got %d: %s", step.name, step.code, rr.Code, rr.Body.String())
+		}
+		if step.code != http.StatusConflict {
+			continue
+		}
+		var got DuplicateItemResponse
+		if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", step.name, err)
+		}
+		if got.ExistingID != step.existingID {
+			t.Errorf("%s: expected existing id %d, got %d", step.name, step.existingID, got.ExistingID)
+		}
+	}
+
+	if n, err := repo.Count(t.Context(), ItemListOptions{}); err != nil || n != 4 {
+		t.Errorf("expected 4 items, got %d (err %v)", n, err)
+	}
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"encoding/csv"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// exportPageRows is how many rows GET /items/export reads per query, and writes between flushes to the client.
+const exportPageRows = 100
+
+// exportHeader is the header row of GET /items/export.
+var exportHeader = []string{"id", "name", "category", "image_name"}
+
+// countingWriter counts the bytes written through it, to know whether the response has started.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ExportItems is a handler to download all items as CSV for GET /items/export .
+// Rows are read by short queries of a page each, so the table is never loaded into memory at once
+// and no database connection is held while a slow client receives the rows.
+func (s *Handlers) ExportItems(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=items.csv")
+
+	cw := &countingWriter{w: w}
+	csvw := csv.NewWriter(cw)
+	flusher, _ := w.(http.Flusher)
+	flush := func() error {
+		csvw.Flush()
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return csvw.Error()
+	}
+
+	rows := 0
+	err := csvw.Write(exportHeader)
+	// 接続は1つしかないことがあるので、カーソルを開いたままクライアントに書かない
+	// idで区切ったページを読み終えてから、少しずつ送る
+	for afterID := 0; err == nil; {
+		var page []Item
+		page, err = s.itemRepo.GetItemsAfter(ctx, afterID, exportPageRows)
+		if err != nil {
+			break
+		}
+		for _, item := range page {
+			if err = csvw.Write([]string{strconv.Itoa(item.ID), item.Name, item.Category, item.Image}); err != nil {
+				break
+			}
+			rows++
+		}
+		if err == nil {
+			err = flush()
+		}
+		if len(page) < exportPageRows {
+			break
+		}
+		afterID = page[len(page)-1].ID
+	}
+	checkpoint(ctx, "db")
+
+	if err != nil {
+		if cw.n == 0 {
+			// まだ何も送っていなければ、普通にエラーを返せる
+			slog.Error("failed to export items: ", "error", err)
+			w.Header().Del("Content-Disposition")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// 途中まで送ってしまったので、レスポンスを打ち切って不完全なことを伝える
+		slog.Error("export stream aborted: ", "error", err, "request_id", requestIDFrom(ctx), "written", rows)
+		panic(http.ErrAbortHandler)
+	}
+	slog.Info("items exported", "count", rows)
+}
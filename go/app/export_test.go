@@ -0,0 +1,212 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
+)
+
+func TestExportItemsE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	items := []*Item{
+		{Name: "jacket", Category: "fashion", Image: "default.jpg"},
+		// カンマ・引用符・改行はCSVとしてエスケープされる
+		{Name: `denim "vintage", blue`, Category: "fashion", Image: "default.jpg"},
+		{Name: "line\nbreak", Category: "雑貨", Image: "default.jpg"},
+		{Name: "deleted", Category: "fashion", Image: "default.jpg"},
+	}
+	// 1ページに収まらない件数にする
+	for i := 0; i < exportPageRows; i++ {
+		items = append(items, &Item{Name: "item " + strconv.Itoa(i), Category: "bulk", Image: "default.jpg"})
+	}
+	if err := repo.InsertMany(t.Context(), items); err != nil {
+		t.Fatalf("failed to insert items: %v", err)
+	}
+	if err := repo.SoftDelete(t.Context(), strconv.Itoa(items[3].ID)); err != nil {
+		t.Fatalf("failed to delete item: %v", err)
+	}
+
+	h := &Handlers{itemRepo: repo}
+	rr := httptest.NewRecorder()
+	h.ExportItems(rr, httptest.NewRequest("GET", "/items/export", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "text/csv" {
+		t.Errorf("expected Content-Type text/csv, got %q", got)
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != "attachment; filename=items.csv" {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+
+	got, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	want := [][]string{{"id", "name", "category", "image_name"}}
+	for i, item := range items {
+		if i == 3 {
+			continue
+		}
+		want = append(want, []string{strconv.Itoa(item.ID), item.Name, item.Category, item.Image})
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected CSV (-want +got):\n%s", diff)
+	}
+}
+
+func TestExportItemsError(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		rows  int
+		abort bool
+	}{
+		"ng: failure before anything is sent": {
+			rows:  0,
+			abort: false,
+		},
+		"ng: failure after rows are sent": {
+			rows:  exportPageRows,
+			abort: true,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			if tt.rows > 0 {
+				page := make([]Item, tt.rows)
+				for i := range page {
+					page[i] = Item{ID: i + 1, Name: "jacket", Category: "fashion", Image: "default.jpg"}
+				}
+				mockIR.EXPECT().GetItemsAfter(gomock.Any(), 0, exportPageRows).Return(page, nil)
+			}
+			mockIR.EXPECT().GetItemsAfter(gomock.Any(), tt.rows, exportPageRows).Return(nil, errors.New("database is gone"))
+			h := &Handlers{itemRepo: mockIR}
+			rr := httptest.NewRecorder()
+
+			// 送信を始めた後の失敗は、レスポンスを打ち切って伝える
+			aborted := func() (aborted bool) {
+				defer func() {
+					if r := recover(); r != nil {
+						if r != http.ErrAbortHandler {
+							panic(r)
+						}
+						aborted = true
+					}
+				}()
+				h.ExportItems(rr, httptest.NewRequest("GET", "/items/export", nil))
+				return false
+			}()
+
+			if aborted != tt.abort {
+				t.Fatalf("expected aborted to be %v, got %v", tt.abort, aborted)
+			}
+			if !tt.abort && rr.Code != http.StatusInternalServerError {
+				t.Errorf("expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+			}
+		})
+	}
+}
+
+// stalledWriter is a ResponseWriter whose first Write waits until release is closed, like a client that stopped reading.
+type stalledWriter struct {
+	*httptest.ResponseRecorder
+	stalled chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *stalledWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.stalled)
+		<-w.release
+	})
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestExportItemsStalledClientE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+	// 本番の既定と同じく、接続は1つだけ
+	db.SetMaxOpenConns(1)
+
+	repo := &itemRepository{db: db}
+	// 最初のページを送る時点で、まだ続きが残っている件数にする
+	items := make([]*Item, exportPageRows+1)
+	for i := range items {
+		items[i] = &Item{Name: "item " + strconv.Itoa(i), Category: "bulk", Image: "default.jpg"}
+	}
+	if err := repo.InsertMany(t.Context(), items); err != nil {
+		t.Fatalf("failed to insert items: %v", err)
+	}
+
+	h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: repo}
+	mux := newMux(h.routes())
+
+	sw := &stalledWriter{ResponseRecorder: httptest.NewRecorder(), stalled: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.ServeHTTP(sw, httptest.NewRequest("GET", "/items/export", nil))
+	}()
+	<-sw.stalled
+
+	// 書き込みが止まっている間も、他のリクエストは接続を使える
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Second)
+	defer cancel()
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/items", nil).WithContext(ctx))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status code %d while the export is stalled, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	close(sw.release)
+	<-done
+	got, err := csv.NewReader(sw.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(got) != len(items)+1 {
+		t.Errorf("expected %d rows with the header, got %d", len(items)+1, len(got))
+	}
+}
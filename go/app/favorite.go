@@ -0,0 +1,117 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// お気に入り機能
+// ログインの仕組みができるまでは、クライアントが生成して送るトークンで利用者を区別する
+
+// clientTokenHeader identifies the client of the favorites API until real authentication exists.
+const clientTokenHeader = "X-Client-Token"
+
+type GetFavoritesResponse struct {
+	Items []Item `json:"items"`
+}
+
+// parseClientToken returns the client token of the request, which is required.
+func parseClientToken(r *http.Request) (string, error) {
+	token := strings.TrimSpace(r.Header.Get(clientTokenHeader))
+	if token == "" {
+		return "", fmt.Errorf("%s header is required", clientTokenHeader)
+	}
+	if err := checkParamLen(clientTokenHeader, token, maxClientTokenLen); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// AddFavorite is a handler to favorite an item for POST /items/{item_id}/favorite .
+// Favoriting an item twice is the same as once.
+func (s *Handlers) AddFavorite(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGetItemByIdRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token, err := parseClientToken(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	if err := s.itemRepo.AddFavorite(r.Context(), req.Id, token); err != nil {
+		if errors.Is(err, errItemNotFound) {
+			slog.Warn("item not exist: ", "error", err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to add favorite: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveFavorite is a handler to unfavorite an item for DELETE /items/{item_id}/favorite .
+// Unfavoriting an item that is not a favorite does nothing.
+func (s *Handlers) RemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGetItemByIdRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token, err := parseClientToken(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	if err := s.itemRepo.RemoveFavorite(r.Context(), req.Id, token); err != nil {
+		if errors.Is(err, errItemNotFound) {
+			slog.Warn("item not exist: ", "error", err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to remove favorite: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetFavorites is a handler to return the favorite items of the client for GET /items/favorites .
+func (s *Handlers) GetFavorites(w http.ResponseWriter, r *http.Request) {
+	token, err := parseClientToken(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	items, err := s.itemRepo.GetFavorites(r.Context(), token)
+	if err != nil {
+		slog.Error("failed to get favorites: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(GetFavoritesResponse{Items: items}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
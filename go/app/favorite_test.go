@@ -0,0 +1,122 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFavoritesE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "jacket", Category: "fashion", Image: "default.jpg"},
+		{Name: "iPhone", Category: "phone", Image: "default.jpg"},
+	} {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	h := &Handlers{itemRepo: repo}
+
+	// do sends a favorite request for the item as the client with token.
+	do := func(method, id, token string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/items/"+id+"/favorite", nil)
+		req.SetPathValue("item_id", id)
+		if token != "" {
+			req.Header.Set(clientTokenHeader, token)
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+	favorites := func(token string) []string {
+		req := httptest.NewRequest("GET", "/items/favorites", nil)
+		req.Header.Set(clientTokenHeader, token)
+		rr := httptest.NewRecorder()
+		h.GetFavorites(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp GetFavoritesResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		names := []string{}
+		for _, item := range resp.Items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+	favoritesCounts := func() map[string]int {
+		rr := httptest.NewRecorder()
+		h.GetItems(rr, httptest.NewRequest("GET", "/items", nil))
+		var resp GetItemsResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		counts := map[string]int{}
+		for _, item := range resp.Items {
+			counts[item.Name] = item.FavoritesCount
+		}
+		return counts
+	}
+
+	// 2回登録しても1回分
+	for _, token := range []string{"alice", "alice", "bob"} {
+		if rr := do("POST", "1", token, h.AddFavorite); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := do("POST", "2", "alice", h.AddFavorite); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := do("POST", "99", "alice", h.AddFavorite); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status code %d for a missing item, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := do("POST", "1", "", h.AddFavorite); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status code %d without a client token, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	if diff := cmp.Diff(map[string]int{"jacket": 2, "iPhone": 1}, favoritesCounts()); diff != "" {
+		t.Errorf("unexpected favorites_count (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"iPhone", "jacket"}, favorites("alice")); diff != "" {
+		t.Errorf("unexpected favorites of alice (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"jacket"}, favorites("bob")); diff != "" {
+		t.Errorf("unexpected favorites of bob (-want +got):\n%s", diff)
+	}
+
+	// 外すのも2回やって問題ない
+	for range 2 {
+		if rr := do("DELETE", "1", "alice", h.RemoveFavorite); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := do("DELETE", "99", "alice", h.RemoveFavorite); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status code %d for a missing item, got %d", http.StatusNotFound, rr.Code)
+	}
+	if diff := cmp.Diff([]string{"iPhone"}, favorites("alice")); diff != "" {
+		t.Errorf("unexpected favorites of alice after removing (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]int{"jacket": 1, "iPhone": 1}, favoritesCounts()); diff != "" {
+		t.Errorf("unexpected favorites_count after removing (-want +got):\n%s", diff)
+	}
+}
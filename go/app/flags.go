@@ -0,0 +1,504 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 環境変数で切り替えていた動作を、再起動せずに GET/PATCH /admin/flags で確認・変更できるようにする
+// 値はスナップショットごと差し替えるので、読む側はロックを取らない
+
+// Flag types.
+const (
+	flagTypeBool     = "bool"
+	flagTypeInt      = "int"
+	flagTypeDuration = "duration"
+	flagTypeString   = "string"
+)
+
+// Flag names.
+const (
+	flagVerifyDedupe       = "verify_dedupe"
+	flagSlowRequestMs      = "slow_request_ms"
+	flagStorageStatsTTL    = "storage_stats_ttl"
+	flagDBSerializeWrites  = "db_serialize_writes"
+	flagFrontURL           = "front_url"
+	flagMaxImportRows      = "max_import_rows"
+	flagOrphanCategory     = "orphan_category"
+	flagLogLevel           = "log_level"
+	flagLogFormat          = "log_format"
+	flagDBTimeout          = "db_timeout"
+	flagDuplicateWindow    = "duplicate_window"
+	flagItemCache          = "item_cache"
+	flagItemCacheTTL       = "item_cache_ttl"
+	flagRateLimit          = "rate_limit"
+	flagImageSweepInterval = "image_sweep_interval"
+	flagImageSweepGrace    = "image_sweep_grace"
+
+	flagSearchMaxTerms       = "search_max_terms"
+	flagSearchBroadMinItems  = "search_broad_min_items"
+	flagSearchCandidateLimit = "search_candidate_limit"
+)
+
+var (
+	errUnknownFlag      = errors.New("unknown flag")
+	errImmutableFlag    = errors.New("flag cannot be changed at runtime")
+	errInvalidFlagValue = errors.New("invalid flag value")
+)
+
+// FlagSpec declares a feature flag.
+type FlagSpec struct {
+	Name string
+	// Type is one of flagTypeBool, flagTypeInt, flagTypeDuration and flagTypeString.
+	Type string
+	// Default is a bool, int, time.Duration or string according to Type.
+	Default any
+	// Mutable flags can be changed at runtime. The others are only read at startup.
+	Mutable bool
+	// Env is the environment variable seeding the flag at startup. Empty means none.
+	Env         string
+	Description string
+}
+
+// flagSpecs are the flags of the server.
+var flagSpecs = []FlagSpec{
+	{
+		Name:        flagVerifyDedupe,
+		Type:        flagTypeBool,
+		Default:     false,
+		Mutable:     true,
+		Env:         "VERIFY_DEDUPE",
+		Description: "re-hash an existing image before reusing it for an upload with the same hash",
+	},
+	{
+		Name:        flagSlowRequestMs,
+		Type:        flagTypeInt,
+		Default:     int(defaultSlowRequestThreshold / time.Millisecond),
+		Mutable:     true,
+		Env:         "SLOW_REQUEST_MS",
+		Description: "log the checkpoint breakdown of requests slower than this many milliseconds (0 disables)",
+	},
+	{
+		Name:        flagStorageStatsTTL,
+		Type:        flagTypeDuration,
+		Default:     defaultStorageStatsTTL,
+		Mutable:     true,
+		Env:         "STORAGE_STATS_TTL",
+		Description: "how long the result of GET /admin/storage is reused",
+	},
+	{
+		Name:        flagDBSerializeWrites,
+		Type:        flagTypeBool,
+		Default:     false,
+		Env:         "DB_SERIALIZE_WRITES",
+		Description: "execute all writes on a single writer goroutine with its own connection",
+	},
+	{
+		Name:        flagFrontURL,
+		Type:        flagTypeString,
+		Default:     "http://localhost:3000",
+		Env:         "FRONT_URL",
+		Description: "the comma-separated origins allowed by CORS, or * for any origin",
+	},
+	{
+		Name:        flagMaxImportRows,
+		Type:        flagTypeInt,
+		Default:     defaultMaxImportRows,
+		Mutable:     true,
+		Env:         "MAX_IMPORT_ROWS",
+		Description: "the maximum number of rows of POST /items/bulk and POST /items/import",
+	},
+	{
+		Name:        flagOrphanCategory,
+		Type:        flagTypeString,
+		Default:     defaultOrphanCategory,
+		Mutable:     true,
+		Env:         "ORPHAN_CATEGORY",
+		Description: "the category receiving the items of a deleted category",
+	},
+	{
+		Name:        flagLogLevel,
+		Type:        flagTypeString,
+		Default:     "info",
+		Env:         "LOG_LEVEL",
+		Description: "the minimum level of the logs: debug, info, warn or error",
+	},
+	{
+		Name:        flagLogFormat,
+		Type:        flagTypeString,
+		Default:     logFormatJSON,
+		Env:         "LOG_FORMAT",
+		Description: "the format of the logs: json, or text for local development",
+	},
+	{
+		Name:        flagDBTimeout,
+		Type:        flagTypeDuration,
+		Default:     defaultDBTimeout,
+		Mutable:     true,
+		Env:         "DB_TIMEOUT",
+		Description: "how long a database call of a request may take before it is cancelled with 503 (0 disables)",
+	},
+	{
+		Name:        flagDuplicateWindow,
+		Type:        flagTypeDuration,
+		Default:     time.Duration(0),
+		Env:         "DUPLICATE_WINDOW",
+		Description: "reject POST /items for an item with the same name, category and image as one added within this duration (0 disables)",
+	},
+	{
+		Name:        flagItemCache,
+		Type:        flagTypeBool,
+		Default:     false,
+		Env:         "ITEM_CACHE",
+		Description: "cache the item lists of GET /items in memory, cleared on every item change",
+	},
+	{
+		Name:        flagItemCacheTTL,
+		Type:        flagTypeDuration,
+		Default:     defaultItemCacheTTL,
+		Mutable:     true,
+		Env:         "ITEM_CACHE_TTL",
+		Description: "how long a cached item list is used when item_cache is enabled (0 disables)",
+	},
+	{
+		Name:        flagRateLimit,
+		Type:        flagTypeString,
+		Default:     defaultRateLimit,
+		Env:         "RATE_LIMIT",
+		Description: "the limit of POST /items per client IP as <requests per second>,<burst>, or 0 to disable",
+	},
+	{
+		Name:        flagImageSweepInterval,
+		Type:        flagTypeDuration,
+		Default:     defaultImageSweepInterval,
+		Env:         "IMAGE_SWEEP_INTERVAL",
+		Description: "how often images no item uses are removed from the image directory (0 disables)",
+	},
+	{
+		Name:        flagImageSweepGrace,
+		Type:        flagTypeDuration,
+		Default:     defaultImageSweepGrace,
+		Mutable:     true,
+		Env:         "IMAGE_SWEEP_GRACE",
+		Description: "how long an image no item uses is kept after it was last stored",
+	},
+	{
+		Name:        flagSearchMaxTerms,
+		Type:        flagTypeInt,
+		Default:     defaultSearchMaxTerms,
+		Mutable:     true,
+		Env:         "SEARCH_MAX_TERMS",
+		Description: "the maximum number of terms of GET /search (0 disables)",
+	},
+	{
+		Name:        flagSearchBroadMinItems,
+		Type:        flagTypeInt,
+		Default:     defaultSearchBroadMinItems,
+		Mutable:     true,
+		Env:         "SEARCH_BROAD_MIN_ITEMS",
+		Description: "above this many items, GET /search requires a term of 2 or more characters",
+	},
+	{
+		Name:        flagSearchCandidateLimit,
+		Type:        flagTypeInt,
+		Default:     defaultSearchCandidateLimit,
+		Mutable:     true,
+		Env:         "SEARCH_CANDIDATE_LIMIT",
+		Description: "the maximum number of items a LIKE search of GET /search examines as matches (0 disables)",
+	},
+}
+
+// Flag is a flag with its current value, as returned by GET /admin/flags.
+// Durations are formatted like "30s".
+type Flag struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Value       any    `json:"value"`
+	Default     any    `json:"default"`
+	Mutable     bool   `json:"mutable"`
+	Description string `json:"description"`
+}
+
+// Flags holds the current values of the flags.
+// A nil *Flags returns the defaults of flagSpecs.
+type Flags struct {
+	specs map[string]FlagSpec
+	order []string
+	// values is replaced as a whole on every change, so readers never see a partial update.
+	values atomic.Pointer[map[string]any]
+	// mu serializes changes.
+	mu sync.Mutex
+}
+
+// NewFlags returns flags set to the defaults of specs.
+func NewFlags(specs []FlagSpec) *Flags {
+	f := &Flags{specs: make(map[string]FlagSpec, len(specs))}
+	values := make(map[string]any, len(specs))
+	for _, spec := range specs {
+		f.specs[spec.Name] = spec
+		f.order = append(f.order, spec.Name)
+		values[spec.Name] = spec.Default
+	}
+	f.values.Store(&values)
+	return f
+}
+
+// SeedFromEnv sets the flags from their environment variables, so that the variables used before
+// the flags existed keep working. Invalid values are logged and the default is kept.
+func (f *Flags) SeedFromEnv(lookup func(string) (string, bool)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	values := f.copyValues()
+	for _, name := range f.order {
+		spec := f.specs[name]
+		if spec.Env == "" {
+			continue
+		}
+		raw, found := lookup(spec.Env)
+		if !found {
+			continue
+		}
+		v, err := parseFlagString(spec, raw)
+		if err != nil {
+			slog.Warn("invalid flag value in environment, using default", "env", spec.Env, "value", logValue(raw), "error", err)
+			continue
+		}
+		values[name] = v
+	}
+	f.values.Store(&values)
+}
+
+// Set changes a mutable flag to the JSON value and returns the previous and the new value.
+func (f *Flags) Set(name string, value json.RawMessage) (old, updated any, err error) {
+	spec, ok := f.specs[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", errUnknownFlag, name)
+	}
+	if !spec.Mutable {
+		return nil, nil, fmt.Errorf("%w: %s", errImmutableFlag, name)
+	}
+	updated, err = parseFlagJSON(spec, value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	values := f.copyValues()
+	old = values[name]
+	values[name] = updated
+	f.values.Store(&values)
+	return old, updated, nil
+}
+
+// List returns the flags in declaration order.
+func (f *Flags) List() []Flag {
+	values := *f.values.Load()
+	flags := make([]Flag, 0, len(f.order))
+	for _, name := range f.order {
+		spec := f.specs[name]
+		flags = append(flags, Flag{
+			Name:        name,
+			Type:        spec.Type,
+			Value:       flagJSONValue(values[name]),
+			Default:     flagJSONValue(spec.Default),
+			Mutable:     spec.Mutable,
+			Description: spec.Description,
+		})
+	}
+	return flags
+}
+
+// Bool returns the value of a bool flag.
+func (f *Flags) Bool(name string) bool {
+	v, _ := f.value(name).(bool)
+	return v
+}
+
+// Int returns the value of an int flag.
+func (f *Flags) Int(name string) int {
+	v, _ := f.value(name).(int)
+	return v
+}
+
+// Duration returns the value of a duration flag.
+func (f *Flags) Duration(name string) time.Duration {
+	v, _ := f.value(name).(time.Duration)
+	return v
+}
+
+// String returns the value of a string flag.
+func (f *Flags) String(name string) string {
+	v, _ := f.value(name).(string)
+	return v
+}
+
+// value returns the current value of the flag, or the default of flagSpecs when f is nil.
+func (f *Flags) value(name string) any {
+	if f == nil {
+		for _, spec := range flagSpecs {
+			if spec.Name == name {
+				return spec.Default
+			}
+		}
+		return nil
+	}
+	return (*f.values.Load())[name]
+}
+
+// copyValues returns a copy of the current values to modify. f.mu must be held.
+func (f *Flags) copyValues() map[string]any {
+	current := *f.values.Load()
+	values := make(map[string]any, len(current))
+	for k, v := range current {
+		values[k] = v
+	}
+	return values
+}
+
+// parseFlagString parses a value from an environment variable.
+func parseFlagString(spec FlagSpec, raw string) (any, error) {
+	switch spec.Type {
+	case flagTypeBool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be true or false", errInvalidFlagValue, spec.Name)
+		}
+		return v, nil
+	case flagTypeInt:
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("%w: %s must be a non-negative integer", errInvalidFlagValue, spec.Name)
+		}
+		return v, nil
+	case flagTypeDuration:
+		v, err := time.ParseDuration(raw)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("%w: %s must be a non-negative duration such as 30s", errInvalidFlagValue, spec.Name)
+		}
+		return v, nil
+	default:
+		return raw, nil
+	}
+}
+
+// parseFlagJSON parses a value from the body of PATCH /admin/flags/{name}.
+// Durations are given as strings like "30s".
+func parseFlagJSON(spec FlagSpec, raw json.RawMessage) (any, error) {
+	switch spec.Type {
+	case flagTypeBool:
+		var v bool
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("%w: %s must be true or false", errInvalidFlagValue, spec.Name)
+		}
+		return v, nil
+	case flagTypeInt:
+		var v int
+		if err := json.Unmarshal(raw, &v); err != nil || v < 0 {
+			return nil, fmt.Errorf("%w: %s must be a non-negative integer", errInvalidFlagValue, spec.Name)
+		}
+		return v, nil
+	default:
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("%w: %s must be a string", errInvalidFlagValue, spec.Name)
+		}
+		return parseFlagString(spec, v)
+	}
+}
+
+// flagJSONValue converts a flag value to its JSON form.
+func flagJSONValue(v any) any {
+	if d, ok := v.(time.Duration); ok {
+		return d.String()
+	}
+	return v
+}
+
+/* Flags */
+type GetFlagsResponse struct {
+	Flags []Flag `json:"flags"`
+}
+
+type PatchFlagRequest struct {
+	Value json.RawMessage `json:"value"`
+}
+
+// GetFlags is a handler to return the feature flags for GET /admin/flags .
+func (s *Handlers) GetFlags(w http.ResponseWriter, r *http.Request) {
+	flags := s.flags
+	if flags == nil {
+		flags = NewFlags(flagSpecs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(GetFlagsResponse{Flags: flags.List()}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
+
+// PatchFlag is a handler to change a feature flag for PATCH /admin/flags/{name} .
+// The body is {"value": ...}. Unknown flags are 404 and immutable flags or invalid values are 400.
+func (s *Handlers) PatchFlag(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := checkParamLen("name", name, maxShortParamLen); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req PatchFlagRequest
+	limits := defaultJSONLimits
+	limits.MaxBytes = 1 << 10 // 1KB
+	if err := decodeJSONBody(r, limits, &req); err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			writeValidationError(w, validationErr)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Value == nil {
+		http.Error(w, `request body must be {"value": ...}`, http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	if s.flags == nil {
+		http.Error(w, "flags are not configured", http.StatusServiceUnavailable)
+		return
+	}
+	old, updated, err := s.flags.Set(name, req.Value)
+	if err != nil {
+		if errors.Is(err, errUnknownFlag) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 誰がいつ何を変えたか追えるように、監査ログに残す
+	slog.Info("audit", "action", "flag.update", "flag", name,
+		"old", flagJSONValue(old), "new", flagJSONValue(updated), "remote_addr", r.RemoteAddr)
+
+	for _, flag := range s.flags.List() {
+		if flag.Name != name {
+			continue
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(flag); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	checkpoint(r.Context(), "encode")
+}
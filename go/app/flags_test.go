@@ -0,0 +1,172 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFlagsSeedFromEnv(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		"VERIFY_DEDUPE":     "true",
+		"STORAGE_STATS_TTL": "5m",
+		// 不正な値はデフォルトのまま
+		"SLOW_REQUEST_MS": "fast",
+	}
+	flags := NewFlags(flagSpecs)
+	flags.SeedFromEnv(func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+
+	if !flags.Bool(flagVerifyDedupe) {
+		t.Errorf("expected %s to be seeded from the environment", flagVerifyDedupe)
+	}
+	if got := flags.Duration(flagStorageStatsTTL); got != 5*time.Minute {
+		t.Errorf("expected %s of 5m, got %v", flagStorageStatsTTL, got)
+	}
+	if got, want := flags.Int(flagSlowRequestMs), int(defaultSlowRequestThreshold/time.Millisecond); got != want {
+		t.Errorf("expected %s to keep the default %d, got %d", flagSlowRequestMs, want, got)
+	}
+
+	// nilのときはデフォルト値を返す
+	var none *Flags
+	if got := none.String(flagFrontURL); got != "http://localhost:3000" {
+		t.Errorf("expected the default of %s, got %q", flagFrontURL, got)
+	}
+}
+
+func TestPatchFlag(t *testing.T) {
+	t.Parallel()
+
+	type wants struct {
+		code  int
+		value any
+	}
+	cases := map[string]struct {
+		name string
+		body string
+		wants
+	}{
+		"ok: bool": {
+			name:  flagVerifyDedupe,
+			body:  `{"value":true}`,
+			wants: wants{code: http.StatusOK, value: true},
+		},
+		"ok: duration": {
+			name:  flagStorageStatsTTL,
+			body:  `{"value":"1m30s"}`,
+			wants: wants{code: http.StatusOK, value: "1m30s"},
+		},
+		"ng: immutable flag": {
+			name:  flagDBSerializeWrites,
+			body:  `{"value":true}`,
+			wants: wants{code: http.StatusBadRequest},
+		},
+		"ng: unknown flag": {
+			name:  "canary_percent",
+			body:  `{"value":10}`,
+			wants: wants{code: http.StatusNotFound},
+		},
+		"ng: wrong type": {
+			name:  flagSlowRequestMs,
+			body:  `{"value":"slow"}`,
+			wants: wants{code: http.StatusBadRequest},
+		},
+		"ng: missing value": {
+			name:  flagVerifyDedupe,
+			body:  `{}`,
+			wants: wants{code: http.StatusBadRequest},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handlers{flags: NewFlags(flagSpecs)}
+			req := httptest.NewRequest("PATCH", "/admin/flags/"+tt.name, strings.NewReader(tt.body))
+			req.SetPathValue("name", tt.name)
+			rr := httptest.NewRecorder()
+			h.PatchFlag(rr, req)
+
+			if rr.Code != tt.wants.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.wants.code, rr.Code, rr.Body.String())
+			}
+			if tt.wants.code >= 400 {
+				return
+			}
+			var flag Flag
+			if err := json.NewDecoder(rr.Body).Decode(&flag); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if diff := cmp.Diff(tt.wants.value, flag.Value); diff != "" {
+				t.Errorf("unexpected value (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFlagChangesBehaviorAtRuntime(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeImage := func(name string) {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, 10), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	h := &Handlers{imgDirPath: dir, flags: NewFlags(flagSpecs), storageStats: &storageStatsCache{}}
+	imageCount := func() int {
+		rr := httptest.NewRecorder()
+		h.GetStorageStats(rr, httptest.NewRequest("GET", "/admin/storage", nil))
+		var stats StorageStats
+		if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return stats.ImageCount
+	}
+
+	writeImage("a.jpg")
+	if got := imageCount(); got != 1 {
+		t.Fatalf("expected 1 image, got %d", got)
+	}
+	// デフォルトのTTLの間はキャッシュが返る
+	writeImage("b.jpg")
+	if got := imageCount(); got != 1 {
+		t.Fatalf("expected the cached count 1, got %d", got)
+	}
+
+	// 再起動せずにTTLを0にすると、次のリクエストから毎回数え直す
+	req := httptest.NewRequest("PATCH", "/admin/flags/"+flagStorageStatsTTL, strings.NewReader(`{"value":"0s"}`))
+	req.SetPathValue("name", flagStorageStatsTTL)
+	rr := httptest.NewRecorder()
+	h.PatchFlag(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if got := imageCount(); got != 2 {
+		t.Errorf("expected 2 images after disabling the cache, got %d", got)
+	}
+
+	rr = httptest.NewRecorder()
+	h.GetFlags(rr, httptest.NewRequest("GET", "/admin/flags", nil))
+	var resp GetFlagsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	for _, flag := range resp.Flags {
+		if flag.Name == flagStorageStatsTTL && flag.Value != "0s" {
+			t.Errorf("expected GET /admin/flags to show the new value, got %v", flag.Value)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// 商品名・カテゴリ名・ブランドの全文検索 (SQLite FTS5)
+// FTS5はgo-sqlite3を -tags sqlite_fts5 でビルドしたときだけ使える
+// 使えない場合は、これまで通りLIKEで検索する
+
+// ftsMinTermLen is the shortest term the trigram tokenizer can match.
+// Shorter terms are searched with LIKE instead.
+const ftsMinTermLen = 3
+
+// ftsSchema creates the FTS table and the triggers that keep it in sync with items and categories.
+// trigramトークナイザにすると、LIKEと同じく単語の途中にも一致する (日本語のように空白で区切られない名前でも検索できる)
+// 名前は、検索語と同じく正規化したもの (name_normalized) を索引に入れる
+const ftsSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS items_fts USING fts5(name_normalized, category, brand, tokenize = 'trigram');
+
+CREATE TRIGGER IF NOT EXISTS items_fts_insert AFTER INSERT ON items BEGIN
+	INSERT INTO items_fts (rowid, name_normalized, category, brand)
+	VALUES (new.id, new.name_normalized, (SELECT name FROM categories WHERE id = new.category_id), new.brand);
+END;
+
+CREATE TRIGGER IF NOT EXISTS items_fts_delete AFTER DELETE ON items BEGIN
+	DELETE FROM items_fts WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS items_fts_update AFTER UPDATE OF name_normalized, category_id, brand ON items BEGIN
+	DELETE FROM items_fts WHERE rowid = old.id;
+	INSERT INTO items_fts (rowid, name_normalized, category, brand)
+	VALUES (new.id, new.name_normalized, (SELECT name FROM categories WHERE id = new.category_id), new.brand);
+END;
+
+CREATE TRIGGER IF NOT EXISTS items_fts_category_update AFTER UPDATE OF name ON categories BEGIN
+	UPDATE items_fts SET category = new.name WHERE rowid IN (SELECT id FROM items WHERE category_id = new.id);
+END;
+`
+
+// dropObsoleteFTS is run before ftsSchema. It drops an FTS table created before the brand column
+// or the normalized name, together with its triggers, so that ftsSchema recreates them and the index is rebuilt.
+const dropObsoleteFTS = `
+DROP TABLE items_fts;
+DROP TRIGGER IF EXISTS items_fts_insert;
+DROP TRIGGER IF EXISTS items_fts_delete;
+DROP TRIGGER IF EXISTS items_fts_update;
+DROP TRIGGER IF EXISTS items_fts_category_update;
+`
+
+// setupFTS creates the FTS table if the SQLite build supports FTS5, and reports whether it is available.
+// The index is rebuilt when it does not match the items table, e.g. when it was just created for an existing database.
+func setupFTS(db *sql.DB) (bool, error) {
+	// ブランドや正規化した名前を追加する前に作られた索引は、列が足りないので作り直す
+	var obsolete bool
+	err := db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'items_fts')
+			AND (
+				NOT EXISTS (SELECT 1 FROM pragma_table_info('items_fts') WHERE name = 'brand')
+				OR NOT EXISTS (SELECT 1 FROM pragma_table_info('items_fts') WHERE name = 'name_normalized')
+			)`).Scan(&obsolete)
+	if err != nil {
+		if !strings.Contains(err.Error(), "no such module: fts5") {
+			return false, fmt.Errorf("failed to check FTS table: %w", err)
+		}
+	} else if obsolete {
+		if _, err := db.Exec(dropObsoleteFTS); err != nil {
+			return false, fmt.Errorf("failed to drop obsolete FTS table: %w", err)
+		}
+	}
+
+	if _, err := db.Exec(ftsSchema); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create FTS table: %w", err)
+	}
+
+	var items, indexed int
+	if err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM items), (SELECT COUNT(*) FROM items_fts)`).Scan(&items, &indexed); err != nil {
+		return false, err
+	}
+	if items != indexed {
+		if err := rebuildFTS(db); err != nil {
+			return false, fmt.Errorf("failed to rebuild FTS index: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// rebuildFTS recreates the FTS index from the items table.
+func rebuildFTS(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM items_fts`); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO items_fts (rowid, name_normalized, category, brand)
+		SELECT items.id, items.name_normalized, categories.name, items.brand FROM items LEFT JOIN categories ON items.category_id = categories.id`)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// searchTerms splits a keyword into its terms, normalized with normalizeSearchText.
+// With matchAny, a standalone OR only separates the terms, so that "bag OR pouch" and "bag pouch" are the same search.
+func searchTerms(keyword string, matchAny bool) []string {
+	terms := strings.Fields(keyword)
+	if matchAny {
+		terms = slices.DeleteFunc(terms, func(t string) bool { return t == "OR" })
+	}
+	for i, t := range terms {
+		terms[i] = normalizeSearchText(t)
+	}
+	return terms
+}
+
+// ftsQuery builds an FTS5 MATCH query requiring all terms, or any of them with matchAny.
+// It returns false if a term is too short for the trigram tokenizer.
+func ftsQuery(terms []string, matchAny bool) (string, bool) {
+	quoted := make([]string, 0, len(terms))
+	for _, t := range terms {
+		if utf8.RuneCountInString(t) < ftsMinTermLen {
+			return "", false
+		}
+		// ダブルクォートで囲んで、FTS5の演算子として解釈されないようにする
+		quoted = append(quoted, `"`+strings.ReplaceAll(t, `"`, `""`)+`"`)
+	}
+	// FTS5では空白で並べるとAND、ORでつなぐとOR
+	sep := " "
+	if matchAny {
+		sep = " OR "
+	}
+	return strings.Join(quoted, sep), len(quoted) > 0
+}
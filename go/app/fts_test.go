@@ -0,0 +1,368 @@
+package app
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// setupFTSRepo sets up a database with items and returns a repository using full-text search.
+// The test is skipped unless the SQLite build supports FTS5 (go test -tags sqlite_fts5).
+func setupFTSRepo(t *testing.T, items []*Item) *itemRepository {
+	t.Helper()
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	// FTSを作る前に入っていた商品も検索できるように、作成時に索引を作り直す
+	for _, item := range items {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	repo.fts, err = setupFTS(db)
+	if err != nil {
+		t.Fatalf("failed to set up FTS: %v", err)
+	}
+	if !repo.fts {
+		t.Skip("SQLite is built without FTS5")
+	}
+	return repo
+}
+
+// searchNames returns the names of the items found by the keyword.
+func searchNames(t *testing.T, repo *itemRepository, keyword string) []string {
+	t.Helper()
+
+	var names []string
+	err := repo.SearchItemsByKeyword(t.Context(), SearchFilter{Keyword: keyword, MaxPrice: priceUnbounded}, func(item Item) error {
+		names = append(names, item.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to search items: %v", err)
+	}
+	count, err := repo.CountItemsByKeyword(t.Context(), SearchFilter{Keyword: keyword, MaxPrice: priceUnbounded})
+	if err != nil {
+		t.Fatalf("failed to count items: %v", err)
+	}
+	if count != len(names) {
+		t.Errorf("expected count %d to match %d results", count, len(names))
+	}
+	return names
+}
+
+func TestSearchFTSE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	repo := setupFTSRepo(t, []*Item{
+		{Name: "red leather bag with a pocket for running shoes", Category: "bags", Image: "default.jpg"},
+		{Name: "blue shoes", Category: "fashion", Image: "default.jpg"},
+		{Name: "red running shoes", Category: "fashion", Image: "default.jpg"},
+		{Name: "ランニングシューズ", Category: "スポーツ用品", Image: "default.jpg"},
+	})
+
+	cases := map[string]struct {
+		keyword string
+		names   []string
+	}{
+		"ok: multi-word query requires all words": {
+			keyword: "red shoes",
+			// 短い名前の方が関連度が高い
+			names: []string{"red running shoes", "red leather bag with a pocket for running shoes"},
+		},
+		"ok: relevance order": {
+			keyword: "shoes",
+			names:   []string{"blue shoes", "red running shoes", "red leather bag with a pocket for running shoes"},
+		},
+		"ok: category matches": {
+			keyword: "bags",
+			names:   []string{"red leather bag with a pocket for running shoes"},
+		},
+		"ok: substring without spaces": {
+			keyword: "シューズ",
+			names:   []string{"ランニングシューズ"},
+		},
+		"ok: short term falls back to LIKE": {
+			keyword: "ue",
+			names:   []string{"blue shoes"},
+		},
+		"ok: operators are not interpreted": {
+			keyword: `shoes OR "bag`,
+			names:   nil,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.names, searchNames(t, repo, tt.keyword)); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSearchFTSConsistencyE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	repo := setupFTSRepo(t, []*Item{
+		{Name: "jacket", Category: "fashion", Image: "default.jpg"},
+	})
+
+	// FTSを作った後に追加した商品も索引に入る
+	for _, item := range []*Item{
+		{Name: "denim jacket", Category: "fashion", Image: "default.jpg"},
+		{Name: "iPhone", Category: "phone", Image: "default.jpg"},
+	} {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	if err := repo.InsertMany(t.Context(), []*Item{{Name: "rain jacket", Category: "outdoor", Image: "default.jpg"}}); err != nil {
+		t.Fatalf("failed to insert items: %v", err)
+	}
+
+	var items, indexed int
+	if err := repo.db.QueryRow(`SELECT (SELECT COUNT(*) FROM items), (SELECT COUNT(*) FROM items_fts)`).Scan(&items, &indexed); err != nil {
+		t.Fatalf("failed to count rows: %v", err)
+	}
+	if items != indexed {
+		t.Errorf("expected %d indexed rows, got %d", items, indexed)
+	}
+	if diff := cmp.Diff([]string{"jacket", "rain jacket", "denim jacket"}, searchNames(t, repo, "jacket")); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+
+	// カテゴリ名を変えると索引も変わる
+	if _, err := repo.db.Exec(`UPDATE categories SET name = 'smartphone' WHERE name = 'phone'`); err != nil {
+		t.Fatalf("failed to rename category: %v", err)
+	}
+	if diff := cmp.Diff([]string{"iPhone"}, searchNames(t, repo, "smartphone")); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+}
+
+func TestSearchFTSBrandE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	repo := setupFTSRepo(t, []*Item{
+		{Name: "running shoes", Category: "shoes", Image: "default.jpg", Brand: "Nike"},
+		{Name: "sandals", Category: "shoes", Image: "default.jpg"},
+	})
+	if diff := cmp.Diff([]string{"running shoes"}, searchNames(t, repo, "nike")); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+
+	// ブランドを追加する前の索引 (name, categoryだけ) は作り直される
+	_, err := repo.db.Exec(`
+		DROP TABLE items_fts;
+		DROP TRIGGER items_fts_insert;
+		DROP TRIGGER items_fts_update;
+		CREATE VIRTUAL TABLE items_fts USING fts5(name, category, tokenize = 'trigram');
+		CREATE TRIGGER items_fts_insert AFTER INSERT ON items BEGIN
+			INSERT INTO items_fts (rowid, name, category)
+			VALUES (new.id, new.name, (SELECT name FROM categories WHERE id = new.category_id));
+		END;
+		INSERT INTO items_fts (rowid, name, category)
+		SELECT items.id, items.name, categories.name FROM items INNER JOIN categories ON items.category_id = categories.id;
+	`)
+	if err != nil {
+		t.Fatalf("failed to create an old FTS table: %v", err)
+	}
+	if _, err := setupFTS(repo.db); err != nil {
+		t.Fatalf("failed to set up FTS: %v", err)
+	}
+	if err := repo.Insert(t.Context(), &Item{Name: "tote bag", Category: "bags", Image: "default.jpg", Brand: "Nike"}); err != nil {
+		t.Fatalf("failed to insert item: %v", err)
+	}
+	// 関連度が同じなので、順番は比べない
+	names := searchNames(t, repo, "nike")
+	slices.Sort(names)
+	if diff := cmp.Diff([]string{"running shoes", "tote bag"}, names); diff != "" {
+		t.Errorf("unexpected items after the upgrade (-want +got):\n%s", diff)
+	}
+}
+
+func TestSearchFTSNormalizedE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	repo := setupFTSRepo(t, []*Item{
+		{Name: "ＬＥＡＴＨＥＲ ｂａｇ", Category: "fashion", Image: "default.jpg"},
+		{Name: "ﾗﾝﾆﾝｸﾞｼｭｰｽﾞ", Category: "fashion", Image: "default.jpg"},
+	})
+
+	cases := map[string]struct {
+		keyword string
+		names   []string
+	}{
+		"ok: lower case":          {keyword: "leather", names: []string{"ＬＥＡＴＨＥＲ ｂａｇ"}},
+		"ok: full-width keyword":  {keyword: "ＬＥＡＴＨＥＲ", names: []string{"ＬＥＡＴＨＥＲ ｂａｇ"}},
+		"ok: full-width katakana": {keyword: "シューズ", names: []string{"ﾗﾝﾆﾝｸﾞｼｭｰｽﾞ"}},
+		"ok: half-width katakana": {keyword: "ｼｭｰｽﾞ", names: []string{"ﾗﾝﾆﾝｸﾞｼｭｰｽﾞ"}},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.names, searchNames(t, repo, tt.keyword)); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSearchFTSRelevanceE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	// FTSで検索しても名前の一致の度合いで並べ、同じ度合いの中ではbm25の順 (短い名前が先)
+	repo := setupFTSRepo(t, relevanceFixture())
+	want := []string{"Bag", "bagpipe", "bag charm", "leather bag", "tote", "wallet"}
+	if diff := cmp.Diff(want, searchNames(t, repo, "bag")); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+}
+
+func TestSearchFTSMatchAnyE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	repo := setupFTSRepo(t, []*Item{
+		{Name: "leather bag", Category: "bags", Image: "default.jpg"},
+		{Name: "pouch", Category: "bags", Image: "default.jpg"},
+		{Name: "leather pouch", Category: "bags", Image: "default.jpg"},
+		{Name: "jacket", Category: "fashion", Image: "default.jpg"},
+	})
+
+	cases := map[string]struct {
+		matchAny bool
+		names    []string
+	}{
+		"ok: and is the intersection": {names: []string{"leather pouch"}},
+		"ok: or is the union":         {matchAny: true, names: []string{"leather bag", "leather pouch", "pouch"}},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			filter := SearchFilter{Keyword: "leather OR pouch", MaxPrice: priceUnbounded, MatchAny: tt.matchAny}
+			if !tt.matchAny {
+				filter.Keyword = "leather pouch"
+			}
+			var names []string
+			err := repo.SearchItemsByKeyword(t.Context(), filter, func(item Item) error {
+				names = append(names, item.Name)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failed to search items: %v", err)
+			}
+			// 関連度の順番は比べない
+			slices.Sort(names)
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+			count, err := repo.CountItemsByKeyword(t.Context(), filter)
+			if err != nil {
+				t.Fatalf("failed to count items: %v", err)
+			}
+			if count != len(tt.names) {
+				t.Errorf("expected count %d, got %d", len(tt.names), count)
+			}
+		})
+	}
+}
+
+// benchmarkSearchItems is the number of items in the database of BenchmarkSearchItemsByKeyword.
+const benchmarkSearchItems = 100_000
+
+// BenchmarkSearchItemsByKeyword compares the LIKE and the FTS5 search on benchmarkSearchItems items,
+// counting the matches and reading the first page as GET /search does.
+// The FTS5 cases are skipped unless SQLite is built with it:
+//
+//	go test -tags sqlite_fts5 -run '^$' -bench SearchItemsByKeyword
+func BenchmarkSearchItemsByKeyword(b *testing.B) {
+	db, closers, err := setupDB(b)
+	if err != nil {
+		b.Fatalf("failed to set up database: %v", err)
+	}
+	b.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	// 1件ずつInsertすると時間がかかるので、SQLでまとめて作る (FTSの索引は後で作り直す)
+	now := formatTimestamp(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	_, err = db.Exec(`
+		INSERT INTO categories (name) VALUES ('fashion'), ('bags'), ('shoes'), ('audio'), ('furniture');
+		WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < ?)
+		INSERT INTO items (name, category_id, image_name, price, created_at, updated_at)
+		SELECT
+			CASE n % 5
+				WHEN 0 THEN 'vintage denim jacket'
+				WHEN 1 THEN 'leather shoulder bag'
+				WHEN 2 THEN 'running shoes'
+				WHEN 3 THEN 'wireless earphones'
+				ELSE 'wooden chair'
+			END || ' ' || n,
+			n % 5 + 1, -- 空のデータベースなので、カテゴリのidは1から5
+			'default.jpg', n % 10000, ?, ?
+		FROM seq;
+		-- 名前はすべて小文字のASCIIなので、正規化しても同じ
+		UPDATE items SET name_normalized = name;`, benchmarkSearchItems, now, now)
+	if err != nil {
+		b.Fatalf("failed to insert items: %v", err)
+	}
+	fts, err := setupFTS(db)
+	if err != nil {
+		b.Fatalf("failed to set up FTS: %v", err)
+	}
+
+	// 多くの商品に一致する語と、ほとんど一致しない語
+	keywords := map[string]string{
+		"common": "jacket",
+		"rare":   "earphones 31313",
+	}
+	for _, engine := range []struct {
+		name string
+		fts  bool
+	}{{name: "like", fts: false}, {name: "fts5", fts: true}} {
+		for kind, keyword := range keywords {
+			b.Run(engine.name+"/"+kind, func(b *testing.B) {
+				if engine.fts && !fts {
+					b.Skip("SQLite is built without FTS5")
+				}
+				repo := &itemRepository{db: db, fts: engine.fts}
+				filter := SearchFilter{Keyword: keyword, MaxPrice: priceUnbounded, Limit: defaultItemsLimit}
+				for b.Loop() {
+					if _, err := repo.CountItemsByKeyword(b.Context(), filter); err != nil {
+						b.Fatalf("failed to count items: %v", err)
+					}
+					err := repo.SearchItemsByKeyword(b.Context(), filter, func(Item) error { return nil })
+					if err != nil {
+						b.Fatalf("failed to search items: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
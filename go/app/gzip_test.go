@@ -0,0 +1,92 @@
+package app
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGzipMiddleware(t *testing.T) {
+	t.Parallel()
+
+	const body = `{"message":"Hello, world!"}`
+
+	type wants struct {
+		encoding string
+	}
+	cases := map[string]struct {
+		acceptEncoding string
+		contentType    string
+		wants
+	}{
+		"ok: json is compressed": {
+			acceptEncoding: "gzip, deflate",
+			contentType:    "application/json",
+			wants:          wants{encoding: "gzip"},
+		},
+		"ok: text with quality is compressed": {
+			acceptEncoding: "br;q=1.0, gzip;q=0.8",
+			contentType:    "text/plain; charset=utf-8",
+			wants:          wants{encoding: "gzip"},
+		},
+		"ok: images are not compressed again": {
+			acceptEncoding: "gzip",
+			contentType:    "image/jpeg",
+			wants:          wants{encoding: ""},
+		},
+		"ok: gzip not accepted": {
+			acceptEncoding: "",
+			contentType:    "application/json",
+			wants:          wants{encoding: ""},
+		},
+		"ok: gzip refused with q=0": {
+			acceptEncoding: "gzip;q=0",
+			contentType:    "application/json",
+			wants:          wants{encoding: ""},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			h := gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("Content-Length", "27")
+				io.WriteString(w, body)
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if got := rr.Header().Get("Content-Encoding"); got != tt.wants.encoding {
+				t.Fatalf("expected Content-Encoding %q, got %q", tt.wants.encoding, got)
+			}
+			if got := rr.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("expected Vary Accept-Encoding, got %q", got)
+			}
+			var r io.Reader = rr.Body
+			if tt.wants.encoding == "gzip" {
+				// 圧縮後の長さは分からないので、元の長さは消す
+				if got := rr.Header().Get("Content-Length"); got != "" {
+					t.Errorf("expected no Content-Length, got %q", got)
+				}
+				zr, err := gzip.NewReader(rr.Body)
+				if err != nil {
+					t.Fatalf("failed to read gzip header: %v", err)
+				}
+				r = zr
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+			if string(got) != body {
+				t.Errorf("expected body %q, got %q", body, got)
+			}
+		})
+	}
+}
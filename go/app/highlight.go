@@ -0,0 +1,110 @@
+package app
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// 検索結果のハイライト
+// 検索語が商品名などのどこに一致したかを、元の文字列の文字 (rune) 単位の位置で返す
+// 検索は正規化した文字列 (normalizeSearchText) で比べるので、正規化した位置を元の文字列の位置に戻してから返す
+// (半角カタカナ ﾊﾞｯｸﾞ は正規化するとバッグの3文字になるが、ハイライトするのは元の5文字)
+
+// Fields of an item that matches are reported for.
+const (
+	matchFieldName     = "name"
+	matchFieldCategory = "category"
+	matchFieldBrand    = "brand"
+)
+
+// MatchRange is a part of a field of an item matching a term of the keyword.
+// Start and Length count runes of the field as returned, not bytes.
+type MatchRange struct {
+	Field  string `json:"field"`
+	Start  int    `json:"start"`
+	Length int    `json:"length"`
+}
+
+// SearchItem is an item of GET /search with the parts of its fields matching the keyword.
+type SearchItem struct {
+	Item
+	// Matches are ordered by field (name, category, brand) and then by position. Overlapping matches are merged.
+	Matches []MatchRange `json:"matches"`
+}
+
+// searchItem returns the item with the ranges matching the normalized terms.
+func searchItem(item Item, terms []string) SearchItem {
+	matches := []MatchRange{}
+	for _, f := range []struct{ field, value string }{
+		{matchFieldName, item.Name},
+		{matchFieldCategory, item.Category},
+		{matchFieldBrand, item.Brand},
+	} {
+		matches = append(matches, matchRanges(f.field, f.value, terms)...)
+	}
+	return SearchItem{Item: item, Matches: matches}
+}
+
+// matchRanges returns the ranges of value matching any of the normalized terms, in rune offsets of value.
+func matchRanges(field, value string, terms []string) []MatchRange {
+	normalized, starts, ends := normalizedRunes(value)
+
+	// 正規化した文字列での一致を、元の文字列の[start, end)に戻す
+	type span struct{ start, end int }
+	var spans []span
+	for _, t := range terms {
+		term := []rune(t)
+		if len(term) == 0 {
+			continue
+		}
+		for i := 0; i+len(term) <= len(normalized); {
+			if !slices.Equal(normalized[i:i+len(term)], term) {
+				i++
+				continue
+			}
+			spans = append(spans, span{start: starts[i], end: ends[i+len(term)-1]})
+			i += len(term)
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(spans, func(a, b span) int { return a.start - b.start })
+	ranges := make([]MatchRange, 0, len(spans))
+	cur := spans[0]
+	for _, sp := range spans[1:] {
+		if sp.start < cur.end {
+			cur.end = max(cur.end, sp.end)
+			continue
+		}
+		ranges = append(ranges, MatchRange{Field: field, Start: cur.start, Length: cur.end - cur.start})
+		cur = sp
+	}
+	return append(ranges, MatchRange{Field: field, Start: cur.start, Length: cur.end - cur.start})
+}
+
+// normalizedRunes returns the runes of normalizeSearchText(s), and for each of them the range [start, end)
+// of the runes of s it comes from. A rune made from several runes of s, such as バ from ﾊﾞ, covers all of them.
+func normalizedRunes(s string) (normalized []rune, starts, ends []int) {
+	var it norm.Iter
+	it.InitString(norm.NFKC, s)
+	pos := 0   // sのバイト位置
+	runes := 0 // sの文字位置
+	for !it.Done() {
+		seg := strings.ToLower(string(it.Next()))
+		next := it.Pos()
+		segRunes := utf8.RuneCountInString(s[pos:next])
+		for _, r := range seg {
+			normalized = append(normalized, r)
+			starts = append(starts, runes)
+			ends = append(ends, runes+segRunes)
+		}
+		pos = next
+		runes += segRunes
+	}
+	return normalized, starts, ends
+}
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMatchRanges(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		value   string
+		keyword string
+		want    []MatchRange
+	}{
+		"ascii": {
+			value:   "red bag",
+			keyword: "bag",
+			want:    []MatchRange{{Field: "name", Start: 4, Length: 3}},
+		},
+		"ignoring case": {
+			value:   "Red BAG",
+			keyword: "bag",
+			want:    []MatchRange{{Field: "name", Start: 4, Length: 3}},
+		},
+		// バイト位置ではなく文字位置
+		"japanese": {
+			value:   "革のバッグ",
+			keyword: "バッグ",
+			want:    []MatchRange{{Field: "name", Start: 2, Length: 3}},
+		},
+		"after multi-byte runes": {
+			value:   "新品 iPhone 15",
+			keyword: "iphone",
+			want:    []MatchRange{{Field: "name", Start: 3, Length: 6}},
+		},
+		"after an emoji": {
+			value:   "👜 bag",
+			keyword: "bag",
+			want:    []MatchRange{{Field: "name", Start: 2, Length: 3}},
+		},
+		"full-width name": {
+			value:   "ＢＡＧ ｃｈａｒｍ",
+			keyword: "charm",
+			want:    []MatchRange{{Field: "name", Start: 4, Length: 5}},
+		},
+		// 正規化で3文字になる5文字 (ﾊﾞｯｸﾞ) を全部ハイライトする
+		"half-width katakana": {
+			value:   "ﾚｻﾞｰﾊﾞｯｸﾞ",
+			keyword: "バッグ",
+			want:    []MatchRange{{Field: "name", Start: 4, Length: 5}},
+		},
+		"decomposed katakana": {
+			value:   "\u30cf\u3099\u30c3\u30af\u3099",
+			keyword: "バッグ",
+			want:    []MatchRange{{Field: "name", Start: 0, Length: 5}},
+		},
+		// 1文字が正規化で2文字になる
+		"expanded rune": {
+			value:   "1㌔ dumbbell",
+			keyword: "キロ",
+			want:    []MatchRange{{Field: "name", Start: 1, Length: 1}},
+		},
+		"part of an expanded rune": {
+			value:   "1㌔ dumbbell",
+			keyword: "ロ",
+			want:    []MatchRange{{Field: "name", Start: 1, Length: 1}},
+		},
+		"several terms": {
+			value:   "leather bag with a pouch",
+			keyword: "pouch bag",
+			want:    []MatchRange{{Field: "name", Start: 8, Length: 3}, {Field: "name", Start: 19, Length: 5}},
+		},
+		"repeated term": {
+			value:   "バッグinバッグ",
+			keyword: "バッグ",
+			want:    []MatchRange{{Field: "name", Start: 0, Length: 3}, {Field: "name", Start: 5, Length: 3}},
+		},
+		"overlapping terms": {
+			value:   "handbag",
+			keyword: "handb bag",
+			want:    []MatchRange{{Field: "name", Start: 0, Length: 7}},
+		},
+		"no match": {
+			value:   "jacket",
+			keyword: "bag",
+			want:    nil,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := matchRanges("name", tt.value, searchTerms(tt.keyword, false))
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected ranges (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSearchItemMatches(t *testing.T) {
+	t.Parallel()
+
+	item := Item{Name: "バッグ charm", Category: "fashion", Brand: "bagshop"}
+	got := searchItem(item, searchTerms("ﾊﾞｯｸﾞ OR bag", true)).Matches
+	want := []MatchRange{
+		{Field: matchFieldName, Start: 0, Length: 3},
+		{Field: matchFieldBrand, Start: 0, Length: 3},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected matches (-want +got):\n%s", diff)
+	}
+
+	// 一致がなくても、nullではなく空の配列を返す
+	data, err := json.Marshal(searchItem(Item{Name: "jacket"}, searchTerms("bag", false)))
+	if err != nil {
+		t.Fatalf("failed to encode item: %v", err)
+	}
+	var decoded map[string]json.RawMessage
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to decode item: %v", err)
+	}
+	if got := string(decoded["matches"]); got != "[]" {
+		t.Errorf("expected empty matches, got %s", got)
+	}
+}
+
+func TestSearchHighlightE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "本革ﾊﾞｯｸﾞ", Category: "fashion", Image: "default.jpg"},
+		{Name: "トートバッグ", Category: "fashion", Image: "default.jpg"},
+	} {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	h := &Handlers{itemRepo: repo}
+
+	q := url.Values{"keyword": {"バッグ"}, "sort": {searchSortNewest}}
+	rr := httptest.NewRecorder()
+	h.SearchItemsByKeyword(rr, httptest.NewRequest("GET", "/search?"+q.Encode(), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var resp SearchItemsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	want := map[string][]MatchRange{
+		"トートバッグ":  {{Field: matchFieldName, Start: 3, Length: 3}},
+		"本革ﾊﾞｯｸﾞ": {{Field: matchFieldName, Start: 2, Length: 5}},
+	}
+	got := map[string][]MatchRange{}
+	for _, item := range resp.Items {
+		got[item.Name] = item.Matches
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected matches (-want +got):\n%s", diff)
+	}
+}
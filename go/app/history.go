@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 商品の変更履歴 (GET /items/{item_id}/history)
+// 商品の作成と更新を、変更と同じトランザクションで item_events に記録する
+// ログインの仕組みができるまでは、誰が変更したかはクライアントが X-Actor ヘッダで送る (なければanonymous)
+
+// actorHeader names who makes a change, recorded in the history of the item.
+const actorHeader = "X-Actor"
+
+// defaultActor is recorded for changes made without actorHeader.
+const defaultActor = "anonymous"
+
+// itemEventCreated is the field of the event recorded when an item is added.
+const itemEventCreated = "created"
+
+// itemEventPublished is the field of the event recorded when a draft is published.
+const itemEventPublished = "published"
+
+// ItemEvent is a change of an item. Field is itemEventCreated, itemEventPublished or one of updatableFields.
+type ItemEvent struct {
+	ID     int    `json:"id"`
+	ItemID int    `json:"item_id"`
+	Field  string `json:"field"`
+	// OldValue and NewValue are nil for itemEventCreated and itemEventPublished.
+	OldValue  *string   `json:"old_value"`
+	NewValue  *string   `json:"new_value"`
+	CreatedAt time.Time `json:"created_at"`
+	Actor     string    `json:"actor"`
+}
+
+type GetItemHistoryResponse struct {
+	Events []ItemEvent `json:"events"`
+}
+
+// actorKey is the context key set by withActor.
+type actorKey struct{}
+
+// withActor returns a context whose changes are recorded as made by actor.
+func withActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actorFrom returns the actor set by withActor, or defaultActor.
+func actorFrom(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return defaultActor
+}
+
+// parseActor returns the actor of the request, or defaultActor if actorHeader is not given.
+func parseActor(r *http.Request) (string, error) {
+	actor := strings.TrimSpace(r.Header.Get(actorHeader))
+	if err := checkParamLen(actorHeader, actor, maxActorLen); err != nil {
+		return "", err
+	}
+	if actor == "" {
+		return defaultActor, nil
+	}
+	return actor, nil
+}
+
+// appendEventTx records a change of an item by the actor of ctx.
+func appendEventTx(ctx context.Context, tx *sql.Tx, itemID int, field string, oldValue, newValue *string, now time.Time) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO item_events (item_id, field, old_value, new_value, created_at, actor) VALUES (?, ?, ?, ?, ?, ?)`,
+		itemID, field, oldValue, newValue, formatTimestamp(now), actorFrom(ctx))
+	return err
+}
+
+// appendUpdateEventsTx records an event for each of updatableFields that differs between before and after.
+func appendUpdateEventsTx(ctx context.Context, tx *sql.Tx, before, after Item, now time.Time) error {
+	for _, f := range updatableFields {
+		oldValue, newValue := f.value(before), f.value(after)
+		if oldValue == newValue {
+			continue
+		}
+		if err := appendEventTx(ctx, tx, after.ID, f.name, &oldValue, &newValue, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetItemHistory is a handler to return the changes of an item, oldest first, for GET /items/{item_id}/history .
+func (s *Handlers) GetItemHistory(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGetItemByIdRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	events, err := s.itemRepo.GetItemHistory(r.Context(), req.Id)
+	if err != nil {
+		if errors.Is(err, errItemNotFound) {
+			slog.Warn("item not exist: ", "error", err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get item history: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(GetItemHistoryResponse{Events: events}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"mercari-build-training/app/apptest"
+)
+
+func TestParseActor(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		header  string
+		want    string
+		wantErr bool
+	}{
+		"ok: given":    {header: "alice", want: "alice"},
+		"ok: trimmed":  {header: " alice ", want: "alice"},
+		"ok: omitted":  {header: "", want: defaultActor},
+		"ok: blank":    {header: "  ", want: defaultActor},
+		"ng: too long": {header: strings.Repeat("a", maxActorLen+1), wantErr: true},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("PATCH", "/items/1", nil)
+			if tt.header != "" {
+				req.Header.Set(actorHeader, tt.header)
+			}
+			got, err := parseActor(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestItemHistoryE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	created := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	clock := apptest.NewFakeClock(created)
+	repo := &itemRepository{db: db, clock: clock}
+	h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: repo}
+
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(`{"name":"jacket","category":"fashion","image_name":"default.jpg","price":3000}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(actorHeader, "alice")
+	rr := httptest.NewRecorder()
+	h.AddItem(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	patch := func(body, actor string) {
+		t.Helper()
+		req := httptest.NewRequest("PATCH", "/items/1", strings.NewReader(body))
+		req.SetPathValue("item_id", "1")
+		req.Header.Set("Content-Type", "application/json")
+		if actor != "" {
+			req.Header.Set(actorHeader, actor)
+		}
+		rr := httptest.NewRecorder()
+		h.UpdateItem(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+	}
+	clock.Advance(time.Hour)
+	patch(`{"name":"coat","category":"outer"}`, "bob")
+	// 値が変わらない更新は記録しない
+	clock.Advance(time.Hour)
+	patch(`{"name":"coat"}`, "bob")
+	clock.Advance(time.Hour)
+	patch(`{"price":2500}`, "")
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/items/1/history", nil)
+	req.SetPathValue("item_id", "1")
+	h.GetItemHistory(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var resp GetItemHistoryResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	ptr := func(s string) *string { return &s }
+	want := []ItemEvent{
+		{ItemID: 1, Field: itemEventCreated, CreatedAt: created, Actor: "alice"},
+		{ItemID: 1, Field: "name", OldValue: ptr("jacket"), NewValue: ptr("coat"), CreatedAt: created.Add(time.Hour), Actor: "bob"},
+		{ItemID: 1, Field: "category", OldValue: ptr("fashion"), NewValue: ptr("outer"), CreatedAt: created.Add(time.Hour), Actor: "bob"},
+		{ItemID: 1, Field: "price", OldValue: ptr("3000"), NewValue: ptr("2500"), CreatedAt: created.Add(3 * time.Hour), Actor: defaultActor},
+	}
+	if diff := cmp.Diff(want, resp.Events, cmpopts.IgnoreFields(ItemEvent{}, "ID")); diff != "" {
+		t.Errorf("unexpected history (-want +got):\n%s", diff)
+	}
+
+	t.Run("ng: unknown item", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/items/99/history", nil)
+		req.SetPathValue("item_id", "99")
+		h.GetItemHistory(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusNotFound, rr.Code, rr.Body.String())
+		}
+	})
+}
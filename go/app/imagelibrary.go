@@ -0,0 +1,278 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 画像ライブラリ (POST /images, GET /images/{filename}/meta)
+// 商品とは別に先に画像をアップロードしておき、POST /items の image_name で後から商品に付ける
+// どの商品にも使われないまま猶予期間を過ぎた画像は、sweepOrphanImages が消す
+
+const (
+	// maxUploadImages is the maximum number of files of POST /images.
+	maxUploadImages = 10
+	// uploadImagesField is the multipart field of the files of POST /images.
+	uploadImagesField = "images"
+)
+
+var errTooManyImages = fmt.Errorf("too many images: the maximum is %d", maxUploadImages)
+
+// UploadedImage is the outcome of a file of POST /images, in the order of the request.
+type UploadedImage struct {
+	// File is the name of the uploaded file, to match the result with the request.
+	File string `json:"file"`
+	// FileName is the stored name to use as image_name of POST /items.
+	FileName string `json:"filename,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	// ExpiresUnlessUsedBy is when the image becomes eligible for removal if no item uses it by then.
+	// It is omitted when the image is already used or images are never swept.
+	ExpiresUnlessUsedBy *time.Time `json:"expires_unless_used_by,omitempty"`
+	// Error is the reason when the file was rejected.
+	Error string `json:"error,omitempty"`
+}
+
+type UploadImagesResponse struct {
+	Images []UploadedImage `json:"images"`
+	// Failed is the number of rejected files.
+	Failed int `json:"failed"`
+}
+
+// ImageMeta is the response of GET /images/{filename}/meta.
+type ImageMeta struct {
+	FileName string `json:"filename"`
+	Size     int64  `json:"size"`
+	// Width and Height are omitted when the file cannot be decoded as a JPEG image.
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// Items is the number of items, including soft-deleted ones, using the image.
+	Items               int        `json:"items"`
+	ExpiresUnlessUsedBy *time.Time `json:"expires_unless_used_by,omitempty"`
+}
+
+// parseUploadImagesRequest returns the files of POST /images, at most maxUploadImages of them.
+func parseUploadImagesRequest(r *http.Request, maxImageBytes int64) ([]*multipart.FileHeader, error) {
+	r.Body = http.MaxBytesReader(nil, r.Body, maxUploadImages*maxImageBytes+formOverheadBytes)
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return nil, errors.New("content type must be multipart/form-data")
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, errImageTooLarge
+		}
+		return nil, fmt.Errorf("failed to parse multipart form: %w", err)
+	}
+	files := r.MultipartForm.File[uploadImagesField]
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s is required", uploadImagesField)
+	}
+	if len(files) > maxUploadImages {
+		return nil, errTooManyImages
+	}
+	return files, nil
+}
+
+// readUploadedImage reads and validates a file of POST /images: a .jpg or .jpeg JPEG image of at most maxImageBytes.
+func readUploadedImage(fh *multipart.FileHeader, maxImageBytes int64) ([]byte, error) {
+	name := strings.ToLower(fh.Filename)
+	if !strings.HasSuffix(name, ".jpg") && !strings.HasSuffix(name, ".jpeg") {
+		return nil, errors.New("must be a .jpg or .jpeg file")
+	}
+	file, err := fh.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open: %w", err)
+	}
+	defer file.Close()
+
+	// 上限+1バイトまで読んで、超えていたらエラー
+	data, err := io.ReadAll(io.LimitReader(file, maxImageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+	if int64(len(data)) > maxImageBytes {
+		return nil, fmt.Errorf("%w: the maximum is %d bytes", errImageTooLarge, maxImageBytes)
+	}
+	// 後からメタ情報で大きさを返せるように、JPEGとして読めるものだけ受け付ける
+	if _, err := jpeg.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return nil, errors.New("must be a JPEG image")
+	}
+	return data, nil
+}
+
+// imageExpiry returns when the image with the file info becomes eligible for sweepOrphanImages if refs items use it.
+// It returns nil when the image is used or images are never swept.
+func (s *Handlers) imageExpiry(info os.FileInfo, refs int) *time.Time {
+	if refs > 0 || s.flags.Duration(flagImageSweepInterval) <= 0 {
+		return nil
+	}
+	expiry := info.ModTime().Add(s.flags.Duration(flagImageSweepGrace)).UTC()
+	return &expiry
+}
+
+// storeUploadedImage stores a validated image of POST /images and fills in the result.
+func (s *Handlers) storeUploadedImage(ctx context.Context, result UploadedImage, data []byte) (UploadedImage, error) {
+	path, err := s.storeImage(data)
+	if err != nil {
+		return result, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return result, err
+	}
+	result.FileName = filepath.Base(path)
+	result.Size = info.Size()
+	// 同じ画像がすでに商品に使われていれば、消されることはない
+	refs, err := s.itemRepo.CountItemsUsingImage(ctx, result.FileName)
+	if err != nil {
+		return result, err
+	}
+	result.ExpiresUnlessUsedBy = s.imageExpiry(info, refs)
+	return result, nil
+}
+
+// UploadImages is a handler to store images without an item for POST /images .
+// The files are sent as the "images" field of multipart/form-data, up to maxUploadImages of them.
+// Each file is stored under its hash like the image of POST /items, and the stored name is given
+// to POST /items as image_name. Files failing validation are reported in the results and the others are still stored.
+func (s *Handlers) UploadImages(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	files, err := parseUploadImagesRequest(r, s.maxImageBytes())
+	if err != nil {
+		if errors.Is(err, errImageTooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(ctx, "parse")
+
+	resp := UploadImagesResponse{Images: make([]UploadedImage, 0, len(files))}
+	for _, fh := range files {
+		result := UploadedImage{File: fh.Filename}
+		data, err := readUploadedImage(fh, s.maxImageBytes())
+		if err != nil {
+			// 不正なファイルは黙って捨てずに、理由を返して残りを続ける
+			result.Error = err.Error()
+			resp.Failed++
+			resp.Images = append(resp.Images, result)
+			continue
+		}
+		result, err = s.storeUploadedImage(ctx, result, data)
+		if err != nil {
+			slog.Error("failed to store image: ", "error", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		resp.Images = append(resp.Images, result)
+	}
+	checkpoint(ctx, "image")
+	slog.Info("images received", "count", len(files)-resp.Failed, "failed", resp.Failed)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(ctx, "encode")
+}
+
+type GetImageMetaRequest struct {
+	FileName string // path value
+}
+
+func parseGetImageMetaRequest(r *http.Request) (*GetImageMetaRequest, error) {
+	req := &GetImageMetaRequest{
+		FileName: r.PathValue("filename"),
+	}
+	if req.FileName == "" {
+		return nil, errors.New("filename is required")
+	}
+	if err := checkParamLen("filename", req.FileName, maxImageNameLen); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// GetImageMeta is a handler to return the size, dimensions and references of a stored image
+// for GET /images/{filename}/meta . Unlike GET /images/{filename}, it returns 404 for a missing image.
+func (s *Handlers) GetImageMeta(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGetImageMetaRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	imgPath, err := s.buildImagePath(req.FileName)
+	if err != nil {
+		if errors.Is(err, errImageNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 縮小版などサブディレクトリのファイルは対象外
+	if filepath.Dir(imgPath) != filepath.Clean(s.imgDirPath) {
+		http.Error(w, "invalid image path: "+req.FileName, http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	meta, err := s.imageMeta(r.Context(), imgPath)
+	if err != nil {
+		slog.Error("failed to get image meta: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(meta); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
+
+// imageMeta returns the meta data of the stored image at imgPath.
+func (s *Handlers) imageMeta(ctx context.Context, imgPath string) (*ImageMeta, error) {
+	f, err := os.Open(imgPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	meta := &ImageMeta{FileName: filepath.Base(imgPath), Size: info.Size()}
+	// 以前は拡張子しか確かめていなかったので、JPEGとして読めない画像もありうる
+	if cfg, err := jpeg.DecodeConfig(f); err == nil {
+		meta.Width, meta.Height = cfg.Width, cfg.Height
+	} else {
+		slog.Warn("failed to decode image config: ", "path", imgPath, "error", err)
+	}
+
+	if meta.Items, err = s.itemRepo.CountItemsUsingImage(ctx, meta.FileName); err != nil {
+		return nil, err
+	}
+	// デフォルト画像は掃除されない
+	if meta.FileName != defaultImageName {
+		meta.ExpiresUnlessUsedBy = s.imageExpiry(info, meta.Items)
+	}
+	return meta, nil
+}
@@ -0,0 +1,264 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+)
+
+// newTestJPEG encodes a width x height JPEG image whose content depends on seed.
+func newTestJPEG(t *testing.T, width, height int, seed uint8) []byte {
+	t.Helper()
+
+	src := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		for y := range height {
+			src.Set(x, y, color.RGBA{seed, uint8(x), uint8(y), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// uploadFile is a file of POST /images.
+type uploadFile struct {
+	name string
+	data []byte
+}
+
+// newImagesUpload builds a multipart body with the files as the images field.
+func newImagesUpload(t *testing.T, files []uploadFile) (*bytes.Buffer, string) {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for _, f := range files {
+		fw, err := mw.CreateFormFile(uploadImagesField, f.name)
+		if err != nil {
+			t.Fatalf("failed to create form file: %v", err)
+		}
+		if _, err := fw.Write(f.data); err != nil {
+			t.Fatalf("failed to write image: %v", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("failed to close multipart writer: %v", err)
+	}
+	return body, mw.FormDataContentType()
+}
+
+func TestUploadImages(t *testing.T) {
+	t.Parallel()
+
+	small := newTestJPEG(t, 8, 8, 1)
+	other := newTestJPEG(t, 8, 8, 2)
+	// JPEGとして読めるが、上限を超える
+	large := append(newTestJPEG(t, 8, 8, 3), make([]byte, 1024)...)
+
+	// result is the expected outcome of a file: stored, or rejected with the error.
+	type result struct {
+		stored bool
+		error  string
+	}
+	cases := map[string]struct {
+		files   []uploadFile
+		code    int
+		results []result
+	}{
+		"ok: several files": {
+			files:   []uploadFile{{"a.jpg", small}, {"b.jpeg", other}},
+			code:    http.StatusOK,
+			results: []result{{stored: true}, {stored: true}},
+		},
+		"ok: some files are rejected": {
+			files: []uploadFile{
+				{"a.jpg", small},
+				{"b.png", other},
+				{"c.jpg", []byte("not an image")},
+				{"d.jpg", large},
+			},
+			code: http.StatusOK,
+			results: []result{
+				{stored: true},
+				{error: "must be a .jpg or .jpeg file"},
+				{error: "must be a JPEG image"},
+				{error: "image is too large: the maximum is 1024 bytes"},
+			},
+		},
+		"ng: no files": {
+			files: nil,
+			code:  http.StatusBadRequest,
+		},
+		"ng: too many files": {
+			files: func() []uploadFile {
+				files := make([]uploadFile, maxUploadImages+1)
+				for i := range files {
+					files[i] = uploadFile{fmt.Sprintf("%d.jpg", i), small}
+				}
+				return files
+			}(),
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			mockIR.EXPECT().CountItemsUsingImage(gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
+			dir := setupImageDir(t)
+			h := &Handlers{imgDirPath: dir, itemRepo: mockIR, MaxImageBytes: 1024}
+
+			body, contentType := newImagesUpload(t, tt.files)
+			req := httptest.NewRequest("POST", "/images", body)
+			req.Header.Set("Content-Type", contentType)
+			rr := httptest.NewRecorder()
+			h.UploadImages(rr, req)
+
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var resp UploadImagesResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(resp.Images) != len(tt.results) {
+				t.Fatalf("expected %d results, got %d: %+v", len(tt.results), len(resp.Images), resp.Images)
+			}
+			failed := 0
+			for i, want := range tt.results {
+				got := resp.Images[i]
+				if got.File != tt.files[i].name {
+					t.Errorf("result %d: expected file %q, got %q", i, tt.files[i].name, got.File)
+				}
+				if !want.stored {
+					failed++
+					if got.Error != want.error || got.FileName != "" {
+						t.Errorf("result %d: expected error %q, got %+v", i, want.error, got)
+					}
+					continue
+				}
+				// 保存した画像は、image_name としてそのまま使える
+				info, err := os.Stat(filepath.Join(dir, got.FileName))
+				if err != nil {
+					t.Fatalf("result %d: expected the image to be stored: %v", i, err)
+				}
+				if got.Size != info.Size() || got.Error != "" {
+					t.Errorf("result %d: unexpected result %+v", i, got)
+				}
+				want := info.ModTime().Add(defaultImageSweepGrace)
+				if got.ExpiresUnlessUsedBy == nil || !got.ExpiresUnlessUsedBy.Equal(want) {
+					t.Errorf("result %d: expected expires_unless_used_by %v, got %v", i, want, got.ExpiresUnlessUsedBy)
+				}
+			}
+			if resp.Failed != failed {
+				t.Errorf("expected %d failed, got %d", failed, resp.Failed)
+			}
+		})
+	}
+}
+
+func TestImageLibraryE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: &itemRepository{db: db}}
+	mux := newMux(h.routes())
+
+	// 画像だけを先にアップロードする
+	body, contentType := newImagesUpload(t, []uploadFile{{"photo.jpg", newTestJPEG(t, 40, 30, 1)}})
+	req := httptest.NewRequest("POST", "/images", body)
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("upload: expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var uploaded UploadImagesResponse
+	if err := json.NewDecoder(rr.Body).Decode(&uploaded); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(uploaded.Images) != 1 || uploaded.Images[0].FileName == "" {
+		t.Fatalf("expected one stored image, got %+v", uploaded.Images)
+	}
+	stored := uploaded.Images[0]
+
+	getMeta := func(filename string) (int, ImageMeta) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/images/"+filename+"/meta", nil))
+		var meta ImageMeta
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&meta); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return rr.Code, meta
+	}
+
+	code, meta := getMeta(stored.FileName)
+	if code != http.StatusOK {
+		t.Fatalf("meta: expected status code %d, got %d", http.StatusOK, code)
+	}
+	if meta.FileName != stored.FileName || meta.Size != stored.Size || meta.Width != 40 || meta.Height != 30 || meta.Items != 0 {
+		t.Errorf("unexpected meta of the uploaded image: %+v", meta)
+	}
+	if meta.ExpiresUnlessUsedBy == nil || !meta.ExpiresUnlessUsedBy.Equal(*stored.ExpiresUnlessUsedBy) {
+		t.Errorf("expected expires_unless_used_by %v, got %v", stored.ExpiresUnlessUsedBy, meta.ExpiresUnlessUsedBy)
+	}
+
+	// image_name で商品に付けると、参照が数えられて期限がなくなる
+	item := `{"name":"jacket","category":"fashion","price":3000,"image_name":"` + stored.FileName + `"}`
+	req = httptest.NewRequest("POST", "/items", strings.NewReader(item))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("add item: expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	code, meta = getMeta(stored.FileName)
+	if code != http.StatusOK {
+		t.Fatalf("meta: expected status code %d, got %d", http.StatusOK, code)
+	}
+	if meta.Items != 1 || meta.ExpiresUnlessUsedBy != nil {
+		t.Errorf("expected the image used by 1 item without expiry, got %+v", meta)
+	}
+
+	if code, _ := getMeta("missing.jpg"); code != http.StatusNotFound {
+		t.Errorf("unknown image: expected status code %d, got %d", http.StatusNotFound, code)
+	}
+	if code, _ := getMeta("notes.txt"); code != http.StatusBadRequest {
+		t.Errorf("not an image: expected status code %d, got %d", http.StatusBadRequest, code)
+	}
+}
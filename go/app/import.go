@@ -0,0 +1,269 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Columns of the CSV of POST /items/import. name and category are required, price and image_url are optional.
+const (
+	importColumnName     = "name"
+	importColumnCategory = "category"
+	importColumnPrice    = "price"
+	importColumnImageURL = "image_url"
+)
+
+// ImportRowResult is the outcome of a row of POST /items/import.
+type ImportRowResult struct {
+	// Line is the line number of the row in the CSV, starting at 1 for the header.
+	Line int `json:"line"`
+	// ID is the assigned id when the row was inserted. It is 0 for a dry run.
+	ID int `json:"id,omitempty"`
+	// Error is the reason when the row was rejected.
+	Error string `json:"error,omitempty"`
+	// Fields are the problems with the fields when the row failed validation like POST /items/bulk.
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// ImportRowError is a row of POST /items/import that was skipped.
+type ImportRowError struct {
+	Line   int          `json:"line"`
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+type ImportItemsResponse struct {
+	DryRun   bool `json:"dry_run"`
+	Inserted int  `json:"inserted"`
+	// Skipped is the number of rows rejected by validation, which are listed in Errors.
+	Skipped int              `json:"skipped"`
+	Errors  []ImportRowError `json:"errors"`
+	// Results has an entry for each row of the CSV.
+	Results []ImportRowResult `json:"results"`
+}
+
+// importRow is a row of the CSV, converted to a bulk item unless err is set.
+type importRow struct {
+	line int
+	item BulkItem
+	err  error
+}
+
+// openImportBody returns the CSV of the request, either the body of text/csv or the "file" part of multipart/form-data.
+func openImportBody(r *http.Request) (io.ReadCloser, error) {
+	contentType := r.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get file: %w", err)
+		}
+		return file, nil
+	}
+	if !strings.HasPrefix(contentType, "text/csv") {
+		return nil, errors.New("content type must be text/csv or multipart/form-data")
+	}
+	return io.NopCloser(r.Body), nil
+}
+
+// parseImportCSV reads the header and the rows of the CSV, stopping as soon as there are more than maxRows rows.
+// Rows that cannot be read or converted are returned with an error instead of failing the whole import.
+func parseImportCSV(body io.Reader, maxRows int) ([]importRow, error) {
+	cr := csv.NewReader(body)
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("header row is required")
+		}
+		return nil, fmt.Errorf("failed to read header row: %w", err)
+	}
+
+	// 列の順番は自由だが、知らない列や重複した列は受け付けない
+	// Excelが付けるBOMや、大文字の列名はそのまま受け付ける
+	columns := map[string]int{}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case importColumnName, importColumnCategory, importColumnPrice, importColumnImageURL:
+		default:
+			return nil, fmt.Errorf("unknown column %q in header row", name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("duplicate column %q in header row", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{importColumnName, importColumnCategory} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("column %q is required in header row", name)
+		}
+	}
+	// 列数が合わない行はcsv.ErrFieldCountになる
+	cr.FieldsPerRecord = len(header)
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	var rows []importRow
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if len(rows) == maxRows {
+			return nil, fmt.Errorf("%w: the maximum is %d rows", errTooManyBulkItems, maxRows)
+		}
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return nil, fmt.Errorf("%w: the maximum is %d rows", errTooManyBulkItems, maxRows)
+			}
+			// 壊れた行があっても、次の行から読み続ける
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("failed to read CSV: %w", err)
+			}
+			rows = append(rows, importRow{line: parseErr.StartLine, err: parseErr.Err})
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+
+		row := importRow{line: line, item: BulkItem{
+			Name:     field(record, importColumnName),
+			Category: field(record, importColumnCategory),
+		}}
+		if v := strings.TrimSpace(field(record, importColumnPrice)); v != "" {
+			if price, err := strconv.Atoi(v); err != nil {
+				row.err = fmt.Errorf("%w: %q is not an integer", errInvalidPrice, v)
+			} else {
+				row.item.Price = &price
+			}
+		}
+		if row.err == nil {
+			row.item.ImageName, row.err = importImageName(field(record, importColumnImageURL))
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("rows are required")
+	}
+
+	return rows, nil
+}
+
+// importImageName converts image_url to the name of a stored image.
+// Only images already stored on this server are accepted, as a file name or as /images/{filename}.
+// 任意のURLをサーバーから取りに行くとSSRFの危険があるので、外部のURLは受け付けない
+func importImageName(imageURL string) (string, error) {
+	imageURL = strings.TrimSpace(imageURL)
+	if strings.Contains(imageURL, "://") {
+		return "", fmt.Errorf("image_url %q: remote images are not supported, upload the image first", imageURL)
+	}
+	return strings.TrimPrefix(imageURL, "/images/"), nil
+}
+
+// ImportItems is a handler to add items from a CSV for POST /items/import .
+// Rows failing validation are reported with their line numbers and the others are inserted in a single transaction.
+// With dry_run=true, the rows are only validated.
+func (s *Handlers) ImportItems(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	q, err := parseQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRunParam, err := queryParam(q, "dry_run", maxShortParamLen)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun := false
+	if dryRunParam != "" {
+		if dryRun, err = strconv.ParseBool(dryRunParam); err != nil {
+			http.Error(w, fmt.Sprintf("invalid dry_run: %s", dryRunParam), http.StatusBadRequest)
+			return
+		}
+	}
+
+	maxRows := s.maxBulkItems()
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxRows)*maxBulkItemBytes)
+	body, err := openImportBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer body.Close()
+	rows, err := parseImportCSV(body, maxRows)
+	if err != nil {
+		if errors.Is(err, errTooManyBulkItems) {
+			writeTooManyRows(w, maxRows)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	actor, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(ctx, "parse")
+
+	resp := ImportItemsResponse{DryRun: dryRun, Errors: []ImportRowError{}, Results: make([]ImportRowResult, len(rows))}
+	items := make([]*Item, 0, len(rows))
+	// indexes[i] is the index in rows of items[i].
+	indexes := make([]int, 0, len(rows))
+	for idx, row := range rows {
+		resp.Results[idx].Line = row.line
+		err := row.err
+		var item *Item
+		if err == nil {
+			item, err = s.validateBulkItem(row.item)
+		}
+		if err != nil {
+			fields := validationFields(err)
+			resp.Results[idx].Error, resp.Results[idx].Fields = err.Error(), fields
+			resp.Errors = append(resp.Errors, ImportRowError{Line: row.line, Error: err.Error(), Fields: fields})
+			resp.Skipped++
+			continue
+		}
+		items = append(items, item)
+		indexes = append(indexes, idx)
+	}
+
+	if !dryRun && len(items) > 0 {
+		if err := s.itemRepo.InsertMany(withActor(ctx, actor), items); err != nil {
+			slog.Error("failed to import items: ", "error", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		s.setCategoriesVersionHeader(ctx, w)
+		for i, item := range items {
+			resp.Results[indexes[i]].ID = item.ID
+		}
+		resp.Inserted = len(items)
+	}
+	checkpoint(ctx, "db")
+	slog.Info("items imported", "inserted", resp.Inserted, "skipped", resp.Skipped, "dry_run", dryRun)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(ctx, "encode")
+}
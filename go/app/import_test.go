@@ -0,0 +1,285 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.uber.org/mock/gomock"
+)
+
+func TestImportItems(t *testing.T) {
+	t.Parallel()
+
+	// assignIDs makes InsertMany assign ids from 10 and expects n items.
+	assignIDs := func(n int) func(m *MockItemRepository) {
+		return func(m *MockItemRepository) {
+			m.EXPECT().InsertMany(gomock.Any(), gomock.Len(n)).DoAndReturn(func(_ context.Context, items []*Item) error {
+				for i, item := range items {
+					item.ID = 10 + i
+				}
+				return nil
+			})
+			m.EXPECT().CategoriesVersion(gomock.Any()).Return(int64(1), nil)
+		}
+	}
+
+	type wants struct {
+		code int
+		resp *ImportItemsResponse
+	}
+	cases := map[string]struct {
+		target      string
+		contentType string
+		body        string
+		injector    func(m *MockItemRepository)
+		wants
+	}{
+		"ok: all rows are valid": {
+			body: "category,name,price,image_url\n" +
+				"fashion,jacket,3000,\n" +
+				"fashion,\"denim \"\"vintage\"\", blue\",,/images/default.jpg\n",
+			injector: assignIDs(2),
+			wants: wants{
+				code: http.StatusOK,
+				resp: &ImportItemsResponse{Inserted: 2, Errors: []ImportRowError{}, Results: []ImportRowResult{{Line: 2, ID: 10}, {Line: 3, ID: 11}}},
+			},
+		},
+		"ok: invalid rows are reported with line numbers": {
+			body: "name,category,price,image_url\n" +
+				"jacket,fashion,3000,\n" +
+				",fashion,100,\n" +
+				"hat,fashion,free,\n" +
+				"shirt,fashion\n" +
+				"bag,fashion,100,https://example.com/bag.jpg\n" +
+				"\"multi\nline\",fashion,100,default.jpg\n",
+			injector: assignIDs(1),
+			wants: wants{
+				code: http.StatusOK,
+				resp: &ImportItemsResponse{Inserted: 1, Skipped: 5, Errors: []ImportRowError{
+					{Line: 3, Error: "name: required", Fields: []FieldError{{Field: "name", Message: "required"}}},
+					{Line: 4, Error: `invalid price: "free" is not an integer`},
+					{Line: 5, Error: "wrong number of fields"},
+					{Line: 6, Error: `image_url "https://example.com/bag.jpg": remote images are not supported, upload the image first`},
+					{Line: 7, Error: "name: must not contain control characters", Fields: []FieldError{{Field: "name", Message: "must not contain control characters"}}},
+				}, Results: []ImportRowResult{
+					{Line: 2, ID: 10},
+					{Line: 3, Error: "name: required", Fields: []FieldError{{Field: "name", Message: "required"}}},
+					{Line: 4, Error: `invalid price: "free" is not an integer`},
+					{Line: 5, Error: "wrong number of fields"},
+					{Line: 6, Error: `image_url "https://example.com/bag.jpg": remote images are not supported, upload the image first`},
+					// 引用符の中の改行は読めるが、POST /items と同じく名前には使えない
+					{Line: 7, Error: "name: must not contain control characters", Fields: []FieldError{{Field: "name", Message: "must not contain control characters"}}},
+				}},
+			},
+		},
+		"ok: rows rejected like POST /items": {
+			body: "name,category,price\n" +
+				strings.Repeat("a", maxItemNameChars+1) + ",fashion,100\n" +
+				"jack\tet,\" \",100\n" +
+				"jacket,fashion,-1\n" +
+				"coat,Fashion,100\n",
+			injector: assignIDs(1),
+			wants: wants{
+				code: http.StatusOK,
+				resp: &ImportItemsResponse{Inserted: 1, Skipped: 3, Errors: []ImportRowError{
+					{Line: 2, Error: "name: must be at most 120 characters", Fields: []FieldError{{Field: "name", Message: "must be at most 120 characters"}}},
+					{Line: 3, Error: "name: must not contain control characters; category: required", Fields: []FieldError{
+						{Field: "name", Message: "must not contain control characters"},
+						{Field: "category", Message: "required"},
+					}},
+					{Line: 4, Error: "price: must be a non-negative integer", Fields: []FieldError{{Field: "price", Message: "must be a non-negative integer"}}},
+				}, Results: []ImportRowResult{
+					{Line: 2, Error: "name: must be at most 120 characters", Fields: []FieldError{{Field: "name", Message: "must be at most 120 characters"}}},
+					{Line: 3, Error: "name: must not contain control characters; category: required", Fields: []FieldError{
+						{Field: "name", Message: "must not contain control characters"},
+						{Field: "category", Message: "required"},
+					}},
+					{Line: 4, Error: "price: must be a non-negative integer", Fields: []FieldError{{Field: "price", Message: "must be a non-negative integer"}}},
+					{Line: 5, ID: 10},
+				}},
+			},
+		},
+		"ok: header with BOM and capitals": {
+			body:     "\ufeffName,Category\njacket,fashion\n",
+			injector: assignIDs(1),
+			wants: wants{
+				code: http.StatusOK,
+				resp: &ImportItemsResponse{Inserted: 1, Errors: []ImportRowError{}, Results: []ImportRowResult{{Line: 2, ID: 10}}},
+			},
+		},
+		"ok: malformed quotes": {
+			body:     "name,category\njacket,fashion\n\"hat,fashion\nshirt\",fashion\nbag,\"fash\"ion\"\ncoat,fashion\n",
+			injector: assignIDs(2),
+			wants: wants{
+				code: http.StatusOK,
+				resp: &ImportItemsResponse{Inserted: 2, Skipped: 2, Errors: []ImportRowError{
+					{Line: 3, Error: "name: must not contain control characters", Fields: []FieldError{{Field: "name", Message: "must not contain control characters"}}},
+					{Line: 5, Error: `extraneous or missing " in quoted-field`},
+				}, Results: []ImportRowResult{
+					{Line: 2, ID: 10},
+					{Line: 3, Error: "name: must not contain control characters", Fields: []FieldError{{Field: "name", Message: "must not contain control characters"}}},
+					{Line: 5, Error: `extraneous or missing " in quoted-field`},
+					{Line: 6, ID: 11},
+				}},
+			},
+		},
+		"ok: dry run": {
+			target:   "/items/import?dry_run=true",
+			body:     "name,category\njacket,fashion\n,fashion\n",
+			injector: func(m *MockItemRepository) {},
+			wants: wants{
+				code: http.StatusOK,
+				resp: &ImportItemsResponse{DryRun: true, Skipped: 1, Errors: []ImportRowError{{Line: 3, Error: "name: required", Fields: []FieldError{{Field: "name", Message: "required"}}}}, Results: []ImportRowResult{{Line: 2}, {Line: 3, Error: "name: required", Fields: []FieldError{{Field: "name", Message: "required"}}}}},
+			},
+		},
+		"ng: unknown column": {
+			body:     "name,category,color\njacket,fashion,red\n",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{code: http.StatusBadRequest},
+		},
+		"ng: missing required column": {
+			body:     "name,price\njacket,100\n",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{code: http.StatusBadRequest},
+		},
+		"ng: no rows": {
+			body:     "name,category\n",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{code: http.StatusBadRequest},
+		},
+		"ng: too many rows": {
+			body:     "name,category\n" + strings.Repeat("jacket,fashion\n", 7),
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{code: http.StatusBadRequest},
+		},
+		"ng: not csv": {
+			contentType: "application/json",
+			body:        `[{"name":"jacket","category":"fashion"}]`,
+			injector:    func(m *MockItemRepository) {},
+			wants:       wants{code: http.StatusBadRequest},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			tt.injector(mockIR)
+			h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: mockIR, MaxBulkItems: 6}
+
+			target := tt.target
+			if target == "" {
+				target = "/items/import"
+			}
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "text/csv"
+			}
+			req := httptest.NewRequest("POST", target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", contentType)
+			rr := httptest.NewRecorder()
+			h.ImportItems(rr, req)
+
+			if rr.Code != tt.wants.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.wants.code, rr.Code, rr.Body.String())
+			}
+			if tt.wants.resp == nil {
+				return
+			}
+			var resp ImportItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if diff := cmp.Diff(*tt.wants.resp, resp, cmpopts.IgnoreUnexported(FieldError{})); diff != "" {
+				t.Errorf("unexpected response (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestImportItemsMaxRows(t *testing.T) {
+	t.Parallel()
+
+	flags := NewFlags(flagSpecs)
+	flags.SeedFromEnv(func(key string) (string, bool) {
+		if key == "MAX_IMPORT_ROWS" {
+			return "2", true
+		}
+		return "", false
+	})
+	// 上限を超えたら、1行も検証・挿入せずに断る
+	ctrl := gomock.NewController(t)
+	h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: NewMockItemRepository(ctrl), flags: flags}
+
+	req := httptest.NewRequest("POST", "/items/import", strings.NewReader("name,category\n"+strings.Repeat("jacket,fashion\n", 3)))
+	req.Header.Set("Content-Type", "text/csv")
+	rr := httptest.NewRecorder()
+	h.ImportItems(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+	want := "import exceeds maximum of 2 rows; split it into chunks of at most 2 rows\n"
+	if got := rr.Body.String(); got != want {
+		t.Errorf("expected response body %q, got %q", want, got)
+	}
+}
+
+func TestImportItemsE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+	repo := &itemRepository{db: db}
+	h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: repo}
+
+	// スプレッドシートから書き出したファイルをmultipartでアップロードする
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	fw, err := mw.CreateFormFile("file", "items.csv")
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	fw.Write([]byte("name,category,price\njacket,fashion,3000\n,fashion,100\niPhone,phone,50000\n"))
+	if err := mw.Close(); err != nil {
+		t.Fatalf("failed to close multipart writer: %v", err)
+	}
+	req := httptest.NewRequest("POST", "/items/import", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rr := httptest.NewRecorder()
+	h.ImportItems(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	items, err := repo.GetAll(t.Context(), ItemListOptions{})
+	if err != nil {
+		t.Fatalf("failed to get items: %v", err)
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, item.Name+"/"+item.Category)
+	}
+	if diff := cmp.Diff([]string{"jacket/fashion", "iPhone/phone"}, got); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+}
@@ -2,24 +2,246 @@ package app
 
 import (
 	"context"
+	"crypto/rand"
 
 	"database/sql"
 	"errors"
+	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 var errImageNotFound = errors.New("image not found")
 var errItemNotFound = errors.New("item not found")
+var errImageTooLarge = errors.New("image is too large")
+var errInvalidItemStatus = errors.New("invalid item status")
+var errInvalidItemCondition = errors.New("invalid item condition")
+var errItemSold = errors.New("item is already sold")
+var errInvalidPrice = errors.New("invalid price")
+var errInvalidQuantity = errors.New("invalid quantity")
+var errInvalidRequestBody = errors.New("invalid request body")
+var errCategoryNotFound = errors.New("category not found")
+var errFallbackCategory = errors.New("the fallback category cannot be deleted")
+
+// Item statuses. A sold item is kept so that it can still be shown greyed out.
+const (
+	itemStatusOnSale = "on_sale"
+	itemStatusSold   = "sold"
+)
+
+// Item conditions, from the best to the worst. Items added without one are used.
+const (
+	itemConditionNew     = "new"
+	itemConditionLikeNew = "like_new"
+	itemConditionUsed    = "used"
+	itemConditionJunk    = "junk"
+)
+
+// itemConditions are the valid item conditions in the order shown to clients.
+var itemConditions = []string{itemConditionNew, itemConditionLikeNew, itemConditionUsed, itemConditionJunk}
+
+// validateItemCondition returns errInvalidItemCondition unless condition is a known item condition.
+func validateItemCondition(condition string) error {
+	if slices.Contains(itemConditions, condition) {
+		return nil
+	}
+	return fmt.Errorf("%w: %q (must be one of %s)", errInvalidItemCondition, condition, strings.Join(itemConditions, ", "))
+}
+
+// defaultSeller is the seller of items added without one.
+const defaultSeller = "anonymous"
+
+// validateItemStatus returns errInvalidItemStatus unless status is a known item status.
+func validateItemStatus(status string) error {
+	switch status {
+	case itemStatusOnSale, itemStatusSold:
+		return nil
+	}
+	return fmt.Errorf("%w: %q (must be %s or %s)", errInvalidItemStatus, status, itemStatusOnSale, itemStatusSold)
+}
 
 type Item struct {
 	ID       int    `db:"id" json:"id"`
 	Name     string `db:"name" json:"name"`
 	Category string `json:"category"`
 	Image    string `db:"image_name" json:"image_name"`
+	Status   string `db:"status" json:"status"`
+	// Price is the amount in the minor unit of its currency. An integer avoids floating point rounding.
+	Price Price `json:"price"`
+	// SortOrder is the position in the manual order (sort=manual). nil means unordered, placed last.
+	SortOrder *int      `db:"sort_order" json:"sort_order"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	// DeletedAt is set when the item is soft-deleted.
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+	// FavoritesCount is the number of clients that favorited the item.
+	FavoritesCount int `json:"favorites_count"`
+	// ViewCount is the number of times the item detail was fetched. Recent views may not be flushed yet.
+	ViewCount int `db:"view_count" json:"view_count"`
+	// Seller is the id of the user selling the item.
+	Seller string `db:"seller" json:"seller"`
+	// Tags are the lowercase tags of the item in alphabetical order.
+	Tags []string `json:"tags"`
+	// Condition is one of itemConditions.
+	Condition string `db:"condition" json:"condition"`
+	// Quantity is the number left in stock. Each purchase takes one, and the item is sold when it reaches zero.
+	Quantity int `db:"quantity" json:"quantity"`
+	// Brand is the normalized brand, or empty if none.
+	Brand string `db:"brand" json:"brand"`
+	// ShippingPayer is one of shippingPayers.
+	ShippingPayer string `db:"shipping_payer" json:"shipping_payer"`
+	// ShippingDays is the number of days within which the item is shipped.
+	ShippingDays int `db:"shipping_days" json:"shipping_days"`
+	// Draft is true until the item is published. Drafts are left out of the lists and the search,
+	// but can be fetched by id. Items are published unless added as drafts.
+	Draft bool `json:"draft"`
+}
+
+// itemColumns is the column list shared by the queries returning Item.
+// The order must match scanItem.
+const itemColumns = `
+	items.id,
+	items.name,
+	categories.name AS category,
+	items.image_name,
+	items.status,
+	items.price,
+	items.price_currency,
+	items.sort_order,
+	items.created_at,
+	items.updated_at,
+	items.deleted_at,
+	items.view_count,
+	items.seller,
+	items.condition,
+	items.quantity,
+	items.brand,
+	items.shipping_payer,
+	items.shipping_days,
+	items.is_published = 0 AS draft,
+	(SELECT GROUP_CONCAT(tags.name) FROM item_tags INNER JOIN tags ON item_tags.tag_id = tags.id WHERE item_tags.item_id = items.id) AS tags,
+	(SELECT COUNT(*) FROM favorites WHERE favorites.item_id = items.id) AS favorites_count`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanItem scans a row selected with itemColumns into an Item.
+func scanItem(row rowScanner) (Item, error) {
+	var item Item
+	var sortOrder sql.NullInt64
+	var createdAt, updatedAt, deletedAt, tags sql.NullString
+	err := row.Scan(&item.ID, &item.Name, &item.Category, &item.Image, &item.Status, &item.Price.Amount, &item.Price.Currency, &sortOrder, &createdAt, &updatedAt, &deletedAt, &item.ViewCount, &item.Seller, &item.Condition, &item.Quantity, &item.Brand, &item.ShippingPayer, &item.ShippingDays, &item.Draft, &tags, &item.FavoritesCount)
+	if err != nil {
+		return Item{}, err
+	}
+	item.Tags = splitTags(tags.String)
+	if sortOrder.Valid {
+		order := int(sortOrder.Int64)
+		item.SortOrder = &order
+	}
+	if item.CreatedAt, err = parseTimestamp(createdAt); err != nil {
+		return Item{}, err
+	}
+	if item.UpdatedAt, err = parseTimestamp(updatedAt); err != nil {
+		return Item{}, err
+	}
+	if deletedAt.Valid {
+		t, err := parseTimestamp(deletedAt)
+		if err != nil {
+			return Item{}, err
+		}
+		item.DeletedAt = &t
+	}
+	return item, nil
+}
+
+// timestampLayout is the format of the timestamps stored in the database.
+// 秒単位のUTCに揃えることで、文字列のままでも時刻順に並べ替えられる
+const timestampLayout = time.RFC3339
+
+// formatTimestamp converts t to the stored timestamp format.
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// parseTimestamp parses a stored timestamp. NULL becomes the zero time.
+func parseTimestamp(s sql.NullString) (time.Time, error) {
+	if !s.Valid || s.String == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(timestampLayout, s.String)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: %w", s.String, err)
+	}
+	return t, nil
+}
+
+// Clock abstracts the current time so that tests can freeze it.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// IDGenerator abstracts the generation of unique ids so that tests can fix them.
+type IDGenerator interface {
+	NewID() string
+}
+
+// randomIDGenerator generates random ids of 26 base32 characters (128 bits).
+type randomIDGenerator struct{}
+
+func (randomIDGenerator) NewID() string { return rand.Text() }
+
+// Item orderings accepted by GetAll.
+const (
+	sortByID        = ""
+	sortByCreatedAt = "created_at"
+	sortByManual    = "manual"
+	// sortByNameJa orders by name in the Japanese collation order.
+	sortByNameJa = "name_ja"
+)
+
+// ItemListOptions holds the filters and ordering used by GetAll.
+type ItemListOptions struct {
+	// Sort is the ordering of the items. The zero value orders by id.
+	Sort string
+	// IncludeDeleted includes soft-deleted items.
+	IncludeDeleted bool
+	// IncludeDrafts includes items that are not published yet.
+	IncludeDrafts bool
+	// Status filters the items by status. Empty means all statuses.
+	Status string
+	// Seller filters the items by seller. Empty means all sellers.
+	Seller string
+	// Brand filters the items by normalized brand, ignoring case. Empty means all brands.
+	Brand string
+	// Currency filters the items by price currency. Empty means all currencies.
+	Currency string
+	// Tag filters the items having the tag. Empty means all items.
+	Tag string
+	// Condition filters the items by condition. Empty means all conditions.
+	Condition string
+	// ShippingPayer filters the items by who pays the shipping. Empty means both.
+	ShippingPayer string
+	// IDs limits the items to the given ids and orders them as given, overriding Sort.
+	// Ids that do not exist are ignored. Empty means all items.
+	IDs []int
+	// Limit is the maximum number of items to return. 0 means no limit.
+	Limit int
+	// Offset is the number of items to skip before the first one returned.
+	Offset int
 }
 
 // item操作に関するメソッドを抽象化して定義している
@@ -31,35 +253,116 @@ type Item struct {
 // https://zenn.dev/logica0419/articles/understanding-go-interface
 type ItemRepository interface {
 	Insert(ctx context.Context, item *Item) error
-	GetAll(ctx context.Context) ([]Item, error)
+	InsertMany(ctx context.Context, items []*Item) error
+	GetAll(ctx context.Context, opts ItemListOptions) ([]Item, error)
+	Count(ctx context.Context, opts ItemListOptions) (int, error)
+	GetPage(ctx context.Context, opts ItemListOptions) ([]Item, int, error)
+	GetItemsAfter(ctx context.Context, afterID, limit int) ([]Item, error)
 	GetItemById(ctx context.Context, item_id string) (Item, error)
-	SearchItemsByKeyword(ctx context.Context, keyword string) ([]Item, error)
+	GetItemByImage(ctx context.Context, imageName string) (Item, error)
+	CountItemsUsingImage(ctx context.Context, imageName string) (int, error)
+	GetImageNames(ctx context.Context) ([]string, error)
+	GetCategoryItems(ctx context.Context, category string, excludeID, limit int) ([]Item, error)
+	GetRelatedItems(ctx context.Context, item_id string, limit int) ([]Item, error)
+	GetItemsBySeller(ctx context.Context, seller string) ([]Item, error)
+	GetRecentItems(ctx context.Context, limit int) ([]Item, error)
+	SearchItemsByKeyword(ctx context.Context, filter SearchFilter, fn func(Item) error) error
+	CountItemsByKeyword(ctx context.Context, filter SearchFilter) (int, error)
+	CheckCategoryHealth(ctx context.Context) ([]HealthIssue, error)
+	SoftDelete(ctx context.Context, item_id string) error
+	Restore(ctx context.Context, item_id string) error
+	Publish(ctx context.Context, item_id string) error
+	Purge(ctx context.Context, item_id string) (unusedImage string, err error)
+	Ping(ctx context.Context) error
+	SampleByCategory(ctx context.Context, perCategory int) ([]Item, error)
+	Purchase(ctx context.Context, item_id string) error
+	Update(ctx context.Context, item_id string, patch ItemPatch) (before, after Item, err error)
+	GetItemHistory(ctx context.Context, item_id string) ([]ItemEvent, error)
+	AddFavorite(ctx context.Context, item_id string, clientToken string) error
+	RemoveFavorite(ctx context.Context, item_id string, clientToken string) error
+	GetFavorites(ctx context.Context, clientToken string) ([]Item, error)
+	AddViews(ctx context.Context, views map[int]int) error
+	Reorder(ctx context.Context, ids []int) error
+	Swap(ctx context.Context, a, b int) error
+	GetCategories(ctx context.Context) ([]Category, int64, error)
+	DeleteCategory(ctx context.Context, id int, fallback string) (int, error)
+	CategoriesVersion(ctx context.Context) (int64, error)
+}
+
+// HealthIssue kinds reported by CheckCategoryHealth.
+const (
+	healthIssueOrphanedItem  = "orphaned_item"
+	healthIssueEmptyCategory = "empty_category"
+)
+
+// HealthIssue is an inconsistency between the items and categories tables.
+type HealthIssue struct {
+	Kind string
+	ID   int
 }
 
 type itemRepository struct {
 	db *sql.DB
+	// clock is used for created_at/updated_at. nil means the real clock.
+	clock Clock
+	// fts is true when the SQLite build supports FTS5 and items_fts is set up.
+	fts bool
+	// collation is true when the connections have the ja collation. Otherwise names are sorted in Go.
+	collation bool
+	// duplicateWindow makes Insert reject an item identical to one created within this duration. 0 disables the check.
+	duplicateWindow time.Duration
+}
+
+// ItemRepositoryOptions configures NewItemRepository.
+type ItemRepositoryOptions struct {
+	// DuplicateWindow makes Insert return a *DuplicateItemError for an item with the same name, category
+	// and image as one created within this duration. 0 disables the check.
+	DuplicateWindow time.Duration
+}
+
+// now returns the current time from the repository's clock.
+func (i *itemRepository) now() time.Time {
+	if i.clock == nil {
+		return time.Now()
+	}
+	return i.clock.Now()
 }
 
 // 返り値を増やした
 // -> server.goのRun()でNewItemRepositoryのerrを検知できずに
 // nilのitemRepoを使用したことによるnil参照panicを防ぐ
 // NewItemRepositoryでデータベースの初期化に失敗した場合に、nilのitemRepoが使用されることを防ぐ
-func NewItemRepository(db *sql.DB) (ItemRepository, error) {
+func NewItemRepository(db *sql.DB, opts ItemRepositoryOptions) (ItemRepository, error) {
 	// items tableがなかったら作成
 	q, err := os.ReadFile("db/items.sql")
 	if err != nil {
 		return &itemRepository{}, err
 	}
 
-	query := string(q)
-	_, err = db.Exec(query)
+	repo := &itemRepository{db: db, clock: realClock{}, duplicateWindow: opts.DuplicateWindow}
+	err = initSchema(db, string(q), repo.now())
 	if err != nil {
 		slog.Error("failed to create items table and categories table", "error", err)
 		return nil, err
 	}
+	repo.fts, err = setupFTS(db)
+	if err != nil {
+		slog.Error("failed to set up full-text search", "error", err)
+		return nil, err
+	}
+	if !repo.fts {
+		slog.Warn("SQLite is built without FTS5, searching with LIKE")
+	}
+	repo.collation, err = hasJaCollation(db)
+	if err != nil {
+		return nil, err
+	}
+	if !repo.collation {
+		slog.Warn("the database has no ja collation, sorting names in Go")
+	}
 
 	// データベース接続情報(db)を持つitemRepository構造体のインスタンスを作成し、そのポインタをItemRepositoryインターフェース型として返す。
-	return &itemRepository{db: db}, nil
+	return repo, nil
 }
 
 func (i *itemRepository) Insert(ctx context.Context, item *Item) error {
@@ -70,53 +373,305 @@ func (i *itemRepository) Insert(ctx context.Context, item *Item) error {
 		return err
 	}
 	defer tx.Rollback()
+	traceQuery(ctx, "items.insert")
 
-	// カテゴリが既に存在するか確認
-	var categoryID int64
-	err = tx.QueryRowContext(ctx, "SELECT id FROM categories WHERE name = ?", item.Category).Scan(&categoryID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			// カテゴリが存在しない場合は挿入
-			_, err = tx.ExecContext(ctx, "INSERT INTO categories (name) VALUES (?)", item.Category)
-			if err != nil {
-				return err
-			}
-			// 挿入したカテゴリのIDを取得
-			err = tx.QueryRowContext(ctx, "SELECT id FROM categories WHERE name = ?", item.Category).Scan(&categoryID)
-			if err != nil {
-				return err
-			}
-		} else {
+	now := i.now().UTC().Truncate(time.Second)
+	// 確認と挿入を同じトランザクションで行う
+	if since, ok := duplicateSince(ctx, now, i.duplicateWindow); ok {
+		if err := findDuplicateTx(ctx, tx, item, since); err != nil {
 			return err
 		}
 	}
+	if err := insertItemTx(ctx, tx, item, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	item.CreatedAt = now
+	item.UpdatedAt = now
+	return nil
+}
+
+// InsertMany inserts the items in a single transaction, so that either all of them are inserted or none are.
+// The assigned ids are set to the items.
+func (i *itemRepository) InsertMany(ctx context.Context, items []*Item) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	traceQuery(ctx, "items.insert_many")
+
+	now := i.now().UTC().Truncate(time.Second)
+	for idx, item := range items {
+		if err := insertItemTx(ctx, tx, item, now); err != nil {
+			return fmt.Errorf("items[%d]: %w", idx, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, item := range items {
+		item.CreatedAt = now
+		item.UpdatedAt = now
+	}
+	return nil
+}
+
+// insertItemTx inserts an item, creating its category if it does not exist yet, and sets the assigned id.
+func insertItemTx(ctx context.Context, tx *sql.Tx, item *Item, now time.Time) error {
+	// 前後の空白が違うだけのカテゴリが別の行にならないように、必ずtrimしてから探す
+	item.Category = normalizeCategory(item.Category)
+
+	categoryID, err := categoryIDTx(ctx, tx, item.Category)
+	if err != nil {
+		return err
+	}
+
+	if item.Status == "" {
+		item.Status = itemStatusOnSale
+	}
+	if err := validateItemStatus(item.Status); err != nil {
+		return err
+	}
+	if item.Seller == "" {
+		item.Seller = defaultSeller
+	}
+	if item.Condition == "" {
+		item.Condition = itemConditionUsed
+	}
+	if err := validateItemCondition(item.Condition); err != nil {
+		return err
+	}
+	// 在庫数を指定しなければ1点もの (売り切れで登録するなら0)
+	if item.Quantity == 0 && item.Status != itemStatusSold {
+		item.Quantity = 1
+	}
+	if item.Quantity < 0 {
+		return fmt.Errorf("%w: %d is negative", errInvalidQuantity, item.Quantity)
+	}
+	item.Brand = normalizeBrand(item.Brand)
+	if item.ShippingPayer == "" {
+		item.ShippingPayer = shippingPayerSeller
+	}
+	if err := validateShippingPayer(item.ShippingPayer); err != nil {
+		return err
+	}
+	if item.ShippingDays == 0 {
+		item.ShippingDays = defaultShippingDays
+	}
+	if err := validateShippingDays(item.ShippingDays); err != nil {
+		return err
+	}
+	if item.Price.Currency == "" {
+		item.Price.Currency = defaultCurrency
+	}
+	if item.Price.Currency, err = parseCurrency(item.Price.Currency); err != nil {
+		return err
+	}
 
 	// itemsテーブルに挿入
-	query := `INSERT INTO items (name, category_id, image_name) VALUES (?, ?, ?)`
-	_, err = tx.ExecContext(ctx, query, item.Name, categoryID, item.Image)
+	// 時刻はSQLiteのデフォルト値ではなくGo側で設定する (ドライバに依存しないように)
+	// 手動の並び順では、新しい商品は最後に追加する
+	query := `INSERT INTO items (name, name_normalized, category_id, image_name, status, price, price_currency, seller, condition, quantity, brand, shipping_payer, shipping_days, is_published, sort_order, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM items), ?, ?)
+		RETURNING id, sort_order`
+	var sortOrder int
+	err = tx.QueryRowContext(ctx, query, item.Name, normalizeSearchText(item.Name), categoryID, item.Image, item.Status, item.Price.Amount, item.Price.Currency, item.Seller, item.Condition, item.Quantity, item.Brand, item.ShippingPayer, item.ShippingDays, !item.Draft, formatTimestamp(now), formatTimestamp(now)).Scan(&item.ID, &sortOrder)
 	if err != nil {
 		return err
 	}
+	item.SortOrder = &sortOrder
 
-	return tx.Commit()
+	// タグは小文字に揃えて重複を除いたものを保存する
+	if item.Tags == nil {
+		item.Tags = []string{}
+	}
+	for _, tag := range item.Tags {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO tags (name) VALUES (?)`, tag); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO item_tags (item_id, tag_id) SELECT ?, id FROM tags WHERE name = ?`, item.ID, tag); err != nil {
+			return err
+		}
+	}
+	return appendEventTx(ctx, tx, item.ID, itemEventCreated, nil, nil, now)
+}
+
+// categoryIDTx returns the id of the category with the normalized name, creating it if it does not exist yet.
+func categoryIDTx(ctx context.Context, tx *sql.Tx, name string) (int64, error) {
+	name = normalizeCategory(name)
+	// カテゴリが既に存在するか確認
+	var categoryID int64
+	err := tx.QueryRowContext(ctx, "SELECT id FROM categories WHERE name = ?", name).Scan(&categoryID)
+	if err == nil {
+		return categoryID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+	// カテゴリが存在しない場合は挿入
+	// 確認と挿入の間に別の接続が同じ名前を挿入していたら、UNIQUE制約違反にせずにそのカテゴリを使う
+	res, err := tx.ExecContext(ctx, "INSERT INTO categories (name) VALUES (?) ON CONFLICT (name) DO NOTHING", name)
+	if err != nil {
+		return 0, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	// 挿入したカテゴリのIDを取得
+	err = tx.QueryRowContext(ctx, "SELECT id FROM categories WHERE name = ?", name).Scan(&categoryID)
+	if err != nil {
+		return 0, err
+	}
+	if inserted > 0 {
+		if err := bumpCategoriesVersion(ctx, tx); err != nil {
+			return 0, err
+		}
+	}
+	return categoryID, nil
+}
+
+func (i *itemRepository) GetAll(ctx context.Context, opts ItemListOptions) ([]Item, error) {
+	return i.getAll(ctx, i.db, opts)
+}
+
+// Count returns the number of items matching the filters of opts. Limit and Offset are ignored.
+func (i *itemRepository) Count(ctx context.Context, opts ItemListOptions) (int, error) {
+	return i.count(ctx, i.db, opts)
+}
+
+// GetPage returns the page of items selected by opts together with the number of items matching its filters.
+func (i *itemRepository) GetPage(ctx context.Context, opts ItemListOptions) ([]Item, int, error) {
+	// 件数とページを同じトランザクションで読んで、食い違わないようにする
+	tx, err := i.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	total, err := i.count(ctx, tx, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	items, err := i.getAll(ctx, tx, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, tx.Commit()
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// listWhere returns the WHERE conditions and arguments for the filters of opts.
+func listWhere(opts ItemListOptions) ([]string, []any) {
+	// 論理削除されたものは、指定がない限り除外する
+	var where []string
+	var args []any
+	if !opts.IncludeDeleted {
+		where = append(where, "items.deleted_at IS NULL")
+	}
+	// 下書きも、指定がない限り除外する
+	if !opts.IncludeDrafts {
+		where = append(where, "items.is_published = 1")
+	}
+	if opts.Status != "" {
+		where = append(where, "items.status = ?")
+		args = append(args, opts.Status)
+	}
+	if opts.Seller != "" {
+		where = append(where, "items.seller = ?")
+		args = append(args, opts.Seller)
+	}
+	if opts.Brand != "" {
+		where = append(where, "items.brand = ? COLLATE NOCASE")
+		args = append(args, opts.Brand)
+	}
+	if opts.Currency != "" {
+		where = append(where, "items.price_currency = ?")
+		args = append(args, opts.Currency)
+	}
+	if opts.Condition != "" {
+		where = append(where, "items.condition = ?")
+		args = append(args, opts.Condition)
+	}
+	if opts.ShippingPayer != "" {
+		where = append(where, "items.shipping_payer = ?")
+		args = append(args, opts.ShippingPayer)
+	}
+	if opts.Tag != "" {
+		where = append(where, "items.id IN (SELECT item_tags.item_id FROM item_tags INNER JOIN tags ON item_tags.tag_id = tags.id WHERE tags.name = ?)")
+		args = append(args, opts.Tag)
+	}
+	if len(opts.IDs) > 0 {
+		// idの数だけプレースホルダを並べる
+		where = append(where, "items.id IN ("+strings.TrimSuffix(strings.Repeat("?,", len(opts.IDs)), ",")+")")
+		for _, id := range opts.IDs {
+			args = append(args, id)
+		}
+	}
+	return where, args
+}
+
+func (i *itemRepository) count(ctx context.Context, q queryer, opts ItemListOptions) (int, error) {
+	where, args := listWhere(opts)
+	query := `SELECT COUNT(*) FROM items INNER JOIN categories ON items.category_id = categories.id ` + whereClause(where)
+
+	traceQuery(ctx, "items.count")
+	var count int
+	if err := q.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
-func (i *itemRepository) GetAll(ctx context.Context) ([]Item, error) {
+func (i *itemRepository) getAll(ctx context.Context, q queryer, opts ItemListOptions) ([]Item, error) {
+	var orderBy string
+	switch opts.Sort {
+	case sortByID:
+		orderBy = "items.id"
+	case sortByCreatedAt:
+		// 新しい順 (同じ秒に作られたものはidの大きい順)
+		orderBy = "items.created_at DESC, items.id DESC"
+	case sortByManual:
+		// sort_orderの小さい順 (NULLは最後, 同じならidの小さい順)
+		orderBy = "items.sort_order IS NULL, items.sort_order, items.id"
+	case sortByNameJa:
+		// 照合順序が使えなければ、全件読んでからGo側で並べ替える
+		orderBy = "items.name" + i.collateJa() + ", items.id"
+	default:
+		return nil, fmt.Errorf("unknown sort: %s", opts.Sort)
+	}
+
+	where, args := listWhere(opts)
+
 	// itemsとcategoriesをいったんinner join
 	query := `
-				SELECT
-					items.id,
-					items.name,
-					categories.name AS category,
-					items.image_name
+				SELECT` + itemColumns + `
 				FROM
 					items
 				INNER JOIN
-					categories ON items.category_id = categories.id;
-			`
+					categories ON items.category_id = categories.id
+				` + whereClause(where) + `
+				ORDER BY ` + orderBy
+	// idsの指定があるときやGo側で並べ替えるときは、並べ替えた後で切り出す
+	sortInGo := opts.Sort == sortByNameJa && !i.collation && len(opts.IDs) == 0
+	if opts.Limit > 0 && len(opts.IDs) == 0 && !sortInGo {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, opts.Limit, opts.Offset)
+	}
 
+	traceQuery(ctx, "items.get_all")
 	// GetAll メソッドは単一のクエリで完結するため Query/Close を使用
-	rows, err := i.db.Query(query)
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -125,17 +680,43 @@ func (i *itemRepository) GetAll(ctx context.Context) ([]Item, error) {
 	// Item 構造体のスライス
 	var items []Item
 	for rows.Next() {
-		var item Item
-		err := rows.Scan(&item.ID, &item.Name, &item.Category, &item.Image)
+		item, err := scanItem(rows)
 		if err != nil {
 			return nil, err
 		}
 		items = append(items, item)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
+	if len(opts.IDs) > 0 {
+		items = orderByIDs(items, opts.IDs)
+	}
+	if sortInGo {
+		sortItemsByNameJa(items)
+	}
+	if opts.Limit > 0 && (len(opts.IDs) > 0 || sortInGo) {
+		items = items[min(opts.Offset, len(items)):min(opts.Offset+opts.Limit, len(items))]
+	}
 	return items, nil
 }
 
+// orderByIDs reorders items to follow the order of ids. Items not in ids are dropped.
+func orderByIDs(items []Item, ids []int) []Item {
+	byID := make(map[int]Item, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+	ordered := make([]Item, 0, len(items))
+	for _, id := range ids {
+		if item, ok := byID[id]; ok {
+			ordered = append(ordered, item)
+		}
+	}
+	return ordered
+}
+
 // server.goのstoreImageで完結しているのでこっちのコードは使っていない
 func StoreImage(fileName string, image []byte) error {
 	savePath := filepath.Join("images", fileName)
@@ -147,21 +728,47 @@ func StoreImage(fileName string, image []byte) error {
 	return nil
 }
 
+// GetItemsAfter returns at most limit items that are not deleted and whose id is greater than afterID, in id order.
+// Reading all items page by page with it never keeps a cursor open between pages.
+func (i *itemRepository) GetItemsAfter(ctx context.Context, afterID, limit int) ([]Item, error) {
+	query := `
+				SELECT` + itemColumns + `
+				FROM items
+				INNER JOIN categories ON items.category_id = categories.id
+				WHERE items.id > ? AND items.deleted_at IS NULL
+				ORDER BY items.id
+				LIMIT ?
+			`
+	traceQuery(ctx, "items.get_after")
+	rows, err := i.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Item{}
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
 func (i *itemRepository) GetItemById(ctx context.Context, item_id string) (Item, error) {
 	query := `
-				SELECT 
-					items.id, 
-					items.name, 
-					categories.name AS category, 
-					items.image_name 
+				SELECT` + itemColumns + `
 				FROM items
 				INNER JOIN categories ON items.category_id = categories.id
-				WHERE items.id = ?
+				WHERE items.id = ? AND items.deleted_at IS NULL
 			`
-	row := i.db.QueryRow(query, item_id)
-	var item Item
+	traceQuery(ctx, "items.get_by_id")
+	row := i.db.QueryRowContext(ctx, query, item_id)
 	// itemの各要素にセット
-	err := row.Scan(&item.ID, &item.Name, &item.Category, &item.Image)
+	item, err := scanItem(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return Item{}, errItemNotFound
@@ -172,39 +779,1052 @@ func (i *itemRepository) GetItemById(ctx context.Context, item_id string) (Item,
 	return item, nil
 }
 
-func (i *itemRepository) SearchItemsByKeyword(ctx context.Context, keyword string) ([]Item, error) {
-	// itemsとcategoriesをいったんinner join
+// GetItemByImage returns the first item, in id order, that is not deleted and uses the stored image name.
+// It returns errItemNotFound if no item references the image.
+func (i *itemRepository) GetItemByImage(ctx context.Context, imageName string) (Item, error) {
 	query := `
-				SELECT
-								items.id,
-								items.name,
-								categories.name AS category,
-								items.image_name
-				FROM
-								items
-				INNER JOIN
-								categories ON items.category_id = categories.id
-				WHERE
-								items.name LIKE ?
-		`
+				SELECT` + itemColumns + `
+				FROM items
+				INNER JOIN categories ON items.category_id = categories.id
+				WHERE items.image_name = ? AND items.deleted_at IS NULL
+				ORDER BY items.id
+				LIMIT 1
+			`
+	traceQuery(ctx, "items.get_by_image")
+	item, err := scanItem(i.db.QueryRowContext(ctx, query, imageName))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Item{}, errItemNotFound
+		}
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// CountItemsUsingImage returns the number of items whose stored image name is imageName.
+// Soft-deleted items are counted too, since they use the image again once restored.
+func (i *itemRepository) CountItemsUsingImage(ctx context.Context, imageName string) (int, error) {
+	traceQuery(ctx, "items.count_by_image")
+	var count int
+	if err := i.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM items WHERE image_name = ?`, imageName).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
 
-	// queryの?部分がkeywordで置き換えられる
-	// % はワイルドカード文字: 0文字以上の任意の文字列
-	rows, err := i.db.Query(query, "%"+keyword+"%")
+// GetImageNames returns the distinct stored image names of all items, including soft-deleted ones.
+func (i *itemRepository) GetImageNames(ctx context.Context) ([]string, error) {
+	traceQuery(ctx, "items.image_names")
+	rows, err := i.db.QueryContext(ctx, `SELECT DISTINCT image_name FROM items`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var items []Item
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// GetItemsBySeller returns the items of the seller that are not deleted, newest first.
+func (i *itemRepository) GetItemsBySeller(ctx context.Context, seller string) ([]Item, error) {
+	return i.getAll(ctx, i.db, ItemListOptions{Seller: seller, Sort: sortByCreatedAt})
+}
+
+// GetRecentItems returns up to limit items that are not deleted, most recently added first.
+func (i *itemRepository) GetRecentItems(ctx context.Context, limit int) ([]Item, error) {
+	return i.getAll(ctx, i.db, ItemListOptions{Sort: sortByCreatedAt, Limit: limit})
+}
+
+// GetCategoryItems returns up to limit items of the category in id order, excluding the item with excludeID.
+func (i *itemRepository) GetCategoryItems(ctx context.Context, category string, excludeID, limit int) ([]Item, error) {
+	query := `
+				SELECT` + itemColumns + `
+				FROM items
+				INNER JOIN categories ON items.category_id = categories.id
+				WHERE categories.name = ? AND items.id != ? AND items.deleted_at IS NULL AND items.is_published = 1
+				ORDER BY items.id
+				LIMIT ?
+			`
+	traceQuery(ctx, "items.get_category_items")
+	rows, err := i.db.QueryContext(ctx, query, category, excludeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Item{}
 	for rows.Next() {
-		var item Item
-		err := rows.Scan(&item.ID, &item.Name, &item.Category, &item.Image)
+		item, err := scanItem(rows)
 		if err != nil {
 			return nil, err
 		}
 		items = append(items, item)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return items, nil
 }
+
+// GetRelatedItems returns up to limit items of the same category as the item, newest first, excluding the item.
+// When the category has fewer, the rest are the newest items of other categories.
+// It returns errItemNotFound if the item does not exist or is deleted.
+func (i *itemRepository) GetRelatedItems(ctx context.Context, item_id string, limit int) ([]Item, error) {
+	traceQuery(ctx, "items.get_related_items")
+	var id, categoryID int
+	err := i.db.QueryRowContext(ctx, `SELECT id, category_id FROM items WHERE id = ? AND deleted_at IS NULL`, item_id).Scan(&id, &categoryID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errItemNotFound
+		}
+		return nil, err
+	}
+
+	// 同じカテゴリの商品が足りなければ、他のカテゴリの新しい商品で埋める
+	items := []Item{}
+	for _, cond := range []string{"items.category_id = ?", "items.category_id != ?"} {
+		if len(items) >= limit {
+			break
+		}
+		query := `
+				SELECT` + itemColumns + `
+				FROM items
+				INNER JOIN categories ON items.category_id = categories.id
+				WHERE ` + cond + ` AND items.id != ? AND items.deleted_at IS NULL AND items.is_published = 1
+				ORDER BY items.created_at DESC, items.id DESC
+				LIMIT ?
+			`
+		rows, err := i.db.QueryContext(ctx, query, categoryID, id, limit-len(items))
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			item, err := scanItem(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// collateJa returns the COLLATE clause for the Japanese order, or empty when the connections do not have it.
+func (i *itemRepository) collateJa() string {
+	if i.collation {
+		return " COLLATE " + collationJa
+	}
+	return ""
+}
+
+// priceUnbounded is the upper price bound used when SearchFilter.MaxPrice is not set.
+const priceUnbounded = math.MaxInt64
+
+// SearchFilter holds the conditions of SearchItemsByKeyword.
+type SearchFilter struct {
+	Keyword string
+	// MinPrice and MaxPrice bound the price, inclusive. Use 0 and priceUnbounded for no bound.
+	MinPrice int
+	MaxPrice int
+	// CandidateLimit bounds the matches of a LIKE search, which cannot use an index. 0 means no limit.
+	// The FTS search is not bounded.
+	CandidateLimit int
+	// Limit is the maximum number of items SearchItemsByKeyword returns, after skipping Offset items.
+	// 0 means no limit. CountItemsByKeyword ignores both.
+	Limit  int
+	Offset int
+	// IncludeDrafts includes items that are not published yet.
+	IncludeDrafts bool
+	// MatchAny matches items with any of the terms of the keyword instead of all of them.
+	MatchAny bool
+	// Sort is searchSortNewest, or searchSortRelevance. The zero value is relevance.
+	Sort string
+	// Category narrows the search to the items of the category, compared ignoring case. Empty means any category.
+	Category string
+}
+
+// searchQuery returns the FROM and WHERE clauses shared by SearchItemsByKeyword and CountItemsByKeyword and their args,
+// and the ORDER BY clause and its args. Every term of the keyword, or any term with MatchAny, must match the name,
+// the category or the brand of the item.
+// FTSが使えるときはMATCHで検索し、使えないときはLIKEで検索する
+func (i *itemRepository) searchQuery(f SearchFilter) (from string, args []any, orderBy string, orderArgs []any) {
+	terms := searchTerms(f.Keyword, f.MatchAny)
+	where := []string{"items.deleted_at IS NULL", "items.price BETWEEN ? AND ?"}
+	args = []any{f.MinPrice, f.MaxPrice}
+	if !f.IncludeDrafts {
+		where = append(where, "items.is_published = 1")
+	}
+	if f.Category != "" {
+		// カテゴリ名は小文字で保存されている
+		where = append(where, "categories.name = ?")
+		args = append(args, normalizeCategory(f.Category))
+	}
+
+	if match, ok := ftsQuery(terms, f.MatchAny); i.fts && ok {
+		where = append(where, "items_fts MATCH ?")
+		args = append(args, match)
+		from = `
+				items_fts
+				INNER JOIN
+					items ON items.id = items_fts.rowid
+				INNER JOIN
+					categories ON items.category_id = categories.id
+				` + whereClause(where)
+		// 関連度が同じなら、FTSの関連度 (bm25) の順
+		orderBy, orderArgs = searchOrder(f, terms, "items_fts.rank, items.id")
+		return from, args, orderBy, orderArgs
+	}
+
+	matches := make([]string, 0, len(terms))
+	for _, t := range terms {
+		// % はワイルドカード文字: 0文字以上の任意の文字列
+		pattern := "%" + escapeLike(t) + "%"
+		matches = append(matches, `(items.name_normalized LIKE ? ESCAPE '\' OR categories.name LIKE ? ESCAPE '\' OR items.brand LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern, pattern)
+	}
+	if f.MatchAny && len(matches) > 0 {
+		where = append(where, "("+strings.Join(matches, " OR ")+")")
+	} else {
+		where = append(where, matches...)
+	}
+	from = `
+				items
+				INNER JOIN
+					categories ON items.category_id = categories.id
+				` + whereClause(where)
+	if f.CandidateLimit > 0 {
+		// 一致した最初のCandidateLimit件で走査を打ち切る (件数も同じ範囲で数える)
+		from = `
+				items
+				INNER JOIN
+					categories ON items.category_id = categories.id
+				WHERE items.id IN (SELECT items.id FROM` + from + ` ORDER BY items.id LIMIT ?)`
+		args = append(args, f.CandidateLimit)
+	}
+	orderBy, orderArgs = searchOrder(f, terms, "items.id")
+	return from, args, orderBy, orderArgs
+}
+
+// likeEscaper escapes the wildcards of LIKE and the escape character itself, for LIKE ... ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike returns s as a LIKE pattern matching s literally, so that a search for "50%" does not match everything.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// searchOrder returns the ORDER BY clause of a search and its args.
+// By relevance, an item whose name is the keyword comes first, then names starting with it, then names containing
+// every term (any term with MatchAny), and last the items matching only by the category or the brand.
+// Items of the same relevance are ordered by tieBreak.
+func searchOrder(f SearchFilter, terms []string, tieBreak string) (string, []any) {
+	if f.Sort == searchSortNewest {
+		return "items.created_at DESC, items.id DESC", nil
+	}
+	keyword := strings.Join(terms, " ")
+	args := []any{keyword, escapeLike(keyword) + "%"}
+	nameMatches := make([]string, 0, len(terms))
+	for _, t := range terms {
+		nameMatches = append(nameMatches, `items.name_normalized LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(t)+"%")
+	}
+	op := " AND "
+	if f.MatchAny {
+		op = " OR "
+	}
+	if len(nameMatches) == 0 {
+		// 語がなければ、名前に含まれることはない
+		nameMatches = []string{"0"}
+	}
+	orderBy := `CASE
+					WHEN items.name_normalized = ? THEN 0
+					WHEN items.name_normalized LIKE ? ESCAPE '\' THEN 1
+					WHEN ` + strings.Join(nameMatches, op) + ` THEN 2
+					ELSE 3
+				END, ` + tieBreak
+	return orderBy, args
+}
+
+// SearchItemsByKeyword calls fn for each item matching the keyword whose price is in range.
+// Items are ordered by relevance (see searchOrder), ties broken by the FTS rank when full-text search is available,
+// or from the newest with Sort searchSortNewest.
+// Rows are passed to fn as they are read, so that the caller can stream them without holding the whole result.
+// If fn returns an error, the search stops and the error is returned.
+func (i *itemRepository) SearchItemsByKeyword(ctx context.Context, filter SearchFilter, fn func(Item) error) error {
+	from, args, orderBy, orderArgs := i.searchQuery(filter)
+	// itemsとcategoriesをいったんinner join
+	query := `
+				SELECT` + itemColumns + `
+				FROM` + from + `
+				ORDER BY ` + orderBy
+	args = append(args, orderArgs...)
+	if filter.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	traceQuery(ctx, "items.search")
+	rows, err := i.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// CountItemsByKeyword returns the number of items SearchItemsByKeyword would return without Limit and Offset.
+func (i *itemRepository) CountItemsByKeyword(ctx context.Context, filter SearchFilter) (int, error) {
+	from, args, _, _ := i.searchQuery(filter)
+	query := `SELECT COUNT(*) FROM` + from
+
+	traceQuery(ctx, "items.search_count")
+	var count int
+	if err := i.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CheckCategoryHealth detects items whose category no longer exists and categories that have no items.
+// アプリの外から直接SQLを実行した場合などに起きる不整合を検出する
+func (i *itemRepository) CheckCategoryHealth(ctx context.Context) ([]HealthIssue, error) {
+	traceQuery(ctx, "categories.health")
+
+	checks := []struct {
+		kind  string
+		query string
+	}{
+		{
+			kind:  healthIssueOrphanedItem,
+			query: `SELECT items.id FROM items LEFT JOIN categories ON items.category_id = categories.id WHERE categories.id IS NULL ORDER BY items.id`,
+		},
+		{
+			kind:  healthIssueEmptyCategory,
+			query: `SELECT categories.id FROM categories LEFT JOIN items ON items.category_id = categories.id WHERE items.id IS NULL ORDER BY categories.id`,
+		},
+	}
+
+	var issues []HealthIssue
+	for _, c := range checks {
+		rows, err := i.db.QueryContext(ctx, c.query)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			issues = append(issues, HealthIssue{Kind: c.kind, ID: id})
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return issues, nil
+}
+
+// whereClause joins the conditions with AND. It returns an empty string when there are no conditions.
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// SoftDelete marks the item as deleted without removing the row, so that it can be restored.
+// It returns errItemNotFound if the item does not exist or is already deleted.
+func (i *itemRepository) SoftDelete(ctx context.Context, item_id string) error {
+	traceQuery(ctx, "items.soft_delete")
+	now := formatTimestamp(i.now())
+	res, err := i.db.ExecContext(ctx, `UPDATE items SET deleted_at = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL`, now, now, item_id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errItemNotFound
+	}
+	return nil
+}
+
+// Restore clears the deletion mark of the item.
+// Restoring an item that is not deleted does nothing. It returns errItemNotFound if the item does not exist.
+func (i *itemRepository) Restore(ctx context.Context, item_id string) error {
+	traceQuery(ctx, "items.restore")
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT deleted_at FROM items WHERE id = ?`, item_id).Scan(&deletedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errItemNotFound
+		}
+		return err
+	}
+	if !deletedAt.Valid {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE items SET deleted_at = NULL, updated_at = ? WHERE id = ?`, formatTimestamp(i.now()), item_id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Publish makes a draft item appear in the lists and the search. Publishing a published item does nothing.
+// It returns errItemNotFound if the item does not exist or is deleted.
+func (i *itemRepository) Publish(ctx context.Context, item_id string) error {
+	traceQuery(ctx, "items.publish")
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var published bool
+	err = tx.QueryRowContext(ctx, `SELECT id, is_published FROM items WHERE id = ? AND deleted_at IS NULL`, item_id).Scan(&id, &published)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errItemNotFound
+		}
+		return err
+	}
+	if published {
+		return nil
+	}
+
+	now := i.now()
+	if _, err := tx.ExecContext(ctx, `UPDATE items SET is_published = 1, updated_at = ? WHERE id = ?`, formatTimestamp(now), id); err != nil {
+		return err
+	}
+	if err := appendEventTx(ctx, tx, id, itemEventPublished, nil, nil, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Purge removes the item with its favorites and tags, whether or not it is soft-deleted.
+// It returns the name of the item's image if no other item uses it any more, and an empty string otherwise.
+// The image is not removed here; the caller deletes the file once the transaction has been committed.
+func (i *itemRepository) Purge(ctx context.Context, item_id string) (string, error) {
+	traceQuery(ctx, "items.purge")
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var image string
+	err = tx.QueryRowContext(ctx, `SELECT image_name FROM items WHERE id = ?`, item_id).Scan(&image)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", errItemNotFound
+		}
+		return "", err
+	}
+
+	for _, query := range []string{
+		`DELETE FROM favorites WHERE item_id = ?`,
+		`DELETE FROM item_tags WHERE item_id = ?`,
+		`DELETE FROM item_events WHERE item_id = ?`,
+		`DELETE FROM items WHERE id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, item_id); err != nil {
+			return "", err
+		}
+	}
+
+	// 論理削除された商品も復元されれば画像を使うので、参照として数える
+	var refs int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM items WHERE image_name = ?`, image).Scan(&refs); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	if refs > 0 || image == defaultImageName {
+		return "", nil
+	}
+	return image, nil
+}
+
+// Ping checks that the database is reachable.
+func (i *itemRepository) Ping(ctx context.Context) error {
+	return i.db.PingContext(ctx)
+}
+
+// SampleByCategory returns up to perCategory random items from each category, ordered by category name.
+func (i *itemRepository) SampleByCategory(ctx context.Context, perCategory int) ([]Item, error) {
+	// カテゴリごとにランダムな順番を振って、上位perCategory件だけ残す
+	// ひらがなとカタカナなど照合順序で等しいカテゴリが混ざらないように、バイト順でも並べる
+	query := `
+				SELECT` + itemColumns + `
+				FROM (
+					SELECT
+						items.*,
+						ROW_NUMBER() OVER (PARTITION BY items.category_id ORDER BY RANDOM()) AS sample_rank
+					FROM items
+					WHERE items.deleted_at IS NULL AND items.is_published = 1
+				) AS items
+				INNER JOIN
+					categories ON items.category_id = categories.id
+				WHERE items.sample_rank <= ?
+				ORDER BY categories.name` + i.collateJa() + `, categories.name, items.sample_rank
+			`
+
+	traceQuery(ctx, "items.sample_by_category")
+	rows, err := i.db.QueryContext(ctx, query, perCategory)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []Item
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// COLLATE ja が使えない場合はGo側で並べ替える (安定ソートなのでカテゴリ内のランダムな順番は保たれる)
+	if !i.collation {
+		sortItemsByCategoryJa(items)
+	}
+
+	return items, nil
+}
+
+// Purchase atomically takes one from the stock of the item, changing it from on_sale to sold when none is left.
+// It returns errItemNotFound if the item does not exist and errItemSold if it is already sold or out of stock.
+func (i *itemRepository) Purchase(ctx context.Context, item_id string) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	traceQuery(ctx, "items.purchase")
+
+	// 在庫の確認と更新を1つのUPDATEで行うので、同時に購入されても在庫より多く売れることはない
+	// SETの右辺のquantityは更新前の値
+	res, err := tx.ExecContext(ctx, `
+		UPDATE items
+		SET quantity = quantity - 1, status = CASE WHEN quantity = 1 THEN ? ELSE status END, updated_at = ?
+		WHERE id = ? AND status = ? AND quantity > 0 AND deleted_at IS NULL`,
+		itemStatusSold, formatTimestamp(i.now()), item_id, itemStatusOnSale)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// 更新できなかった理由を調べる (存在しないのか、売り切れなのか)
+		var status string
+		err := tx.QueryRowContext(ctx, `SELECT status FROM items WHERE id = ? AND deleted_at IS NULL`, item_id).Scan(&status)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errItemNotFound
+			}
+			return err
+		}
+		return errItemSold
+	}
+
+	return tx.Commit()
+}
+
+// ItemPatch holds the fields to change in Update. nil fields are left as they are.
+type ItemPatch struct {
+	Name          *string
+	Category      *string
+	Status        *string
+	Price         *int
+	ShippingPayer *string
+	ShippingDays  *int
+}
+
+// getItemByIdTx reads the item that is not deleted with q, so that it can be read inside a transaction.
+func getItemByIdTx(ctx context.Context, q queryer, item_id string) (Item, error) {
+	query := `
+				SELECT` + itemColumns + `
+				FROM items
+				INNER JOIN categories ON items.category_id = categories.id
+				WHERE items.id = ? AND items.deleted_at IS NULL
+			`
+	item, err := scanItem(q.QueryRowContext(ctx, query, item_id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Item{}, errItemNotFound
+		}
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// Update changes the fields of the item set in patch, creating the category if needed.
+// It returns the item before and after the update, both read in the same transaction.
+// updated_at is only changed when a value actually changes.
+// It returns errItemNotFound if the item does not exist or is deleted.
+func (i *itemRepository) Update(ctx context.Context, item_id string, patch ItemPatch) (Item, Item, error) {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Item{}, Item{}, err
+	}
+	defer tx.Rollback()
+	traceQuery(ctx, "items.update")
+
+	before, err := getItemByIdTx(ctx, tx, item_id)
+	if err != nil {
+		return Item{}, Item{}, err
+	}
+
+	var sets []string
+	var args []any
+	if patch.Name != nil && *patch.Name != before.Name {
+		sets = append(sets, "name = ?", "name_normalized = ?")
+		args = append(args, *patch.Name, normalizeSearchText(*patch.Name))
+	}
+	if patch.Category != nil && normalizeCategory(*patch.Category) != before.Category {
+		categoryID, err := categoryIDTx(ctx, tx, *patch.Category)
+		if err != nil {
+			return Item{}, Item{}, err
+		}
+		sets = append(sets, "category_id = ?")
+		args = append(args, categoryID)
+	}
+	if patch.Status != nil && *patch.Status != before.Status {
+		if err := validateItemStatus(*patch.Status); err != nil {
+			return Item{}, Item{}, err
+		}
+		sets = append(sets, "status = ?")
+		args = append(args, *patch.Status)
+	}
+	if patch.Price != nil && *patch.Price != before.Price.Amount {
+		if *patch.Price < 0 {
+			return Item{}, Item{}, fmt.Errorf("%w: %d is negative", errInvalidPrice, *patch.Price)
+		}
+		sets = append(sets, "price = ?")
+		args = append(args, *patch.Price)
+	}
+	if patch.ShippingPayer != nil && *patch.ShippingPayer != before.ShippingPayer {
+		if err := validateShippingPayer(*patch.ShippingPayer); err != nil {
+			return Item{}, Item{}, err
+		}
+		sets = append(sets, "shipping_payer = ?")
+		args = append(args, *patch.ShippingPayer)
+	}
+	if patch.ShippingDays != nil && *patch.ShippingDays != before.ShippingDays {
+		if err := validateShippingDays(*patch.ShippingDays); err != nil {
+			return Item{}, Item{}, err
+		}
+		sets = append(sets, "shipping_days = ?")
+		args = append(args, *patch.ShippingDays)
+	}
+	// 値が変わらなければ書き込まない (updated_atも変えない)
+	if len(sets) == 0 {
+		return before, before, tx.Commit()
+	}
+
+	now := i.now()
+	sets = append(sets, "updated_at = ?")
+	args = append(args, formatTimestamp(now), item_id)
+	if _, err := tx.ExecContext(ctx, `UPDATE items SET `+strings.Join(sets, ", ")+` WHERE id = ?`, args...); err != nil {
+		return Item{}, Item{}, err
+	}
+	after, err := getItemByIdTx(ctx, tx, item_id)
+	if err != nil {
+		return Item{}, Item{}, err
+	}
+	// 変わった項目ごとに履歴を残す
+	if err := appendUpdateEventsTx(ctx, tx, before, after, now); err != nil {
+		return Item{}, Item{}, err
+	}
+
+	return before, after, tx.Commit()
+}
+
+// GetItemHistory returns the events of the item in the order they were recorded.
+// It returns errItemNotFound if the item does not exist or is deleted.
+func (i *itemRepository) GetItemHistory(ctx context.Context, item_id string) ([]ItemEvent, error) {
+	traceQuery(ctx, "item_events.get")
+	var exists bool
+	err := i.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM items WHERE id = ? AND deleted_at IS NULL)`, item_id).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errItemNotFound
+	}
+
+	rows, err := i.db.QueryContext(ctx, `
+		SELECT id, item_id, field, old_value, new_value, created_at, actor
+		FROM item_events
+		WHERE item_id = ?
+		ORDER BY id`, item_id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []ItemEvent{}
+	for rows.Next() {
+		var e ItemEvent
+		var oldValue, newValue, createdAt sql.NullString
+		if err := rows.Scan(&e.ID, &e.ItemID, &e.Field, &oldValue, &newValue, &createdAt, &e.Actor); err != nil {
+			return nil, err
+		}
+		if oldValue.Valid {
+			e.OldValue = &oldValue.String
+		}
+		if newValue.Valid {
+			e.NewValue = &newValue.String
+		}
+		if e.CreatedAt, err = parseTimestamp(createdAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// AddFavorite marks the item as a favorite of the client. Favoriting it again does nothing.
+// It returns errItemNotFound if the item does not exist or is deleted.
+func (i *itemRepository) AddFavorite(ctx context.Context, item_id string, clientToken string) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	traceQuery(ctx, "favorites.add")
+
+	res, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO favorites (item_id, client_token, created_at)
+		SELECT id, ?, ? FROM items WHERE id = ? AND deleted_at IS NULL`,
+		clientToken, formatTimestamp(i.now()), item_id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// 挿入されなかった理由を調べる (存在しないのか、登録済みなのか)
+		var exists bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM items WHERE id = ? AND deleted_at IS NULL)`, item_id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errItemNotFound
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RemoveFavorite unmarks the item as a favorite of the client. Removing a favorite that does not exist does nothing.
+// It returns errItemNotFound if the item does not exist.
+func (i *itemRepository) RemoveFavorite(ctx context.Context, item_id string, clientToken string) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	traceQuery(ctx, "favorites.remove")
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM favorites WHERE item_id = ? AND client_token = ?`, item_id, clientToken)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// 削除済みの商品でもお気に入りは外せるようにする
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM items WHERE id = ?)`, item_id).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return errItemNotFound
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetFavorites returns the items favorited by the client that are not deleted, most recently favorited first.
+func (i *itemRepository) GetFavorites(ctx context.Context, clientToken string) ([]Item, error) {
+	query := `
+				SELECT` + itemColumns + `
+				FROM favorites
+				INNER JOIN items ON favorites.item_id = items.id
+				INNER JOIN categories ON items.category_id = categories.id
+				WHERE favorites.client_token = ? AND items.deleted_at IS NULL
+				ORDER BY favorites.created_at DESC, items.id DESC
+			`
+	traceQuery(ctx, "favorites.get")
+	rows, err := i.db.QueryContext(ctx, query, clientToken)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Item{}
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// AddViews adds the counts to the view_count of the items, keyed by item id.
+// Each item is incremented with a single UPDATE, all in one transaction. Ids that no longer exist are ignored.
+func (i *itemRepository) AddViews(ctx context.Context, views map[int]int) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	traceQuery(ctx, "items.add_views")
+
+	stmt, err := tx.PrepareContext(ctx, `UPDATE items SET view_count = view_count + ? WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for id, n := range views {
+		if _, err := stmt.ExecContext(ctx, n, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Reorder sets the manual display order: the items get sort_order 1, 2, ... in the order of ids.
+// All ids must exist, otherwise nothing is written and errItemNotFound is returned.
+func (i *itemRepository) Reorder(ctx context.Context, ids []int) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	traceQuery(ctx, "items.reorder")
+
+	// 書き込む前に、すべてのidが存在することを確認する
+	for _, id := range ids {
+		var exists int
+		err := tx.QueryRowContext(ctx, `SELECT 1 FROM items WHERE id = ? AND deleted_at IS NULL`, id).Scan(&exists)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: %d", errItemNotFound, id)
+			}
+			return err
+		}
+	}
+
+	for pos, id := range ids {
+		if _, err := tx.ExecContext(ctx, `UPDATE items SET sort_order = ? WHERE id = ?`, pos+1, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Swap exchanges the sort_order of two items.
+func (i *itemRepository) Swap(ctx context.Context, a, b int) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	traceQuery(ctx, "items.swap")
+
+	orders := make([]sql.NullInt64, 2)
+	for idx, id := range []int{a, b} {
+		err := tx.QueryRowContext(ctx, `SELECT sort_order FROM items WHERE id = ? AND deleted_at IS NULL`, id).Scan(&orders[idx])
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: %d", errItemNotFound, id)
+			}
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE items SET sort_order = ? WHERE id = ?`, orders[1], a); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE items SET sort_order = ? WHERE id = ?`, orders[0], b); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Category is a row of the categories table.
+type Category struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// bumpCategoriesVersion increments the categories version.
+// It must be called in the same transaction as the change to the categories,
+// so that a reader never sees new categories with an old version.
+func bumpCategoriesVersion(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `UPDATE meta SET categories_version = categories_version + 1 WHERE id = 1`)
+	return err
+}
+
+// DeleteCategory deletes the category and moves its items to the fallback category, creating it if needed,
+// so that no item is left without a category. Items already orphaned by a missing category are moved too.
+// It returns the number of items moved, errCategoryNotFound if the category does not exist,
+// and errFallbackCategory if the category is the fallback itself.
+func (i *itemRepository) DeleteCategory(ctx context.Context, id int, fallback string) (int, error) {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	traceQuery(ctx, "categories.delete")
+
+	var name string
+	if err := tx.QueryRowContext(ctx, `SELECT name FROM categories WHERE id = ?`, id).Scan(&name); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errCategoryNotFound
+		}
+		return 0, err
+	}
+	fallback = normalizeCategory(fallback)
+	if name == fallback {
+		return 0, errFallbackCategory
+	}
+	fallbackID, err := categoryIDTx(ctx, tx, fallback)
+	if err != nil {
+		return 0, err
+	}
+
+	// 論理削除された商品も移す (復元したときにカテゴリがないと一覧から消えるため)
+	res, err := tx.ExecContext(ctx, `
+		UPDATE items SET category_id = ?
+		WHERE category_id = ? OR category_id NOT IN (SELECT id FROM categories)
+	`, fallbackID, id)
+	if err != nil {
+		return 0, err
+	}
+	moved, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = ?`, id); err != nil {
+		return 0, err
+	}
+	if err := bumpCategoriesVersion(ctx, tx); err != nil {
+		return 0, err
+	}
+
+	return int(moved), tx.Commit()
+}
+
+// CategoriesVersion returns the categories version, which increases every time the categories change.
+func (i *itemRepository) CategoriesVersion(ctx context.Context) (int64, error) {
+	traceQuery(ctx, "meta.categories_version")
+	var version int64
+	err := i.db.QueryRowContext(ctx, `SELECT categories_version FROM meta WHERE id = 1`).Scan(&version)
+	return version, err
+}
+
+// GetCategories returns all categories in the Japanese order of their names together with the version they belong to.
+func (i *itemRepository) GetCategories(ctx context.Context) ([]Category, int64, error) {
+	// バージョンとカテゴリを同じトランザクションで読んで、食い違わないようにする
+	tx, err := i.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+	traceQuery(ctx, "categories.get_all")
+
+	var version int64
+	if err := tx.QueryRowContext(ctx, `SELECT categories_version FROM meta WHERE id = 1`).Scan(&version); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, name FROM categories ORDER BY name`+i.collateJa()+`, id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+			return nil, 0, err
+		}
+		categories = append(categories, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if !i.collation {
+		sortCategoriesByNameJa(categories)
+	}
+
+	return categories, version, tx.Commit()
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestInsertTrimsCategory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, category := range []string{"shoes", " shoes ", "shoes\t"} {
+		if err := repo.Insert(t.Context(), &Item{Name: "sneakers", Category: category, Image: "default.jpg"}); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM categories`).Scan(&count); err != nil {
+		t.Fatalf("failed to count categories: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 category row, got %d", count)
+	}
+}
+
+func TestInsertSetsTimestamps(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	before := time.Now()
+	item := &Item{Name: "sneakers", Category: "shoes", Image: "default.jpg"}
+	if err := repo.Insert(t.Context(), item); err != nil {
+		t.Fatalf("failed to insert item: %v", err)
+	}
+
+	// データベースから読み直して確認する
+	got, err := repo.GetItemById(t.Context(), strconv.Itoa(item.ID))
+	if err != nil {
+		t.Fatalf("failed to get item: %v", err)
+	}
+	if got.CreatedAt.IsZero() {
+		t.Fatal("expected created_at to be set")
+	}
+	// 秒単位で保存されるので、1秒の誤差を許す
+	if d := got.CreatedAt.Sub(before); d < -time.Second || d > 5*time.Second {
+		t.Errorf("expected created_at close to %v, got %v", before, got.CreatedAt)
+	}
+	if !got.UpdatedAt.Equal(got.CreatedAt) {
+		t.Errorf("expected updated_at %v to equal created_at, got %v", got.CreatedAt, got.UpdatedAt)
+	}
+}
+
+func TestQueriesHonorCanceledContext(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	if err := repo.Insert(t.Context(), &Item{Name: "jacket", Category: "fashion", Image: "default.jpg"}); err != nil {
+		t.Fatalf("failed to insert item: %v", err)
+	}
+
+	// クライアントが切断した後のリクエストと同じく、呼び出す前にキャンセルしておく
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	cases := map[string]func() error{
+		"GetItemById": func() error {
+			_, err := repo.GetItemById(ctx, "1")
+			return err
+		},
+		"SearchItemsByKeyword": func() error {
+			return repo.SearchItemsByKeyword(ctx, SearchFilter{Keyword: "jacket"}, func(Item) error {
+				t.Error("expected no item from a canceled search")
+				return nil
+			})
+		},
+	}
+
+	for name, call := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := call(); !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+		})
+	}
+}
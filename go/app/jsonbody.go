@@ -0,0 +1,138 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// JSONのリクエストボディは全てdecodeJSONBodyで読む
+// 深いネストや大量のキーでCPUやメモリを使い切られないように、型にデコードする前に形を確認する
+
+// errJSONLimit is the cause of the errors of decodeJSONBody when the body exceeds one of jsonLimits.
+var errJSONLimit = errors.New("JSON limit exceeded")
+
+// jsonLimits bounds a JSON request body.
+type jsonLimits struct {
+	// MaxBytes bounds the size of the body.
+	MaxBytes int64
+	// MaxDepth bounds the nesting of objects and arrays.
+	MaxDepth int
+	// MaxKeys bounds the number of keys of each object.
+	MaxKeys int
+	// AllowUnknownFields accepts object keys that do not match a struct field. By default they are rejected.
+	AllowUnknownFields bool
+}
+
+// defaultJSONLimits are enough for the bodies of this API, which are flat objects or arrays of flat objects.
+var defaultJSONLimits = jsonLimits{
+	MaxBytes: 64 << 10, // 64KB
+	MaxDepth: 32,
+	MaxKeys:  1000,
+}
+
+// decodeJSONBody decodes the request body, which must be a single JSON value within the limits, into v.
+// Errors are a *ValidationError for the field "body" naming the problem, whose cause is errJSONLimit
+// for an exceeded limit and errInvalidRequestBody otherwise.
+func decodeJSONBody(r *http.Request, limits jsonLimits, v any) error {
+	data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, limits.MaxBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return jsonBodyError(fmt.Sprintf("exceeds the maximum size of %d bytes", limits.MaxBytes), fmt.Errorf("%w: %w", errJSONLimit, maxBytesErr))
+		}
+		return jsonBodyError(fmt.Sprintf("failed to read: %v", err), errInvalidRequestBody)
+	}
+
+	end, err := checkJSONShape(data, limits)
+	if err != nil {
+		return err
+	}
+	// 1つ目の値の後ろに何か残っていたら、黙って無視せずにエラーにする
+	if len(bytes.TrimSpace(data[end:])) > 0 {
+		return jsonBodyError("unexpected data after the JSON value", errInvalidRequestBody)
+	}
+
+	if err := decodeJSONValue(data, limits, v); err != nil {
+		return jsonBodyError(fmt.Sprintf("malformed JSON: %v", err), errInvalidRequestBody)
+	}
+	return nil
+}
+
+// decodeJSONValue decodes data into v, rejecting unknown fields unless limits allow them.
+func decodeJSONValue(data []byte, limits jsonLimits, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if !limits.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+// checkJSONShape scans the tokens of the first JSON value in data and checks its depth and key counts.
+// It returns the offset just after the value.
+func checkJSONShape(data []byte, limits jsonLimits) (int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	type frame struct {
+		object bool
+		keys   int
+		// key is true when the next token of the object is a key or its end.
+		key bool
+	}
+	var stack []frame
+	// valueEnded is called at the end of a value, after which the enclosing object expects a key.
+	valueEnded := func() {
+		if n := len(stack); n > 0 && stack[n-1].object {
+			stack[n-1].key = true
+		}
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, jsonBodyError("a JSON value is required", errInvalidRequestBody)
+			}
+			return 0, jsonBodyError(fmt.Sprintf("malformed JSON: %v", err), errInvalidRequestBody)
+		}
+
+		if n := len(stack); n > 0 && stack[n-1].object && stack[n-1].key {
+			if tok == json.Delim('}') {
+				stack = stack[:n-1]
+				valueEnded()
+			} else {
+				stack[n-1].keys++
+				if stack[n-1].keys > limits.MaxKeys {
+					return 0, jsonBodyError(fmt.Sprintf("an object has more than %d keys", limits.MaxKeys), errJSONLimit)
+				}
+				stack[n-1].key = false
+			}
+		} else {
+			switch tok {
+			case json.Delim('{'), json.Delim('['):
+				if len(stack) == limits.MaxDepth {
+					return 0, jsonBodyError(fmt.Sprintf("exceeds the maximum nesting depth of %d", limits.MaxDepth), errJSONLimit)
+				}
+				object := tok == json.Delim('{')
+				stack = append(stack, frame{object: object, key: object})
+			case json.Delim(']'):
+				stack = stack[:len(stack)-1]
+				valueEnded()
+			default:
+				valueEnded()
+			}
+		}
+
+		if len(stack) == 0 {
+			return int(dec.InputOffset()), nil
+		}
+	}
+}
+
+// jsonBodyError returns a *ValidationError for the request body.
+func jsonBodyError(message string, cause error) error {
+	return &ValidationError{Errors: []FieldError{{Field: "body", Message: message, cause: cause}}}
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodeJSONBody(t *testing.T) {
+	t.Parallel()
+
+	// manyKeys returns an object with n keys.
+	manyKeys := func(n int) string {
+		var b strings.Builder
+		b.WriteString("{")
+		for i := range n {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(`"k` + strconv.Itoa(i) + `":0`)
+		}
+		b.WriteString("}")
+		return b.String()
+	}
+
+	type wants struct {
+		err     error
+		message string
+	}
+	cases := map[string]struct {
+		body   string
+		limits jsonLimits
+		target func() any
+		wants
+	}{
+		"ok: object": {
+			body:   `{"name":"jacket","category":"fashion"}` + "\n",
+			limits: defaultJSONLimits,
+			target: func() any { return &addItemJSONRequest{} },
+		},
+		"ok: nested within the depth": {
+			body:   strings.Repeat("[", 32) + strings.Repeat("]", 32),
+			limits: defaultJSONLimits,
+			target: func() any { return &[]any{} },
+		},
+		"ok: unknown field allowed": {
+			body:   `{"name":"jacket","color":"red"}`,
+			limits: jsonLimits{MaxBytes: 1 << 10, MaxDepth: 2, MaxKeys: 2, AllowUnknownFields: true},
+			target: func() any { return &addItemJSONRequest{} },
+		},
+		"ng: 1000-level nested array": {
+			body:   strings.Repeat("[", 1000) + strings.Repeat("]", 1000),
+			limits: defaultJSONLimits,
+			target: func() any { return &[]any{} },
+			wants:  wants{err: errJSONLimit, message: "exceeds the maximum nesting depth of 32"},
+		},
+		"ng: 100k-key object": {
+			body:   manyKeys(100000),
+			limits: jsonLimits{MaxBytes: 4 << 20, MaxDepth: 32, MaxKeys: 1000},
+			target: func() any { return &map[string]int{} },
+			wants:  wants{err: errJSONLimit, message: "an object has more than 1000 keys"},
+		},
+		"ng: too large": {
+			body:   `{"name":"` + strings.Repeat("a", 100) + `"}`,
+			limits: jsonLimits{MaxBytes: 64, MaxDepth: 32, MaxKeys: 1000},
+			target: func() any { return &addItemJSONRequest{} },
+			wants:  wants{err: errJSONLimit, message: "exceeds the maximum size of 64 bytes"},
+		},
+		"ng: trailing garbage": {
+			body:   `{"name":"jacket"} garbage`,
+			limits: defaultJSONLimits,
+			target: func() any { return &addItemJSONRequest{} },
+			wants:  wants{err: errInvalidRequestBody, message: "unexpected data after the JSON value"},
+		},
+		"ng: second value": {
+			body:   `{"name":"jacket"}{"name":"hat"}`,
+			limits: defaultJSONLimits,
+			target: func() any { return &addItemJSONRequest{} },
+			wants:  wants{err: errInvalidRequestBody, message: "unexpected data after the JSON value"},
+		},
+		"ng: unknown field": {
+			body:   `{"name":"jacket","color":"red"}`,
+			limits: defaultJSONLimits,
+			target: func() any { return &addItemJSONRequest{} },
+			wants:  wants{err: errInvalidRequestBody, message: `malformed JSON: json: unknown field "color"`},
+		},
+		"ng: empty": {
+			body:   "",
+			limits: defaultJSONLimits,
+			target: func() any { return &addItemJSONRequest{} },
+			wants:  wants{err: errInvalidRequestBody, message: "a JSON value is required"},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			err := decodeJSONBody(req, tt.limits, tt.target())
+			if tt.wants.err == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wants.err) {
+				t.Fatalf("expected error %v, got %v", tt.wants.err, err)
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected a *ValidationError, got %T", err)
+			}
+			want := []FieldError{{Field: "body", Message: tt.wants.message}}
+			if diff := cmp.Diff(want, validationErr.Errors, cmp.Comparer(func(a, b FieldError) bool {
+				return a.Field == b.Field && a.Message == b.Message
+			})); diff != "" {
+				t.Errorf("unexpected errors (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAddItemRejectsTrailingGarbage(t *testing.T) {
+	t.Parallel()
+
+	// 以前は1つ目の値だけ読んで、後ろは黙って無視していた
+	h := &Handlers{}
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(`{"name":"jacket","category":"fashion"} {"name":"hat"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	h.AddItem(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+	var resp ValidationError
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Field != "body" {
+		t.Errorf("expected an error for the body, got %+v", resp.Errors)
+	}
+}
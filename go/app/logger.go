@@ -0,0 +1,50 @@
+package app
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// ログの出力レベルと形式は LOG_LEVEL と LOG_FORMAT で変えられる
+// 本番ではdebugログが多すぎるので、デフォルトはinfoにする
+
+// Log formats accepted by LOG_FORMAT.
+const (
+	logFormatJSON = "json"
+	logFormatText = "text"
+)
+
+// parseLogLevel maps LOG_LEVEL to a slog level. It returns false for an unknown level.
+func parseLogLevel(v string) (slog.Level, bool) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "debug":
+		return slog.LevelDebug, true
+	case "info":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	}
+	return slog.LevelInfo, false
+}
+
+// newLogger returns a logger writing to w at the level, as JSON or as text for LOG_FORMAT=text.
+// An unknown level falls back to info and is warned about on the returned logger.
+func newLogger(w io.Writer, level, format string) *slog.Logger {
+	lvl, ok := parseLogLevel(level)
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	if strings.EqualFold(strings.TrimSpace(format), logFormatText) {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	logger := slog.New(handler)
+	if !ok {
+		logger.Warn("invalid LOG_LEVEL, using info", "value", logValue(level))
+	}
+	return logger
+}
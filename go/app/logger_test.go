@@ -0,0 +1,92 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNewLoggerFromEnv(t *testing.T) {
+	cases := map[string]struct {
+		level  string
+		format string
+		// enabled and disabled are the levels expected to be logged and dropped.
+		enabled  slog.Level
+		disabled slog.Level
+		text     bool
+		warned   bool
+	}{
+		"ok: default": {
+			enabled:  slog.LevelInfo,
+			disabled: slog.LevelDebug,
+		},
+		"ok: debug": {
+			level:    "debug",
+			enabled:  slog.LevelDebug,
+			disabled: slog.LevelDebug - 1,
+		},
+		"ok: warn in upper case": {
+			level:    "WARN",
+			enabled:  slog.LevelWarn,
+			disabled: slog.LevelInfo,
+		},
+		"ok: error": {
+			level:    "error",
+			enabled:  slog.LevelError,
+			disabled: slog.LevelWarn,
+		},
+		"ok: text format": {
+			level:    "info",
+			format:   "text",
+			enabled:  slog.LevelInfo,
+			disabled: slog.LevelDebug,
+			text:     true,
+		},
+		"ng: invalid level falls back to info": {
+			level:    "verbose",
+			enabled:  slog.LevelInfo,
+			disabled: slog.LevelDebug,
+			warned:   true,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Runと同じく、環境変数からフラグを経由してロガーを作る
+			if tt.level != "" {
+				t.Setenv("LOG_LEVEL", tt.level)
+			}
+			if tt.format != "" {
+				t.Setenv("LOG_FORMAT", tt.format)
+			}
+			flags := NewFlags(flagSpecs)
+			flags.SeedFromEnv(os.LookupEnv)
+
+			var buf bytes.Buffer
+			logger := newLogger(&buf, flags.String(flagLogLevel), flags.String(flagLogFormat))
+
+			ctx := context.Background()
+			if !logger.Enabled(ctx, tt.enabled) {
+				t.Errorf("expected level %v to be enabled", tt.enabled)
+			}
+			if logger.Enabled(ctx, tt.disabled) {
+				t.Errorf("expected level %v to be disabled", tt.disabled)
+			}
+
+			warned := strings.Contains(buf.String(), "invalid LOG_LEVEL")
+			if warned != tt.warned {
+				t.Errorf("expected warning %v, got output %q", tt.warned, buf.String())
+			}
+
+			buf.Reset()
+			logger.Error("hello")
+			if isJSON := json.Valid(bytes.TrimSpace(buf.Bytes())); isJSON == tt.text {
+				t.Errorf("expected text format %v, got %q", tt.text, buf.String())
+			}
+		})
+	}
+}
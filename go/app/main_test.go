@@ -0,0 +1,30 @@
+package app
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"mercari-build-training/app/apptest"
+)
+
+// テストが起動したgoroutineが残っていたら失敗にする
+func TestMain(m *testing.M) {
+	os.Exit(apptest.VerifyNoLeaks(m))
+}
+
+// startViewCounter starts a viewCounter stopped when the test finishes.
+func startViewCounter(t testing.TB, store viewsStore, interval time.Duration) *viewCounter {
+	t.Helper()
+	c := newViewCounter(store, interval)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// startSerializedItemRepository starts a serializedItemRepository stopped when the test finishes.
+func startSerializedItemRepository(t testing.TB, reads, writes ItemRepository) *serializedItemRepository {
+	t.Helper()
+	s := NewSerializedItemRepository(reads, writes)
+	t.Cleanup(s.Close)
+	return s
+}
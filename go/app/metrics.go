@@ -0,0 +1,252 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// リクエスト数とレイテンシのメトリクス (GET /metrics)
+// Prometheusのテキスト形式で出力する
+// ラベルのrouteには、商品IDなどで種類が増えないように、実際のパスではなく登録したパターンを使う
+// エンドポイントごとのエラー率で警告を出せるように、ステータスの種類 (2xx/4xx/5xx) ごとの件数も出す
+
+// metricsContentType is the content type of the Prometheus text exposition format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricsBuckets are the upper bounds in seconds of the latency histogram, the same as the Prometheus default.
+var metricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metricsMethods are the methods used as the method label. Others are counted as "other".
+var metricsMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// metricsUnmatchedRoute is the route label of requests that matched no pattern, e.g. a method not allowed.
+const metricsUnmatchedRoute = "unmatched"
+
+// requestKey is the labels of http_requests_total.
+type requestKey struct {
+	method string
+	route  string
+	status int
+}
+
+// responseKey is the labels of http_responses_total.
+type responseKey struct {
+	method string
+	route  string
+	class  string
+}
+
+// durationKey is the labels of http_request_duration_seconds.
+type durationKey struct {
+	method string
+	route  string
+}
+
+// histogram is a cumulative histogram over metricsBuckets.
+type histogram struct {
+	// counts[i] is the number of observations of at most metricsBuckets[i].
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// metrics is the registry of the request metrics. It is safe for concurrent use.
+type metrics struct {
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	responses map[responseKey]uint64
+	durations map[durationKey]*histogram
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:  map[requestKey]uint64{},
+		responses: map[responseKey]uint64{},
+		durations: map[durationKey]*histogram{},
+	}
+}
+
+// observe records a request.
+func (m *metrics) observe(method, route string, status int, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{method: method, route: route, status: status}]++
+	m.responses[responseKey{method: method, route: route, class: statusClass(status)}]++
+	h, ok := m.durations[durationKey{method: method, route: route}]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(metricsBuckets))}
+		m.durations[durationKey{method: method, route: route}] = h
+	}
+	seconds := elapsed.Seconds()
+	for i, le := range metricsBuckets {
+		if seconds <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// requestCount returns the value of http_requests_total with the labels.
+func (m *metrics) requestCount(method, route string, status int) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests[requestKey{method: method, route: route, status: status}]
+}
+
+// responseCount returns the value of http_responses_total with the labels.
+func (m *metrics) responseCount(method, route, class string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.responses[responseKey{method: method, route: route, class: class}]
+}
+
+// statusClass returns the class label of a status code, such as "2xx" for 201.
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// writeTo writes the metrics in the Prometheus text exposition format, sorted by labels.
+func (m *metrics) writeTo(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("# HELP http_requests_total Number of HTTP requests by method, route pattern and status.\n")
+	b.WriteString("# TYPE http_requests_total counter\n")
+	requestKeys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		requestKeys = append(requestKeys, k)
+	}
+	slices.SortFunc(requestKeys, func(a, b requestKey) int {
+		return strings.Compare(fmt.Sprintf("%s %s %d", a.route, a.method, a.status), fmt.Sprintf("%s %s %d", b.route, b.method, b.status))
+	})
+	for _, k := range requestKeys {
+		fmt.Fprintf(&b, "http_requests_total{method=%q,route=%q,status=\"%d\"} %d\n", k.method, k.route, k.status, m.requests[k])
+	}
+
+	b.WriteString("# HELP http_responses_total Number of HTTP responses by method, route pattern and status class.\n")
+	b.WriteString("# TYPE http_responses_total counter\n")
+	responseKeys := make([]responseKey, 0, len(m.responses))
+	for k := range m.responses {
+		responseKeys = append(responseKeys, k)
+	}
+	slices.SortFunc(responseKeys, func(a, b responseKey) int {
+		return strings.Compare(a.route+" "+a.method+" "+a.class, b.route+" "+b.method+" "+b.class)
+	})
+	for _, k := range responseKeys {
+		fmt.Fprintf(&b, "http_responses_total{method=%q,route=%q,class=%q} %d\n", k.method, k.route, k.class, m.responses[k])
+	}
+
+	b.WriteString("# HELP http_request_duration_seconds Latency of HTTP requests by method and route pattern.\n")
+	b.WriteString("# TYPE http_request_duration_seconds histogram\n")
+	durationKeys := make([]durationKey, 0, len(m.durations))
+	for k := range m.durations {
+		durationKeys = append(durationKeys, k)
+	}
+	slices.SortFunc(durationKeys, func(a, b durationKey) int {
+		return strings.Compare(a.route+" "+a.method, b.route+" "+b.method)
+	})
+	for _, k := range durationKeys {
+		h := m.durations[k]
+		labels := fmt.Sprintf("method=%q,route=%q", k.method, k.route)
+		for i, le := range metricsBuckets {
+			fmt.Fprintf(&b, "http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, strconv.FormatFloat(le, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(&b, "http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(&b, "http_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// metricsLabels returns the method and route labels of a request matching the ServeMux pattern.
+func metricsLabels(r *http.Request, pattern string) (method, route string) {
+	method = r.Method
+	if !slices.Contains(metricsMethods, method) {
+		method = "other"
+	}
+	// ServeMuxのパターンは "GET /items/{item_id}" のようにメソッド付きのことがある
+	route = pattern
+	if _, path, ok := strings.Cut(route, " "); ok {
+		route = path
+	}
+	if route == "" {
+		route = metricsUnmatchedRoute
+	}
+	return method, route
+}
+
+// metricsMiddleware records the count and latency of each request served by next, labeled by the route of mux.
+// next is mux or a middleware in front of it, so that requests rejected before reaching mux, such as 401s
+// of apiKeyMiddleware, are counted under the route they were sent to.
+func metricsMiddleware(next http.Handler, mux *http.ServeMux, m *metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		// r.Pattern はmuxまで届いたときしか設定されないので、ルートはmuxに直接尋ねる
+		_, pattern := mux.Handler(r)
+		sw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		// 途中で打ち切られたリクエスト (http.ErrAbortHandler) も数える
+		defer func() {
+			method, route := metricsLabels(r, pattern)
+			m.observe(method, route, sw.status, time.Since(start))
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sw *statusRecorder) WriteHeader(status int) {
+	if !sw.wroteHeader {
+		sw.status = status
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusRecorder) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(b)
+}
+
+// Flush keeps GET /search streaming through the recorder.
+func (sw *statusRecorder) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (sw *statusRecorder) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// Metrics is a handler to return the request metrics for GET /metrics .
+func (s *Handlers) Metrics(w http.ResponseWriter, r *http.Request) {
+	if s.metrics == nil {
+		s.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", metricsContentType)
+	if err := s.metrics.writeTo(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
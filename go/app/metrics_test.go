@@ -0,0 +1,229 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	t.Parallel()
+
+	m := newMetrics()
+	h := &Handlers{metrics: m}
+	mux := newMux([]route{
+		{"GET /items/{item_id}", func(w http.ResponseWriter, r *http.Request) {
+			if r.PathValue("item_id") == "3" {
+				http.Error(w, "item not found", http.StatusNotFound)
+				return
+			}
+			w.Write([]byte("{}"))
+		}},
+		{"GET /metrics", h.Metrics},
+		{"/", h.NotFound},
+	})
+	handler := metricsMiddleware(mux, mux, m)
+
+	for _, target := range []string{"/items/1", "/items/2", "/items/3", "/unknown"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	cases := map[string]struct {
+		route  string
+		status int
+		want   uint64
+	}{
+		"pattern instead of path": {route: "/items/{item_id}", status: http.StatusOK, want: 2},
+		"status is a label":       {route: "/items/{item_id}", status: http.StatusNotFound, want: 1},
+		"catch-all":               {route: "/", status: http.StatusNotFound, want: 1},
+		"raw path is not a label": {route: "/items/1", status: http.StatusOK, want: 0},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := m.requestCount(http.MethodGet, tt.route, tt.status); got != tt.want {
+				t.Errorf("expected %d requests, got %d", tt.want, got)
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != metricsContentType {
+		t.Errorf("expected content type %q, got %q", metricsContentType, got)
+	}
+	body := rr.Body.String()
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="/items/{item_id}",status="200"} 2`,
+		`http_requests_total{method="GET",route="/items/{item_id}",status="404"} 1`,
+		`http_request_duration_seconds_bucket{method="GET",route="/items/{item_id}",le="+Inf"} 3`,
+		`http_request_duration_seconds_count{method="GET",route="/items/{item_id}"} 3`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected %q in the metrics:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, `route="/items/1"`) {
+		t.Errorf("expected no raw path in the metrics:\n%s", body)
+	}
+}
+
+func TestMetricsStatusClass(t *testing.T) {
+	t.Parallel()
+
+	m := newMetrics()
+	mux := newMux([]route{
+		{"POST /items", func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Query().Get("result") {
+			case "missing":
+				http.Error(w, "item not found", http.StatusNotFound)
+			case "broken":
+				http.Error(w, "failed to insert", http.StatusInternalServerError)
+			default:
+				w.WriteHeader(http.StatusCreated)
+			}
+		}},
+	})
+	handler := metricsMiddleware(mux, mux, m)
+
+	for _, target := range []string{"/items", "/items", "/items?result=missing", "/items?result=broken"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", target, nil))
+	}
+
+	cases := map[string]struct {
+		class string
+		want  uint64
+	}{
+		"2xx": {class: "2xx", want: 2},
+		"4xx": {class: "4xx", want: 1},
+		"5xx": {class: "5xx", want: 1},
+		"3xx": {class: "3xx", want: 0},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := m.responseCount(http.MethodPost, "/items", tt.class); got != tt.want {
+				t.Errorf("expected %d responses, got %d", tt.want, got)
+			}
+		})
+	}
+
+	var b strings.Builder
+	if err := m.writeTo(&b); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	for _, line := range []string{
+		`http_responses_total{method="POST",route="/items",class="2xx"} 2`,
+		`http_responses_total{method="POST",route="/items",class="4xx"} 1`,
+		`http_responses_total{method="POST",route="/items",class="5xx"} 1`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("expected %q in the metrics:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestMetricsCountsUnauthorized(t *testing.T) {
+	t.Parallel()
+
+	m := newMetrics()
+	mux := newMux([]route{
+		{"POST /items", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		}},
+	})
+	// 本番と同じく、キーの確認より外側で数える
+	handler := metricsMiddleware(apiKeyMiddleware(mux, "secret"), mux, m)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/items", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+	req := httptest.NewRequest("POST", "/items", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := m.responseCount(http.MethodPost, "/items", "4xx"); got != 1 {
+		t.Errorf("expected 1 response of 4xx, got %d", got)
+	}
+	if got := m.requestCount(http.MethodPost, "/items", http.StatusUnauthorized); got != 1 {
+		t.Errorf("expected 1 request of 401, got %d", got)
+	}
+	if got := m.responseCount(http.MethodPost, "/items", "2xx"); got != 1 {
+		t.Errorf("expected 1 response of 2xx, got %d", got)
+	}
+}
+
+func TestMetricsHistogram(t *testing.T) {
+	t.Parallel()
+
+	m := newMetrics()
+	for _, d := range []time.Duration{3 * time.Millisecond, 80 * time.Millisecond, 80 * time.Millisecond, 20 * time.Second} {
+		m.observe(http.MethodGet, "/items", http.StatusOK, d)
+	}
+
+	var b strings.Builder
+	if err := m.writeTo(&b); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	for _, line := range []string{
+		`http_request_duration_seconds_bucket{method="GET",route="/items",le="0.005"} 1`,
+		`http_request_duration_seconds_bucket{method="GET",route="/items",le="0.05"} 1`,
+		`http_request_duration_seconds_bucket{method="GET",route="/items",le="0.1"} 3`,
+		`http_request_duration_seconds_bucket{method="GET",route="/items",le="10"} 3`,
+		`http_request_duration_seconds_bucket{method="GET",route="/items",le="+Inf"} 4`,
+		`http_request_duration_seconds_sum{method="GET",route="/items"} 20.163`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("expected %q in the metrics:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestMetricsLabels(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		method     string
+		pattern    string
+		wantMethod string
+		wantRoute  string
+	}{
+		"pattern with method":    {method: "GET", pattern: "GET /items/{item_id}", wantMethod: "GET", wantRoute: "/items/{item_id}"},
+		"pattern without method": {method: "POST", pattern: "/", wantMethod: "POST", wantRoute: "/"},
+		"unmatched":              {method: "GET", pattern: "", wantMethod: "GET", wantRoute: metricsUnmatchedRoute},
+		"unknown method":         {method: "BREW", pattern: "/", wantMethod: "other", wantRoute: "/"},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(tt.method, "/", nil)
+			method, route := metricsLabels(r, tt.pattern)
+			if method != tt.wantMethod || route != tt.wantRoute {
+				t.Errorf("expected (%q, %q), got (%q, %q)", tt.wantMethod, tt.wantRoute, method, route)
+			}
+		})
+	}
+}
+
+func TestStatusRecorderFlush(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	sw := &statusRecorder{ResponseWriter: rr, status: http.StatusOK}
+	sw.WriteHeader(http.StatusAccepted)
+	sw.WriteHeader(http.StatusInternalServerError)
+	sw.Flush()
+	if !rr.Flushed {
+		t.Error("expected the flush to reach the underlying writer")
+	}
+	if sw.status != http.StatusAccepted {
+		t.Errorf("expected the first status %d, got %d", http.StatusAccepted, sw.status)
+	}
+}
@@ -3,18 +3,56 @@ package app
 import (
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 )
 
 // This file provides some utility functions for middleware.
 // You do not have to modify this file.
 
+// parseOrigins parses a comma-separated list of origins such as FRONT_URL, dropping empty entries.
+func parseOrigins(v string) []string {
+	var origins []string
+	for _, origin := range strings.Split(v, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
 // CORSを有効にする
-func simpleCORSMiddleware(next http.Handler, origin string, methods []string) http.Handler {
+// リクエストのOriginが許可リストにあればそのまま返し、なければCORSのヘッダーを付けない
+// 許可リストに "*" があれば、全てのOriginを許可する
+func simpleCORSMiddleware(next http.Handler, origins []string, methods []string) http.Handler {
+	wildcard := slices.Contains(origins, "*")
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", origin)
-		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ","))
-		w.Header().Set("Access-Control-Allow-Headers", "*")
+		origin := r.Header.Get("Origin")
+		allowed := true
+		switch {
+		case wildcard:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && slices.Contains(origins, origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		default:
+			allowed = false
+		}
+		if !wildcard {
+			// 返すヘッダーがOriginによって変わるので、キャッシュに区別させる
+			w.Header().Add("Vary", "Origin")
+		}
+
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ","))
+			// 要求されたヘッダーはそのまま許可する (資格情報付きのリクエストでは "*" が使えないため)
+			if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				w.Header().Set("Access-Control-Allow-Headers", requested)
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			} else {
+				w.Header().Set("Access-Control-Allow-Headers", "*")
+			}
+		}
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -26,9 +64,45 @@ func simpleCORSMiddleware(next http.Handler, origin string, methods []string) ht
 }
 
 // HTTPリクエストに関する情報をログに出力
-func simpleLoggerMiddleware(next http.Handler) http.Handler {
+// slowThresholdを超えたリクエストは、チェックポイントごとの内訳も追加で出力する (0なら無効)
+// 実行中に閾値を変えられるように、リクエストごとに閾値を取得する
+func simpleLoggerMiddleware(next http.Handler, slowThreshold func() time.Duration) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		slog.Info("request received", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr, "user_agent", r.UserAgent())
-		next.ServeHTTP(w, r)
+		slog.Info("request received", "request_id", requestIDFrom(r.Context()), "method", r.Method, "path", logValue(r.URL.Path), "remote_addr", r.RemoteAddr, "user_agent", logValue(r.UserAgent()))
+
+		ctx, trace := startTrace(r.Context())
+		defer releaseTrace(trace)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+
+		elapsed := time.Since(trace.start)
+		threshold := slowThreshold()
+		if threshold <= 0 || elapsed <= threshold {
+			return
+		}
+		logSlowRequest(r, elapsed, trace)
 	})
 }
+
+// logSlowRequest emits the checkpoint breakdown of a request that exceeded the slow threshold.
+func logSlowRequest(r *http.Request, elapsed time.Duration, trace *requestTrace) {
+	type checkpointLog struct {
+		Name string  `json:"name"`
+		Ms   float64 `json:"ms"`
+	}
+	checkpoints := make([]checkpointLog, 0, len(trace.spans))
+	for _, s := range trace.spans {
+		checkpoints = append(checkpoints, checkpointLog{Name: s.Name, Ms: float64(s.Duration.Microseconds()) / 1000})
+	}
+	// プールに戻すとスライスが再利用されるので、ログ用にコピーしておく
+	queries := append([]string{}, trace.queries...)
+
+	slog.Warn("slow request",
+		"request_id", requestIDFrom(r.Context()),
+		"method", r.Method,
+		"path", logValue(r.URL.Path),
+		"duration_ms", float64(elapsed.Microseconds())/1000,
+		"checkpoints", checkpoints,
+		"queries", queries,
+	)
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRouteMethods(t *testing.T) {
+	t.Parallel()
+
+	got := routeMethods((&Handlers{}).routes())
+	// 登録されているルートのメソッドが全て含まれる
+	want := []string{"DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected methods (-want +got):\n%s", diff)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	t.Parallel()
+
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+	methods := routeMethods((&Handlers{}).routes())
+	handler := simpleCORSMiddleware(next, []string{"http://localhost:3000"}, methods)
+
+	cases := map[string]struct {
+		requestHeaders string
+		wantHeaders    string
+	}{
+		"ok: echo requested headers": {
+			requestHeaders: "Content-Type, X-Client-Token",
+			wantHeaders:    "Content-Type, X-Client-Token",
+		},
+		"ok: no requested headers": {
+			wantHeaders: "*",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("OPTIONS", "/items/1/favorite", nil)
+			req.Header.Set("Origin", "http://localhost:3000")
+			req.Header.Set("Access-Control-Request-Method", "DELETE")
+			if tt.requestHeaders != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.requestHeaders)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:3000" {
+				t.Errorf("unexpected Access-Control-Allow-Origin: %q", got)
+			}
+			allowed := strings.Split(rr.Header().Get("Access-Control-Allow-Methods"), ",")
+			for _, method := range []string{"DELETE", "PATCH"} {
+				if !slices.Contains(allowed, method) {
+					t.Errorf("expected %s in Access-Control-Allow-Methods, got %v", method, allowed)
+				}
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Headers"); got != tt.wantHeaders {
+				t.Errorf("expected Access-Control-Allow-Headers %q, got %q", tt.wantHeaders, got)
+			}
+		})
+	}
+	if called {
+		t.Error("expected the preflight not to reach the handler")
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	const (
+		dev     = "http://localhost:3000"
+		preview = "https://preview.example.com"
+	)
+
+	cases := map[string]struct {
+		frontURL string
+		origin   string
+		// wantOrigin is the expected Access-Control-Allow-Origin. Empty means no CORS headers.
+		wantOrigin string
+	}{
+		"ok: first allowed origin": {
+			frontURL:   dev + ", " + preview,
+			origin:     dev,
+			wantOrigin: dev,
+		},
+		"ok: second allowed origin": {
+			frontURL:   dev + ", " + preview,
+			origin:     preview,
+			wantOrigin: preview,
+		},
+		"ng: disallowed origin": {
+			frontURL: dev + ", " + preview,
+			origin:   "https://evil.example.com",
+		},
+		"ng: no origin": {
+			frontURL: dev,
+		},
+		"ok: wildcard": {
+			frontURL:   "*",
+			origin:     "https://evil.example.com",
+			wantOrigin: "*",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := simpleCORSMiddleware(next, parseOrigins(tt.frontURL), []string{"GET", "OPTIONS"})
+			req := httptest.NewRequest("GET", "/items", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.wantOrigin, got)
+			}
+			if tt.wantOrigin == "" {
+				for _, h := range []string{"Access-Control-Allow-Methods", "Access-Control-Allow-Headers"} {
+					if got := rr.Header().Get(h); got != "" {
+						t.Errorf("expected no %s, got %q", h, got)
+					}
+				}
+			}
+			// 許可リストで判定している場合は、キャッシュがOriginごとに分かれるようにする
+			if tt.frontURL != "*" && !slices.Contains(rr.Header().Values("Vary"), "Origin") {
+				t.Errorf("expected Vary: Origin, got %v", rr.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestParseOrigins(t *testing.T) {
+	t.Parallel()
+
+	got := parseOrigins(" http://localhost:3000 ,, https://preview.example.com,")
+	want := []string{"http://localhost:3000", "https://preview.example.com"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected origins (-want +got):\n%s", diff)
+	}
+}
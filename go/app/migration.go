@@ -0,0 +1,258 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// db/items.sql は CREATE TABLE IF NOT EXISTS なので、既存のテーブルに後から追加したカラムは作られない
+// ここで足りないカラムを追加し、既存の行を埋める
+
+// initSchema creates the tables from schema and migrates an existing database to the current schema.
+// now is used to backfill timestamps of rows created before the columns existed.
+func initSchema(db *sql.DB, schema string, now time.Time) error {
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+	return migrate(db, now)
+}
+
+// migrate adds the columns missing from an older database and backfills them.
+func migrate(db *sql.DB, now time.Time) error {
+	for _, column := range []string{"created_at", "updated_at", "deleted_at"} {
+		if err := addColumnIfMissing(db, "items", column, "TEXT"); err != nil {
+			return err
+		}
+	}
+	if err := addColumnIfMissing(db, "items", "status", "TEXT NOT NULL DEFAULT 'on_sale' CHECK (status IN ('on_sale', 'sold'))"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "items", "price", "INTEGER NOT NULL DEFAULT 0 CHECK (price >= 0)"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "items", "sort_order", "INTEGER"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "items", "view_count", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "items", "seller", "TEXT NOT NULL DEFAULT '"+defaultSeller+"'"); err != nil {
+		return err
+	}
+	// 既存の商品は中古 (used) として扱う
+	if err := addColumnIfMissing(db, "items", "condition", "TEXT NOT NULL DEFAULT '"+itemConditionUsed+"' CHECK (condition IN ('new', 'like_new', 'used', 'junk'))"); err != nil {
+		return err
+	}
+	// 既存の商品は1点ものとして扱い、売り切れの商品は在庫0にする
+	hasQuantity, err := columnExists(db, "items", "quantity")
+	if err != nil {
+		return err
+	}
+	if !hasQuantity {
+		if err := addColumnIfMissing(db, "items", "quantity", "INTEGER NOT NULL DEFAULT 1 CHECK (quantity >= 0)"); err != nil {
+			return err
+		}
+		if _, err := db.Exec(`UPDATE items SET quantity = 0 WHERE status = ?`, itemStatusSold); err != nil {
+			return fmt.Errorf("failed to backfill quantity: %w", err)
+		}
+	}
+	if err := addColumnIfMissing(db, "items", "brand", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_items_brand ON items (brand COLLATE NOCASE)`); err != nil {
+		return fmt.Errorf("failed to create index on items.brand: %w", err)
+	}
+	// 通貨の導入前の価格はすべて円
+	if err := addColumnIfMissing(db, "items", "price_currency", "TEXT NOT NULL DEFAULT 'JPY'"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "items", "shipping_payer", "TEXT NOT NULL DEFAULT '"+shippingPayerSeller+"' CHECK (shipping_payer IN ('seller', 'buyer'))"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "items", "shipping_days", fmt.Sprintf("INTEGER NOT NULL DEFAULT %d CHECK (shipping_days BETWEEN %d AND %d)", defaultShippingDays, minShippingDays, maxShippingDays)); err != nil {
+		return err
+	}
+	// 下書きの導入前の商品はすべて公開済み
+	if err := addColumnIfMissing(db, "items", "is_published", "INTEGER NOT NULL DEFAULT 1 CHECK (is_published IN (0, 1))"); err != nil {
+		return err
+	}
+	// 検索用の名前は、追加した後に既存の商品の分を埋める
+	if err := addColumnIfMissing(db, "items", "name_normalized", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := backfillNameNormalized(db); err != nil {
+		return fmt.Errorf("failed to backfill name_normalized: %w", err)
+	}
+	// 出品者ごとの一覧のため (カラムを追加した後でないと作れないので、スキーマではなくここで作る)
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_items_seller ON items (seller)`); err != nil {
+		return fmt.Errorf("failed to create index on items.seller: %w", err)
+	}
+	ts := formatTimestamp(now.UTC().Truncate(time.Second))
+	if _, err := db.Exec(`UPDATE items SET created_at = ? WHERE created_at IS NULL`, ts); err != nil {
+		return fmt.Errorf("failed to backfill created_at: %w", err)
+	}
+	if _, err := db.Exec(`UPDATE items SET updated_at = created_at WHERE updated_at IS NULL`); err != nil {
+		return fmt.Errorf("failed to backfill updated_at: %w", err)
+	}
+	if err := mergeDuplicateCategories(db); err != nil {
+		return fmt.Errorf("failed to merge duplicate categories: %w", err)
+	}
+	return nil
+}
+
+// backfillNameNormalized sets name_normalized of the items that do not have it yet, i.e. those added before the column.
+func backfillNameNormalized(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, name FROM items WHERE name_normalized = '' AND name <> ''`)
+	if err != nil {
+		return err
+	}
+	names := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		names[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for id, name := range names {
+		if _, err := tx.Exec(`UPDATE items SET name_normalized = ? WHERE id = ?`, normalizeSearchText(name), id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// mergeDuplicateCategories merges categories whose names differ only by leading/trailing whitespace or case,
+// such as "Shoes" and "shoes ", which were created before Insert started normalizing category names.
+// Items are moved to the category with the normalized name, and the duplicates are deleted.
+func mergeDuplicateCategories(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, name FROM categories ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	type category struct {
+		id   int64
+		name string
+	}
+	groups := map[string][]category{}
+	var order []string
+	for rows.Next() {
+		var c category
+		if err := rows.Scan(&c.id, &c.name); err != nil {
+			rows.Close()
+			return err
+		}
+		key := normalizeCategory(c.name)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], c)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, name := range order {
+		group := groups[name]
+		// 既に正規化済みの名前の行があればそれを残し、なければ一番古い行を残す
+		keep := group[0]
+		for _, c := range group {
+			if c.name == name {
+				keep = c
+				break
+			}
+		}
+		for _, c := range group {
+			if c.id == keep.id {
+				continue
+			}
+			if _, err := tx.Exec(`UPDATE items SET category_id = ? WHERE category_id = ?`, keep.id, c.id); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM categories WHERE id = ?`, c.id); err != nil {
+				return err
+			}
+			changed = true
+		}
+		// UNIQUE制約に引っかからないように、重複を消してから名前を直す
+		if keep.name != name {
+			if _, err := tx.Exec(`UPDATE categories SET name = ? WHERE id = ?`, name, keep.id); err != nil {
+				return err
+			}
+			changed = true
+		}
+	}
+	if changed {
+		if err := bumpCategoriesVersion(context.Background(), tx); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// addColumnIfMissing adds a column to the table unless it already exists.
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	exists, err := columnExists(db, table, column)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// columnExists reports whether the table has the column.
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			typ        string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultVal, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMigrateBackfillsTimestamps(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "old.sqlite3"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// タイムスタンプのカラムがない古いスキーマ
+	_, err = db.Exec(`
+		CREATE TABLE items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			category_id INTEGER NOT NULL,
+			image_name TEXT NOT NULL
+		);
+		CREATE TABLE categories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE
+		);
+		INSERT INTO categories (name) VALUES ('old');
+		INSERT INTO items (name, category_id, image_name) VALUES ('Ｏｌｄ Item', 1, 'default.jpg');
+	`)
+	if err != nil {
+		t.Fatalf("failed to create old schema: %v", err)
+	}
+
+	schema, err := os.ReadFile("../db/items.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	now := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	if err := initSchema(db, string(schema), now); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	repo := &itemRepository{db: db}
+	item, err := repo.GetItemById(t.Context(), "1")
+	if err != nil {
+		t.Fatalf("failed to get item: %v", err)
+	}
+	if !item.CreatedAt.Equal(now) || !item.UpdatedAt.Equal(now) {
+		t.Errorf("expected timestamps to be backfilled with %v, got created_at=%v updated_at=%v", now, item.CreatedAt, item.UpdatedAt)
+	}
+	if item.Condition != itemConditionUsed {
+		t.Errorf("expected condition %q for an old item, got %q", itemConditionUsed, item.Condition)
+	}
+	if item.Quantity != 1 {
+		t.Errorf("expected quantity 1 for an old item, got %d", item.Quantity)
+	}
+	if item.Price.Currency != defaultCurrency {
+		t.Errorf("expected currency %q for an old item, got %q", defaultCurrency, item.Price.Currency)
+	}
+	if item.Draft {
+		t.Error("expected an old item to be published")
+	}
+	if item.ShippingPayer != shippingPayerSeller || item.ShippingDays != defaultShippingDays {
+		t.Errorf("expected the default shipping for an old item, got (%s, %d)", item.ShippingPayer, item.ShippingDays)
+	}
+	if item.Seller != defaultSeller {
+		t.Errorf("expected seller %q for an old item, got %q", defaultSeller, item.Seller)
+	}
+	var normalized string
+	if err := db.QueryRow(`SELECT name_normalized FROM items WHERE id = 1`).Scan(&normalized); err != nil {
+		t.Fatalf("failed to get name_normalized: %v", err)
+	}
+	if normalized != "old item" {
+		t.Errorf("expected name_normalized %q to be backfilled, got %q", "old item", normalized)
+	}
+}
+
+func TestMergeDuplicateCategories(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	// 正規化される前に作られた重複カテゴリ
+	_, err = db.Exec(`
+		INSERT INTO categories (id, name) VALUES (1, ' shoes'), (2, 'shoes '), (3, 'bags'), (4, 'bags '), (5, 'Shoes'), (6, 'BAGS');
+		INSERT INTO items (name, category_id, image_name) VALUES ('a', 1, 'default.jpg'), ('b', 2, 'default.jpg'), ('c', 3, 'default.jpg'), ('d', 4, 'default.jpg'), ('e', 5, 'default.jpg'), ('f', 6, 'default.jpg');
+	`)
+	if err != nil {
+		t.Fatalf("failed to insert duplicate categories: %v", err)
+	}
+
+	if err := mergeDuplicateCategories(db); err != nil {
+		t.Fatalf("failed to merge categories: %v", err)
+	}
+
+	rows, err := db.Query(`SELECT categories.name, COUNT(items.id) FROM categories LEFT JOIN items ON items.category_id = categories.id GROUP BY categories.id ORDER BY categories.name`)
+	if err != nil {
+		t.Fatalf("failed to query categories: %v", err)
+	}
+	defer rows.Close()
+
+	got := map[string]int{}
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			t.Fatalf("failed to scan: %v", err)
+		}
+		got[name] = count
+	}
+	want := map[string]int{"bags": 3, "shoes": 3}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected categories (-want +got):\n%s", diff)
+	}
+}
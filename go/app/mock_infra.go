@@ -1,9 +1,9 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: C:\Users\maple\Desktop\mercari-build-training\go\app\infra.go
+// Source: infra.go
 //
 // Generated by this command:
 //
-//	mockgen -source=C:\Users\maple\Desktop\mercari-build-training\go\app\infra.go -package=app -destination=C:\Users\maple\Desktop\mercari-build-training\go\app\mock_infra.go
+//	mockgen -source=infra.go -package=app -destination=mock_infra.go
 //
 
 // Package app is a generated GoMock package.
@@ -11,11 +11,93 @@ package app
 
 import (
 	context "context"
+	sql "database/sql"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
 
+// MockrowScanner is a mock of rowScanner interface.
+type MockrowScanner struct {
+	ctrl     *gomock.Controller
+	recorder *MockrowScannerMockRecorder
+	isgomock struct{}
+}
+
+// MockrowScannerMockRecorder is the mock recorder for MockrowScanner.
+type MockrowScannerMockRecorder struct {
+	mock *MockrowScanner
+}
+
+// NewMockrowScanner creates a new mock instance.
+func NewMockrowScanner(ctrl *gomock.Controller) *MockrowScanner {
+	mock := &MockrowScanner{ctrl: ctrl}
+	mock.recorder = &MockrowScannerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockrowScanner) EXPECT() *MockrowScannerMockRecorder {
+	return m.recorder
+}
+
+// Scan mocks base method.
+func (m *MockrowScanner) Scan(dest ...any) error {
+	m.ctrl.T.Helper()
+	varargs := []any{}
+	for _, a := range dest {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Scan", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *MockrowScannerMockRecorder) Scan(dest ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockrowScanner)(nil).Scan), dest...)
+}
+
+// MockClock is a mock of Clock interface.
+type MockClock struct {
+	ctrl     *gomock.Controller
+	recorder *MockClockMockRecorder
+	isgomock struct{}
+}
+
+// MockClockMockRecorder is the mock recorder for MockClock.
+type MockClockMockRecorder struct {
+	mock *MockClock
+}
+
+// NewMockClock creates a new mock instance.
+func NewMockClock(ctrl *gomock.Controller) *MockClock {
+	mock := &MockClock{ctrl: ctrl}
+	mock.recorder = &MockClockMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClock) EXPECT() *MockClockMockRecorder {
+	return m.recorder
+}
+
+// Now mocks base method.
+func (m *MockClock) Now() time.Time {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Now")
+	ret0, _ := ret[0].(time.Time)
+	return ret0
+}
+
+// Now indicates an expected call of Now.
+func (mr *MockClockMockRecorder) Now() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Now", reflect.TypeOf((*MockClock)(nil).Now))
+}
+
 // MockItemRepository is a mock of ItemRepository interface.
 type MockItemRepository struct {
 	ctrl     *gomock.Controller
@@ -40,19 +122,198 @@ func (m *MockItemRepository) EXPECT() *MockItemRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AddFavorite mocks base method.
+func (m *MockItemRepository) AddFavorite(ctx context.Context, item_id, clientToken string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddFavorite", ctx, item_id, clientToken)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddFavorite indicates an expected call of AddFavorite.
+func (mr *MockItemRepositoryMockRecorder) AddFavorite(ctx, item_id, clientToken any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddFavorite", reflect.TypeOf((*MockItemRepository)(nil).AddFavorite), ctx, item_id, clientToken)
+}
+
+// AddViews mocks base method.
+func (m *MockItemRepository) AddViews(ctx context.Context, views map[int]int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddViews", ctx, views)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddViews indicates an expected call of AddViews.
+func (mr *MockItemRepositoryMockRecorder) AddViews(ctx, views any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddViews", reflect.TypeOf((*MockItemRepository)(nil).AddViews), ctx, views)
+}
+
+// CategoriesVersion mocks base method.
+func (m *MockItemRepository) CategoriesVersion(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CategoriesVersion", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CategoriesVersion indicates an expected call of CategoriesVersion.
+func (mr *MockItemRepositoryMockRecorder) CategoriesVersion(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CategoriesVersion", reflect.TypeOf((*MockItemRepository)(nil).CategoriesVersion), ctx)
+}
+
+// CheckCategoryHealth mocks base method.
+func (m *MockItemRepository) CheckCategoryHealth(ctx context.Context) ([]HealthIssue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckCategoryHealth", ctx)
+	ret0, _ := ret[0].([]HealthIssue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckCategoryHealth indicates an expected call of CheckCategoryHealth.
+func (mr *MockItemRepositoryMockRecorder) CheckCategoryHealth(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckCategoryHealth", reflect.TypeOf((*MockItemRepository)(nil).CheckCategoryHealth), ctx)
+}
+
+// Count mocks base method.
+func (m *MockItemRepository) Count(ctx context.Context, opts ItemListOptions) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", ctx, opts)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockItemRepositoryMockRecorder) Count(ctx, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockItemRepository)(nil).Count), ctx, opts)
+}
+
+// CountItemsByKeyword mocks base method.
+func (m *MockItemRepository) CountItemsByKeyword(ctx context.Context, filter SearchFilter) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountItemsByKeyword", ctx, filter)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountItemsByKeyword indicates an expected call of CountItemsByKeyword.
+func (mr *MockItemRepositoryMockRecorder) CountItemsByKeyword(ctx, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountItemsByKeyword", reflect.TypeOf((*MockItemRepository)(nil).CountItemsByKeyword), ctx, filter)
+}
+
+// CountItemsUsingImage mocks base method.
+func (m *MockItemRepository) CountItemsUsingImage(ctx context.Context, imageName string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountItemsUsingImage", ctx, imageName)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountItemsUsingImage indicates an expected call of CountItemsUsingImage.
+func (mr *MockItemRepositoryMockRecorder) CountItemsUsingImage(ctx, imageName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountItemsUsingImage", reflect.TypeOf((*MockItemRepository)(nil).CountItemsUsingImage), ctx, imageName)
+}
+
+// DeleteCategory mocks base method.
+func (m *MockItemRepository) DeleteCategory(ctx context.Context, id int, fallback string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCategory", ctx, id, fallback)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteCategory indicates an expected call of DeleteCategory.
+func (mr *MockItemRepositoryMockRecorder) DeleteCategory(ctx, id, fallback any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCategory", reflect.TypeOf((*MockItemRepository)(nil).DeleteCategory), ctx, id, fallback)
+}
+
 // GetAll mocks base method.
-func (m *MockItemRepository) GetAll(ctx context.Context) ([]Item, error) {
+func (m *MockItemRepository) GetAll(ctx context.Context, opts ItemListOptions) ([]Item, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll", ctx)
+	ret := m.ctrl.Call(m, "GetAll", ctx, opts)
 	ret0, _ := ret[0].([]Item)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAll indicates an expected call of GetAll.
-func (mr *MockItemRepositoryMockRecorder) GetAll(ctx any) *gomock.Call {
+func (mr *MockItemRepositoryMockRecorder) GetAll(ctx, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockItemRepository)(nil).GetAll), ctx, opts)
+}
+
+// GetCategories mocks base method.
+func (m *MockItemRepository) GetCategories(ctx context.Context) ([]Category, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCategories", ctx)
+	ret0, _ := ret[0].([]Category)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetCategories indicates an expected call of GetCategories.
+func (mr *MockItemRepositoryMockRecorder) GetCategories(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCategories", reflect.TypeOf((*MockItemRepository)(nil).GetCategories), ctx)
+}
+
+// GetCategoryItems mocks base method.
+func (m *MockItemRepository) GetCategoryItems(ctx context.Context, category string, excludeID, limit int) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCategoryItems", ctx, category, excludeID, limit)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCategoryItems indicates an expected call of GetCategoryItems.
+func (mr *MockItemRepositoryMockRecorder) GetCategoryItems(ctx, category, excludeID, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCategoryItems", reflect.TypeOf((*MockItemRepository)(nil).GetCategoryItems), ctx, category, excludeID, limit)
+}
+
+// GetFavorites mocks base method.
+func (m *MockItemRepository) GetFavorites(ctx context.Context, clientToken string) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFavorites", ctx, clientToken)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFavorites indicates an expected call of GetFavorites.
+func (mr *MockItemRepositoryMockRecorder) GetFavorites(ctx, clientToken any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFavorites", reflect.TypeOf((*MockItemRepository)(nil).GetFavorites), ctx, clientToken)
+}
+
+// GetImageNames mocks base method.
+func (m *MockItemRepository) GetImageNames(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImageNames", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImageNames indicates an expected call of GetImageNames.
+func (mr *MockItemRepositoryMockRecorder) GetImageNames(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockItemRepository)(nil).GetAll), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageNames", reflect.TypeOf((*MockItemRepository)(nil).GetImageNames), ctx)
 }
 
 // GetItemById mocks base method.
@@ -70,6 +331,112 @@ func (mr *MockItemRepositoryMockRecorder) GetItemById(ctx, item_id any) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItemById", reflect.TypeOf((*MockItemRepository)(nil).GetItemById), ctx, item_id)
 }
 
+// GetItemByImage mocks base method.
+func (m *MockItemRepository) GetItemByImage(ctx context.Context, imageName string) (Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetItemByImage", ctx, imageName)
+	ret0, _ := ret[0].(Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetItemByImage indicates an expected call of GetItemByImage.
+func (mr *MockItemRepositoryMockRecorder) GetItemByImage(ctx, imageName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItemByImage", reflect.TypeOf((*MockItemRepository)(nil).GetItemByImage), ctx, imageName)
+}
+
+// GetItemHistory mocks base method.
+func (m *MockItemRepository) GetItemHistory(ctx context.Context, item_id string) ([]ItemEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetItemHistory", ctx, item_id)
+	ret0, _ := ret[0].([]ItemEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetItemHistory indicates an expected call of GetItemHistory.
+func (mr *MockItemRepositoryMockRecorder) GetItemHistory(ctx, item_id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItemHistory", reflect.TypeOf((*MockItemRepository)(nil).GetItemHistory), ctx, item_id)
+}
+
+// GetItemsAfter mocks base method.
+func (m *MockItemRepository) GetItemsAfter(ctx context.Context, afterID, limit int) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetItemsAfter", ctx, afterID, limit)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetItemsAfter indicates an expected call of GetItemsAfter.
+func (mr *MockItemRepositoryMockRecorder) GetItemsAfter(ctx, afterID, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItemsAfter", reflect.TypeOf((*MockItemRepository)(nil).GetItemsAfter), ctx, afterID, limit)
+}
+
+// GetItemsBySeller mocks base method.
+func (m *MockItemRepository) GetItemsBySeller(ctx context.Context, seller string) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetItemsBySeller", ctx, seller)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetItemsBySeller indicates an expected call of GetItemsBySeller.
+func (mr *MockItemRepositoryMockRecorder) GetItemsBySeller(ctx, seller any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItemsBySeller", reflect.TypeOf((*MockItemRepository)(nil).GetItemsBySeller), ctx, seller)
+}
+
+// GetPage mocks base method.
+func (m *MockItemRepository) GetPage(ctx context.Context, opts ItemListOptions) ([]Item, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPage", ctx, opts)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetPage indicates an expected call of GetPage.
+func (mr *MockItemRepositoryMockRecorder) GetPage(ctx, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPage", reflect.TypeOf((*MockItemRepository)(nil).GetPage), ctx, opts)
+}
+
+// GetRecentItems mocks base method.
+func (m *MockItemRepository) GetRecentItems(ctx context.Context, limit int) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRecentItems", ctx, limit)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRecentItems indicates an expected call of GetRecentItems.
+func (mr *MockItemRepositoryMockRecorder) GetRecentItems(ctx, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecentItems", reflect.TypeOf((*MockItemRepository)(nil).GetRecentItems), ctx, limit)
+}
+
+// GetRelatedItems mocks base method.
+func (m *MockItemRepository) GetRelatedItems(ctx context.Context, item_id string, limit int) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRelatedItems", ctx, item_id, limit)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRelatedItems indicates an expected call of GetRelatedItems.
+func (mr *MockItemRepositoryMockRecorder) GetRelatedItems(ctx, item_id, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRelatedItems", reflect.TypeOf((*MockItemRepository)(nil).GetRelatedItems), ctx, item_id, limit)
+}
+
 // Insert mocks base method.
 func (m *MockItemRepository) Insert(ctx context.Context, item *Item) error {
 	m.ctrl.T.Helper()
@@ -84,17 +451,251 @@ func (mr *MockItemRepositoryMockRecorder) Insert(ctx, item any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockItemRepository)(nil).Insert), ctx, item)
 }
 
-// SearchItemsByKeyword mocks base method.
-func (m *MockItemRepository) SearchItemsByKeyword(ctx context.Context, keyword string) ([]Item, error) {
+// InsertMany mocks base method.
+func (m *MockItemRepository) InsertMany(ctx context.Context, items []*Item) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SearchItemsByKeyword", ctx, keyword)
+	ret := m.ctrl.Call(m, "InsertMany", ctx, items)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertMany indicates an expected call of InsertMany.
+func (mr *MockItemRepositoryMockRecorder) InsertMany(ctx, items any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertMany", reflect.TypeOf((*MockItemRepository)(nil).InsertMany), ctx, items)
+}
+
+// Ping mocks base method.
+func (m *MockItemRepository) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockItemRepositoryMockRecorder) Ping(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockItemRepository)(nil).Ping), ctx)
+}
+
+// Publish mocks base method.
+func (m *MockItemRepository) Publish(ctx context.Context, item_id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", ctx, item_id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockItemRepositoryMockRecorder) Publish(ctx, item_id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockItemRepository)(nil).Publish), ctx, item_id)
+}
+
+// Purchase mocks base method.
+func (m *MockItemRepository) Purchase(ctx context.Context, item_id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purchase", ctx, item_id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Purchase indicates an expected call of Purchase.
+func (mr *MockItemRepositoryMockRecorder) Purchase(ctx, item_id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purchase", reflect.TypeOf((*MockItemRepository)(nil).Purchase), ctx, item_id)
+}
+
+// Purge mocks base method.
+func (m *MockItemRepository) Purge(ctx context.Context, item_id string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", ctx, item_id)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockItemRepositoryMockRecorder) Purge(ctx, item_id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockItemRepository)(nil).Purge), ctx, item_id)
+}
+
+// RemoveFavorite mocks base method.
+func (m *MockItemRepository) RemoveFavorite(ctx context.Context, item_id, clientToken string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveFavorite", ctx, item_id, clientToken)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveFavorite indicates an expected call of RemoveFavorite.
+func (mr *MockItemRepositoryMockRecorder) RemoveFavorite(ctx, item_id, clientToken any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveFavorite", reflect.TypeOf((*MockItemRepository)(nil).RemoveFavorite), ctx, item_id, clientToken)
+}
+
+// Reorder mocks base method.
+func (m *MockItemRepository) Reorder(ctx context.Context, ids []int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reorder", ctx, ids)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reorder indicates an expected call of Reorder.
+func (mr *MockItemRepositoryMockRecorder) Reorder(ctx, ids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reorder", reflect.TypeOf((*MockItemRepository)(nil).Reorder), ctx, ids)
+}
+
+// Restore mocks base method.
+func (m *MockItemRepository) Restore(ctx context.Context, item_id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, item_id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockItemRepositoryMockRecorder) Restore(ctx, item_id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockItemRepository)(nil).Restore), ctx, item_id)
+}
+
+// SampleByCategory mocks base method.
+func (m *MockItemRepository) SampleByCategory(ctx context.Context, perCategory int) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SampleByCategory", ctx, perCategory)
 	ret0, _ := ret[0].([]Item)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// SampleByCategory indicates an expected call of SampleByCategory.
+func (mr *MockItemRepositoryMockRecorder) SampleByCategory(ctx, perCategory any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SampleByCategory", reflect.TypeOf((*MockItemRepository)(nil).SampleByCategory), ctx, perCategory)
+}
+
+// SearchItemsByKeyword mocks base method.
+func (m *MockItemRepository) SearchItemsByKeyword(ctx context.Context, filter SearchFilter, fn func(Item) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchItemsByKeyword", ctx, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
 // SearchItemsByKeyword indicates an expected call of SearchItemsByKeyword.
-func (mr *MockItemRepositoryMockRecorder) SearchItemsByKeyword(ctx, keyword any) *gomock.Call {
+func (mr *MockItemRepositoryMockRecorder) SearchItemsByKeyword(ctx, filter, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchItemsByKeyword", reflect.TypeOf((*MockItemRepository)(nil).SearchItemsByKeyword), ctx, filter, fn)
+}
+
+// SoftDelete mocks base method.
+func (m *MockItemRepository) SoftDelete(ctx context.Context, item_id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SoftDelete", ctx, item_id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SoftDelete indicates an expected call of SoftDelete.
+func (mr *MockItemRepositoryMockRecorder) SoftDelete(ctx, item_id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SoftDelete", reflect.TypeOf((*MockItemRepository)(nil).SoftDelete), ctx, item_id)
+}
+
+// Swap mocks base method.
+func (m *MockItemRepository) Swap(ctx context.Context, a, b int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Swap", ctx, a, b)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Swap indicates an expected call of Swap.
+func (mr *MockItemRepositoryMockRecorder) Swap(ctx, a, b any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Swap", reflect.TypeOf((*MockItemRepository)(nil).Swap), ctx, a, b)
+}
+
+// Update mocks base method.
+func (m *MockItemRepository) Update(ctx context.Context, item_id string, patch ItemPatch) (Item, Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, item_id, patch)
+	ret0, _ := ret[0].(Item)
+	ret1, _ := ret[1].(Item)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockItemRepositoryMockRecorder) Update(ctx, item_id, patch any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockItemRepository)(nil).Update), ctx, item_id, patch)
+}
+
+// Mockqueryer is a mock of queryer interface.
+type Mockqueryer struct {
+	ctrl     *gomock.Controller
+	recorder *MockqueryerMockRecorder
+	isgomock struct{}
+}
+
+// MockqueryerMockRecorder is the mock recorder for Mockqueryer.
+type MockqueryerMockRecorder struct {
+	mock *Mockqueryer
+}
+
+// NewMockqueryer creates a new mock instance.
+func NewMockqueryer(ctrl *gomock.Controller) *Mockqueryer {
+	mock := &Mockqueryer{ctrl: ctrl}
+	mock.recorder = &MockqueryerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockqueryer) EXPECT() *MockqueryerMockRecorder {
+	return m.recorder
+}
+
+// QueryContext mocks base method.
+func (m *Mockqueryer) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryContext", varargs...)
+	ret0, _ := ret[0].(*sql.Rows)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryContext indicates an expected call of QueryContext.
+func (mr *MockqueryerMockRecorder) QueryContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryContext", reflect.TypeOf((*Mockqueryer)(nil).QueryContext), varargs...)
+}
+
+// QueryRowContext mocks base method.
+func (m *Mockqueryer) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryRowContext", varargs...)
+	ret0, _ := ret[0].(*sql.Row)
+	return ret0
+}
+
+// QueryRowContext indicates an expected call of QueryRowContext.
+func (mr *MockqueryerMockRecorder) QueryRowContext(ctx, query any, args ...any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchItemsByKeyword", reflect.TypeOf((*MockItemRepository)(nil).SearchItemsByKeyword), ctx, keyword)
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRowContext", reflect.TypeOf((*Mockqueryer)(nil).QueryRowContext), varargs...)
 }
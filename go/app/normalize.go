@@ -0,0 +1,17 @@
+package app
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// 検索のための文字の正規化
+// 全角英数字 (ＢＡＧ) や半角カタカナ (ﾊﾞｯｸﾞ)、大文字小文字の違いで検索に漏れないように、
+// 保存する名前 (items.name_normalized) と検索語の両方を同じ形にそろえてから比べる
+
+// normalizeSearchText returns s in the form compared by search: NFKC, which folds full-width ASCII,
+// half-width katakana and compatibility characters such as ① and ㌔, followed by lower-casing.
+func normalizeSearchText(s string) string {
+	return strings.ToLower(norm.NFKC.String(s))
+}
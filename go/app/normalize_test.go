@@ -0,0 +1,117 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalizeSearchText(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		in   string
+		want string
+	}{
+		// 全角ASCII
+		"full-width upper case":  {in: "ＢＡＧ", want: "bag"},
+		"full-width lower case":  {in: "ｂａｇ", want: "bag"},
+		"full-width digits":      {in: "ｉＰｈｏｎｅ１５", want: "iphone15"},
+		"full-width symbols":     {in: "Ｔ－ｓｈｉｒｔ（Ｌ）", want: "t-shirt(l)"},
+		"full-width space":       {in: "ｒｅｄ　ｂａｇ", want: "red bag"},
+		"full-width percent":     {in: "１００％", want: "100%"},
+		"mixed width":            {in: "Ｎｉｋｅ shoes", want: "nike shoes"},
+		"ascii upper case":       {in: "BAG", want: "bag"},
+		"ascii title case":       {in: "Bag", want: "bag"},
+		"ascii lower case":       {in: "bag", want: "bag"},
+		"non-ascii upper case":   {in: "ÄPFEL", want: "äpfel"},
+		"decomposed latin":       {in: "Cafe\u0301", want: "caf\u00e9"},
+		"ligature":               {in: "ﬁsh", want: "fish"},
+		"circled digit":          {in: "①", want: "1"},
+		"roman numeral":          {in: "Ⅳ", want: "iv"},
+		"superscript":            {in: "m²", want: "m2"},
+		"trademark":              {in: "Brand™", want: "brandtm"},
+		"squared unit":           {in: "㌔", want: "キロ"},
+		"squared era":            {in: "㍻", want: "平成"},
+		"half-width katakana":    {in: "ｶﾒﾗ", want: "カメラ"},
+		"half-width dakuten":     {in: "ﾊﾞｯｸﾞ", want: "バッグ"},
+		"half-width handakuten":  {in: "ﾎﾟｰﾁ", want: "ポーチ"},
+		"half-width long vowel":  {in: "ｼｭｰｽﾞ", want: "シューズ"},
+		"decomposed katakana":    {in: "\u30ab\u3099メラ", want: "\u30acメラ"},
+		"full-width katakana":    {in: "カメラ", want: "カメラ"},
+		"hiragana is kept":       {in: "かめら", want: "かめら"},
+		"kanji is kept":          {in: "革の鞄", want: "革の鞄"},
+		"half-width punctuation": {in: "｢ﾊﾞｯｸﾞ｣", want: "「バッグ」"},
+		"empty":                  {in: "", want: ""},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := normalizeSearchText(tt.in); got != tt.want {
+				t.Errorf("normalizeSearchText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			// 正規化した結果をもう一度正規化しても変わらない
+			if got := normalizeSearchText(tt.want); got != tt.want {
+				t.Errorf("normalizeSearchText(%q) = %q, expected it to be unchanged", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSearchNormalizedE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "leather bag", Category: "fashion", Image: "default.jpg"},
+		{Name: "ＢＡＧ ｃｈａｒｍ", Category: "fashion", Image: "default.jpg"},
+		{Name: "ﾎﾟｰﾁ", Category: "fashion", Image: "default.jpg"},
+		{Name: "jacket", Category: "fashion", Image: "default.jpg"},
+	} {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	// 名前を変えると、検索用の名前も変わる
+	name := "Ｄｅｎｉｍ Jacket"
+	if _, _, err := repo.Update(t.Context(), "4", ItemPatch{Name: &name}); err != nil {
+		t.Fatalf("failed to update item: %v", err)
+	}
+
+	// 名前が検索語で始まる商品が先
+	cases := map[string]struct {
+		keyword string
+		names   []string
+	}{
+		"ok: lower case":              {keyword: "bag", names: []string{"ＢＡＧ ｃｈａｒｍ", "leather bag"}},
+		"ok: full-width keyword":      {keyword: "ＢＡＧ", names: []string{"ＢＡＧ ｃｈａｒｍ", "leather bag"}},
+		"ok: title case keyword":      {keyword: "Bag", names: []string{"ＢＡＧ ｃｈａｒｍ", "leather bag"}},
+		"ok: full-width item only":    {keyword: "charm", names: []string{"ＢＡＧ ｃｈａｒｍ"}},
+		"ok: katakana keyword":        {keyword: "ポーチ", names: []string{"ﾎﾟｰﾁ"}},
+		"ok: half-width keyword":      {keyword: "ﾎﾟｰﾁ", names: []string{"ﾎﾟｰﾁ"}},
+		"ok: updated name":            {keyword: "denim", names: []string{"Ｄｅｎｉｍ Jacket"}},
+		"ok: updated name full-width": {keyword: "ＪＡＣＫＥＴ", names: []string{"Ｄｅｎｉｍ Jacket"}},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.names, searchNames(t, repo, tt.keyword)); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,546 @@
+package app
+
+import (
+	"encoding/json"
+	"go/token"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIの仕様 (GET /openapi.json, OpenAPI 3.0)
+// 操作の一覧は手で書いているので、ルートを追加・変更したら openAPIOperations も直す (テストでルートの表と突き合わせている)
+// リクエストとレスポンスのスキーマはGoの型からリフレクションで作るので、フィールドの追加は自動で反映される
+
+// openAPIVersion is the version of the OpenAPI Specification the document follows.
+const openAPIVersion = "3.0.3"
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	Summary     string                     `json:"summary"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Required    bool           `json:"required,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPISchema struct {
+	Ref         string                    `json:"$ref,omitempty"`
+	Type        string                    `json:"type,omitempty"`
+	Format      string                    `json:"format,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Nullable    bool                      `json:"nullable,omitempty"`
+	Enum        []string                  `json:"enum,omitempty"`
+	Items       *openAPISchema            `json:"items,omitempty"`
+	Properties  map[string]*openAPISchema `json:"properties,omitempty"`
+	OneOf       []*openAPISchema          `json:"oneOf,omitempty"`
+}
+
+// openAPISchemas builds the schemas of Go types. Exported struct types become components referenced by $ref.
+type openAPISchemas struct {
+	components map[string]*openAPISchema
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	jsonNumberType = reflect.TypeFor[json.Number]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// of returns the schema of the JSON encoding of t.
+func (s *openAPISchemas) of(t reflect.Type) *openAPISchema {
+	switch t {
+	case timeType:
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case jsonNumberType:
+		return &openAPISchema{Type: "number"}
+	case rawMessageType:
+		// 任意のJSON
+		return &openAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.of(t.Elem())
+		if schema.Ref != "" {
+			// OpenAPI 3.0では $ref の隣に nullable を書けない
+			return &openAPISchema{OneOf: []*openAPISchema{schema}, Nullable: true}
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object"}
+	case reflect.Struct:
+		if !token.IsExported(t.Name()) {
+			return s.object(t)
+		}
+		if _, ok := s.components[t.Name()]; !ok {
+			// 再帰する型でも止まるように、先に登録しておく
+			s.components[t.Name()] = &openAPISchema{}
+			*s.components[t.Name()] = *s.object(t)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	// interface{} などは任意の値
+	return &openAPISchema{}
+}
+
+// object returns the schema of a struct with the properties encoding/json writes.
+func (s *openAPISchemas) object(t reflect.Type) *openAPISchema {
+	schema := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// タグのない埋め込み構造体のフィールドは、encoding/jsonと同じく外側に展開する
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for k, v := range s.object(f.Type).Properties {
+				schema.Properties[k] = v
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema.Properties[name] = s.of(f.Type)
+	}
+	return schema
+}
+
+// form returns the schema of a form with the fields tagged with form.
+// Every value of a form is text, so lists are described as comma-separated strings.
+func (s *openAPISchemas) form(t reflect.Type, multipart bool) *openAPISchema {
+	schema := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name := f.Tag.Get("form")
+		if name == "" {
+			continue
+		}
+		switch {
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.Uint8:
+			if !multipart {
+				continue
+			}
+			schema.Properties[name] = &openAPISchema{Type: "string", Format: "binary", Description: "a .jpg or .jpeg file"}
+		case f.Type.Kind() == reflect.Slice:
+			schema.Properties[name] = &openAPISchema{Type: "string", Description: "a comma-separated list"}
+		default:
+			schema.Properties[name] = s.of(f.Type)
+		}
+	}
+	return schema
+}
+
+func inPath(name, description string) openAPIParameter {
+	return openAPIParameter{Name: name, In: "path", Required: true, Description: description, Schema: &openAPISchema{Type: "string"}}
+}
+
+func inQuery(name, typ, description string) openAPIParameter {
+	return openAPIParameter{Name: name, In: "query", Description: description, Schema: &openAPISchema{Type: typ}}
+}
+
+func inHeader(name string, required bool, description string) openAPIParameter {
+	return openAPIParameter{Name: name, In: "header", Required: required, Description: description, Schema: &openAPISchema{Type: "string"}}
+}
+
+// openAPIOperations returns the operation of each route pattern of Handlers.routes, except the catch-all.
+func openAPIOperations(s *openAPISchemas) map[string]*openAPIOperation {
+	jsonOf := func(v any) map[string]openAPIMediaType {
+		return map[string]openAPIMediaType{"application/json": {Schema: s.of(reflect.TypeOf(v))}}
+	}
+	ok := func(description string, v any) openAPIResponse {
+		return openAPIResponse{Description: description, Content: jsonOf(v)}
+	}
+	text := func(description string) openAPIResponse {
+		return openAPIResponse{Description: description, Content: map[string]openAPIMediaType{"text/plain": {Schema: &openAPISchema{Type: "string"}}}}
+	}
+	noContent := openAPIResponse{Description: "done"}
+	badRequest := text("invalid request")
+	notFound := text("item not found")
+	invalid := ok("invalid fields", ValidationError{})
+	// /admin/ 以下は、API_KEY が設定されていればメソッドによらずキーが必要
+	unauthorized := ok("missing or invalid API key", ErrorResponse{})
+	jsonBody := func(v any) *openAPIRequestBody {
+		return &openAPIRequestBody{Required: true, Content: jsonOf(v)}
+	}
+	itemID := inPath("item_id", "item id")
+	actor := inHeader(actorHeader, false, "who makes the change, recorded in the item history")
+	clientToken := inHeader(clientTokenHeader, true, "identifies the client until real authentication exists")
+	limit := inQuery("limit", "integer", "page size")
+	offset := inQuery("offset", "integer", "items to skip")
+
+	return map[string]*openAPIOperation{
+		"GET /{$}": {
+			Summary:   "Hello, world!",
+			Responses: map[string]openAPIResponse{"200": ok("greeting", HelloResponse{})},
+		},
+		"GET /healthz": {
+			Summary: "Check that the process and the database are alive",
+			Responses: map[string]openAPIResponse{
+				"200": ok("healthy", HealthResponse{}),
+				"503": ok("the database is unavailable", HealthResponse{}),
+			},
+		},
+		"GET /metrics": {
+			Summary:   "Request metrics in the Prometheus text format",
+			Responses: map[string]openAPIResponse{"200": text("metrics"), "404": text("metrics are disabled")},
+		},
+		"GET /openapi.json": {
+			Summary:   "This document",
+			Responses: map[string]openAPIResponse{"200": {Description: "OpenAPI 3.0 document", Content: map[string]openAPIMediaType{"application/json": {Schema: &openAPISchema{Type: "object"}}}}},
+		},
+		"POST /items": {
+			Summary:    "Add an item",
+			Parameters: []openAPIParameter{inQuery("dedupe", "boolean", "reject the item if an identical one exists"), actor},
+			RequestBody: &openAPIRequestBody{Required: true, Content: map[string]openAPIMediaType{
+				"multipart/form-data":               {Schema: withPublished(s.form(reflect.TypeFor[AddItemRequest](), true))},
+				"application/x-www-form-urlencoded": {Schema: withPublished(s.form(reflect.TypeFor[AddItemRequest](), false))},
+				"application/json":                  {Schema: s.of(reflect.TypeFor[addItemJSONRequest]())},
+			}},
+			Responses: map[string]openAPIResponse{
+				"201": ok("the added item", AddItemResponse{}),
+				"400": invalid,
+				"409": ok("an identical item exists", DuplicateItemResponse{}),
+				"413": text("the request body is too large"),
+				"422": ok("invalid fields, keyed by field", FieldErrorsResponse{}),
+				"429": text("too many items added from the client IP; see Retry-After"),
+			},
+		},
+		"GET /items": {
+			Summary: "List items",
+			Parameters: []openAPIParameter{
+				inQuery("sort", "string", "created_at, manual or name_ja. Defaults to id order"),
+				inQuery("status", "string", "on_sale or sold"),
+				inQuery("seller", "string", ""),
+				inQuery("brand", "string", "ignoring case"),
+				inQuery("currency", "string", ""),
+				inQuery("tag", "string", ""),
+				inQuery("condition", "string", ""),
+				inQuery("shipping_payer", "string", "seller or buyer"),
+				inQuery("include_deleted", "boolean", ""),
+				inQuery("include_drafts", "boolean", ""),
+				inQuery("ids", "string", "comma-separated ids, in the order to return"),
+				limit, offset,
+			},
+			Responses: map[string]openAPIResponse{"200": ok("a page of items", GetItemsResponse{}), "400": badRequest},
+		},
+		"GET /items/recent": {
+			Summary:    "The most recently added items",
+			Parameters: []openAPIParameter{inQuery("n", "integer", "number of items, at most 100"), inQuery("limit", "integer", "same as n")},
+			Responses:  map[string]openAPIResponse{"200": ok("items, newest first", GetRecentItemsResponse{}), "400": badRequest},
+		},
+		"PATCH /items/{item_id}": {
+			Summary:     "Change some fields of an item",
+			Parameters:  []openAPIParameter{itemID, actor},
+			RequestBody: jsonBody(updateItemJSONRequest{}),
+			Responses:   map[string]openAPIResponse{"200": ok("the item and the changed fields", UpdateItemResponse{}), "400": invalid, "404": notFound},
+		},
+		"GET /users/{user_id}/items": {
+			Summary:    "Items of a seller",
+			Parameters: []openAPIParameter{inPath("user_id", "seller")},
+			Responses:  map[string]openAPIResponse{"200": ok("items, newest first", GetUserItemsResponse{}), "400": badRequest},
+		},
+		"POST /items/bulk": {
+			Summary:     "Add several items",
+			Parameters:  []openAPIParameter{actor},
+			RequestBody: jsonBody([]BulkItem{}),
+			Responses:   map[string]openAPIResponse{"200": ok("the result of each item", AddItemsBulkResponse{}), "400": badRequest},
+		},
+		"POST /items/import": {
+			Summary:    "Add items from a CSV",
+			Parameters: []openAPIParameter{inQuery("dry_run", "boolean", "validate without inserting"), actor},
+			RequestBody: &openAPIRequestBody{Required: true, Content: map[string]openAPIMediaType{
+				"text/csv": {Schema: &openAPISchema{Type: "string"}},
+				"multipart/form-data": {Schema: &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{
+					"file": {Type: "string", Format: "binary"},
+				}}},
+			}},
+			Responses: map[string]openAPIResponse{"200": ok("the result of each row", ImportItemsResponse{}), "400": badRequest},
+		},
+		"POST /items/reorder": {
+			Summary:     "Set the manual order of items",
+			RequestBody: jsonBody(ReorderItemsRequest{}),
+			Responses:   map[string]openAPIResponse{"204": noContent, "400": badRequest},
+		},
+		"GET /images/{filename}": {
+			Summary:    "An image",
+			Parameters: []openAPIParameter{inPath("filename", "stored image name"), inQuery("max_width", "integer", "")},
+			Responses: map[string]openAPIResponse{
+				"200": {Description: "the image, or the default image", Content: map[string]openAPIMediaType{"image/jpeg": {Schema: &openAPISchema{Type: "string", Format: "binary"}}}},
+				"304": {Description: "not modified"},
+				"400": badRequest,
+			},
+		},
+		"HEAD /images/{filename}": {
+			Summary:    "The headers of an image",
+			Parameters: []openAPIParameter{inPath("filename", "stored image name"), inQuery("max_width", "integer", "")},
+			Responses:  map[string]openAPIResponse{"200": {Description: "the headers of GET"}, "400": badRequest},
+		},
+		"DELETE /images/{filename}": {
+			Summary:    "Delete an image no item uses, with its scaled versions",
+			Parameters: []openAPIParameter{inPath("filename", "stored image name")},
+			Responses: map[string]openAPIResponse{
+				"204": noContent,
+				"400": badRequest,
+				"404": text("image not found"),
+				"409": text("the image is used by an item, or is the default image"),
+			},
+		},
+		"POST /images": {
+			Summary: "Store images to attach to items later with image_name",
+			RequestBody: &openAPIRequestBody{Required: true, Content: map[string]openAPIMediaType{
+				"multipart/form-data": {Schema: &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{
+					uploadImagesField: {Type: "array", Items: &openAPISchema{Type: "string", Format: "binary"}},
+				}}},
+			}},
+			Responses: map[string]openAPIResponse{
+				"200": ok("the result of each file", UploadImagesResponse{}),
+				"400": badRequest,
+				"413": text("the request body is too large"),
+				"429": text("too many uploads from the client IP; see Retry-After"),
+			},
+		},
+		"GET /images/{filename}/meta": {
+			Summary:    "Size, dimensions and references of a stored image",
+			Parameters: []openAPIParameter{inPath("filename", "stored image name")},
+			Responses:  map[string]openAPIResponse{"200": ok("the image meta data", ImageMeta{}), "400": badRequest, "404": text("image not found")},
+		},
+		"GET /items/sample": {
+			Summary:    "A few items of each category",
+			Parameters: []openAPIParameter{inQuery("per_category", "integer", "")},
+			Responses:  map[string]openAPIResponse{"200": ok("items by category", SampleItemsResponse{}), "400": badRequest},
+		},
+		"GET /items/export": {
+			Summary: "All items as CSV",
+			Responses: map[string]openAPIResponse{
+				"200": {Description: "id, name, category and image_name of each item", Content: map[string]openAPIMediaType{"text/csv": {Schema: &openAPISchema{Type: "string"}}}},
+			},
+		},
+		"GET /items/favorites": {
+			Summary:    "Items favorited by the client",
+			Parameters: []openAPIParameter{clientToken},
+			Responses:  map[string]openAPIResponse{"200": ok("items, most recently favorited first", GetFavoritesResponse{}), "400": badRequest},
+		},
+		"GET /items/by-image": {
+			Summary:    "The item using a stored image",
+			Parameters: []openAPIParameter{{Name: "filename", In: "query", Required: true, Description: "stored image name, with or without the directory and extension", Schema: &openAPISchema{Type: "string"}}},
+			Responses:  map[string]openAPIResponse{"200": ok("the oldest item using the image", Item{}), "400": badRequest, "404": notFound},
+		},
+		"GET /items/{item_id}": {
+			Summary:    "An item, drafts included",
+			Parameters: []openAPIParameter{itemID, inQuery("expand", "string", "category_items to include other items of the category"), limit},
+			Responses: map[string]openAPIResponse{
+				"200": {Description: "the item", Content: map[string]openAPIMediaType{"application/json": {Schema: &openAPISchema{OneOf: []*openAPISchema{
+					s.of(reflect.TypeFor[Item]()),
+					s.of(reflect.TypeFor[GetItemWithCategoryItemsResponse]()),
+				}}}}},
+				"400": badRequest,
+				"404": notFound,
+			},
+		},
+		"GET /items/{item_id}/history": {
+			Summary:    "Changes of an item",
+			Parameters: []openAPIParameter{itemID},
+			Responses:  map[string]openAPIResponse{"200": ok("events, oldest first", GetItemHistoryResponse{}), "404": notFound},
+		},
+		"GET /items/{item_id}/related": {
+			Summary:    "Items related to an item",
+			Parameters: []openAPIParameter{itemID, limit},
+			Responses:  map[string]openAPIResponse{"200": ok("items of the same category first", GetRelatedItemsResponse{}), "400": badRequest, "404": notFound},
+		},
+		"DELETE /items/{item_id}": {
+			Summary:    "Delete an item",
+			Parameters: []openAPIParameter{itemID, inQuery("purge", "boolean", "remove permanently instead of soft-deleting")},
+			Responses:  map[string]openAPIResponse{"204": noContent, "400": badRequest, "404": notFound},
+		},
+		"POST /items/{item_id}/restore": {
+			Summary:    "Restore a soft-deleted item",
+			Parameters: []openAPIParameter{itemID},
+			Responses:  map[string]openAPIResponse{"200": ok("the item", Item{}), "404": notFound},
+		},
+		"POST /items/{item_id}/publish": {
+			Summary:    "Publish a draft",
+			Parameters: []openAPIParameter{itemID, actor},
+			Responses:  map[string]openAPIResponse{"200": ok("the item", Item{}), "404": notFound},
+		},
+		"POST /items/{item_id}/purchase": {
+			Summary:    "Buy one of an item",
+			Parameters: []openAPIParameter{itemID},
+			Responses:  map[string]openAPIResponse{"200": ok("the item", Item{}), "404": notFound, "409": text("the item is sold")},
+		},
+		"POST /items/{item_id}/favorite": {
+			Summary:    "Favorite an item",
+			Parameters: []openAPIParameter{itemID, clientToken},
+			Responses:  map[string]openAPIResponse{"204": noContent, "400": badRequest, "404": notFound},
+		},
+		"DELETE /items/{item_id}/favorite": {
+			Summary:    "Unfavorite an item",
+			Parameters: []openAPIParameter{itemID, clientToken},
+			Responses:  map[string]openAPIResponse{"204": noContent, "400": badRequest, "404": notFound},
+		},
+		"POST /items/{a}/swap/{b}": {
+			Summary:    "Swap the manual order of two items",
+			Parameters: []openAPIParameter{inPath("a", "item id"), inPath("b", "item id")},
+			Responses:  map[string]openAPIResponse{"204": noContent, "400": badRequest},
+		},
+		"GET /search": {
+			Summary: "Search items by keyword",
+			Parameters: []openAPIParameter{
+				{Name: "keyword", In: "query", Required: true, Schema: &openAPISchema{Type: "string"}},
+				inQuery("min_price", "integer", ""),
+				inQuery("max_price", "integer", ""),
+				inQuery("category", "string", "only items of the category, ignoring case"),
+				inQuery("include_drafts", "boolean", ""),
+				{Name: "op", In: "query", Description: "match all the terms, or any of them", Schema: &openAPISchema{Type: "string", Enum: []string{searchOpAnd, searchOpOr}}},
+				{Name: "sort", In: "query", Description: "relevance (exact name, prefix, substring, then category or brand) or newest", Schema: &openAPISchema{Type: "string", Enum: []string{searchSortRelevance, searchSortNewest}}},
+				limit, offset,
+			},
+			Responses: map[string]openAPIResponse{
+				"200": ok("a page of matching items", SearchItemsResponse{}),
+				"400": badRequest,
+				"422": text("the search is too broad"),
+			},
+		},
+		"GET /categories": {
+			Summary:    "All categories",
+			Parameters: []openAPIParameter{inHeader("If-None-Match", false, "")},
+			Responses:  map[string]openAPIResponse{"200": ok("categories", GetCategoriesResponse{}), "304": {Description: "not modified"}},
+		},
+		"DELETE /categories/{category_id}": {
+			Summary:    "Delete a category, moving its items to the fallback category",
+			Parameters: []openAPIParameter{inPath("category_id", "category id")},
+			Responses: map[string]openAPIResponse{
+				"200": ok("the moved items", DeleteCategoryResponse{}),
+				"400": badRequest,
+				"404": text("category not found"),
+				"409": text("the fallback category cannot be deleted"),
+			},
+		},
+		"GET /admin/category-health": {
+			Summary:   "Inconsistencies between items and categories",
+			Responses: map[string]openAPIResponse{"200": ok("issues", CategoryHealthResponse{}), "401": unauthorized},
+		},
+		"GET /admin/storage": {
+			Summary:   "Storage footprint of the images",
+			Responses: map[string]openAPIResponse{"200": ok("stats", StorageStats{}), "401": unauthorized},
+		},
+		"GET /admin/flags": {
+			Summary:   "Feature flags",
+			Responses: map[string]openAPIResponse{"200": ok("flags", GetFlagsResponse{}), "401": unauthorized},
+		},
+		"PATCH /admin/flags/{name}": {
+			Summary:     "Change a mutable flag",
+			Parameters:  []openAPIParameter{inPath("name", "flag name")},
+			RequestBody: jsonBody(PatchFlagRequest{}),
+			Responses:   map[string]openAPIResponse{"200": ok("the flag", Flag{}), "400": badRequest, "401": unauthorized, "404": text("unknown flag")},
+		},
+	}
+}
+
+// withPublished adds the published field of POST /items, which has no form tag since it is stored inverted as Draft.
+func withPublished(schema *openAPISchema) *openAPISchema {
+	schema.Properties["published"] = &openAPISchema{Type: "boolean", Description: "false to add a draft. Defaults to true"}
+	return schema
+}
+
+// buildOpenAPIDocument returns the OpenAPI document of the routes.
+func buildOpenAPIDocument() *openAPIDocument {
+	schemas := &openAPISchemas{components: map[string]*openAPISchema{}}
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    openAPIInfo{Title: "mercari-build-training", Version: "1.0.0"},
+		Paths:   map[string]map[string]*openAPIOperation{},
+	}
+	for pattern, op := range openAPIOperations(schemas) {
+		method, path := openAPIPath(pattern)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*openAPIOperation{}
+		}
+		doc.Paths[path][method] = op
+	}
+	// ハンドラが直接返すエラーの形も載せておく
+	schemas.of(reflect.TypeFor[ErrorResponse]())
+	doc.Components.Schemas = schemas.components
+	return doc
+}
+
+// openAPIPath converts a route pattern such as "GET /items/{item_id}" to the OpenAPI method and path.
+func openAPIPath(pattern string) (method, path string) {
+	method, path, _ = strings.Cut(pattern, " ")
+	path = strings.TrimSuffix(path, "{$}")
+	return strings.ToLower(method), path
+}
+
+// openAPIJSON is the encoded document. The routes do not change while the server runs.
+var openAPIJSON = sync.OnceValues(func() ([]byte, error) {
+	return json.Marshal(buildOpenAPIDocument())
+})
+
+// OpenAPI is a handler to return the OpenAPI document of the API for GET /openapi.json .
+func (s *Handlers) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	body, err := openAPIJSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOpenAPI(t *testing.T) {
+	t.Parallel()
+
+	h := &Handlers{}
+	rr := httptest.NewRecorder()
+	newMux(h.routes()).ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("expected content type application/json, got %q", got)
+	}
+
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to decode the document: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.0.") {
+		t.Errorf("expected OpenAPI 3.0, got %q", doc.OpenAPI)
+	}
+
+	// ルートと仕様が一対一に対応すること
+	documented := 0
+	for _, path := range doc.Paths {
+		documented += len(path)
+	}
+	routes := 0
+	for _, rt := range h.routes() {
+		if rt.pattern == "/" {
+			continue
+		}
+		routes++
+		method, path := openAPIPath(rt.pattern)
+		if _, ok := doc.Paths[path][method]; !ok {
+			t.Errorf("expected %s in the document", rt.pattern)
+		}
+	}
+	if documented != routes {
+		t.Errorf("expected %d operations, got %d", routes, documented)
+	}
+
+	for _, name := range []string{"Item", "ErrorResponse", "ValidationError"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("expected the schema %s", name)
+		}
+	}
+}
+
+func TestOpenAPIAddItemForm(t *testing.T) {
+	t.Parallel()
+
+	op := buildOpenAPIDocument().Paths["/items"]["post"]
+	form, ok := op.RequestBody.Content["multipart/form-data"]
+	if !ok {
+		t.Fatal("expected a multipart body")
+	}
+	for name, want := range map[string]string{"name": "string", "price": "integer", "tags": "string", "published": "boolean", "image": "string"} {
+		p, ok := form.Schema.Properties[name]
+		if !ok {
+			t.Errorf("expected the field %s", name)
+			continue
+		}
+		if p.Type != want {
+			t.Errorf("expected %s to be %s, got %s", name, want, p.Type)
+		}
+	}
+	if got := form.Schema.Properties["image"].Format; got != "binary" {
+		t.Errorf("expected the image to be binary, got %q", got)
+	}
+}
+
+func TestOpenAPISchemaOf(t *testing.T) {
+	t.Parallel()
+
+	s := &openAPISchemas{components: map[string]*openAPISchema{}}
+	if got := s.of(reflect.TypeFor[SearchItemsResponse]()); got.Ref != "#/components/schemas/SearchItemsResponse" {
+		t.Fatalf("expected a reference, got %+v", got)
+	}
+	// SearchItemはItemを埋め込んでいるので、Itemのフィールドも持つ
+	item := s.components["SearchItem"]
+	if item == nil {
+		t.Fatal("expected the schema of SearchItem to be registered")
+	}
+
+	cases := map[string]struct {
+		schema *openAPISchema
+		want   openAPISchema
+	}{
+		"time":     {schema: item.Properties["created_at"], want: openAPISchema{Type: "string", Format: "date-time"}},
+		"pointer":  {schema: item.Properties["deleted_at"], want: openAPISchema{Type: "string", Format: "date-time", Nullable: true}},
+		"slice":    {schema: item.Properties["tags"], want: openAPISchema{Type: "array", Items: &openAPISchema{Type: "string"}}},
+		"struct":   {schema: item.Properties["price"], want: openAPISchema{Ref: "#/components/schemas/Price"}},
+		"embedded": {schema: s.components["SearchItemsResponse"].Properties["total"], want: openAPISchema{Type: "integer"}},
+		"matches":  {schema: item.Properties["matches"], want: openAPISchema{Type: "array", Items: &openAPISchema{Ref: "#/components/schemas/MatchRange"}}},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(&tt.want, tt.schema); diff != "" {
+				t.Errorf("unexpected schema (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// 手動の並び順 (sort_order) を変更するハンドラ
+
+type ReorderItemsRequest struct {
+	// IDs are the items in the new display order.
+	IDs []int `json:"ids"`
+}
+
+func parseReorderItemsRequest(r *http.Request) (*ReorderItemsRequest, error) {
+	req := &ReorderItemsRequest{}
+	if err := decodeJSONBody(r, defaultJSONLimits, req); err != nil {
+		return nil, err
+	}
+
+	// validation
+	if len(req.IDs) == 0 {
+		return nil, errors.New("ids are required")
+	}
+	seen := make(map[int]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate id: %d", id)
+		}
+		seen[id] = true
+	}
+
+	return req, nil
+}
+
+// ReorderItems is a handler to rewrite the manual display order for POST /items/reorder .
+// The positions are rewritten in one transaction. If any id does not exist, nothing is written and it returns 400.
+func (s *Handlers) ReorderItems(w http.ResponseWriter, r *http.Request) {
+	req, err := parseReorderItemsRequest(r)
+	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			writeValidationError(w, validationErr)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	if err := s.itemRepo.Reorder(r.Context(), req.IDs); err != nil {
+		if errors.Is(err, errItemNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Error("failed to reorder items: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type SwapItemsRequest struct {
+	A int // path value
+	B int // path value
+}
+
+func parseSwapItemsRequest(r *http.Request) (*SwapItemsRequest, error) {
+	a, err := strconv.Atoi(r.PathValue("a"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid id: %q", r.PathValue("a"))
+	}
+	b, err := strconv.Atoi(r.PathValue("b"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid id: %q", r.PathValue("b"))
+	}
+	if a == b {
+		return nil, errors.New("cannot swap an item with itself")
+	}
+	return &SwapItemsRequest{A: a, B: b}, nil
+}
+
+// SwapItems is a handler to exchange the display order of two items for POST /items/{a}/swap/{b} .
+func (s *Handlers) SwapItems(w http.ResponseWriter, r *http.Request) {
+	req, err := parseSwapItemsRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	if err := s.itemRepo.Swap(r.Context(), req.A, req.B); err != nil {
+		if errors.Is(err, errItemNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Error("failed to swap items: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	w.WriteHeader(http.StatusNoContent)
+}
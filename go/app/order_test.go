@@ -0,0 +1,209 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReorderItemsE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	cases := map[string]struct {
+		body      string
+		wantCode  int
+		wantOrder map[int]int // id -> sort_order (0 means NULL)
+	}{
+		"ok: reorder": {
+			body:      `{"ids":[3,1,2]}`,
+			wantCode:  http.StatusNoContent,
+			wantOrder: map[int]int{1: 2, 2: 3, 3: 1},
+		},
+		"ok: partial reorder": {
+			body:      `{"ids":[2,1]}`,
+			wantCode:  http.StatusNoContent,
+			wantOrder: map[int]int{1: 2, 2: 1, 3: 3},
+		},
+		"ng: missing id writes nothing": {
+			body:      `{"ids":[3,1,99]}`,
+			wantCode:  http.StatusBadRequest,
+			wantOrder: map[int]int{1: 1, 2: 2, 3: 3},
+		},
+		"ng: duplicate id": {
+			body:      `{"ids":[1,1]}`,
+			wantCode:  http.StatusBadRequest,
+			wantOrder: map[int]int{1: 1, 2: 2, 3: 3},
+		},
+		"ng: empty ids": {
+			body:      `{"ids":[]}`,
+			wantCode:  http.StatusBadRequest,
+			wantOrder: map[int]int{1: 1, 2: 2, 3: 3},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			h, db := setupOrderItems(t)
+
+			req := httptest.NewRequest("POST", "/items/reorder", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			h.ReorderItems(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status code %d, got %d: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+			if diff := cmp.Diff(tt.wantOrder, sortOrders(t, db)); diff != "" {
+				t.Errorf("unexpected sort_order (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSwapItemsE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	cases := map[string]struct {
+		a, b      string
+		wantCode  int
+		wantOrder map[int]int
+	}{
+		"ok: swap": {
+			a: "1", b: "3",
+			wantCode:  http.StatusNoContent,
+			wantOrder: map[int]int{1: 3, 2: 2, 3: 1},
+		},
+		"ng: missing id": {
+			a: "1", b: "99",
+			wantCode:  http.StatusBadRequest,
+			wantOrder: map[int]int{1: 1, 2: 2, 3: 3},
+		},
+		"ng: same id": {
+			a: "2", b: "2",
+			wantCode:  http.StatusBadRequest,
+			wantOrder: map[int]int{1: 1, 2: 2, 3: 3},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			h, db := setupOrderItems(t)
+			if err := h.itemRepo.Reorder(t.Context(), []int{1, 2, 3}); err != nil {
+				t.Fatalf("failed to reorder items: %v", err)
+			}
+
+			req := httptest.NewRequest("POST", fmt.Sprintf("/items/%s/swap/%s", tt.a, tt.b), nil)
+			req.SetPathValue("a", tt.a)
+			req.SetPathValue("b", tt.b)
+			rr := httptest.NewRecorder()
+			h.SwapItems(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status code %d, got %d: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+			if diff := cmp.Diff(tt.wantOrder, sortOrders(t, db)); diff != "" {
+				t.Errorf("unexpected sort_order (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// setupOrderItems sets up a database with three items (id 1, 2, 3) and returns handlers using it.
+func setupOrderItems(t *testing.T) (*Handlers, *sql.DB) {
+	t.Helper()
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, name := range []string{"jacket", "jeans", "shirt"} {
+		if err := repo.Insert(t.Context(), &Item{Name: name, Category: "fashion", Image: "default.jpg"}); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	return &Handlers{itemRepo: repo}, db
+}
+
+// sortOrders returns the sort_order of each item, with 0 for NULL.
+func sortOrders(t *testing.T, db *sql.DB) map[int]int {
+	t.Helper()
+
+	rows, err := db.Query(`SELECT id, COALESCE(sort_order, 0) FROM items`)
+	if err != nil {
+		t.Fatalf("failed to query sort_order: %v", err)
+	}
+	defer rows.Close()
+
+	orders := map[int]int{}
+	for rows.Next() {
+		var id, order int
+		if err := rows.Scan(&id, &order); err != nil {
+			t.Fatalf("failed to scan sort_order: %v", err)
+		}
+		orders[id] = order
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("failed to query sort_order: %v", err)
+	}
+	return orders
+}
+
+func TestManualOrderE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	h, db := setupOrderItems(t)
+
+	// 並べ替えたあとに追加した商品は最後に来る
+	if err := h.itemRepo.Reorder(t.Context(), []int{3, 1, 2}); err != nil {
+		t.Fatalf("failed to reorder items: %v", err)
+	}
+	if err := h.itemRepo.Insert(t.Context(), &Item{Name: "coat", Category: "fashion", Image: "default.jpg"}); err != nil {
+		t.Fatalf("failed to insert item: %v", err)
+	}
+	// sort_orderがNULLの行 (並び順が決まっていないもの) はさらに後ろ
+	if _, err := db.Exec(`INSERT INTO items (name, category_id, image_name) SELECT 'socks', category_id, image_name FROM items WHERE id = 1`); err != nil {
+		t.Fatalf("failed to insert unordered item: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/items?sort=manual", nil)
+	rr := httptest.NewRecorder()
+	h.GetItems(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	var resp GetItemsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	var names []string
+	for _, item := range resp.Items {
+		names = append(names, item.Name)
+	}
+	if diff := cmp.Diff([]string{"shirt", "jacket", "jeans", "coat", "socks"}, names); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+	if last := resp.Items[len(resp.Items)-1]; last.SortOrder != nil {
+		t.Errorf("expected nil sort_order for the unordered item, got %d", *last.SortOrder)
+	}
+	if coat := resp.Items[3]; coat.SortOrder == nil || *coat.SortOrder != 4 {
+		t.Errorf("expected sort_order 4 for the new item, got %v", coat.SortOrder)
+	}
+}
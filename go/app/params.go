@@ -0,0 +1,91 @@
+package app
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// リクエストの文字列パラメータの長さの上限
+// 巨大な値をそのまま確保・ログ出力・SQLiteに渡さないように、parse*の段階で弾く
+const (
+	// maxRawQueryLen bounds the whole query string.
+	maxRawQueryLen = 8 << 10 // 8KB
+	// maxParamValues bounds how many times a query parameter may be repeated.
+	maxParamValues = 10
+
+	// maxKeywordLen, maxNameLen and maxCategoryLen fit the character limits of validation.go in UTF-8.
+	maxKeywordLen  = maxKeywordChars * utf8.UTFMax
+	maxNameLen     = maxItemNameChars * utf8.UTFMax
+	maxCategoryLen = maxCategoryChars * utf8.UTFMax
+	maxSellerLen   = maxSellerChars * utf8.UTFMax
+	maxTagLen      = maxTagChars * utf8.UTFMax
+	// maxBrandLen leaves room for whitespace collapsed by normalizeBrand.
+	maxBrandLen = 2 * maxBrandChars * utf8.UTFMax
+	// maxTagsLen leaves room for a few empty entries and spaces around the commas.
+	maxTagsLen      = 2 * maxTagsPerItem * (maxTagLen + 2)
+	maxImageNameLen = 255
+	// maxShortParamLen is for enum and number parameters such as sort, status and price.
+	maxShortParamLen = 20
+	// maxClientTokenLen bounds the X-Client-Token header of the favorites API.
+	maxClientTokenLen = 128
+	// maxActorLen bounds the X-Actor header recorded in the history of an item.
+	maxActorLen = 128
+	// maxIDsParamLen fits maxItemIDs ids of up to 10 digits separated by commas.
+	maxIDsParamLen = maxItemIDs * 11
+
+	// maxLogValueLen bounds a request value written to the log.
+	maxLogValueLen = 256
+)
+
+var errParamLimit = errors.New("parameter limit exceeded")
+
+// parseQuery returns the query parameters of the request after checking the size of the query string.
+func parseQuery(r *http.Request) (url.Values, error) {
+	if len(r.URL.RawQuery) > maxRawQueryLen {
+		return nil, fmt.Errorf("%w: query string exceeds the maximum length of %d", errParamLimit, maxRawQueryLen)
+	}
+	return r.URL.Query(), nil
+}
+
+// queryParam returns the first value of the query parameter.
+// It fails if the parameter is repeated more than maxParamValues times or any value is longer than maxLen bytes.
+func queryParam(q url.Values, name string, maxLen int) (string, error) {
+	values := q[name]
+	if len(values) > maxParamValues {
+		return "", fmt.Errorf("%w: %s is repeated more than %d times", errParamLimit, name, maxParamValues)
+	}
+	for _, v := range values {
+		if err := checkParamLen(name, v, maxLen); err != nil {
+			return "", err
+		}
+	}
+	if len(values) == 0 {
+		return "", nil
+	}
+	return values[0], nil
+}
+
+// checkParamLen fails if the value of the named parameter is longer than maxLen bytes.
+func checkParamLen(name, v string, maxLen int) error {
+	if len(v) > maxLen {
+		return fmt.Errorf("%w: %s exceeds the maximum length of %d", errParamLimit, name, maxLen)
+	}
+	return nil
+}
+
+// logValue shortens a request value for logging, so that a huge value cannot balloon the log.
+// A value longer than maxLogValueLen is truncated and suffixed with its length and a short hash.
+func logValue(v string) string {
+	if len(v) <= maxLogValueLen {
+		return v
+	}
+	sum := sha256.Sum256([]byte(v))
+	// マルチバイト文字の途中で切れても壊れた文字列にならないようにする
+	head := strings.ToValidUTF8(v[:maxLogValueLen], "")
+	return fmt.Sprintf("%s...(%d bytes, sha256:%x)", head, len(v), sum[:8])
+}
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParamLengthLimits(t *testing.T) {
+	t.Parallel()
+
+	// parseFunc parses a request built from a value of the parameter under test.
+	type parseFunc func(v string) error
+
+	query := func(parse func(*http.Request) error, base url.Values, name string) parseFunc {
+		return func(v string) error {
+			q := url.Values{}
+			for k, vs := range base {
+				q[k] = vs
+			}
+			q.Set(name, v)
+			return parse(httptest.NewRequest("GET", "/?"+q.Encode(), nil))
+		}
+	}
+	form := func(name string) parseFunc {
+		return func(v string) error {
+			values := url.Values{"name": {"jacket"}, "category": {"fashion"}, "price": {"100"}}
+			values.Set(name, v)
+			req := httptest.NewRequest("POST", "/items", strings.NewReader(values.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			_, err := parseAddItemRequest(req, defaultMaxImageBytes)
+			return err
+		}
+	}
+	search := func(r *http.Request) error { _, err := parseGetItemByKeywordRequest(r); return err }
+	items := func(r *http.Request) error { _, err := parseGetItemsRequest(r); return err }
+
+	cases := map[string]struct {
+		parse parseFunc
+		limit int
+		// value builds a value of length n. Values under the limit may still be invalid for other reasons,
+		// so only the error kind is checked.
+		value func(n int) string
+	}{
+		"keyword":   {parse: query(search, nil, "keyword"), limit: maxKeywordLen, value: func(n int) string { return strings.Repeat("a", n) }},
+		"min_price": {parse: query(search, url.Values{"keyword": {"a"}}, "min_price"), limit: maxShortParamLen, value: func(n int) string { return strings.Repeat("1", n) }},
+		"sort":      {parse: query(items, nil, "sort"), limit: maxShortParamLen, value: func(n int) string { return strings.Repeat("a", n) }},
+		"status":    {parse: query(items, nil, "status"), limit: maxShortParamLen, value: func(n int) string { return strings.Repeat("a", n) }},
+		"ids":       {parse: query(items, nil, "ids"), limit: maxIDsParamLen, value: func(n int) string { return strings.Repeat("1", n) }},
+		"name":      {parse: form("name"), limit: maxNameLen, value: func(n int) string { return strings.Repeat("a", n) }},
+		"category":  {parse: form("category"), limit: maxCategoryLen, value: func(n int) string { return strings.Repeat("a", n) }},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, n := range []int{tt.limit - 1, tt.limit} {
+				if err := tt.parse(tt.value(n)); errors.Is(err, errParamLimit) {
+					t.Errorf("expected length %d to be accepted, got %v", n, err)
+				}
+			}
+			err := tt.parse(tt.value(tt.limit + 1))
+			if !errors.Is(err, errParamLimit) {
+				t.Fatalf("expected %v for length %d, got %v", errParamLimit, tt.limit+1, err)
+			}
+			if !strings.Contains(err.Error(), name) {
+				t.Errorf("expected the error to name %s, got %v", name, err)
+			}
+		})
+	}
+}
+
+func TestQueryParamLimits(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		rawQuery string
+		wantErr  bool
+	}{
+		"ok: repeated up to the limit": {
+			rawQuery: strings.Repeat("keyword=a&", maxParamValues),
+			wantErr:  false,
+		},
+		"ng: repeated over the limit": {
+			rawQuery: strings.Repeat("keyword=a&", maxParamValues+1),
+			wantErr:  true,
+		},
+		"ng: query string over the limit": {
+			rawQuery: "keyword=a&x=" + strings.Repeat("a", maxRawQueryLen),
+			wantErr:  true,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/search?"+tt.rawQuery, nil)
+			_, err := parseGetItemByKeywordRequest(req)
+			if got := errors.Is(err, errParamLimit); got != tt.wantErr {
+				t.Errorf("expected limit error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLogValue(t *testing.T) {
+	t.Parallel()
+
+	short := strings.Repeat("a", maxLogValueLen)
+	if got := logValue(short); got != short {
+		t.Errorf("expected a value at the limit to be kept, got %q", got)
+	}
+
+	long := strings.Repeat("あ", 1<<20)
+	got := logValue(long)
+	if len(got) > maxLogValueLen+64 {
+		t.Errorf("expected a truncated value, got %d bytes", len(got))
+	}
+	if !strings.Contains(got, "(3145728 bytes, sha256:") {
+		t.Errorf("expected the length and hash in %q", got)
+	}
+	if !strings.HasPrefix(got, "あ") || strings.ContainsRune(got, '�') {
+		t.Errorf("expected valid UTF-8 prefix, got %q", got)
+	}
+}
+
+func TestAddItemParamLimit(t *testing.T) {
+	t.Parallel()
+
+	h := &Handlers{}
+	values := url.Values{"name": {strings.Repeat("a", maxNameLen+1)}, "category": {"fashion"}, "price": {"100"}}
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	h.AddItem(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 商品の完全削除 (DELETE /items/{item_id}?purge=true)
+// 論理削除と違って行を消し、他の商品が使っていない画像ファイルも消す
+// 画像はコミットした後に消すので、ロールバックされた削除で画像だけが消えることはない
+
+type DeleteItemRequest struct {
+	Id string
+	// Purge removes the item permanently instead of soft-deleting it.
+	Purge bool
+}
+
+func parseDeleteItemRequest(r *http.Request) (*DeleteItemRequest, error) {
+	idReq, err := parseGetItemByIdRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	req := &DeleteItemRequest{Id: idReq.Id}
+
+	purge, err := queryParam(r.URL.Query(), "purge", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	if purge != "" {
+		v, err := strconv.ParseBool(purge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid purge: %s", purge)
+		}
+		req.Purge = v
+	}
+	return req, nil
+}
+
+// purgeItem removes the item permanently, then deletes its image if no other item uses it.
+// Soft-deleted items can be purged too.
+func (s *Handlers) purgeItem(w http.ResponseWriter, r *http.Request, id string) {
+	image, err := s.itemRepo.Purge(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, errItemNotFound) {
+			slog.Warn("item not exist: ", "error", err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to purge item: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	// 商品はもう消えているので、画像を消せなくてもリクエストは成功させる
+	if image != "" {
+		if err := s.removeImage(image); err != nil {
+			slog.Warn("failed to remove unused image: ", "image", image, "error", err)
+		}
+	}
+
+	slog.Info("item purged", "id", id, "removed_image", image)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeImage deletes the image file and its cached variants. A missing file is not an error.
+func (s *Handlers) removeImage(name string) error {
+	// DBの値をそのままパスにしないよう、画像ディレクトリ直下のファイル名だけを使う
+	name = filepath.Base(name)
+	if err := os.Remove(filepath.Join(s.imgDirPath, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	variants, err := filepath.Glob(filepath.Join(s.imgDirPath, variantDirName, strings.TrimSuffix(name, filepath.Ext(name))+"_w*.jpg"))
+	if err != nil {
+		return err
+	}
+	for _, v := range variants {
+		if err := os.Remove(v); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPurgeItemE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	dir := setupImageDir(t)
+	for _, name := range []string{"shared.jpg", "solo.jpg", filepath.Join(variantDirName, "solo_w100.jpg")} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("image"), 0644); err != nil {
+			t.Fatalf("failed to write image: %v", err)
+		}
+	}
+
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "jacket", Category: "fashion", Image: "shared.jpg"},
+		{Name: "coat", Category: "fashion", Image: "shared.jpg"},
+		{Name: "hat", Category: "fashion", Image: "solo.jpg"},
+		{Name: "pen", Category: "stationery", Image: defaultImageName},
+	} {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	if err := repo.AddFavorite(t.Context(), "3", "client"); err != nil {
+		t.Fatalf("failed to add favorite: %v", err)
+	}
+	// 論理削除された商品も画像を使っている
+	if err := repo.SoftDelete(t.Context(), "2"); err != nil {
+		t.Fatalf("failed to delete item: %v", err)
+	}
+	h := &Handlers{imgDirPath: dir, itemRepo: repo}
+
+	purge := func(id string) int {
+		req := httptest.NewRequest("DELETE", "/items/"+id+"?purge=true", nil)
+		req.SetPathValue("item_id", id)
+		rr := httptest.NewRecorder()
+		h.DeleteItem(rr, req)
+		return rr.Code
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	steps := []struct {
+		id    string
+		code  int
+		files map[string]bool
+	}{
+		// 他に使う商品がなければ、縮小版も含めて画像を消す
+		{id: "3", code: http.StatusNoContent, files: map[string]bool{"solo.jpg": false, filepath.Join(variantDirName, "solo_w100.jpg"): false, "shared.jpg": true}},
+		// 論理削除された商品 (id 2) がまだ使っている
+		{id: "1", code: http.StatusNoContent, files: map[string]bool{"shared.jpg": true}},
+		{id: "2", code: http.StatusNoContent, files: map[string]bool{"shared.jpg": false}},
+		// default.jpgは共有なので消さない
+		{id: "4", code: http.StatusNoContent, files: map[string]bool{defaultImageName: true}},
+		{id: "4", code: http.StatusNotFound},
+	}
+	for _, step := range steps {
+		if code := purge(step.id); code != step.code {
+			t.Fatalf("purge %s: expected status code %d, got %d", step.id, step.code, code)
+		}
+		for name, want := range step.files {
+			if got := exists(name); got != want {
+				t.Errorf("purge %s: expected %s to exist %v, got %v", step.id, name, want, got)
+			}
+		}
+	}
+
+	if _, err := repo.GetItemById(t.Context(), "3"); !errors.Is(err, errItemNotFound) {
+		t.Errorf("expected errItemNotFound for a purged item, got %v", err)
+	}
+	var favorites int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM favorites`).Scan(&favorites); err != nil {
+		t.Fatalf("failed to count favorites: %v", err)
+	}
+	if favorites != 0 {
+		t.Errorf("expected favorites of purged items to be removed, got %d", favorites)
+	}
+}
+
+func TestParseDeleteItemRequest(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		query   string
+		want    DeleteItemRequest
+		wantErr bool
+	}{
+		"ok: soft delete": {query: "", want: DeleteItemRequest{Id: "1"}},
+		"ok: purge":       {query: "?purge=true", want: DeleteItemRequest{Id: "1", Purge: true}},
+		"ok: no purge":    {query: "?purge=false", want: DeleteItemRequest{Id: "1"}},
+		"ng: invalid":     {query: "?purge=yes", wantErr: true},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("DELETE", "/items/1"+tt.query, nil)
+			req.SetPathValue("item_id", "1")
+			got, err := parseDeleteItemRequest(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, *got)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// 出品 (POST /items) のクライアントIPごとの回数制限
+// トークンバケットで、平均の速さ (rate) と一度に許す回数 (burst) を RATE_LIMIT で指定する
+// しばらくリクエストのないIPのバケットは定期的に捨てて、マップが増え続けないようにする
+
+const (
+	// defaultRateLimit is the default of the rate_limit flag: 1 request per second with bursts of 10.
+	defaultRateLimit = "1,10"
+	// rateLimiterIdleTTL is how long the bucket of a client without requests is kept.
+	rateLimiterIdleTTL = 10 * time.Minute
+	// rateLimiterEvictInterval is how often idle buckets are evicted.
+	rateLimiterEvictInterval = time.Minute
+)
+
+// parseRateLimit parses "<requests per second>,<burst>", such as "0.5,5". "0" or "off" disables the limit.
+func parseRateLimit(v string) (limit rate.Limit, burst int, enabled bool, err error) {
+	v = strings.TrimSpace(v)
+	if v == "0" || v == "off" {
+		return 0, 0, false, nil
+	}
+	perSecond, b, ok := strings.Cut(v, ",")
+	if !ok {
+		return 0, 0, false, fmt.Errorf("rate limit must be <requests per second>,<burst>: %q", v)
+	}
+	r, err := strconv.ParseFloat(strings.TrimSpace(perSecond), 64)
+	if err != nil || r <= 0 || math.IsInf(r, 0) {
+		return 0, 0, false, fmt.Errorf("requests per second must be a positive number: %q", perSecond)
+	}
+	burst, err = strconv.Atoi(strings.TrimSpace(b))
+	if err != nil || burst < 1 {
+		return 0, 0, false, fmt.Errorf("burst must be a positive integer: %q", b)
+	}
+	return rate.Limit(r), burst, true, nil
+}
+
+// clientIP returns the IP of the client: the first address of X-Forwarded-For, or the host of RemoteAddr.
+// X-Forwarded-For can be set by anyone, so it identifies the client only behind a proxy that overwrites it.
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		if ip := strings.TrimSpace(first); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ipLimiter is the bucket of a client and when it was last used.
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// ipRateLimiter keeps a token bucket per client IP. It is safe for concurrent use.
+type ipRateLimiter struct {
+	limit rate.Limit
+	burst int
+	clock Clock
+
+	mu       sync.Mutex
+	limiters map[string]*ipLimiter
+}
+
+func newIPRateLimiter(limit rate.Limit, burst int, clock Clock) *ipRateLimiter {
+	return &ipRateLimiter{limit: limit, burst: burst, clock: clock, limiters: map[string]*ipLimiter{}}
+}
+
+// reserve takes a token of the client. If none is left, it returns false and how long until one is.
+func (l *ipRateLimiter) reserve(ip string) (bool, time.Duration) {
+	now := l.clock.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.limiters[ip]
+	if !ok {
+		entry = &ipLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[ip] = entry
+	}
+	entry.lastSeen = now
+	if entry.limiter.AllowN(now, 1) {
+		return true, 0
+	}
+	// 予約して待ち時間を調べ、使わないのですぐに取り消す
+	r := entry.limiter.ReserveN(now, 1)
+	delay := r.DelayFrom(now)
+	r.CancelAt(now)
+	return false, delay
+}
+
+// evict removes the buckets of clients without requests for idle, and returns how many remain.
+func (l *ipRateLimiter) evict(idle time.Duration) int {
+	cutoff := l.clock.Now().Add(-idle)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ip, entry := range l.limiters {
+		if entry.lastSeen.Before(cutoff) {
+			delete(l.limiters, ip)
+		}
+	}
+	return len(l.limiters)
+}
+
+// runEvictor evicts idle buckets every interval until done is closed.
+func (l *ipRateLimiter) runEvictor(interval, idle time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.evict(idle)
+		case <-done:
+			return
+		}
+	}
+}
+
+// rateLimitMiddleware rejects requests over the limit of their client with 429 and Retry-After.
+// A nil limiter disables the limit.
+func rateLimitMiddleware(next http.HandlerFunc, l *ipRateLimiter) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, delay := l.reserve(clientIP(r))
+		if !ok {
+			// Retry-Afterは秒単位なので切り上げる
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
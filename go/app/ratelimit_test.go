@@ -0,0 +1,180 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"mercari-build-training/app/apptest"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		in          string
+		wantLimit   rate.Limit
+		wantBurst   int
+		wantEnabled bool
+		wantErr     bool
+	}{
+		"ok: default":           {in: defaultRateLimit, wantLimit: 1, wantBurst: 10, wantEnabled: true},
+		"ok: fraction":          {in: "0.5,5", wantLimit: 0.5, wantBurst: 5, wantEnabled: true},
+		"ok: spaces":            {in: " 2 , 3 ", wantLimit: 2, wantBurst: 3, wantEnabled: true},
+		"ok: disabled":          {in: "0"},
+		"ok: off":               {in: "off"},
+		"ng: no burst":          {in: "1", wantErr: true},
+		"ng: zero rate":         {in: "0,5", wantErr: true},
+		"ng: negative rate":     {in: "-1,5", wantErr: true},
+		"ng: not a number":      {in: "fast,5", wantErr: true},
+		"ng: zero burst":        {in: "1,0", wantErr: true},
+		"ng: fractional burst":  {in: "1,1.5", wantErr: true},
+		"ng: empty":             {in: "", wantErr: true},
+		"ng: infinite requests": {in: "Inf,5", wantErr: true},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			limit, burst, enabled, err := parseRateLimit(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got %v", tt.wantErr, err)
+			}
+			if limit != tt.wantLimit || burst != tt.wantBurst || enabled != tt.wantEnabled {
+				t.Errorf("expected (%v, %d, %v), got (%v, %d, %v)", tt.wantLimit, tt.wantBurst, tt.wantEnabled, limit, burst, enabled)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		forwarded  string
+		remoteAddr string
+		want       string
+	}{
+		"remote addr":              {remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		"remote addr ipv6":         {remoteAddr: "[2001:db8::1]:1234", want: "2001:db8::1"},
+		"remote addr without port": {remoteAddr: "192.0.2.1", want: "192.0.2.1"},
+		"forwarded":                {forwarded: "203.0.113.5", remoteAddr: "192.0.2.1:1234", want: "203.0.113.5"},
+		"first of forwarded":       {forwarded: "203.0.113.5, 198.51.100.7", remoteAddr: "192.0.2.1:1234", want: "203.0.113.5"},
+		"empty forwarded entry":    {forwarded: " , 198.51.100.7", remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest("POST", "/items", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := clientIP(r); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	t.Parallel()
+
+	clock := apptest.NewFakeClock(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	limiter := newIPRateLimiter(0.5, 2, clock)
+	next := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) }
+	handler := rateLimitMiddleware(next, limiter)
+
+	post := func(ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/items", nil)
+		r.RemoteAddr = ip + ":1234"
+		rr := httptest.NewRecorder()
+		handler(rr, r)
+		return rr
+	}
+
+	// burstの2回までは通り、3回目は断られる
+	for i := range 2 {
+		if rr := post("192.0.2.1"); rr.Code != http.StatusCreated {
+			t.Fatalf("request %d: expected status code %d, got %d", i+1, http.StatusCreated, rr.Code)
+		}
+	}
+	rr := post("192.0.2.1")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status code %d over the limit, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	// 0.5回/秒なので、次のトークンまで2秒
+	if got := rr.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("expected Retry-After 2, got %q", got)
+	}
+
+	// 別のIPには別のバケットがある
+	if rr := post("192.0.2.2"); rr.Code != http.StatusCreated {
+		t.Errorf("expected another client to be allowed, got %d", rr.Code)
+	}
+
+	// 断られたリクエストはトークンを使わないので、待てばまた通る
+	clock.Advance(2 * time.Second)
+	if rr := post("192.0.2.1"); rr.Code != http.StatusCreated {
+		t.Errorf("expected the client to be allowed after Retry-After, got %d", rr.Code)
+	}
+	if rr := post("192.0.2.1"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the next request to be limited again, got %d", rr.Code)
+	}
+}
+
+func TestRateLimitOnlyAddItem(t *testing.T) {
+	t.Parallel()
+
+	clock := apptest.NewFakeClock(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	h := &Handlers{uploadLimiter: newIPRateLimiter(1, 1, clock)}
+	mux := newMux(h.routes())
+
+	serve := func(method, target string) int {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+		return rr.Code
+	}
+
+	// 1回目はハンドラまで届く (本文がないので400)
+	if code := serve("POST", "/items"); code == http.StatusTooManyRequests {
+		t.Fatalf("expected the first POST /items to reach the handler, got %d", code)
+	}
+	if code := serve("POST", "/items"); code != http.StatusTooManyRequests {
+		t.Errorf("expected the second POST /items to be limited, got %d", code)
+	}
+	// 他のルートは制限しない
+	if code := serve("GET", "/openapi.json"); code != http.StatusOK {
+		t.Errorf("expected GET to be unlimited, got %d", code)
+	}
+}
+
+func TestIPRateLimiterEvict(t *testing.T) {
+	t.Parallel()
+
+	clock := apptest.NewFakeClock(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	limiter := newIPRateLimiter(1, 1, clock)
+	limiter.reserve("192.0.2.1")
+	clock.Advance(5 * time.Minute)
+	limiter.reserve("192.0.2.2")
+	clock.Advance(6 * time.Minute)
+
+	// 10分使われていない192.0.2.1だけを捨てる
+	if got := limiter.evict(rateLimiterIdleTTL); got != 1 {
+		t.Fatalf("expected 1 limiter to remain, got %d", got)
+	}
+	if _, ok := limiter.limiters["192.0.2.2"]; !ok {
+		t.Error("expected the recently used limiter to remain")
+	}
+
+	// 捨てたIPは新しいバケットで、burstの分だけまた通る
+	if ok, _ := limiter.reserve("192.0.2.1"); !ok {
+		t.Error("expected an evicted client to start with a full bucket")
+	}
+}
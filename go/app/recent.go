@@ -0,0 +1,85 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// 新着商品 (GET /items/recent?n=10)
+// トップページの「新着」のために、全件を取得してクライアントで並べ替えなくていいようにする
+// 件数は n で指定する (最初に作ったときの limit も同じ意味で受け付ける)
+
+const (
+	// defaultRecentItems is the number of items of GET /items/recent without n.
+	defaultRecentItems = 10
+	// maxRecentItems caps n of GET /items/recent. Larger values are lowered to it.
+	maxRecentItems = 100
+)
+
+type GetRecentItemsRequest struct {
+	Limit int
+}
+
+type GetRecentItemsResponse struct {
+	Items []Item `json:"items"`
+}
+
+func parseGetRecentItemsRequest(r *http.Request) (*GetRecentItemsRequest, error) {
+	q, err := parseQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	n, err := queryParam(q, "n", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := queryParam(q, "limit", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &GetRecentItemsRequest{Limit: defaultRecentItems}
+	name, value := "n", n
+	if n == "" {
+		name, value = "limit", limit
+	} else if limit != "" {
+		return nil, errors.New("n and limit cannot be used together")
+	}
+	if value != "" {
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 1 {
+			return nil, fmt.Errorf("%s must be a positive integer", name)
+		}
+		req.Limit = min(v, maxRecentItems)
+	}
+	return req, nil
+}
+
+// GetRecentItems is a handler to return the n most recently added items for GET /items/recent .
+func (s *Handlers) GetRecentItems(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGetRecentItemsRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	items, err := s.itemRepo.GetRecentItems(r.Context(), req.Limit)
+	if err != nil {
+		slog.Error("failed to get recent items: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(GetRecentItemsResponse{Items: items}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
+
+	"mercari-build-training/app/apptest"
+)
+
+func TestParseGetRecentItemsRequest(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		query     string
+		wantLimit int
+		wantErr   bool
+	}{
+		"ok: default":      {query: "", wantLimit: defaultRecentItems},
+		"ok: n":            {query: "?n=5", wantLimit: 5},
+		"ok: at the cap":   {query: "?n=100", wantLimit: maxRecentItems},
+		"ok: capped":       {query: "?n=1000", wantLimit: maxRecentItems},
+		"ok: limit":        {query: "?limit=5", wantLimit: 5},
+		"ok: capped limit": {query: "?limit=1000", wantLimit: maxRecentItems},
+		"ng: zero":         {query: "?n=0", wantErr: true},
+		"ng: negative":     {query: "?n=-1", wantErr: true},
+		"ng: not integer":  {query: "?n=abc", wantErr: true},
+		"ng: both":         {query: "?n=5&limit=5", wantErr: true},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseGetRecentItemsRequest(httptest.NewRequest("GET", "/items/recent"+tt.query, nil))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && got.Limit != tt.wantLimit {
+				t.Errorf("expected limit %d, got %d", tt.wantLimit, got.Limit)
+			}
+		})
+	}
+}
+
+func TestGetRecentItemsE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	clock := apptest.NewFakeClock(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	repo := &itemRepository{db: db, clock: clock}
+	for i := 1; i <= 25; i++ {
+		clock.Advance(time.Minute)
+		if err := repo.Insert(t.Context(), &Item{Name: "item" + strconv.Itoa(i), Category: "fashion", Image: "default.jpg"}); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	if err := repo.SoftDelete(t.Context(), "25"); err != nil {
+		t.Fatalf("failed to delete item: %v", err)
+	}
+	// 新しい方から並べた、削除されていない商品
+	newest := func(n int) []string {
+		names := []string{}
+		for i := 24; i > 24-n; i-- {
+			names = append(names, "item"+strconv.Itoa(i))
+		}
+		return names
+	}
+	h := &Handlers{itemRepo: repo}
+
+	cases := map[string]struct {
+		target string
+		code   int
+		names  []string
+	}{
+		"ok: default n":     {target: "/items/recent", code: http.StatusOK, names: newest(defaultRecentItems)},
+		"ok: n":             {target: "/items/recent?n=3", code: http.StatusOK, names: newest(3)},
+		"ok: limit":         {target: "/items/recent?limit=3", code: http.StatusOK, names: newest(3)},
+		"ok: more than all": {target: "/items/recent?n=100", code: http.StatusOK, names: newest(24)},
+		"ok: capped":        {target: "/items/recent?n=101", code: http.StatusOK, names: newest(24)},
+		"ng: zero":          {target: "/items/recent?n=0", code: http.StatusBadRequest},
+		"ng: not integer":   {target: "/items/recent?n=ten", code: http.StatusBadRequest},
+		"ng: zero limit":    {target: "/items/recent?limit=0", code: http.StatusBadRequest},
+		"ng: n and limit":   {target: "/items/recent?n=3&limit=3", code: http.StatusBadRequest},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.GetRecentItems(rr, httptest.NewRequest("GET", tt.target, nil))
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var resp GetRecentItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			names := []string{}
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRecentRouteIsNotAnItemID(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockIR := NewMockItemRepository(ctrl)
+	mockIR.EXPECT().GetRecentItems(gomock.Any(), defaultRecentItems).Return([]Item{}, nil)
+	h := &Handlers{itemRepo: mockIR}
+
+	rr := httptest.NewRecorder()
+	newMux(h.routes()).ServeHTTP(rr, httptest.NewRequest("GET", "/items/recent", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
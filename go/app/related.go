@@ -0,0 +1,85 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// 関連商品 (GET /items/{item_id}/related)
+// 商品ページの「こちらもおすすめ」に、同じカテゴリの商品を返す (足りなければ新着の商品で埋める)
+
+const (
+	// defaultRelatedItems is the number of items of GET /items/{item_id}/related without limit.
+	defaultRelatedItems = 6
+	// maxRelatedItems caps limit of GET /items/{item_id}/related. A larger limit is lowered to it.
+	maxRelatedItems = 20
+)
+
+type GetRelatedItemsRequest struct {
+	Id    string
+	Limit int
+}
+
+type GetRelatedItemsResponse struct {
+	Items []Item `json:"items"`
+}
+
+func parseGetRelatedItemsRequest(r *http.Request) (*GetRelatedItemsRequest, error) {
+	idReq, err := parseGetItemByIdRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	q, err := parseQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := queryParam(q, "limit", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &GetRelatedItemsRequest{Id: idReq.Id, Limit: defaultRelatedItems}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("limit must be a positive integer: %s", limit)
+		}
+		req.Limit = min(n, maxRelatedItems)
+	}
+	return req, nil
+}
+
+// GetRelatedItems is a handler to return items related to an item for GET /items/{item_id}/related .
+// It returns up to limit (at most maxRelatedItems) items of the same category, then recently added items of other categories.
+func (s *Handlers) GetRelatedItems(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGetRelatedItemsRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	items, err := s.itemRepo.GetRelatedItems(r.Context(), req.Id, req.Limit)
+	if err != nil {
+		if errors.Is(err, errItemNotFound) {
+			slog.Warn("item not exist: ", "error", err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get related items: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(GetRelatedItemsResponse{Items: items}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
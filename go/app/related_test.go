@@ -0,0 +1,138 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"mercari-build-training/app/apptest"
+)
+
+func TestParseGetRelatedItemsRequest(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		query     string
+		wantLimit int
+		wantErr   bool
+	}{
+		"ok: default":     {query: "", wantLimit: defaultRelatedItems},
+		"ok: limit":       {query: "?limit=3", wantLimit: 3},
+		"ok: at the cap":  {query: "?limit=20", wantLimit: maxRelatedItems},
+		"ok: capped":      {query: "?limit=1000", wantLimit: maxRelatedItems},
+		"ng: zero":        {query: "?limit=0", wantErr: true},
+		"ng: negative":    {query: "?limit=-1", wantErr: true},
+		"ng: not integer": {query: "?limit=abc", wantErr: true},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/items/1/related"+tt.query, nil)
+			req.SetPathValue("item_id", "1")
+			got, err := parseGetRelatedItemsRequest(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && got.Limit != tt.wantLimit {
+				t.Errorf("expected limit %d, got %d", tt.wantLimit, got.Limit)
+			}
+		})
+	}
+}
+
+func TestGetRelatedItemsE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	clock := apptest.NewFakeClock(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	repo := &itemRepository{db: db, clock: clock}
+	for _, item := range []Item{
+		{Name: "jacket", Category: "fashion"},
+		{Name: "jeans", Category: "fashion"},
+		{Name: "coat", Category: "fashion"},
+		{Name: "sneakers", Category: "shoes"},
+		{Name: "iPhone", Category: "phone"},
+		{Name: "scarf", Category: "fashion"},
+	} {
+		clock.Advance(time.Hour)
+		item.Image = "default.jpg"
+		if err := repo.Insert(t.Context(), &item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	if err := repo.SoftDelete(t.Context(), "6"); err != nil {
+		t.Fatalf("failed to delete item: %v", err)
+	}
+	h := &Handlers{itemRepo: repo}
+
+	cases := map[string]struct {
+		id     string
+		target string
+		code   int
+		names  []string
+	}{
+		"ok: same category first": {
+			id: "1", target: "/items/1/related", code: http.StatusOK,
+			names: []string{"coat", "jeans", "iPhone", "sneakers"},
+		},
+		"ok: limit": {
+			id: "1", target: "/items/1/related?limit=2", code: http.StatusOK,
+			names: []string{"coat", "jeans"},
+		},
+		"ok: fallback only": {
+			id: "4", target: "/items/4/related?limit=3", code: http.StatusOK,
+			names: []string{"iPhone", "coat", "jeans"},
+		},
+		"ok: limit is capped": {
+			id: "1", target: "/items/1/related?limit=1000", code: http.StatusOK,
+			names: []string{"coat", "jeans", "iPhone", "sneakers"},
+		},
+		"ng: unknown item":  {id: "99", target: "/items/99/related", code: http.StatusNotFound},
+		"ng: deleted item":  {id: "6", target: "/items/6/related", code: http.StatusNotFound},
+		"ng: zero limit":    {id: "1", target: "/items/1/related?limit=0", code: http.StatusBadRequest},
+		"ng: invalid limit": {id: "1", target: "/items/1/related?limit=abc", code: http.StatusBadRequest},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tt.target, nil)
+			req.SetPathValue("item_id", tt.id)
+			h.GetRelatedItems(rr, req)
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var resp GetRelatedItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			names := []string{}
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
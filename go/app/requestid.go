@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"net/http"
+)
+
+// リクエストごとのID
+// ログの行を同じリクエストのものとして結び付けられるように、レスポンスのヘッダーでも返す
+
+// requestIDHeader is the header carrying the request id, both in the request and the response.
+const requestIDHeader = "X-Request-Id"
+
+// maxRequestIDLen bounds the request id accepted from a client.
+const maxRequestIDLen = 64
+
+// requestIDKey is the context key set by requestIDMiddleware.
+type requestIDKey struct{}
+
+// requestIDFrom returns the id of the request set by requestIDMiddleware, or "" if there is none.
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID reports whether a request id given by a client is safe to log and echo:
+// 1 to maxRequestIDLen letters, digits, '-', '_' or '.'.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range []byte(id) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDMiddleware gives each request an id, sets it on the response as X-Request-Id
+// and makes it available to the handlers and the logs through requestIDFrom.
+// A valid X-Request-Id from the client, such as one set by a proxy, is kept; otherwise ids makes a new one.
+func requestIDMiddleware(next http.Handler, ids IDGenerator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = ids.NewID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
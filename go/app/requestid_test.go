@@ -0,0 +1,74 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mercari-build-training/app/apptest"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		// headers are the X-Request-Id of the requests in order. Empty means none.
+		headers []string
+		want    []string
+	}{
+		"ok: ids are generated in sequence": {
+			headers: []string{"", ""},
+			want:    []string{"req-1", "req-2"},
+		},
+		"ok: id from a proxy is kept": {
+			headers: []string{"abc-123_X.9"},
+			want:    []string{"abc-123_X.9"},
+		},
+		"ng: unsafe ids are replaced": {
+			headers: []string{"bad id", "line\nbreak", strings.Repeat("a", maxRequestIDLen+1)},
+			want:    []string{"req-1", "req-2", "req-3"},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var seen []string
+			handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = append(seen, requestIDFrom(r.Context()))
+			}), apptest.NewSequenceIDGenerator("req", 1))
+
+			for i, header := range tt.headers {
+				req := httptest.NewRequest("GET", "/items", nil)
+				if header != "" {
+					req.Header[requestIDHeader] = []string{header}
+				}
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+
+				if got := rr.Header().Get(requestIDHeader); got != tt.want[i] {
+					t.Errorf("request %d: expected %s %q, got %q", i, requestIDHeader, tt.want[i], got)
+				}
+				if seen[i] != tt.want[i] {
+					t.Errorf("request %d: expected the handler to see %q, got %q", i, tt.want[i], seen[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRandomIDGenerator(t *testing.T) {
+	t.Parallel()
+
+	var ids randomIDGenerator
+	a, b := ids.NewID(), ids.NewID()
+	if a == b {
+		t.Errorf("expected different ids, got %q twice", a)
+	}
+	// 生成したIDは、クライアントから受け取ったものと同じ検査を通る
+	if !validRequestID(a) || len(a) != 26 {
+		t.Errorf("expected a valid id of 26 characters, got %q", a)
+	}
+}
@@ -0,0 +1,408 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// searchFirstFlushItems is the number of items written before the first flush of GET /search,
+// so that the client can render the first results while the rest are still being read.
+const searchFirstFlushItems = 10
+
+// 検索のコストの上限
+// 語の多い検索や1文字だけの検索で、大きな商品テーブルを何度も走査させないようにする
+const (
+	// defaultSearchMaxTerms is the default of the search_max_terms flag.
+	defaultSearchMaxTerms = 5
+	// defaultSearchBroadMinItems is the default of the search_broad_min_items flag.
+	defaultSearchBroadMinItems = 10000
+	// defaultSearchCandidateLimit is the default of the search_candidate_limit flag.
+	defaultSearchCandidateLimit = 5000
+	// searchMinTermLen is the length a term needs for a search of a large table.
+	searchMinTermLen = 2
+)
+
+// 検索語の組み合わせ方 (?op=)
+const (
+	// searchOpAnd matches items with all the terms. It is the default.
+	searchOpAnd = "and"
+	// searchOpOr matches items with any of the terms.
+	searchOpOr = "or"
+)
+
+// 検索結果の並び順 (?sort=)
+const (
+	// searchSortRelevance orders the items by how well the name matches the keyword. It is the default.
+	searchSortRelevance = "relevance"
+	// searchSortNewest orders the items from the most recently added.
+	searchSortNewest = "newest"
+)
+
+// errSearchTooBroad is returned with guidance when a search would cost too much. It is 422.
+var errSearchTooBroad = errors.New("search query is too broad")
+
+/* SearchItemsByKeyword */
+type GetItemByKeywordRequest struct {
+	Keyword  string
+	MinPrice int
+	MaxPrice int
+	Limit    int
+	Offset   int
+	// IncludeDrafts includes unpublished items. It is given as ?include_drafts=true.
+	IncludeDrafts bool
+	// Op is searchOpAnd or searchOpOr.
+	Op string
+	// Sort is searchSortRelevance or searchSortNewest.
+	Sort string
+	// Category narrows the search to a category. Empty means any category.
+	Category string
+}
+
+func parseGetItemByKeywordRequest(r *http.Request) (*GetItemByKeywordRequest, error) {
+	q, err := parseQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	req := &GetItemByKeywordRequest{
+		// 指定がなければ上限・下限なし
+		MinPrice: 0,
+		MaxPrice: priceUnbounded,
+		Op:       searchOpAnd,
+		Sort:     searchSortRelevance,
+	}
+
+	// クエリパラメータを取得
+	req.Keyword, err = queryParam(q, "keyword", maxKeywordLen)
+	if err != nil {
+		return nil, err
+	}
+	minPrice, err := queryParam(q, "min_price", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	maxPrice, err := queryParam(q, "max_price", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := queryParam(q, "limit", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := queryParam(q, "offset", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	includeDrafts, err := queryParam(q, "include_drafts", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	op, err := queryParam(q, "op", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	sort, err := queryParam(q, "sort", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	req.Category, err = queryParam(q, "category", maxCategoryLen)
+	if err != nil {
+		return nil, err
+	}
+
+	// validation
+	switch op {
+	case "":
+	case searchOpAnd, searchOpOr:
+		req.Op = op
+	default:
+		return nil, fmt.Errorf("invalid op: %s, must be %s or %s", op, searchOpAnd, searchOpOr)
+	}
+	switch sort {
+	case "":
+	case searchSortRelevance, searchSortNewest:
+		req.Sort = sort
+	default:
+		return nil, fmt.Errorf("invalid sort: %s, must be %s or %s", sort, searchSortRelevance, searchSortNewest)
+	}
+	if req.Keyword == "" {
+		return nil, errors.New("keyword is required")
+	}
+	if n := utf8.RuneCountInString(req.Keyword); n > maxKeywordChars {
+		return nil, fmt.Errorf("keyword must be at most %d characters, got %d", maxKeywordChars, n)
+	}
+	if len(searchTerms(req.Keyword, req.matchAny())) == 0 {
+		return nil, errors.New("keyword has no terms")
+	}
+	if minPrice != "" {
+		p, err := parsePrice(minPrice)
+		if err != nil {
+			return nil, fmt.Errorf("min_price: %w", err)
+		}
+		req.MinPrice = p
+	}
+	if maxPrice != "" {
+		p, err := parsePrice(maxPrice)
+		if err != nil {
+			return nil, fmt.Errorf("max_price: %w", err)
+		}
+		req.MaxPrice = p
+	}
+	if req.MinPrice > req.MaxPrice {
+		return nil, fmt.Errorf("min_price (%d) must not be greater than max_price (%d)", req.MinPrice, req.MaxPrice)
+	}
+	if req.Limit, req.Offset, err = parsePage(limit, offset); err != nil {
+		return nil, err
+	}
+	if includeDrafts != "" {
+		v, err := strconv.ParseBool(includeDrafts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include_drafts: %s", includeDrafts)
+		}
+		req.IncludeDrafts = v
+	}
+
+	return req, nil
+}
+
+// SearchItemsResponse is the response of GET /search.
+// The handler streams the items by hand and then encodes searchPage after them, so the JSON must stay in sync with itemStreamWriter.
+type SearchItemsResponse struct {
+	Items []SearchItem `json:"items"`
+	searchPage
+}
+
+// searchPage is the pagination metadata written after the items of GET /search.
+type searchPage struct {
+	PaginationMeta
+	Keyword string `json:"keyword"`
+	// MinPrice, MaxPrice and Category echo the filters applied to the search, so that the client can show them as active filters.
+	// A bound that filters nothing, such as min_price=0, is omitted.
+	MinPrice *int   `json:"min_price,omitempty"`
+	MaxPrice *int   `json:"max_price,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// page returns the pagination metadata of the search with total items matching it.
+func (req *GetItemByKeywordRequest) page(total int) searchPage {
+	page := searchPage{PaginationMeta: newPaginationMeta(total, req.Limit, req.Offset), Keyword: req.Keyword, Category: req.Category}
+	if req.MinPrice > 0 {
+		page.MinPrice = &req.MinPrice
+	}
+	if req.MaxPrice != priceUnbounded {
+		page.MaxPrice = &req.MaxPrice
+	}
+	return page
+}
+
+// matchAny reports whether items with any of the terms match.
+func (req *GetItemByKeywordRequest) matchAny() bool {
+	return req.Op == searchOpOr
+}
+
+// filter returns the repository filter of the request.
+func (req *GetItemByKeywordRequest) filter(candidateLimit int) SearchFilter {
+	return SearchFilter{
+		Keyword:        req.Keyword,
+		MinPrice:       req.MinPrice,
+		MaxPrice:       req.MaxPrice,
+		CandidateLimit: candidateLimit,
+		Limit:          req.Limit,
+		Offset:         req.Offset,
+		IncludeDrafts:  req.IncludeDrafts,
+		MatchAny:       req.matchAny(),
+		Sort:           req.Sort,
+		Category:       req.Category,
+	}
+}
+
+// checkSearchCost rejects searches with too many terms, and searches of a large table whose terms are all
+// single characters, with errSearchTooBroad. With matchAny, a single such term is enough to be rejected,
+// since it alone matches most items.
+func (s *Handlers) checkSearchCost(ctx context.Context, keyword string, matchAny bool) error {
+	terms := searchTerms(keyword, matchAny)
+	if maxTerms := s.flags.Int(flagSearchMaxTerms); maxTerms > 0 && len(terms) > maxTerms {
+		return fmt.Errorf("%w: %d terms given, use at most %d terms", errSearchTooBroad, len(terms), maxTerms)
+	}
+	long := func(t string) bool { return utf8.RuneCountInString(t) >= searchMinTermLen }
+	if matchAny && !slices.ContainsFunc(terms, func(t string) bool { return !long(t) }) {
+		return nil
+	}
+	if !matchAny && slices.ContainsFunc(terms, long) {
+		return nil
+	}
+
+	// 語が短いときだけ、商品の数を確かめる
+	total, err := s.itemRepo.Count(ctx, ItemListOptions{})
+	if err != nil {
+		return err
+	}
+	if minItems := s.flags.Int(flagSearchBroadMinItems); total > minItems {
+		if matchAny {
+			return fmt.Errorf("%w: with op=or, every term needs %d or more characters", errSearchTooBroad, searchMinTermLen)
+		}
+		return fmt.Errorf("%w: include at least one term of %d or more characters", errSearchTooBroad, searchMinTermLen)
+	}
+	return nil
+}
+
+// SearchItemsByKeyword is a handler to search items by keyword for GET /search .
+// The response is {"items":[...],"total":N,"limit":L,"offset":O,"page":P,"total_pages":T,"has_more":B,"keyword":"..."},
+// paged with ?limit=&offset= (see PaginationMeta),
+// with "min_price", "max_price" and "category" echoed when the search is narrowed by ?min_price=&max_price=&category=.
+// Each item has "matches", the rune ranges of its name, category and brand matching the terms (see SearchItem).
+// An unknown category matches no items.
+// Items are streamed as they are read from the database: the first searchFirstFlushItems items are
+// flushed right away, and total, which is counted by a separate query running in parallel, is written last.
+// The rows of the page are handed to the writer through a buffer of limit items, so the query finishes
+// and releases its connection even when the client reads slowly.
+// Searches that would cost too much are rejected with 422 and guidance, and a LIKE search stops at
+// the search_candidate_limit flag, so total is at most that many.
+func (s *Handlers) SearchItemsByKeyword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := parseGetItemByKeywordRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkSearchCost(ctx, req.Keyword, req.matchAny()); err != nil {
+		if errors.Is(err, errSearchTooBroad) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Error("failed to check search cost: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(ctx, "parse")
+	filter := req.filter(s.flags.Int(flagSearchCandidateLimit))
+
+	// 件数は別のクエリで並行して数える
+	type countResult struct {
+		total int
+		err   error
+	}
+	countCh := make(chan countResult, 1)
+	go func() {
+		total, err := s.itemRepo.CountItemsByKeyword(ctx, filter)
+		countCh <- countResult{total: total, err: err}
+	}()
+
+	// 接続は1つしかないことがあるので、行を読みながらクライアントへの書き込みを待たない
+	// ページ分 (limit件) のバッファがあれば、読み込みは書き込みに関係なく終わる
+	sw := &itemStreamWriter{w: w, terms: searchTerms(req.Keyword, req.matchAny())}
+	itemCh := make(chan Item, filter.Limit)
+	writeErrCh := make(chan error, 1)
+	go func() {
+		var err error
+		for item := range itemCh {
+			// 書き込みに失敗しても、読み込みが止まらないように受け取り続ける
+			if err == nil {
+				err = sw.write(item)
+			}
+		}
+		writeErrCh <- err
+	}()
+	err = s.itemRepo.SearchItemsByKeyword(ctx, filter, func(item Item) error {
+		itemCh <- item
+		return nil
+	})
+	close(itemCh)
+	if writeErr := <-writeErrCh; err == nil {
+		err = writeErr
+	}
+	checkpoint(ctx, "stream")
+
+	// エラーでも件数のgoroutineの終了を待つ (リクエストのトレースを共有しているため)
+	count := <-countCh
+	if err == nil {
+		err = count.err
+	}
+	checkpoint(ctx, "db")
+
+	if err != nil {
+		if !sw.started {
+			// まだ何も書いていなければ、普通にエラーを返せる
+			slog.Error("failed to search items: ", "error", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		// 途中まで書いてしまったので、レスポンスを打ち切ってクライアントに不完全なことを伝える
+		slog.Error("search stream aborted: ", "error", err, "request_id", requestIDFrom(ctx), "path", logValue(r.URL.Path), "keyword", logValue(req.Keyword), "written", sw.count)
+		panic(http.ErrAbortHandler)
+	}
+
+	sw.start()
+	page, err := json.Marshal(req.page(count.total))
+	if err != nil {
+		slog.Error("failed to encode search page: ", "error", err)
+		return
+	}
+	// {"total":...} の先頭の { を除いて、itemsの配列の後ろにつなげる
+	sw.writeString(`],` + strings.TrimPrefix(string(page), "{") + "\n")
+	checkpoint(ctx, "encode")
+}
+
+// itemStreamWriter writes items as elements of the "items" array of a JSON envelope.
+// The envelope prefix is written lazily on the first item, so that an error before any item
+// can still be reported with a proper status code.
+type itemStreamWriter struct {
+	w http.ResponseWriter
+	// terms are the normalized terms of the keyword, whose matches are written with each item.
+	terms   []string
+	started bool
+	count   int
+	err     error
+}
+
+// start writes the headers and the envelope prefix if they have not been written yet.
+func (sw *itemStreamWriter) start() {
+	if sw.started {
+		return
+	}
+	sw.started = true
+	sw.w.Header().Set("Content-Type", "application/json")
+	sw.w.WriteHeader(http.StatusOK)
+	sw.writeString(`{"items":[`)
+}
+
+// write appends an item with its matches to the array and flushes after the first searchFirstFlushItems items.
+func (sw *itemStreamWriter) write(item Item) error {
+	sw.start()
+	data, err := json.Marshal(searchItem(item, sw.terms))
+	if err != nil {
+		return err
+	}
+	if sw.count > 0 {
+		sw.writeString(",")
+	}
+	if _, err := sw.w.Write(data); err != nil {
+		return err
+	}
+	sw.count++
+	if sw.count == searchFirstFlushItems {
+		sw.flush()
+	}
+	return sw.err
+}
+
+func (sw *itemStreamWriter) writeString(s string) {
+	if sw.err != nil {
+		return
+	}
+	_, sw.err = sw.w.Write([]byte(s))
+}
+
+func (sw *itemStreamWriter) flush() {
+	if f, ok := sw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
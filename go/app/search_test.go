@@ -0,0 +1,986 @@
+package app
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
+
+	"mercari-build-training/app/apptest"
+)
+
+// jacketFilter is the filter of GET /search?keyword=jacket.
+var jacketFilter = SearchFilter{Keyword: "jacket", MinPrice: 0, MaxPrice: priceUnbounded, CandidateLimit: defaultSearchCandidateLimit, Limit: defaultItemsLimit, Sort: searchSortRelevance}
+
+func TestSearchItemsStreaming(t *testing.T) {
+	t.Parallel()
+
+	const (
+		firstBatch = searchFirstFlushItems
+		total      = searchFirstFlushItems + 2
+		stall      = 500 * time.Millisecond
+	)
+
+	cases := map[string]struct {
+		gzip bool
+	}{
+		"ok: plain": {gzip: false},
+		// 圧縮していても、最初のバッチは止まる前に届く
+		"ok: gzip": {gzip: true},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			// 最初のバッチを返したあと、残りを返す前にしばらく止まる
+			mockIR.EXPECT().SearchItemsByKeyword(gomock.Any(), jacketFilter, gomock.Any()).DoAndReturn(func(_ context.Context, _ SearchFilter, fn func(Item) error) error {
+				for i := 1; i <= total; i++ {
+					if i == firstBatch+1 {
+						time.Sleep(stall)
+					}
+					if err := fn(Item{ID: i, Name: fmt.Sprintf("jacket %d", i), Category: "fashion"}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			mockIR.EXPECT().CountItemsByKeyword(gomock.Any(), jacketFilter).Return(total, nil)
+			h := &Handlers{itemRepo: mockIR}
+
+			srv := httptest.NewServer(gzipMiddleware(http.HandlerFunc(h.SearchItemsByKeyword)))
+			t.Cleanup(srv.Close)
+
+			req, err := http.NewRequest("GET", srv.URL+"/search?keyword=jacket", nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			if tt.gzip {
+				// 自分で指定すると、Transportは展開せずにそのまま返す
+				req.Header.Set("Accept-Encoding", "gzip")
+			} else {
+				req.Header.Set("Accept-Encoding", "identity")
+			}
+			start := time.Now()
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("failed to request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var body io.Reader = resp.Body
+			if tt.gzip {
+				if ce := resp.Header.Get("Content-Encoding"); ce != "gzip" {
+					t.Fatalf("expected Content-Encoding gzip, got %q", ce)
+				}
+				zr, err := gzip.NewReader(resp.Body)
+				if err != nil {
+					t.Fatalf("failed to read gzip header: %v", err)
+				}
+				body = zr
+			}
+
+			buf := make([]byte, 1)
+			if _, err := io.ReadFull(body, buf); err != nil {
+				t.Fatalf("failed to read first byte: %v", err)
+			}
+			if ttfb := time.Since(start); ttfb >= stall {
+				t.Errorf("expected first byte before the stall of %v, got %v", stall, ttfb)
+			}
+
+			rest, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+			var got SearchItemsResponse
+			if err := json.Unmarshal(append(buf, rest...), &got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(got.Items) != total || got.Total != total {
+				t.Errorf("expected %d items and total %d, got %d items and total %d", total, total, len(got.Items), got.Total)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected Content-Type application/json, got %q", ct)
+			}
+		})
+	}
+}
+
+func TestSearchItemsStalledClientE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+	// 本番の既定と同じく、接続は1つだけ
+	db.SetMaxOpenConns(1)
+
+	repo := &itemRepository{db: db}
+	items := make([]*Item, searchFirstFlushItems+2)
+	for i := range items {
+		items[i] = &Item{Name: fmt.Sprintf("jacket %d", i), Category: "fashion", Image: "default.jpg"}
+	}
+	if err := repo.InsertMany(t.Context(), items); err != nil {
+		t.Fatalf("failed to insert items: %v", err)
+	}
+
+	h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: repo}
+	mux := newMux(h.routes())
+
+	sw := &stalledWriter{ResponseRecorder: httptest.NewRecorder(), stalled: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.ServeHTTP(sw, httptest.NewRequest("GET", "/search?keyword=jacket", nil))
+	}()
+	<-sw.stalled
+
+	// クライアントが読まなくなっても、検索のクエリは接続を返している
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Second)
+	defer cancel()
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/items", nil).WithContext(ctx))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status code %d while the search is stalled, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	close(sw.release)
+	<-done
+	var got SearchItemsResponse
+	if err := json.NewDecoder(sw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(got.Items) != len(items) || got.Total != len(items) {
+		t.Errorf("expected %d items and total %d, got %d items and total %d", len(items), len(items), len(got.Items), got.Total)
+	}
+}
+
+func TestSearchItemsErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		items     int
+		searchErr error
+		countErr  error
+		wantCode  int
+	}{
+		"ng: search fails before any item": {
+			searchErr: errors.New("db error"),
+			wantCode:  http.StatusInternalServerError,
+		},
+		"ng: count fails before any item": {
+			countErr: errors.New("db error"),
+			wantCode: http.StatusInternalServerError,
+		},
+		"ok: no items": {
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			mockIR.EXPECT().SearchItemsByKeyword(gomock.Any(), jacketFilter, gomock.Any()).Return(tt.searchErr)
+			mockIR.EXPECT().CountItemsByKeyword(gomock.Any(), jacketFilter).Return(0, tt.countErr)
+			h := &Handlers{itemRepo: mockIR}
+
+			req := httptest.NewRequest("GET", "/search?keyword=jacket", nil)
+			rr := httptest.NewRecorder()
+			h.SearchItemsByKeyword(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status code %d, got %d", tt.wantCode, rr.Code)
+			}
+			if tt.wantCode == http.StatusOK {
+				if want := `{"items":[],"total":0,"limit":50,"offset":0,"page":1,"total_pages":0,"has_more":false,"keyword":"jacket"}` + "\n"; rr.Body.String() != want {
+					t.Errorf("expected body %q, got %q", want, rr.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestSearchItemsPriceRangeE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "cheap shoe", Category: "fashion", Image: "default.jpg", Price: Price{Amount: 500}},
+		{Name: "shoe", Category: "fashion", Image: "default.jpg", Price: Price{Amount: 1000}},
+		{Name: "good shoe", Category: "fashion", Image: "default.jpg", Price: Price{Amount: 5000}},
+		{Name: "luxury shoe", Category: "fashion", Image: "default.jpg", Price: Price{Amount: 30000}},
+		{Name: "bag", Category: "fashion", Image: "default.jpg", Price: Price{Amount: 3000}},
+	} {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+
+	cases := map[string]struct {
+		target string
+		code   int
+		names  []string
+		// minPrice and maxPrice are the bounds echoed in the response, or 0 when none is.
+		minPrice int
+		maxPrice int
+	}{
+		// 名前が一致する商品が先
+		"ok: no bounds": {
+			target: "/search?keyword=shoe",
+			code:   http.StatusOK,
+			names:  []string{"shoe", "cheap shoe", "good shoe", "luxury shoe"},
+		},
+		"ok: only min": {
+			target:   "/search?keyword=shoe&min_price=1000",
+			code:     http.StatusOK,
+			names:    []string{"shoe", "good shoe", "luxury shoe"},
+			minPrice: 1000,
+		},
+		"ok: only max": {
+			target:   "/search?keyword=shoe&max_price=5000",
+			code:     http.StatusOK,
+			names:    []string{"shoe", "cheap shoe", "good shoe"},
+			maxPrice: 5000,
+		},
+		"ok: both set": {
+			target:   "/search?keyword=shoe&min_price=1000&max_price=5000",
+			code:     http.StatusOK,
+			names:    []string{"shoe", "good shoe"},
+			minPrice: 1000,
+			maxPrice: 5000,
+		},
+		// 0円以上は何も絞り込まないので、返さない
+		"ok: zero min": {
+			target: "/search?keyword=shoe&min_price=0",
+			code:   http.StatusOK,
+			names:  []string{"shoe", "cheap shoe", "good shoe", "luxury shoe"},
+		},
+		"ng: min greater than max": {
+			target: "/search?keyword=shoe&min_price=5000&max_price=1000",
+			code:   http.StatusBadRequest,
+		},
+		"ng: negative min": {
+			target: "/search?keyword=shoe&min_price=-1",
+			code:   http.StatusBadRequest,
+		},
+		"ng: non-integer max": {
+			target: "/search?keyword=shoe&max_price=abc",
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := &Handlers{itemRepo: repo}
+			req := httptest.NewRequest("GET", tt.target, nil)
+			rr := httptest.NewRecorder()
+			h.SearchItemsByKeyword(rr, req)
+
+			if tt.code != rr.Code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code >= 400 {
+				return
+			}
+			var resp SearchItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var names []string
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+			if resp.Total != len(tt.names) {
+				t.Errorf("expected total %d, got %d", len(tt.names), resp.Total)
+			}
+			deref := func(p *int) int {
+				if p == nil {
+					return 0
+				}
+				return *p
+			}
+			if got := deref(resp.MinPrice); got != tt.minPrice {
+				t.Errorf("expected min_price %d to be echoed, got %d", tt.minPrice, got)
+			}
+			if got := deref(resp.MaxPrice); got != tt.maxPrice {
+				t.Errorf("expected max_price %d to be echoed, got %d", tt.maxPrice, got)
+			}
+		})
+	}
+}
+
+func TestSearchItemsPagingE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for i := 1; i <= 5; i++ {
+		if err := repo.Insert(t.Context(), &Item{Name: fmt.Sprintf("shoe %d", i), Category: "fashion", Image: "default.jpg"}); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	h := &Handlers{itemRepo: repo}
+
+	search := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.SearchItemsByKeyword(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+
+	// 2件ずつ最後のページまでたどる
+	var names []string
+	for offset := 0; ; offset += 2 {
+		rr := search(fmt.Sprintf("/search?keyword=shoe&limit=2&offset=%d", offset))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp SearchItemsResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		// 5件を2件ずつ: 3ページ目が最後で、その先は空
+		want := searchPage{
+			PaginationMeta: PaginationMeta{Total: 5, Limit: 2, Offset: offset, Page: offset/2 + 1, TotalPages: 3, HasMore: offset < 4},
+			Keyword:        "shoe",
+		}
+		if resp.searchPage != want {
+			t.Errorf("unexpected page metadata: expected %+v, got %+v", want, resp.searchPage)
+		}
+		if len(resp.Items) == 0 {
+			break
+		}
+		for _, item := range resp.Items {
+			names = append(names, item.Name)
+		}
+	}
+	want := []string{"shoe 1", "shoe 2", "shoe 3", "shoe 4", "shoe 5"}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("unexpected items across pages (-want +got):\n%s", diff)
+	}
+
+	// 一致しなければitemsは空の配列 (nullではない)
+	rr := search("/search?keyword=hat")
+	if want := `{"items":[],"total":0,"limit":50,"offset":0,"page":1,"total_pages":0,"has_more":false,"keyword":"hat"}` + "\n"; rr.Body.String() != want {
+		t.Errorf("expected body %q, got %q", want, rr.Body.String())
+	}
+
+	for _, target := range []string{"/search?keyword=shoe&limit=0", "/search?keyword=shoe&limit=201", "/search?keyword=shoe&offset=-1"} {
+		if rr := search(target); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", target, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestSearchItemsOpE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "leather bag", Category: "accessories", Image: "default.jpg"},
+		{Name: "pouch", Category: "accessories", Image: "default.jpg"},
+		{Name: "bag with a pouch", Category: "accessories", Image: "default.jpg"},
+		{Name: "jacket", Category: "fashion", Image: "default.jpg"},
+		{Name: "color pen", Category: "stationery", Image: "default.jpg"},
+	} {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	h := &Handlers{itemRepo: repo}
+
+	cases := map[string]struct {
+		target string
+		code   int
+		names  []string
+	}{
+		"ok: and by default": {
+			target: "/search?keyword=bag+pouch",
+			code:   http.StatusOK,
+			names:  []string{"bag with a pouch"},
+		},
+		"ok: and": {
+			target: "/search?keyword=bag+pouch&op=and",
+			code:   http.StatusOK,
+			names:  []string{"bag with a pouch"},
+		},
+		"ok: or": {
+			target: "/search?keyword=leather+pouch&op=or",
+			code:   http.StatusOK,
+			names:  []string{"leather bag", "pouch", "bag with a pouch"},
+		},
+		// ORは区切りで、"color" に一致しない
+		"ok: or between terms": {
+			target: "/search?keyword=jacket+OR+pouch&op=or",
+			code:   http.StatusOK,
+			names:  []string{"pouch", "bag with a pouch", "jacket"},
+		},
+		"ng: unknown op": {
+			target: "/search?keyword=bag&op=xor",
+			code:   http.StatusBadRequest,
+		},
+		"ng: upper case op": {
+			target: "/search?keyword=bag&op=OR",
+			code:   http.StatusBadRequest,
+		},
+		"ng: only OR": {
+			target: "/search?keyword=OR&op=or",
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.SearchItemsByKeyword(rr, httptest.NewRequest("GET", tt.target, nil))
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var resp SearchItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var names []string
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+			if resp.Total != len(tt.names) {
+				t.Errorf("expected total %d, got %d", len(tt.names), resp.Total)
+			}
+		})
+	}
+}
+
+func TestSearchItemsCategoryE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "leather bag", Category: "fashion", Image: "default.jpg", Price: Price{Amount: 8000}},
+		{Name: "tote bag", Category: "fashion", Image: "default.jpg", Price: Price{Amount: 3000}},
+		{Name: "bag charm", Category: "fashion", Image: "default.jpg", Price: Price{Amount: 1000}},
+		{Name: "camera bag", Category: "electronics", Image: "default.jpg", Price: Price{Amount: 4000}},
+		{Name: "jacket", Category: "fashion", Image: "default.jpg", Price: Price{Amount: 9000}},
+	} {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	h := &Handlers{itemRepo: repo}
+
+	cases := map[string]struct {
+		target string
+		code   int
+		names  []string
+		total  int
+	}{
+		"ok: category": {
+			target: "/search?keyword=bag&category=fashion&sort=newest",
+			code:   http.StatusOK,
+			names:  []string{"bag charm", "tote bag", "leather bag"},
+			total:  3,
+		},
+		"ok: ignoring case": {
+			target: "/search?keyword=bag&category=+Electronics+",
+			code:   http.StatusOK,
+			names:  []string{"camera bag"},
+			total:  1,
+		},
+		"ok: unknown category": {
+			target: "/search?keyword=bag&category=toys",
+			code:   http.StatusOK,
+			total:  0,
+		},
+		"ok: empty means any category": {
+			target: "/search?keyword=camera&category=",
+			code:   http.StatusOK,
+			names:  []string{"camera bag"},
+			total:  1,
+		},
+		"ok: with price": {
+			target: "/search?keyword=bag&category=fashion&min_price=2000&max_price=9000&sort=newest",
+			code:   http.StatusOK,
+			names:  []string{"tote bag", "leather bag"},
+			total:  2,
+		},
+		"ok: with paging": {
+			target: "/search?keyword=bag&category=fashion&sort=newest&limit=1&offset=1",
+			code:   http.StatusOK,
+			names:  []string{"tote bag"},
+			total:  3,
+		},
+		"ok: with or": {
+			target: "/search?keyword=charm+OR+camera&op=or&category=electronics",
+			code:   http.StatusOK,
+			names:  []string{"camera bag"},
+			total:  1,
+		},
+		"ng: too long": {
+			target: "/search?keyword=bag&category=" + strings.Repeat("a", maxCategoryLen+1),
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.SearchItemsByKeyword(rr, httptest.NewRequest("GET", tt.target, nil))
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var resp SearchItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var names []string
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+			if resp.Total != tt.total {
+				t.Errorf("expected total %d, got %d", tt.total, resp.Total)
+			}
+			// 絞り込みに使ったカテゴリを返す
+			target, _ := url.Parse(tt.target)
+			if want := target.Query().Get("category"); resp.Category != want {
+				t.Errorf("expected category %q to be echoed, got %q", want, resp.Category)
+			}
+		})
+	}
+}
+
+func TestSearchKeywordLength(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		keyword string
+		wantErr bool
+	}{
+		"ok: at the limit":              {keyword: strings.Repeat("a", maxKeywordChars)},
+		"ok: multi-byte at the limit":   {keyword: strings.Repeat("あ", maxKeywordChars)},
+		"ng: over the limit":            {keyword: strings.Repeat("a", maxKeywordChars+1), wantErr: true},
+		"ng: multi-byte over the limit": {keyword: strings.Repeat("あ", maxKeywordChars+1), wantErr: true},
+		"ng: far over the limit":        {keyword: strings.Repeat("a", 10<<10), wantErr: true},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			if !tt.wantErr {
+				mockIR.EXPECT().SearchItemsByKeyword(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				mockIR.EXPECT().CountItemsByKeyword(gomock.Any(), gomock.Any()).Return(0, nil)
+			}
+			h := &Handlers{itemRepo: mockIR}
+
+			q := url.Values{"keyword": {tt.keyword}}
+			rr := httptest.NewRecorder()
+			h.SearchItemsByKeyword(rr, httptest.NewRequest("GET", "/search?"+q.Encode(), nil))
+
+			want := http.StatusOK
+			if tt.wantErr {
+				want = http.StatusBadRequest
+			}
+			if rr.Code != want {
+				t.Fatalf("expected status code %d, got %d: %s", want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestSearchLiteralWildcardsE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "50% off coupon", Category: "tickets", Image: "default.jpg"},
+		{Name: "500 yen coin", Category: "coins", Image: "default.jpg"},
+		{Name: "snake_case mug", Category: "kitchen", Image: "default.jpg"},
+		{Name: "snakes mug", Category: "kitchen", Image: "default.jpg"},
+		{Name: `C:\temp sticker`, Category: "stickers", Image: "default.jpg"},
+		{Name: "jacket", Category: "fashion", Image: "default.jpg"},
+	} {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+
+	cases := map[string]struct {
+		keyword string
+		names   []string
+	}{
+		"ok: percent":            {keyword: "50%", names: []string{"50% off coupon"}},
+		"ok: percent only":       {keyword: "%", names: []string{"50% off coupon"}},
+		"ok: underscore":         {keyword: "snake_", names: []string{"snake_case mug"}},
+		"ok: backslash":          {keyword: `c:\`, names: []string{`C:\temp sticker`}},
+		"ok: escape as a prefix": {keyword: `\temp`, names: []string{`C:\temp sticker`}},
+		"ok: no wildcard":        {keyword: "50", names: []string{"50% off coupon", "500 yen coin"}},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.names, searchNames(t, repo, tt.keyword)); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// relevanceFixture returns items matching "bag" at every level of relevance, in the order of insertion.
+func relevanceFixture() []*Item {
+	return []*Item{
+		{Name: "bag charm", Category: "accessories", Image: "default.jpg"},
+		{Name: "tote", Category: "bags", Image: "default.jpg"},
+		{Name: "leather bag", Category: "accessories", Image: "default.jpg"},
+		{Name: "Bag", Category: "accessories", Image: "default.jpg"},
+		{Name: "bagpipe", Category: "music", Image: "default.jpg"},
+		{Name: "wallet", Category: "accessories", Image: "default.jpg", Brand: "Bagshop"},
+	}
+}
+
+// relevanceOrder is the order of relevanceFixture by relevance for "bag" with the LIKE search.
+var relevanceOrder = []string{
+	// 名前が一致
+	"Bag",
+	// 名前が検索語で始まる (同じならidの順)
+	"bag charm", "bagpipe",
+	// 名前に含まれる
+	"leather bag",
+	// カテゴリかブランドだけが一致
+	"tote", "wallet",
+}
+
+func TestSearchItemsSortE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	clock := apptest.NewFakeClock(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	repo := &itemRepository{db: db, clock: clock}
+	for _, item := range relevanceFixture() {
+		clock.Advance(time.Minute)
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	h := &Handlers{itemRepo: repo}
+
+	cases := map[string]struct {
+		target string
+		code   int
+		names  []string
+	}{
+		"ok: relevance by default": {
+			target: "/search?keyword=bag",
+			code:   http.StatusOK,
+			names:  relevanceOrder,
+		},
+		"ok: relevance": {
+			target: "/search?keyword=bag&sort=relevance",
+			code:   http.StatusOK,
+			names:  relevanceOrder,
+		},
+		"ok: newest": {
+			target: "/search?keyword=bag&sort=newest",
+			code:   http.StatusOK,
+			names:  []string{"wallet", "bagpipe", "Bag", "leather bag", "tote", "bag charm"},
+		},
+		// 全ての語を含む名前は、カテゴリだけの一致より前
+		"ok: relevance of several terms": {
+			target: "/search?keyword=leather+bag&sort=relevance",
+			code:   http.StatusOK,
+			names:  []string{"leather bag"},
+		},
+		"ok: relevance with or": {
+			target: "/search?keyword=pipe+OR+charm&op=or",
+			code:   http.StatusOK,
+			names:  []string{"bag charm", "bagpipe"},
+		},
+		"ok: page of relevance": {
+			target: "/search?keyword=bag&limit=2&offset=1",
+			code:   http.StatusOK,
+			names:  []string{"bag charm", "bagpipe"},
+		},
+		"ng: unknown sort": {
+			target: "/search?keyword=bag&sort=price",
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.SearchItemsByKeyword(rr, httptest.NewRequest("GET", tt.target, nil))
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var resp SearchItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var names []string
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSearchCostLimits(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		keyword string
+		op      string
+		// items is the size of the table, or -1 when it must not be counted.
+		items    int
+		wantCode int
+	}{
+		"ng: too many terms": {
+			keyword:  "a b c d e f",
+			items:    -1,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		"ng: single character on a large table": {
+			keyword:  "a",
+			items:    defaultSearchBroadMinItems + 1,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		// % と _ はワイルドカードではなく、ただの文字として検索する
+		"ok: percent and underscore are characters": {
+			keyword:  "%% __",
+			items:    -1,
+			wantCode: http.StatusOK,
+		},
+		"ng: single percent on a large table": {
+			keyword:  "%",
+			items:    defaultSearchBroadMinItems + 1,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		"ok: single character on a small table": {
+			keyword:  "a",
+			items:    defaultSearchBroadMinItems,
+			wantCode: http.StatusOK,
+		},
+		"ok: one long term among single characters": {
+			keyword:  "a bc",
+			items:    -1,
+			wantCode: http.StatusOK,
+		},
+		"ng: or with a single character on a large table": {
+			keyword:  "a bc",
+			op:       searchOpOr,
+			items:    defaultSearchBroadMinItems + 1,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		"ok: or with long terms": {
+			keyword:  "ab OR bc",
+			op:       searchOpOr,
+			items:    -1,
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			if tt.items >= 0 {
+				mockIR.EXPECT().Count(gomock.Any(), ItemListOptions{}).Return(tt.items, nil)
+			}
+			if tt.wantCode == http.StatusOK {
+				mockIR.EXPECT().SearchItemsByKeyword(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				mockIR.EXPECT().CountItemsByKeyword(gomock.Any(), gomock.Any()).Return(0, nil)
+			}
+			h := &Handlers{itemRepo: mockIR}
+
+			q := url.Values{"keyword": {tt.keyword}}
+			if tt.op != "" {
+				q.Set("op", tt.op)
+			}
+			req := httptest.NewRequest("GET", "/search?"+q.Encode(), nil)
+			rr := httptest.NewRecorder()
+			h.SearchItemsByKeyword(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status code %d, got %d: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestSearchCandidateLimitE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	// 10万件の商品を用意する
+	const seeded = 100000
+	_, err = db.Exec(`
+		INSERT INTO categories (name) VALUES ('bulk');
+		WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < ?)
+		INSERT INTO items (name, category_id, image_name, created_at, updated_at)
+		SELECT 'item ' || n, 1, 'default.jpg', '2025-04-01T00:00:00Z', '2025-04-01T00:00:00Z' FROM seq;
+		UPDATE items SET name_normalized = name;
+	`, seeded)
+	if err != nil {
+		t.Fatalf("failed to seed items: %v", err)
+	}
+	h := &Handlers{itemRepo: &itemRepository{db: db}}
+
+	// 広いが許される検索も、タイムアウトまでに候補の上限件で終わる
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	req := httptest.NewRequest("GET", "/search?keyword=item", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	h.SearchItemsByKeyword(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("expected the search to finish within the timeout: %v", ctx.Err())
+	}
+	var resp SearchItemsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	// 返すのは1ページ分だけで、totalは候補の上限までの件数
+	if resp.Total != defaultSearchCandidateLimit || len(resp.Items) != defaultItemsLimit {
+		t.Errorf("expected %d items and total %d, got %d items and total %d", defaultItemsLimit, defaultSearchCandidateLimit, len(resp.Items), resp.Total)
+	}
+
+	// 1文字だけの検索は断る
+	req = httptest.NewRequest("GET", "/search?keyword=i", nil)
+	rr = httptest.NewRecorder()
+	h.SearchItemsByKeyword(rr, req)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status code %d, got %d: %s", http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+	}
+}
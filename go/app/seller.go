@@ -0,0 +1,57 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// 出品者ごとの商品一覧
+// ログインの仕組みができるまでは、出品時に指定された seller をユーザーIDとして扱う
+
+type GetUserItemsResponse struct {
+	Items []Item `json:"items"`
+}
+
+// parseUserID returns the user id in the path, which must not be blank.
+func parseUserID(r *http.Request) (string, error) {
+	id := strings.TrimSpace(r.PathValue("user_id"))
+	if id == "" {
+		return "", errors.New("user_id is required")
+	}
+	if err := checkParamLen("user_id", id, maxSellerLen); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// GetUserItems is a handler to return the items sold by the user for GET /users/{user_id}/items , newest first.
+func (s *Handlers) GetUserItems(w http.ResponseWriter, r *http.Request) {
+	seller, err := parseUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	items, err := s.itemRepo.GetItemsBySeller(r.Context(), seller)
+	if err != nil {
+		slog.Error("failed to get items of user: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	// 商品がないユーザーも空配列を返す (ユーザーの一覧はないので404にはしない)
+	if items == nil {
+		items = []Item{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(GetUserItemsResponse{Items: items}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSellerItemsE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	h := &Handlers{imgDirPath: "../images", itemRepo: repo}
+
+	// POST /items で出品者を指定する (省略したらanonymous)
+	for _, body := range []string{
+		`{"name":"jacket","category":"fashion","image_name":"default.jpg","price":3000,"seller":"alice"}`,
+		`{"name":"iPhone","category":"phone","image_name":"default.jpg","price":50000,"seller":"bob"}`,
+		`{"name":"hat","category":"fashion","image_name":"default.jpg","price":1000,"seller":"alice"}`,
+		`{"name":"pen","category":"stationery","image_name":"default.jpg","price":100}`,
+	} {
+		req := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.AddItem(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+	}
+	if item, err := repo.GetItemById(t.Context(), "4"); err != nil || item.Seller != defaultSeller {
+		t.Errorf("expected seller %q, got %+v (err: %v)", defaultSeller, item, err)
+	}
+	// 削除した商品は出さない
+	if err := repo.SoftDelete(t.Context(), "1"); err != nil {
+		t.Fatalf("failed to delete item: %v", err)
+	}
+
+	userItems := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/users/"+url.PathEscape(userID)+"/items", nil)
+		req.SetPathValue("user_id", userID)
+		rr := httptest.NewRecorder()
+		h.GetUserItems(rr, req)
+		return rr
+	}
+	names := func(items []Item) []string {
+		names := []string{}
+		for _, item := range items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+
+	cases := map[string]struct {
+		userID string
+		code   int
+		names  []string
+	}{
+		"ok: alice":         {userID: "alice", code: http.StatusOK, names: []string{"hat"}},
+		"ok: anonymous":     {userID: "anonymous", code: http.StatusOK, names: []string{"pen"}},
+		"ok: no items":      {userID: "carol", code: http.StatusOK, names: []string{}},
+		"ng: empty user_id": {userID: "", code: http.StatusBadRequest},
+		"ng: blank user_id": {userID: " ", code: http.StatusBadRequest},
+		"ng: too long user": {userID: strings.Repeat("a", maxSellerLen+1), code: http.StatusBadRequest},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			rr := userItems(tt.userID)
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var resp GetUserItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if diff := cmp.Diff(tt.names, names(resp.Items)); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// GET /items?seller=... でも絞り込める
+	rr := httptest.NewRecorder()
+	h.GetItems(rr, httptest.NewRequest("GET", "/items?seller=bob&include_deleted=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var resp GetItemsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if diff := cmp.Diff([]string{"iPhone"}, names(resp.Items)); diff != "" {
+		t.Errorf("unexpected items of bob (-want +got):\n%s", diff)
+	}
+	if resp.Total != 1 || resp.Items[0].Seller != "bob" {
+		t.Errorf("expected 1 item of bob, got total %d and %+v", resp.Total, resp.Items)
+	}
+}
@@ -1,8 +1,10 @@
 package app
 
 import (
+	"context"
 	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,8 +12,49 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultSlowRequestThreshold is used when SLOW_REQUEST_MS is not set.
+	defaultSlowRequestThreshold = 1000 * time.Millisecond
+	// defaultMaxImageBytes is used when Handlers.MaxImageBytes is not set.
+	defaultMaxImageBytes = 5 << 20 // 5MB
+	// formOverheadBytes is the allowance for form fields other than the image.
+	formOverheadBytes = 1 << 20 // 1MB
+	// defaultImageName is the image of items added without one. It is shared and never deleted.
+	defaultImageName = "default.jpg"
+
+	// defaultSamplePerCategory and maxSamplePerCategory bound per_category of GET /items/sample.
+	defaultSamplePerCategory = 3
+	maxSamplePerCategory     = 20
+
+	// defaultCategoryItems and maxCategoryItems bound limit of GET /items/{item_id}?expand=category_items.
+	defaultCategoryItems = 6
+	maxCategoryItems     = 20
+
+	// defaultItemsLimit and maxItemsLimit bound limit of GET /items and GET /search.
+	defaultItemsLimit = 50
+	maxItemsLimit     = 200
+
+	// maxItemIDs bounds the number of ids of GET /items?ids=... to keep the query small.
+	maxItemIDs = 100
+
+	// healthCheckTimeout bounds the database ping of GET /healthz so that a stuck database does not hang the probe.
+	healthCheckTimeout = 2 * time.Second
+	// shutdownTimeout bounds the wait for in-flight requests when the server is stopped.
+	shutdownTimeout = 10 * time.Second
+
+	// SQLiteは同時に1つしか書き込めないので、コネクションは1本をデフォルトにする
+	defaultDBMaxOpenConns    = 1
+	defaultDBMaxIdleConns    = 1
+	defaultDBConnMaxLifetime = 30 * time.Minute
 )
 
 type Server struct {
@@ -19,66 +62,229 @@ type Server struct {
 	Port string
 	// ImageDirPath is the path to the directory storing images.
 	ImageDirPath string
+	// DBMaxOpenConns is the maximum number of open database connections. Defaults to 1.
+	DBMaxOpenConns int
+	// DBMaxIdleConns is the maximum number of idle database connections. Defaults to 1.
+	DBMaxIdleConns int
+	// DBConnMaxLifetime is the maximum time a database connection may be reused. Defaults to 30 minutes.
+	DBConnMaxLifetime time.Duration
+	// DBSerializeWrites executes all writes on a single writer goroutine with its own connection,
+	// so that DBMaxOpenConns can be raised for reads without "database is locked" errors.
+	// It can also be enabled with DB_SERIALIZE_WRITES=true.
+	DBSerializeWrites bool
+	// EnableCache serves repeated item lists from memory for a short time (item_cache_ttl).
+	// It can also be enabled with ITEM_CACHE=true.
+	EnableCache bool
 }
 
 // Run is a method to start the server.
 // This method returns 0 if the server started successfully, and 1 otherwise.
 func (s Server) Run() int {
+	// 環境変数からフラグの初期値を設定する (フラグ導入前の環境変数もそのまま使える)
+	flags := NewFlags(flagSpecs)
+	flags.SeedFromEnv(os.LookupEnv)
+
 	// set up logger
-	opts := slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	}
-	logger := slog.New(slog.NewJSONHandler(os.Stderr, &opts))
-	slog.SetDefault(logger)
+	slog.SetDefault(newLogger(os.Stderr, flags.String(flagLogLevel), flags.String(flagLogFormat)))
 
 	// set up CORS settings
-	frontURL, found := os.LookupEnv("FRONT_URL")
-	if !found {
-		frontURL = "http://localhost:3000"
+	// FRONT_URLはカンマ区切りで複数のOriginを指定できる
+	frontURLs := parseOrigins(flags.String(flagFrontURL))
+
+	// 遅いリクエストの閾値 (ミリ秒, 0で無効)
+	slowThreshold := func() time.Duration {
+		return time.Duration(flags.Int(flagSlowRequestMs)) * time.Millisecond
 	}
 
 	// STEP 5-1: set up the database connection
-	db, err := sql.Open("sqlite3", "db/mercari.sqlite3")
+	const dbPath = "db/mercari.sqlite3"
+	db, err := sql.Open(sqliteDriverJa, dbPath)
 	if err != nil {
 		slog.Error("failed to open database: ", "error", err)
 		return 1
 	}
 	defer db.Close()
 
+	if err := s.configureDB(db); err != nil {
+		slog.Error("failed to configure database: ", "error", err)
+		return 1
+	}
+
 	// set up handlers
-	itemRepo, err := NewItemRepository(db)
+	repoOpts := ItemRepositoryOptions{DuplicateWindow: flags.Duration(flagDuplicateWindow)}
+	itemRepo, err := NewItemRepository(db, repoOpts)
 	if err != nil {
 		slog.Error("failed to create item repository: ", "error", err)
 		return 1
 	}
-	h := &Handlers{imgDirPath: s.ImageDirPath, itemRepo: itemRepo}
+	if s.DBSerializeWrites || flags.Bool(flagDBSerializeWrites) {
+		// 書き込み専用のコネクションを別に開き、書き込みは1つのgoroutineから順番に行う
+		writeDB, err := sql.Open(sqliteDriverJa, dbPath)
+		if err != nil {
+			slog.Error("failed to open database: ", "error", err)
+			return 1
+		}
+		defer writeDB.Close()
+
+		writer := Server{DBMaxOpenConns: 1, DBMaxIdleConns: 1, DBConnMaxLifetime: s.DBConnMaxLifetime}
+		if err := writer.configureDB(writeDB); err != nil {
+			slog.Error("failed to configure database: ", "error", err)
+			return 1
+		}
+		// 全文検索や照合順序の設定も読み込み側と揃える (スキーマの作成は冪等なので、2回目は何もしない)
+		writeRepo, err := NewItemRepository(writeDB, repoOpts)
+		if err != nil {
+			slog.Error("failed to create item repository: ", "error", err)
+			return 1
+		}
+		serialized := NewSerializedItemRepository(itemRepo, writeRepo)
+		defer serialized.Close()
+		itemRepo = serialized
+	}
+	// 詰まったクエリでgoroutineが溜まらないよう、DB呼び出しごとに時間制限をかける
+	itemRepo = newTimeoutItemRepository(itemRepo, func() time.Duration { return flags.Duration(flagDBTimeout) })
+	if s.EnableCache || flags.Bool(flagItemCache) {
+		// キャッシュに当たればDBを呼ばないので、時間制限より外側に置く
+		itemRepo = newCachedItemRepository(itemRepo, func() time.Duration { return flags.Duration(flagItemCacheTTL) }, realClock{})
+	}
+	// 表示回数はまとめて書き込み、終了時に残りを書き込む
+	views := newViewCounter(itemRepo, defaultViewFlushInterval)
+	defer func() {
+		if err := views.Close(); err != nil {
+			slog.Error("failed to flush item views: ", "error", err)
+		}
+	}()
+	h := &Handlers{
+		imgDirPath:   s.ImageDirPath,
+		itemRepo:     itemRepo,
+		flags:        flags,
+		storageStats: &storageStatsCache{},
+		clock:        realClock{},
+		views:        views,
+		metrics:      newMetrics(),
+	}
+	// 出品の回数制限 (値が正しくなければデフォルトを使う)
+	limit, burst, enabled, err := parseRateLimit(flags.String(flagRateLimit))
+	if err != nil {
+		slog.Warn("invalid RATE_LIMIT, using default", "value", logValue(flags.String(flagRateLimit)), "error", err)
+		limit, burst, enabled, _ = parseRateLimit(defaultRateLimit)
+	}
+	if enabled {
+		h.uploadLimiter = newIPRateLimiter(limit, burst, realClock{})
+		stopEvictor := make(chan struct{})
+		defer close(stopEvictor)
+		go h.uploadLimiter.runEvictor(rateLimiterEvictInterval, rateLimiterIdleTTL, stopEvictor)
+	}
 
 	// set up routes
 	// HTTPリクエストのルーティングを設定
 	// handler:HTTPリクエストを処理する関数やメソッド
-	mux := http.NewServeMux()
-	mux.HandleFunc("GET /", h.Hello)
-	mux.HandleFunc("POST /items", h.AddItem)
-	mux.HandleFunc("GET /items", h.GetItems)
-	mux.HandleFunc("GET /images/{filename}", h.GetImage)
-	mux.HandleFunc("GET /items/{item_id}", h.GetItemById)
-	mux.HandleFunc("GET /search", h.SearchItemsByKeyword)
+	routes := h.routes()
+	mux := newMux(routes)
 
 	// start the server
+	// SIGINT/SIGTERMで止めたときも、deferで表示回数などの書き込みを終えてから終了する
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// 使われなくなった画像の掃除 (起動時の間隔で回し続ける)
+	if interval := flags.Duration(flagImageSweepInterval); interval > 0 {
+		go h.runImageSweeper(ctx, interval)
+	}
+	// CORSのプリフライトにはキーが付かないので、認証はCORSより内側で行う
+	apiKey := os.Getenv(apiKeyEnv)
+	if apiKey == "" {
+		slog.Warn("API_KEY is not set: write requests are not authenticated")
+	}
+	srv := &http.Server{
+		Addr:    ":" + s.Port,
+		Handler: simpleCORSMiddleware(gzipMiddleware(requestIDMiddleware(simpleLoggerMiddleware(metricsMiddleware(apiKeyMiddleware(mux, apiKey), mux, h.metrics), slowThreshold), randomIDGenerator{})), frontURLs, routeMethods(routes)),
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("failed to shut down server: ", "error", err)
+		}
+	}()
+
 	slog.Info("http server started on", "port", s.Port)
-	err = http.ListenAndServe(":"+s.Port, simpleCORSMiddleware(simpleLoggerMiddleware(mux), frontURL, []string{"GET", "HEAD", "POST", "OPTIONS"}))
-	if err != nil {
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		slog.Error("failed to start server: ", "error", err)
 		return 1
 	}
+	slog.Info("http server stopped")
 
 	return 0
 }
 
+// configureDB applies the connection pool settings and enables WAL mode,
+// which lets readers proceed while a write is in progress.
+func (s Server) configureDB(db *sql.DB) error {
+	maxOpen := s.DBMaxOpenConns
+	if maxOpen <= 0 {
+		maxOpen = defaultDBMaxOpenConns
+	}
+	maxIdle := s.DBMaxIdleConns
+	if maxIdle <= 0 {
+		maxIdle = defaultDBMaxIdleConns
+	}
+	lifetime := s.DBConnMaxLifetime
+	if lifetime <= 0 {
+		lifetime = defaultDBConnMaxLifetime
+	}
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(lifetime)
+
+	// journal_modeはデータベースファイルに保存されるので、起動時に1回実行すればよい
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode=WAL").Scan(&mode); err != nil {
+		return fmt.Errorf("failed to enable WAL mode: %w", err)
+	}
+	if mode != "wal" {
+		return fmt.Errorf("failed to enable WAL mode: journal_mode is %s", mode)
+	}
+	return nil
+}
+
 type Handlers struct {
 	// imgDirPath is the path to the directory storing images.
 	imgDirPath string
 	itemRepo   ItemRepository
+	// MaxImageBytes is the maximum size of an uploaded image. Defaults to 5MB.
+	MaxImageBytes int64
+	// MaxBulkItems is the maximum number of rows in a POST /items/bulk or POST /items/import request.
+	// Defaults to the max_import_rows flag.
+	MaxBulkItems int
+	// flags are the feature flags changeable at runtime. nil means the defaults.
+	flags *Flags
+	// storageStats caches the result of GET /admin/storage. nil disables caching.
+	storageStats *storageStatsCache
+	// clock is used for time-dependent behavior. nil means the real clock.
+	clock Clock
+	// views counts the views of GET /items/{item_id}. nil disables counting.
+	views *viewCounter
+	// metrics is served by GET /metrics. nil makes it 404.
+	metrics *metrics
+	// uploadLimiter limits POST /items per client IP. nil disables the limit.
+	uploadLimiter *ipRateLimiter
+}
+
+// now returns the current time from the handlers' clock.
+func (s *Handlers) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
+}
+
+// maxImageBytes returns the configured image size limit or the default.
+func (s *Handlers) maxImageBytes() int64 {
+	if s.MaxImageBytes > 0 {
+		return s.MaxImageBytes
+	}
+	return defaultMaxImageBytes
 }
 
 type HelloResponse struct {
@@ -95,36 +301,287 @@ func (s *Handlers) Hello(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+type ErrorResponse struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+}
+
+// NotFound is a handler to return a JSON 404 for paths no other route matches.
+func (s *Handlers) NotFound(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: "not found", Status: http.StatusNotFound})
+}
+
+type GetItemsRequest struct {
+	Sort           string
+	IncludeDeleted bool
+	IncludeDrafts  bool
+	Status         string
+	Seller         string
+	Brand          string
+	Currency       string
+	Tag            string
+	Condition      string
+	ShippingPayer  string
+	IDs            []int
+	Limit          int
+	Offset         int
+}
+
+type GetItemsResponse struct {
+	Items []Item `json:"items"`
+	PaginationMeta
+}
+
+// parseGetItemsRequest parses and validates the query parameters of GET /items.
+func parseGetItemsRequest(r *http.Request) (*GetItemsRequest, error) {
+	q, err := parseQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	req := &GetItemsRequest{}
+	if req.Sort, err = queryParam(q, "sort", maxShortParamLen); err != nil {
+		return nil, err
+	}
+	if req.Status, err = queryParam(q, "status", maxShortParamLen); err != nil {
+		return nil, err
+	}
+	if req.Seller, err = queryParam(q, "seller", maxSellerLen); err != nil {
+		return nil, err
+	}
+	if req.Brand, err = queryParam(q, "brand", maxBrandLen); err != nil {
+		return nil, err
+	}
+	// ?brand= のように空の値を指定したら、絞り込み忘れとみなして断る
+	req.Brand = normalizeBrand(req.Brand)
+	if q.Has("brand") && req.Brand == "" {
+		return nil, errors.New("brand must not be empty")
+	}
+	currency, err := queryParam(q, "currency", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	if q.Has("currency") {
+		if req.Currency, err = parseCurrency(currency); err != nil {
+			return nil, err
+		}
+	}
+	if req.Tag, err = queryParam(q, "tag", maxTagLen); err != nil {
+		return nil, err
+	}
+	req.Tag = normalizeTag(req.Tag)
+	if req.Condition, err = queryParam(q, "condition", maxShortParamLen); err != nil {
+		return nil, err
+	}
+	if req.ShippingPayer, err = queryParam(q, "shipping_payer", maxShortParamLen); err != nil {
+		return nil, err
+	}
+	includeDeleted, err := queryParam(q, "include_deleted", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	includeDrafts, err := queryParam(q, "include_drafts", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := queryParam(q, "ids", maxIDsParamLen)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := queryParam(q, "limit", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := queryParam(q, "offset", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate the request
+	switch req.Sort {
+	case sortByID, sortByCreatedAt, sortByManual, sortByNameJa:
+	default:
+		return nil, fmt.Errorf("invalid sort: %s", req.Sort)
+	}
+
+	if includeDeleted != "" {
+		v, err := strconv.ParseBool(includeDeleted)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include_deleted: %s", includeDeleted)
+		}
+		req.IncludeDeleted = v
+	}
+	if includeDrafts != "" {
+		v, err := strconv.ParseBool(includeDrafts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include_drafts: %s", includeDrafts)
+		}
+		req.IncludeDrafts = v
+	}
+
+	if req.Status != "" {
+		if err := validateItemStatus(req.Status); err != nil {
+			return nil, err
+		}
+	}
+	if req.Condition != "" {
+		if err := validateItemCondition(req.Condition); err != nil {
+			return nil, err
+		}
+	}
+	if req.ShippingPayer != "" {
+		if err := validateShippingPayer(req.ShippingPayer); err != nil {
+			return nil, err
+		}
+	}
+
+	if ids != "" {
+		req.IDs, err = parseItemIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Limit, req.Offset, err = parsePage(limit, offset); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// PaginationMeta is the pagination metadata of a paginated list such as GET /items and GET /search,
+// so that clients need not compute the pages themselves.
+type PaginationMeta struct {
+	// Total is the number of items matching the filters, across all pages.
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	// Page is the 1-based page the offset falls in, and TotalPages the number of pages of Limit items.
+	Page       int `json:"page"`
+	TotalPages int `json:"total_pages"`
+	// HasMore reports whether items follow this page.
+	HasMore bool `json:"has_more"`
+}
+
+// newPaginationMeta returns the pagination metadata of the page at offset with limit items, out of total items.
+func newPaginationMeta(total, limit, offset int) PaginationMeta {
+	return PaginationMeta{
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+		Page:       offset/limit + 1,
+		TotalPages: (total + limit - 1) / limit,
+		HasMore:    offset+limit < total,
+	}
+}
+
+// parsePage parses the limit and offset of a paginated list such as GET /items and GET /search.
+// limit defaults to defaultItemsLimit and is at most maxItemsLimit, and offset defaults to 0.
+func parsePage(limit, offset string) (int, int, error) {
+	l, o := defaultItemsLimit, 0
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxItemsLimit {
+			return 0, 0, fmt.Errorf("limit must be an integer between 1 and %d", maxItemsLimit)
+		}
+		l = n
+	}
+	if offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+		o = n
+	}
+	return l, o, nil
+}
+
+// parseItemIDs parses a comma-separated list of item ids such as "1,5,9".
+// Duplicates are dropped, keeping the first occurrence.
+func parseItemIDs(v string) ([]int, error) {
+	parts := strings.Split(v, ",")
+	if len(parts) > maxItemIDs {
+		return nil, fmt.Errorf("too many ids: %d (max %d)", len(parts), maxItemIDs)
+	}
+	ids := make([]int, 0, len(parts))
+	seen := make(map[int]bool, len(parts))
+	for _, p := range parts {
+		id, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid id: %q", p)
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// Health is a handler to check that the process and the database are alive for GET /healthz .
+func (s *Handlers) Health(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	code := http.StatusOK
+	resp := HealthResponse{Status: "ok"}
+	if err := s.itemRepo.Ping(ctx); err != nil {
+		slog.Error("health check failed: ", "error", err)
+		code = http.StatusServiceUnavailable
+		resp = HealthResponse{Status: "unavailable"}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode health response: ", "error", err)
+	}
+}
+
 // GetItems ハンドラーを実装 for GET /items
+// ?sort=created_at を指定すると新しい順に並べる
+// ?sort=manual を指定すると手動の並び順 (sort_order) に並べる
+// ?sort=name_ja を指定すると名前の日本語の順 (ひらがなとカタカナを区別しない五十音順など) に並べる
+// ?ids=1,5,9 を指定するとそのidの商品だけを指定した順に返す (存在しないidは無視)
+// ?limit=50&offset=100 でページを指定する (totalは絞り込みに一致する全件数)
 func (s *Handlers) GetItems(w http.ResponseWriter, r *http.Request) {
-	// GetAllメソッドを呼び出す
-	items, err := s.itemRepo.GetAll(r.Context())
+	req, err := parseGetItemsRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	response := struct {
-		Items []struct {
-			ID       int    `json:"id"`
-			Name     string `json:"name"`
-			Category string `json:"category"`
-			Image    string `json:"image_name"`
-		} `json:"items"`
-	}{}
+	// GetPageメソッドを呼び出す (totalは絞り込み後の件数)
+	items, total, err := s.itemRepo.GetPage(r.Context(), ItemListOptions{
+		Sort:           req.Sort,
+		IncludeDeleted: req.IncludeDeleted,
+		IncludeDrafts:  req.IncludeDrafts,
+		Status:         req.Status,
+		Seller:         strings.TrimSpace(req.Seller),
+		Brand:          req.Brand,
+		Currency:       req.Currency,
+		Tag:            req.Tag,
+		Condition:      req.Condition,
+		ShippingPayer:  req.ShippingPayer,
+		IDs:            req.IDs,
+		Limit:          req.Limit,
+		Offset:         req.Offset,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
 
-	for _, item := range items {
-		response.Items = append(response.Items, struct {
-			ID       int    `json:"id"`
-			Name     string `json:"name"`
-			Category string `json:"category"`
-			Image    string `json:"image_name"`
-		}{
-			ID:       item.ID,
-			Name:     item.Name,
-			Category: item.Category,
-			Image:    item.Image,
-		})
+	response := GetItemsResponse{Items: items, PaginationMeta: newPaginationMeta(total, req.Limit, req.Offset)}
+	if response.Items == nil {
+		response.Items = []Item{}
 	}
 
 	// HTTPレスポンスのヘッダーを設定し、JSON形式でデータを書き込んでいます
@@ -133,32 +590,255 @@ func (s *Handlers) GetItems(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	checkpoint(r.Context(), "encode")
+}
+
+// route is a pattern of the mux and its handler.
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// routes returns the routes of the API.
+// CORSで許可するメソッドもここから決まるので、ルートは必ずここに追加する
+func (h *Handlers) routes() []route {
+	return []route{
+		// "GET /" だと全てのGETに一致してしまうので、"/" だけに一致させる
+		{"GET /{$}", h.Hello},
+		{"GET /healthz", h.Health},
+		{"GET /metrics", h.Metrics},
+		{"GET /openapi.json", h.OpenAPI},
+		{"POST /items", rateLimitMiddleware(h.AddItem, h.uploadLimiter)},
+		{"GET /items", h.GetItems},
+		{"GET /items/recent", h.GetRecentItems},
+		{"PATCH /items/{item_id}", h.UpdateItem},
+		{"GET /users/{user_id}/items", h.GetUserItems},
+		{"POST /items/bulk", h.AddItemsBulk},
+		{"POST /items/import", h.ImportItems},
+		{"POST /items/reorder", h.ReorderItems},
+		{"GET /images/{filename}", h.GetImage},
+		{"HEAD /images/{filename}", h.HeadImage},
+		{"DELETE /images/{filename}", h.DeleteImage},
+		{"POST /images", rateLimitMiddleware(h.UploadImages, h.uploadLimiter)},
+		{"GET /images/{filename}/meta", h.GetImageMeta},
+		{"GET /items/sample", h.SampleItems},
+		{"GET /items/export", h.ExportItems},
+		{"GET /items/favorites", h.GetFavorites},
+		{"GET /items/by-image", h.GetItemByImage},
+		{"GET /items/{item_id}", h.GetItemById},
+		{"GET /items/{item_id}/history", h.GetItemHistory},
+		{"GET /items/{item_id}/related", h.GetRelatedItems},
+		{"DELETE /items/{item_id}", h.DeleteItem},
+		{"POST /items/{item_id}/restore", h.RestoreItem},
+		{"POST /items/{item_id}/publish", h.PublishItem},
+		{"POST /items/{item_id}/purchase", h.PurchaseItem},
+		{"POST /items/{item_id}/favorite", h.AddFavorite},
+		{"DELETE /items/{item_id}/favorite", h.RemoveFavorite},
+		{"POST /items/{a}/swap/{b}", h.SwapItems},
+		{"GET /search", h.SearchItemsByKeyword},
+		{"GET /categories", h.GetCategories},
+		{"DELETE /categories/{category_id}", h.DeleteCategory},
+		{"GET /admin/category-health", h.GetCategoryHealth},
+		{"GET /admin/storage", h.GetStorageStats},
+		{"GET /admin/flags", h.GetFlags},
+		{"PATCH /admin/flags/{name}", h.PatchFlag},
+		// どのルートにも一致しないリクエストを受ける
+		{"/", h.NotFound},
+	}
+}
+
+// newMux registers the routes to a new ServeMux.
+func newMux(routes []route) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, rt := range routes {
+		mux.HandleFunc(rt.pattern, rt.handler)
+	}
+	return mux
+}
+
+// routeMethods returns the methods of the routes and OPTIONS for the CORS preflight, sorted.
+func routeMethods(routes []route) []string {
+	methods := []string{http.MethodOptions}
+	for _, rt := range routes {
+		if method, _, ok := strings.Cut(rt.pattern, " "); ok && !slices.Contains(methods, method) {
+			methods = append(methods, method)
+		}
+	}
+	slices.Sort(methods)
+	return methods
 }
 
 type AddItemRequest struct {
 	Name     string `form:"name"`
 	Category string `form:"category"`
-	Image    []byte `form:"image"`
+	Status   string `form:"status"`
+	Price    int    `form:"price"`
+	// Currency is one of currencies. Defaults to JPY.
+	Currency string `form:"currency"`
+	Seller   string `form:"seller"`
+	// Condition is one of itemConditions. Defaults to used.
+	Condition string `form:"condition"`
+	// Quantity is the stock. Defaults to 1. An item added with 0 is sold.
+	Quantity int `form:"quantity"`
+	// Brand is the normalized brand. Optional.
+	Brand string `form:"brand"`
+	// ShippingPayer is one of shippingPayers. Defaults to seller.
+	ShippingPayer string `form:"shipping_payer"`
+	// ShippingDays is between 1 and 30. Defaults to 3.
+	ShippingDays int `form:"shipping_days"`
+	// Tags are the normalized tags, given as a comma-separated list.
+	Tags  []string `form:"tags"`
+	Image []byte   `form:"image"`
+	// ImageName is the name of an image already stored, given instead of Image in a JSON request.
+	ImageName string
+	// Dedupe rejects the item if an identical one already exists. It is given as ?dedupe=true.
+	Dedupe bool
+	// Draft adds the item unpublished. It is given as published=false.
+	Draft bool
+}
+
+// addItemJSONRequest is the body of POST /items with Content-Type: application/json.
+type addItemJSONRequest struct {
+	Name      string `json:"name"`
+	Category  string `json:"category"`
+	Status    string `json:"status"`
+	ImageName string `json:"image_name"`
+	Seller    string `json:"seller"`
+	Condition string `json:"condition"`
+	Brand     string `json:"brand"`
+	Currency  string `json:"currency"`
+	// ShippingPayer is seller or buyer.
+	ShippingPayer string `json:"shipping_payer"`
+	// Tags is a comma-separated list, the same as the form field.
+	Tags string `json:"tags"`
+	// json.Numberにしておき、価格の検証はフォームと同じparsePriceで行う
+	Price        json.Number `json:"price"`
+	Quantity     json.Number `json:"quantity"`
+	ShippingDays json.Number `json:"shipping_days"`
+	// Published defaults to true.
+	Published *bool `json:"published"`
+	// ImageBase64 is a JPEG image encoded in standard base64, given instead of a multipart image.
+	ImageBase64 string `json:"image_base64"`
+}
+
+// addItemJSONLimits are the limits of a JSON body of POST /items, which may carry a base64 image of up to maxImageBytes.
+func addItemJSONLimits(maxImageBytes int64) jsonLimits {
+	limits := defaultJSONLimits
+	limits.MaxBytes = int64(base64.StdEncoding.EncodedLen(int(maxImageBytes))) + formOverheadBytes
+	return limits
 }
 
 type AddItemResponse struct {
+	// Message is kept for clients written before the item was returned.
 	Message string `json:"message"`
+	Item    *Item  `json:"item"`
+}
+
+// imageTooLargeMessage is the field error of an image larger than maxImageBytes.
+func imageTooLargeMessage(maxImageBytes int64) string {
+	return fmt.Sprintf("must be at most %d bytes", maxImageBytes)
 }
 
 // parseAddItemRequest parses and validates the request to add an item.
-func parseAddItemRequest(r *http.Request) (*AddItemRequest, error) {
+// The body is either a form (multipart/form-data or urlencoded) or JSON (application/json),
+// and both are validated the same way. A JSON body carries its image base64-encoded in image_base64.
+// Every problem with the fields, including an image larger than maxImageBytes, is collected into the returned
+// *ValidationError. A body too large to be read at all is rejected with errImageTooLarge instead.
+func parseAddItemRequest(r *http.Request, maxImageBytes int64) (*AddItemRequest, error) {
 	var req = &AddItemRequest{}
+	var price, quantity, tags, published, shippingDays string
+	// 最初の1つで止めずに、全ての項目の問題をまとめて返す
+	var v validator
+
+	// 上限を超えるリクエストボディは読み込む前に打ち切る (JSONの画像はbase64で4/3倍になる)
+	contentType := r.Header.Get("Content-Type")
+	isJSON := strings.HasPrefix(contentType, "application/json")
+	if !isJSON {
+		r.Body = http.MaxBytesReader(nil, r.Body, maxImageBytes+formOverheadBytes)
+	}
+
+	// 重複の確認はクエリパラメータで指定する (ボディの形式によらない)
+	dedupe, err := queryParam(r.URL.Query(), "dedupe", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	if dedupe != "" {
+		if b, err := strconv.ParseBool(dedupe); err != nil {
+			v.add("dedupe", "must be true or false", err)
+		} else {
+			req.Dedupe = b
+		}
+	}
 
 	// multipart/form-dataかを確認
 	// リクエストがファイルアップロードを伴う multipart/form-data 形式であるかどうかを判断する
-	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+	if isJSON {
+		var body addItemJSONRequest
+		if err := decodeJSONBody(r, addItemJSONLimits(maxImageBytes), &body); err != nil {
+			// 大きすぎるボディは、フォームと同じく画像が大きすぎるものとして扱う
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return nil, errImageTooLarge
+			}
+			return nil, err
+		}
+
+		req.Name = body.Name
+		req.Category = body.Category
+		req.Status = body.Status
+		req.ImageName = body.ImageName
+		req.Seller = body.Seller
+		req.Condition = body.Condition
+		req.Brand = body.Brand
+		req.Currency = body.Currency
+		tags = body.Tags
+		price = body.Price.String()
+		quantity = body.Quantity.String()
+		req.ShippingPayer = body.ShippingPayer
+		shippingDays = body.ShippingDays.String()
+		if body.Published != nil {
+			published = strconv.FormatBool(*body.Published)
+		}
+
+		if body.ImageBase64 != "" {
+			imageData, err := base64.StdEncoding.DecodeString(body.ImageBase64)
+			switch {
+			case err != nil:
+				v.add("image_base64", "must be standard base64", err)
+			case int64(len(imageData)) > maxImageBytes:
+				v.add("image_base64", imageTooLargeMessage(maxImageBytes), errImageTooLarge)
+			case req.ImageName != "":
+				v.add("image_base64", "must not be given with image_name", nil)
+			// ファイル名がないので、中身がJPEGかを確かめる
+			case http.DetectContentType(imageData) != "image/jpeg":
+				v.add("image_base64", "must be a JPEG image", nil)
+			default:
+				req.Image = imageData
+			}
+		}
+	} else if strings.HasPrefix(contentType, "multipart/form-data") {
 		err := r.ParseMultipartForm(32 << 20) // 32MBまで
 		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return nil, errImageTooLarge
+			}
 			return nil, fmt.Errorf("failed to parse multipart form: %w", err)
 		}
 
 		req.Name = r.FormValue("name")
 		req.Category = r.FormValue("category")
+		req.Status = r.FormValue("status")
+		req.Seller = r.FormValue("seller")
+		req.Condition = r.FormValue("condition")
+		req.Brand = r.FormValue("brand")
+		req.Currency = r.FormValue("currency")
+		tags = r.FormValue("tags")
+		price = r.FormValue("price")
+		quantity = r.FormValue("quantity")
+		published = r.FormValue("published")
+		req.ShippingPayer = r.FormValue("shipping_payer")
+		shippingDays = r.FormValue("shipping_days")
 
 		// Get the image file
 		file, header, err := r.FormFile("image")
@@ -171,18 +851,20 @@ func parseAddItemRequest(r *http.Request) (*AddItemRequest, error) {
 		} else {
 			defer file.Close()
 
-			// jpgのみ受け付ける
-			if !strings.HasSuffix(strings.ToLower(header.Filename), ".jpg") && !strings.HasSuffix(strings.ToLower(header.Filename), ".jpeg") {
-				return nil, errors.New("only .jpg or .jpeg files are allowed")
-			}
-
-			// Read image data
-			imageData, err := io.ReadAll(file)
+			// Read image data (上限+1バイトまで読んで、超えていたらエラー)
+			imageData, err := io.ReadAll(io.LimitReader(file, maxImageBytes+1))
 			if err != nil {
 				return nil, fmt.Errorf("failed to read image data: %w", err)
 			}
-			if len(imageData) == 0 {
-				return nil, errors.New("image data is empty")
+
+			// jpgのみ受け付ける
+			if !strings.HasSuffix(strings.ToLower(header.Filename), ".jpg") && !strings.HasSuffix(strings.ToLower(header.Filename), ".jpeg") {
+				v.add("image", "must be a .jpg or .jpeg file", nil)
+			} else if len(imageData) == 0 {
+				v.add("image", "must not be empty", nil)
+			}
+			if int64(len(imageData)) > maxImageBytes {
+				v.add("image", imageTooLargeMessage(maxImageBytes), errImageTooLarge)
 			}
 
 			req.Image = imageData
@@ -196,31 +878,203 @@ func parseAddItemRequest(r *http.Request) (*AddItemRequest, error) {
 
 		req.Name = r.FormValue("name")
 		req.Category = r.FormValue("category")
+		req.Status = r.FormValue("status")
+		req.Seller = r.FormValue("seller")
+		req.Condition = r.FormValue("condition")
+		req.Brand = r.FormValue("brand")
+		req.Currency = r.FormValue("currency")
+		tags = r.FormValue("tags")
+		price = r.FormValue("price")
+		quantity = r.FormValue("quantity")
+		published = r.FormValue("published")
+		req.ShippingPayer = r.FormValue("shipping_payer")
+		shippingDays = r.FormValue("shipping_days")
 	}
 
 	// validaion
-	if req.Name == "" {
-		return nil, errors.New("name is required")
+	// 巨大な値は中身を検証する前に弾く
+	for _, p := range []struct {
+		name   string
+		value  string
+		maxLen int
+	}{
+		{"name", req.Name, maxNameLen},
+		{"category", req.Category, maxCategoryLen},
+		{"status", req.Status, maxShortParamLen},
+		{"price", price, maxShortParamLen},
+		{"quantity", quantity, maxShortParamLen},
+		{"image_name", req.ImageName, maxImageNameLen},
+		{"seller", req.Seller, maxSellerLen},
+		{"tags", tags, maxTagsLen},
+		{"condition", req.Condition, maxShortParamLen},
+		{"brand", req.Brand, maxBrandLen},
+		{"currency", req.Currency, maxShortParamLen},
+		{"published", published, maxShortParamLen},
+		{"shipping_payer", req.ShippingPayer, maxShortParamLen},
+		{"shipping_days", shippingDays, maxShortParamLen},
+	} {
+		if err := checkParamLen(p.name, p.value, p.maxLen); err != nil {
+			return nil, err
+		}
+	}
+	v.text("name", req.Name, maxItemNameChars)
+	v.text("category", req.Category, maxCategoryChars)
+	// statusは省略可能 (省略したらon_sale)
+	if req.Status == "" {
+		req.Status = itemStatusOnSale
+	}
+	if err := validateItemStatus(req.Status); err != nil {
+		v.add("status", fmt.Sprintf("must be %s or %s", itemStatusOnSale, itemStatusSold), err)
+	}
+	// sellerは省略可能 (省略したらanonymous)
+	if req.Seller = strings.TrimSpace(req.Seller); req.Seller == "" {
+		req.Seller = defaultSeller
+	} else {
+		v.text("seller", req.Seller, maxSellerChars)
+	}
+	// brandは省略可能
+	if req.Brand = normalizeBrand(req.Brand); req.Brand != "" {
+		v.text("brand", req.Brand, maxBrandChars)
+	}
+	// conditionは省略可能 (省略したらused)
+	if req.Condition == "" {
+		req.Condition = itemConditionUsed
+	}
+	if err := validateItemCondition(req.Condition); err != nil {
+		v.add("condition", "must be one of "+strings.Join(itemConditions, ", "), err)
+	}
+	req.Tags = parseTags(&v, tags)
+	if p, err := parsePrice(price); err != nil {
+		if price == "" {
+			v.add("price", "required", err)
+		} else {
+			v.add("price", "must be a non-negative integer", err)
+		}
+	} else {
+		req.Price = p
+	}
+	// currencyは省略可能 (省略したら円)
+	if req.Currency == "" {
+		req.Currency = defaultCurrency
+	}
+	if c, err := parseCurrency(req.Currency); err != nil {
+		v.add("currency", "must be one of "+strings.Join(currencies, ", "), err)
+	} else {
+		req.Currency = c
 	}
-	if req.Category == "" {
-		return nil, errors.New("category is required")
+	// quantityは省略可能 (省略したら1点もの)
+	if q, err := parseQuantity(quantity); err != nil {
+		v.add("quantity", "must be a non-negative integer", err)
+	} else {
+		req.Quantity = q
+		// 在庫0で出品したものは最初から売り切れ
+		if q == 0 {
+			req.Status = itemStatusSold
+		}
+	}
+	// shipping_payerは省略可能 (省略したら送料込み)
+	if req.ShippingPayer == "" {
+		req.ShippingPayer = shippingPayerSeller
+	}
+	if err := validateShippingPayer(req.ShippingPayer); err != nil {
+		v.add("shipping_payer", "must be one of "+strings.Join(shippingPayers, ", "), err)
+	}
+	if d, err := parseShippingDays(shippingDays); err != nil {
+		v.add("shipping_days", fmt.Sprintf("must be an integer between %d and %d", minShippingDays, maxShippingDays), err)
+	} else {
+		req.ShippingDays = d
+	}
+	// publishedは省略可能 (省略したら公開)
+	if published != "" {
+		if b, err := strconv.ParseBool(published); err != nil {
+			v.add("published", "must be true or false", err)
+		} else {
+			req.Draft = !b
+		}
+	}
+	if err := v.err(); err != nil {
+		return nil, err
 	}
 
 	return req, nil
 }
 
+// parsePrice parses a price amount in the minor unit of the currency. It must be a non-negative integer.
+func parsePrice(v string) (int, error) {
+	if v == "" {
+		return 0, fmt.Errorf("%w: price is required", errInvalidPrice)
+	}
+	price, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not an integer", errInvalidPrice, v)
+	}
+	if price < 0 {
+		return 0, fmt.Errorf("%w: %d is negative", errInvalidPrice, price)
+	}
+	return price, nil
+}
+
+// parseQuantity parses the stock of an item. It must be a non-negative integer, and defaults to 1.
+func parseQuantity(v string) (int, error) {
+	if v == "" {
+		return 1, nil
+	}
+	quantity, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not an integer", errInvalidQuantity, v)
+	}
+	if quantity < 0 {
+		return 0, fmt.Errorf("%w: %d is negative", errInvalidQuantity, quantity)
+	}
+	return quantity, nil
+}
+
 // AddItem is a handler to add a new item for POST /items .
+// It responds with 201 Created, a Location header and the created item,
+// or 409 with the id of the existing item when the same item was just added (see the duplicate_window flag).
 func (s *Handlers) AddItem(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	req, err := parseAddItemRequest(r)
+	req, err := parseAddItemRequest(r, s.maxImageBytes())
 	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			// 読めないJSONボディは項目の問題ではないので400のまま
+			if errors.Is(err, errInvalidRequestBody) || errors.Is(err, errJSONLimit) {
+				writeValidationError(w, validationErr)
+				return
+			}
+			writeFieldErrors(w, validationErr)
+			return
+		}
+		if errors.Is(err, errImageTooLarge) {
+			slog.Warn("rejected too large image: ", "error", err)
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if errors.Is(err, errInvalidRequestBody) || errors.Is(err, errParamLimit) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	actor, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(ctx, "parse")
 
-	fileName := "default.jpg"
-	if len(req.Image) > 0 {
+	fileName := defaultImageName
+	if req.ImageName != "" {
+		// 保存済みの画像を指定された場合は、そのまま使う
+		if _, err := s.buildImagePath(req.ImageName); err != nil {
+			writeFieldErrors(w, &ValidationError{Errors: []FieldError{{Field: "image_name", Message: err.Error()}}})
+			return
+		}
+		fileName = req.ImageName
+	} else if len(req.Image) > 0 {
 		fileName, err = s.storeImage(req.Image)
 		if err != nil {
 			slog.Error("failed to store image: ", "error", err)
@@ -229,7 +1083,7 @@ func (s *Handlers) AddItem(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		// デフォルト画像を読み込んで保存
-		defaultImage, err := os.ReadFile(filepath.Join(s.imgDirPath, "default.jpg"))
+		defaultImage, err := os.ReadFile(filepath.Join(s.imgDirPath, defaultImageName))
 		if err != nil {
 			slog.Error("failed to read default image: ", "error", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -243,30 +1097,60 @@ func (s *Handlers) AddItem(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	checkpoint(ctx, "image")
+
 	item := &Item{
-		Name:     req.Name,
-		Category: req.Category,
-		Image:    strings.TrimPrefix(string(fileName), "images/"),
+		Name:          req.Name,
+		Category:      req.Category,
+		Status:        req.Status,
+		Price:         Price{Amount: req.Price, Currency: req.Currency},
+		Seller:        req.Seller,
+		Condition:     req.Condition,
+		Quantity:      req.Quantity,
+		Brand:         req.Brand,
+		Draft:         req.Draft,
+		ShippingPayer: req.ShippingPayer,
+		ShippingDays:  req.ShippingDays,
+		Tags:          req.Tags,
+		Image:         strings.TrimPrefix(string(fileName), "images/"),
 	}
 
-	err = s.itemRepo.Insert(ctx, item)
+	insertCtx := withActor(ctx, actor)
+	if req.Dedupe {
+		insertCtx = withDedupe(insertCtx)
+	}
+	err = s.itemRepo.Insert(insertCtx, item)
 
 	if err != nil {
+		var dupErr *DuplicateItemError
+		if errors.As(err, &dupErr) {
+			slog.Warn("duplicate item: ", "error", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(DuplicateItemResponse{Error: dupErr.Error(), ExistingID: dupErr.ExistingID})
+			return
+		}
 		slog.Error("failed to store item: ", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
+	s.setCategoriesVersionHeader(ctx, w)
+	checkpoint(ctx, "db")
 
 	message := fmt.Sprintf("item received: %s", item.Name)
 	slog.Info(message)
 
-	resp := AddItemResponse{Message: message}
+	// 作成した商品のidを知るために一覧を取り直さなくていいように、商品そのものを返す
+	resp := AddItemResponse{Message: message, Item: item}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/items/%d", item.ID))
+	w.WriteHeader(http.StatusCreated)
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	checkpoint(ctx, "encode")
 }
 
 // storeImage stores an image and returns the file path and an error if any.
@@ -282,18 +1166,68 @@ func (s *Handlers) storeImage(image []byte) (filePath string, err error) {
 	filePath = filepath.ToSlash(filePath)
 	// - check if the image already exists
 	if _, err := os.Stat(filePath); err == nil {
+		// 重複排除の際に既存ファイルのハッシュを検証する (デフォルトはオフ)
+		if !s.flags.Bool(flagVerifyDedupe) {
+			s.touchImage(filePath)
+			return filePath, nil
+		}
+		// 既存ファイルが壊れていないか、中身のハッシュを確認する
+		existing, err := os.ReadFile(filePath)
+		if err == nil && sha256.Sum256(existing) == hash {
+			s.touchImage(filePath)
+			return filePath, nil
+		}
+		slog.Warn("existing image does not match its hash, rewriting", "path", filePath)
+		if err := writeFileAtomic(filePath, image); err != nil {
+			return "", fmt.Errorf("failed to rewrite image file: %w", err)
+		}
 		return filePath, nil
 	}
 	// - store image
-	if err := os.WriteFile(filePath, image, 0644); err != nil {
+	// 同じ画像が同時にアップロードされると、どちらもStatを通り抜けて書き込みが重なる
+	// 一時ファイルに書いてからrenameするので、途中まで書かれたファイルが見えることはない (どちらが残っても中身は同じ)
+	if err := writeFileAtomic(filePath, image); err != nil {
 		return "", fmt.Errorf("failed to write image file: %w", err)
 	}
 	// - return the image file path
 	return filePath, nil
 }
 
+// touchImage updates the modification time of a reused image, so that sweepOrphanImages keeps it
+// until the item using it is added.
+func (s *Handlers) touchImage(path string) {
+	now := s.now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		slog.Warn("failed to touch reused image: ", "path", path, "error", err)
+	}
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and renames it into place,
+// so that readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // renameに成功していれば何もしない
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
 type GetImageRequest struct {
 	FileName string // path value
+	// MaxWidth is the rounded maximum width of the image (max_width). 0 means the original.
+	MaxWidth int
 }
 
 // parseGetImageRequest parses and validates the request to get an image.
@@ -304,12 +1238,32 @@ func parseGetImageRequest(r *http.Request) (*GetImageRequest, error) {
 	if req.FileName == "" {
 		return nil, errors.New("filename is required")
 	}
+	if err := checkParamLen("filename", req.FileName, maxImageNameLen); err != nil {
+		return nil, err
+	}
+
+	q, err := parseQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	maxWidth, err := queryParam(q, "max_width", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+	if maxWidth != "" {
+		w, err := strconv.Atoi(maxWidth)
+		if err != nil || w < 1 {
+			return nil, fmt.Errorf("max_width must be a positive integer: %s", maxWidth)
+		}
+		req.MaxWidth = variantWidth(w)
+	}
 
 	return req, nil
 }
 
 // GetImage is a handler to return an image for GET /images/{filename} .
 // If the specified image is not found, it returns the default image.
+// With ?max_width=N, it returns a version scaled so that its width does not exceed N rounded to 100px.
 func (s *Handlers) GetImage(w http.ResponseWriter, r *http.Request) {
 
 	req, err := parseGetImageRequest(r)
@@ -319,21 +1273,108 @@ func (s *Handlers) GetImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	imgPath, err := s.resolveImagePath(req)
+	if err != nil {
+		slog.Warn("failed to build image path: ", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	checkpoint(r.Context(), "parse")
+
+	// ETagを設定しておくと、http.ServeFileがIf-None-Matchを見て304を返してくれる
+	setImageCacheHeaders(w, imgPath)
+
+	slog.Info("returned image", "path", imgPath)
+	http.ServeFile(w, r, imgPath)
+	checkpoint(r.Context(), "image")
+}
+
+// HeadImage is a handler to return the headers of an image for HEAD /images/{filename} .
+// Like GetImage, it falls back to the default image when the specified image is not found.
+func (s *Handlers) HeadImage(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGetImageRequest(r)
+	if err != nil {
+		slog.Warn("failed to parse head image request: ", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	imgPath, err := s.resolveImagePath(req)
+	if err != nil {
+		slog.Warn("failed to build image path: ", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info, err := os.Stat(imgPath)
+	if err != nil {
+		slog.Error("failed to stat image: ", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// ボディは返さず、キャッシュの検証に必要なヘッダーだけ返す
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	setImageCacheHeaders(w, imgPath)
+	w.WriteHeader(http.StatusOK)
+}
+
+// resolveImagePath returns the path of the file to serve for the request.
+// It falls back to the default image when the specified image is not found,
+// and to the original image when a scaled version cannot be made.
+func (s *Handlers) resolveImagePath(req *GetImageRequest) (string, error) {
 	imgPath, err := s.buildImagePath(req.FileName)
 	if err != nil {
 		if !errors.Is(err, errImageNotFound) {
-			slog.Warn("failed to build image path: ", "error", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+			return "", err
 		}
 
 		// when the image is not found, it returns the default image without an error.
 		slog.Debug("image not found", "filename", imgPath)
-		imgPath = filepath.Join(s.imgDirPath, "default.jpg")
+		imgPath = filepath.Join(s.imgDirPath, defaultImageName)
 	}
 
-	slog.Info("returned image", "path", imgPath)
-	http.ServeFile(w, r, imgPath)
+	if req.MaxWidth > 0 {
+		variant, err := s.imageVariant(imgPath, req.MaxWidth)
+		if err != nil {
+			// 縮小できなくても、元の画像は返せる
+			slog.Warn("failed to make image variant, returning the original: ", "error", err, "path", imgPath)
+			return imgPath, nil
+		}
+		imgPath = variant
+	}
+	return imgPath, nil
+}
+
+// setImageCacheHeaders marks images stored under their content hash as immutable.
+// The default image is a fallback and can change, so it is not cached for long.
+func setImageCacheHeaders(w http.ResponseWriter, imgPath string) {
+	etag := imageETag(imgPath)
+	if etag == "" {
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+}
+
+// imageETag returns a strong ETag for an image stored under its content hash, or a scaled version of one.
+// It returns an empty string for files not named by a hash, such as default.jpg.
+func imageETag(imgPath string) string {
+	name := strings.TrimSuffix(filepath.Base(imgPath), filepath.Ext(imgPath))
+	// 縮小版 (<hash>_w300) も元の画像と同じく中身が変わらない
+	hash, _, _ := strings.Cut(name, "_w")
+	if len(hash) != sha256.Size*2 {
+		return ""
+	}
+	for _, c := range hash {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return ""
+		}
+	}
+	return `"` + name + `"`
 }
 
 // buildImagePath builds the image path and validates it.
@@ -364,10 +1405,23 @@ func (s *Handlers) buildImagePath(imageFileName string) (string, error) {
 }
 
 /* GetItemById */
-// リクエスト型をわざわざ宣言している理由: データの構造が明確, 
+// expandCategoryItems is the value of expand to include other items of the same category.
+const expandCategoryItems = "category_items"
+
+// リクエスト型をわざわざ宣言している理由: データの構造が明確,
 // リクエストに新しいパラメータを追加する場合、構造体にフィールドを追加するだけで済むなど
 type GetItemByIdRequest struct {
 	Id string
+	// Expand is expandCategoryItems to include other items of the same category, or empty.
+	Expand string
+	// Limit bounds the number of the category items.
+	Limit int
+}
+
+// GetItemWithCategoryItemsResponse is the item with other items of its category, for expand=category_items.
+type GetItemWithCategoryItemsResponse struct {
+	Item
+	CategoryItems []Item `json:"category_items"`
 }
 
 func parseGetItemByIdRequest(r *http.Request) (*GetItemByIdRequest, error) {
@@ -379,16 +1433,59 @@ func parseGetItemByIdRequest(r *http.Request) (*GetItemByIdRequest, error) {
 	if req.Id == "" {
 		return nil, errors.New("id is required")
 	}
+	if err := checkParamLen("item_id", req.Id, maxShortParamLen); err != nil {
+		return nil, err
+	}
 
 	return req, nil
 }
 
-func (s *Handlers) GetItemById(w http.ResponseWriter, r *http.Request) {
+// parseGetItemByIdExpandRequest parses the request of GET /items/{item_id}, which also accepts expand and limit.
+func parseGetItemByIdExpandRequest(r *http.Request) (*GetItemByIdRequest, error) {
 	req, err := parseGetItemByIdRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	q, err := parseQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	if req.Expand, err = queryParam(q, "expand", maxShortParamLen); err != nil {
+		return nil, err
+	}
+	limit, err := queryParam(q, "limit", maxShortParamLen)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate the request
+	req.Limit = defaultCategoryItems
+	if req.Expand != "" && req.Expand != expandCategoryItems {
+		return nil, fmt.Errorf("invalid expand: %s", req.Expand)
+	}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxCategoryItems {
+			return nil, fmt.Errorf("limit must be an integer between 1 and %d", maxCategoryItems)
+		}
+		req.Limit = n
+	}
+
+	return req, nil
+}
+
+// GetItemById is a handler to return an item for GET /items/{item_id} .
+// With expand=category_items, up to limit other items of the same category are nested in category_items,
+// so that a product page needs only one request.
+func (s *Handlers) GetItemById(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGetItemByIdExpandRequest(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
+	checkpoint(r.Context(), "parse")
+
 	item, err := s.itemRepo.GetItemById(r.Context(), req.Id)
 	// エラーがerrItemNotFoundだったら404返す
 	if err != nil {
@@ -397,11 +1494,28 @@ func (s *Handlers) GetItemById(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.Error("failed to get item: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
 	}
+	// 見つかった商品だけ数える
+	s.views.Add(item.ID)
+
+	var resp any = item
+	if req.Expand == expandCategoryItems {
+		// 商品を取ってからカテゴリで絞り込む (商品自身は除く)
+		categoryItems, err := s.itemRepo.GetCategoryItems(r.Context(), item.Category, item.ID, req.Limit)
+		if err != nil {
+			slog.Error("failed to get category items: ", "error", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		resp = GetItemWithCategoryItemsResponse{Item: item, CategoryItems: categoryItems}
+	}
+	checkpoint(r.Context(), "db")
 
 	// jsonに変換
-	jsonData, err := json.Marshal(item)
+	jsonData, err := json.Marshal(resp)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -409,55 +1523,250 @@ func (s *Handlers) GetItemById(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(jsonData)
+	checkpoint(r.Context(), "encode")
+}
+
+/* SampleItems */
+type SampleItemsRequest struct {
+	PerCategory int
 }
 
-/* SearchItemsByKeyword */
-type GetItemByKeywordRequest struct {
-	Keyword string
+type CategorySample struct {
+	Category string `json:"category"`
+	Items    []Item `json:"items"`
 }
 
-func parseGetItemByKeywordRequest(r *http.Request) (*GetItemByKeywordRequest, error) {
-	req := &GetItemByKeywordRequest{
-		// クエリパラメータを取得
-		Keyword: r.URL.Query().Get("keyword"),
+type SampleItemsResponse struct {
+	Categories []CategorySample `json:"categories"`
+}
+
+func parseSampleItemsRequest(r *http.Request) (*SampleItemsRequest, error) {
+	req := &SampleItemsRequest{PerCategory: defaultSamplePerCategory}
+	q, err := parseQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	v, err := queryParam(q, "per_category", maxShortParamLen)
+	if err != nil {
+		return nil, err
 	}
 
-	// validation
-	if req.Keyword == "" {
-		return nil, errors.New("keyword is required")
+	// validate the request
+	if v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSamplePerCategory {
+			return nil, fmt.Errorf("per_category must be an integer between 1 and %d", maxSamplePerCategory)
+		}
+		req.PerCategory = n
 	}
 
 	return req, nil
 }
 
-func (s *Handlers) SearchItemsByKeyword(w http.ResponseWriter, r *http.Request) {
-	req, err := parseGetItemByKeywordRequest(r)
+// SampleItems is a handler to return random items from each category for GET /items/sample .
+func (s *Handlers) SampleItems(w http.ResponseWriter, r *http.Request) {
+	req, err := parseSampleItemsRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	items, err := s.itemRepo.SampleByCategory(r.Context(), req.PerCategory)
+	if err != nil {
+		slog.Error("failed to sample items: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	// カテゴリ順に並んでいるので、カテゴリが変わるたびにグループを作る
+	resp := SampleItemsResponse{Categories: []CategorySample{}}
+	for _, item := range items {
+		n := len(resp.Categories)
+		if n == 0 || resp.Categories[n-1].Category != item.Category {
+			resp.Categories = append(resp.Categories, CategorySample{Category: item.Category})
+			n++
+		}
+		resp.Categories[n-1].Items = append(resp.Categories[n-1].Items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
+
+/* DeleteItem / RestoreItem */
+// DeleteItem is a handler to soft-delete an item for DELETE /items/{item_id} .
+// Deleting an item that is already deleted returns 404. With ?purge=true the item is removed permanently instead.
+func (s *Handlers) DeleteItem(w http.ResponseWriter, r *http.Request) {
+	req, err := parseDeleteItemRequest(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	checkpoint(r.Context(), "parse")
 
-	items, err := s.itemRepo.SearchItemsByKeyword(r.Context(), req.Keyword)
+	if req.Purge {
+		s.purgeItem(w, r, req.Id)
+		return
+	}
 
+	err = s.itemRepo.SoftDelete(r.Context(), req.Id)
 	if err != nil {
 		if errors.Is(err, errItemNotFound) {
 			slog.Warn("item not exist: ", "error", err)
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
+		slog.Error("failed to delete item: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	slog.Info("item deleted", "id", req.Id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreItem is a handler to restore a soft-deleted item for POST /items/{item_id}/restore .
+// Restoring an item that is not deleted does nothing and returns the item.
+func (s *Handlers) RestoreItem(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGetItemByIdRequest(r)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	checkpoint(r.Context(), "parse")
 
-	if items == nil {
-		items = []Item{}
+	err = s.itemRepo.Restore(r.Context(), req.Id)
+	if err != nil {
+		if errors.Is(err, errItemNotFound) {
+			slog.Warn("item not exist: ", "error", err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to restore item: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
 	}
 
-	// jsonに変換
-	jsonData, err := json.Marshal(items)
+	item, err := s.itemRepo.GetItemById(r.Context(), req.Id)
 	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(item); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	checkpoint(r.Context(), "encode")
+}
+
+/* PurchaseItem */
+// PurchaseItem is a handler to buy one of an item for POST /items/{item_id}/purchase .
+// The item becomes sold when the last one is bought. It returns 409 if the item is already sold.
+func (s *Handlers) PurchaseItem(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGetItemByIdRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	err = s.itemRepo.Purchase(r.Context(), req.Id)
+	if err != nil {
+		switch {
+		case errors.Is(err, errItemNotFound):
+			slog.Warn("item not exist: ", "error", err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, errItemSold):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			slog.Error("failed to purchase item: ", "error", err)
+			http.Error(w, err.Error(), errorStatus(err))
+		}
+		return
+	}
+
+	item, err := s.itemRepo.GetItemById(r.Context(), req.Id)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	slog.Info("item purchased", "id", req.Id)
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(jsonData)
+	if err := json.NewEncoder(w).Encode(item); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
+
+/* GetCategoryHealth */
+type CategoryHealthResponse struct {
+	OrphanedItems   []int `json:"orphaned_items"`
+	EmptyCategories []int `json:"empty_categories"`
+}
+
+// GetCategoryHealth is a handler to report inconsistencies between items and categories for GET /admin/category-health .
+func (s *Handlers) GetCategoryHealth(w http.ResponseWriter, r *http.Request) {
+	issues, err := s.itemRepo.CheckCategoryHealth(r.Context())
+	if err != nil {
+		slog.Error("failed to check category health: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	// 問題がなくても空配列を返す (nullにしない)
+	resp := CategoryHealthResponse{OrphanedItems: []int{}, EmptyCategories: []int{}}
+	for _, issue := range issues {
+		switch issue.Kind {
+		case healthIssueOrphanedItem:
+			resp.OrphanedItems = append(resp.OrphanedItems, issue.ID)
+		case healthIssueEmptyCategory:
+			resp.EmptyCategories = append(resp.EmptyCategories, issue.ID)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
+
+// GetStorageStats is a handler to return the storage footprint of the image directory for GET /admin/storage .
+// Like every /admin/ endpoint, it needs the API key when API_KEY is set (see apiKeyMiddleware).
+func (s *Handlers) GetStorageStats(w http.ResponseWriter, r *http.Request) {
+	var stats StorageStats
+	var err error
+	if s.storageStats != nil {
+		stats, err = s.storageStats.get(s.imgDirPath, s.now(), s.flags.Duration(flagStorageStatsTTL))
+	} else {
+		stats, err = collectStorageStats(s.imgDirPath)
+	}
+	if err != nil {
+		slog.Error("failed to collect storage stats: ", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "image")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
 }
@@ -2,17 +2,29 @@ package app
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"mercari-build-training/app/apptest"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"go.uber.org/mock/gomock"
 )
 
@@ -33,15 +45,230 @@ func TestParseAddItemRequest(t *testing.T) {
 			args: map[string]string{
 				"name":     "test",         // fill here
 				"category": "testCategory", // fill here
+				"price":    "1500",
+			},
+			wants: wants{
+				req: &AddItemRequest{
+					Name:          "test",         // fill here
+					Category:      "testCategory", // fill here
+					Status:        "on_sale",
+					Price:         1500,
+					Currency:      "JPY",
+					Seller:        "anonymous",
+					Condition:     "used",
+					Quantity:      1,
+					ShippingPayer: "seller",
+					ShippingDays:  3,
+				},
+				err: false,
+			},
+		},
+		"ok: seller": {
+			args: map[string]string{
+				"name":     "test",
+				"category": "testCategory",
+				"price":    "1500",
+				"seller":   " alice ",
+			},
+			wants: wants{
+				req: &AddItemRequest{
+					Name:          "test",
+					Category:      "testCategory",
+					Status:        "on_sale",
+					Price:         1500,
+					Currency:      "JPY",
+					Seller:        "alice",
+					Condition:     "used",
+					Quantity:      1,
+					ShippingPayer: "seller",
+					ShippingDays:  3,
+				},
+				err: false,
+			},
+		},
+		"ok: condition": {
+			args: map[string]string{
+				"name":      "test",
+				"category":  "testCategory",
+				"price":     "1500",
+				"condition": "like_new",
+			},
+			wants: wants{
+				req: &AddItemRequest{
+					Name:          "test",
+					Category:      "testCategory",
+					Status:        "on_sale",
+					Price:         1500,
+					Currency:      "JPY",
+					Seller:        "anonymous",
+					Condition:     "like_new",
+					Quantity:      1,
+					ShippingPayer: "seller",
+					ShippingDays:  3,
+				},
+				err: false,
+			},
+		},
+		"ok: zero quantity is sold": {
+			args: map[string]string{
+				"name":     "test",
+				"category": "testCategory",
+				"price":    "1500",
+				"quantity": "0",
+			},
+			wants: wants{
+				req: &AddItemRequest{
+					Name:          "test",
+					Category:      "testCategory",
+					Status:        "sold",
+					Price:         1500,
+					Currency:      "JPY",
+					Seller:        "anonymous",
+					Condition:     "used",
+					Quantity:      0,
+					ShippingPayer: "seller",
+					ShippingDays:  3,
+				},
+				err: false,
+			},
+		},
+		"ng: negative quantity": {
+			args: map[string]string{
+				"name":     "test",
+				"category": "testCategory",
+				"price":    "1500",
+				"quantity": "-1",
+			},
+			wants: wants{
+				req: nil,
+				err: true,
+			},
+		},
+		"ng: unknown condition": {
+			args: map[string]string{
+				"name":      "test",
+				"category":  "testCategory",
+				"price":     "1500",
+				"condition": "broken",
+			},
+			wants: wants{
+				req: nil,
+				err: true,
+			},
+		},
+		"ng: seller too long": {
+			args: map[string]string{
+				"name":     "test",
+				"category": "testCategory",
+				"price":    "1500",
+				"seller":   strings.Repeat("a", maxSellerChars+1),
+			},
+			wants: wants{
+				req: nil,
+				err: true,
+			},
+		},
+		"ok: sold status": {
+			args: map[string]string{
+				"name":     "test",
+				"category": "testCategory",
+				"status":   "sold",
+				"price":    "0",
+			},
+			wants: wants{
+				req: &AddItemRequest{
+					Name:          "test",
+					Category:      "testCategory",
+					Status:        "sold",
+					Currency:      "JPY",
+					Seller:        "anonymous",
+					Condition:     "used",
+					Quantity:      1,
+					ShippingPayer: "seller",
+					ShippingDays:  3,
+				},
+				err: false,
+			},
+		},
+		"ok: currency": {
+			args: map[string]string{
+				"name":     "test",
+				"category": "testCategory",
+				"price":    "1500",
+				"currency": "usd",
 			},
 			wants: wants{
 				req: &AddItemRequest{
-					Name:     "test",         // fill here
-					Category: "testCategory", // fill here
+					Name:          "test",
+					Category:      "testCategory",
+					Status:        "on_sale",
+					Price:         1500,
+					Currency:      "USD",
+					Seller:        "anonymous",
+					Condition:     "used",
+					Quantity:      1,
+					ShippingPayer: "seller",
+					ShippingDays:  3,
 				},
 				err: false,
 			},
 		},
+		"ng: unknown currency": {
+			args: map[string]string{
+				"name":     "test",
+				"category": "testCategory",
+				"price":    "1500",
+				"currency": "BTC",
+			},
+			wants: wants{
+				req: nil,
+				err: true,
+			},
+		},
+		"ng: missing price": {
+			args: map[string]string{
+				"name":     "test",
+				"category": "testCategory",
+			},
+			wants: wants{
+				req: nil,
+				err: true,
+			},
+		},
+		"ng: negative price": {
+			args: map[string]string{
+				"name":     "test",
+				"category": "testCategory",
+				"price":    "-1",
+			},
+			wants: wants{
+				req: nil,
+				err: true,
+			},
+		},
+		"ng: non-integer price": {
+			args: map[string]string{
+				"name":     "test",
+				"category": "testCategory",
+				"price":    "12.5",
+			},
+			wants: wants{
+				req: nil,
+				err: true,
+			},
+		},
+		"ng: unknown status": {
+			args: map[string]string{
+				"name":     "test",
+				"category": "testCategory",
+				"status":   "reserved",
+				"price":    "1500",
+			},
+			wants: wants{
+				req: nil,
+				err: true,
+			},
+		},
 		"ng: empty request": {
 			args: map[string]string{},
 			wants: wants{
@@ -69,7 +296,7 @@ func TestParseAddItemRequest(t *testing.T) {
 			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 			// execute test target
-			got, err := parseAddItemRequest(req)
+			got, err := parseAddItemRequest(req, defaultMaxImageBytes)
 
 			// confirm the result
 			if err != nil {
@@ -85,6 +312,210 @@ func TestParseAddItemRequest(t *testing.T) {
 	}
 }
 
+func TestParseAddItemJSONRequest(t *testing.T) {
+	t.Parallel()
+
+	type wants struct {
+		req *AddItemRequest
+		err error
+	}
+	cases := map[string]struct {
+		body string
+		wants
+	}{
+		"ok: valid request": {
+			body: `{"name":"jacket","category":"fashion","image_name":"default.jpg","price":3000}`,
+			wants: wants{
+				req: &AddItemRequest{
+					Name:          "jacket",
+					Category:      "fashion",
+					Status:        "on_sale",
+					Price:         3000,
+					Currency:      "JPY",
+					Seller:        "anonymous",
+					Condition:     "used",
+					Quantity:      1,
+					ShippingPayer: "seller",
+					ShippingDays:  3,
+					ImageName:     "default.jpg",
+				},
+			},
+		},
+		"ng: unknown field": {
+			body: `{"name":"jacket","category":"fashion","price":3000,"color":"red"}`,
+			wants: wants{
+				err: errInvalidRequestBody,
+			},
+		},
+		"ng: malformed json": {
+			body: `{"name":"jacket",`,
+			wants: wants{
+				err: errInvalidRequestBody,
+			},
+		},
+		"ng: negative price": {
+			body: `{"name":"jacket","category":"fashion","price":-1}`,
+			wants: wants{
+				err: errInvalidPrice,
+			},
+		},
+		"ng: missing price": {
+			body: `{"name":"jacket","category":"fashion"}`,
+			wants: wants{
+				err: errInvalidPrice,
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("POST", "/items", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			got, err := parseAddItemRequest(req, defaultMaxImageBytes)
+			if !errors.Is(err, tt.wants.err) {
+				t.Fatalf("expected error %v, got %v", tt.wants.err, err)
+			}
+			if diff := cmp.Diff(tt.wants.req, got); diff != "" {
+				t.Errorf("unexpected request (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// フォームとJSONで同じ検証・同じエラーメッセージになる
+	form := httptest.NewRequest("POST", "/items", strings.NewReader("category=fashion&price=100"))
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, formErr := parseAddItemRequest(form, defaultMaxImageBytes)
+	js := httptest.NewRequest("POST", "/items", strings.NewReader(`{"category":"fashion","price":100}`))
+	js.Header.Set("Content-Type", "application/json")
+	_, jsonErr := parseAddItemRequest(js, defaultMaxImageBytes)
+	if formErr == nil || jsonErr == nil || formErr.Error() != jsonErr.Error() {
+		t.Errorf("expected the same error for form and json, got %v and %v", formErr, jsonErr)
+	}
+}
+
+func TestAddItemJSON(t *testing.T) {
+	t.Parallel()
+
+	// JPEGとして判定される最小限の中身
+	jpeg := []byte("\xff\xd8\xff\xe0 jpeg")
+	withImage := func(image []byte, extra string) string {
+		return `{"name":"jacket","category":"fashion","price":3000,"image_base64":"` + base64.StdEncoding.EncodeToString(image) + `"` + extra + `}`
+	}
+
+	cases := map[string]struct {
+		body          string
+		maxImageBytes int64
+		injector      func(m *MockItemRepository)
+		code          int
+	}{
+		"ok: base64 image": {
+			body: withImage(jpeg, ""),
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Insert(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, item *Item) error {
+					if want := fmt.Sprintf("%x.jpg", sha256.Sum256(jpeg)); filepath.Base(item.Image) != want {
+						t.Errorf("expected image %s, got %s", want, item.Image)
+					}
+					return nil
+				})
+				m.EXPECT().CategoriesVersion(gomock.Any()).Return(int64(1), nil)
+			},
+			code: http.StatusCreated,
+		},
+		"ok: without an image": {
+			body: `{"name":"jacket","category":"fashion","price":3000}`,
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Insert(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, item *Item) error {
+					// デフォルト画像をハッシュ名で保存したもの
+					if item.Image == "" || item.Image == defaultImageName {
+						t.Errorf("expected the default image stored under its hash, got %q", item.Image)
+					}
+					return nil
+				})
+				m.EXPECT().CategoriesVersion(gomock.Any()).Return(int64(1), nil)
+			},
+			code: http.StatusCreated,
+		},
+		"ng: invalid base64": {
+			body:     `{"name":"jacket","category":"fashion","price":3000,"image_base64":"not base64!"}`,
+			injector: func(m *MockItemRepository) {},
+			code:     http.StatusUnprocessableEntity,
+		},
+		"ng: not a jpeg": {
+			body:     withImage([]byte("GIF89a image"), ""),
+			injector: func(m *MockItemRepository) {},
+			code:     http.StatusUnprocessableEntity,
+		},
+		"ng: base64 image with image_name": {
+			body:     withImage(jpeg, `,"image_name":"default.jpg"`),
+			injector: func(m *MockItemRepository) {},
+			code:     http.StatusUnprocessableEntity,
+		},
+		"ng: too large image": {
+			body:          withImage(jpeg, ""),
+			maxImageBytes: int64(len(jpeg)) - 1,
+			injector:      func(m *MockItemRepository) {},
+			code:          http.StatusUnprocessableEntity,
+		},
+		"ng: too large body": {
+			body:          withImage(append(jpeg, make([]byte, formOverheadBytes)...), ""),
+			maxImageBytes: int64(len(jpeg)),
+			injector:      func(m *MockItemRepository) {},
+			code:          http.StatusRequestEntityTooLarge,
+		},
+		"ok: stored image": {
+			body: `{"name":"jacket","category":"fashion","image_name":"default.jpg","price":3000}`,
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Insert(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, item *Item) error {
+					if item.Image != "default.jpg" || item.Price.Amount != 3000 {
+						t.Errorf("unexpected item: %+v", item)
+					}
+					return nil
+				})
+				m.EXPECT().CategoriesVersion(gomock.Any()).Return(int64(1), nil)
+			},
+			code: http.StatusCreated,
+		},
+		"ng: unknown image": {
+			body:     `{"name":"jacket","category":"fashion","image_name":"missing.jpg","price":3000}`,
+			injector: func(m *MockItemRepository) {},
+			code:     http.StatusUnprocessableEntity,
+		},
+		"ng: unknown field": {
+			body:     `{"name":"jacket","category":"fashion","price":3000,"color":"red"}`,
+			injector: func(m *MockItemRepository) {},
+			code:     http.StatusBadRequest,
+		},
+		"ng: malformed json": {
+			body:     `not json`,
+			injector: func(m *MockItemRepository) {},
+			code:     http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			tt.injector(mockIR)
+			h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: mockIR, MaxImageBytes: tt.maxImageBytes}
+
+			req := httptest.NewRequest("POST", "/items", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			h.AddItem(rr, req)
+
+			if rr.Code != tt.code {
+				t.Errorf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
 func TestHelloHandler(t *testing.T) {
 	t.Parallel()
 
@@ -111,102 +542,1204 @@ func TestHelloHandler(t *testing.T) {
 		t.Errorf("expected status code %d, got %d", want.code, res.Code)
 	}
 
-	// STEP 6-2: confirm response body
-	expectedRes := HelloResponse{Message: want.body["message"]}
+	// STEP 6-2: confirm response body
+	expectedRes := HelloResponse{Message: want.body["message"]}
+
+	var response HelloResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		t.Errorf("failed to decode response: %v", err)
+	}
+
+	if diff := cmp.Diff(expectedRes, response); diff != "" {
+		t.Errorf("unexpected response (-want +got):\n%s", diff)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	t.Parallel()
+
+	mux := newMux((&Handlers{}).routes())
+
+	cases := map[string]struct {
+		method string
+		path   string
+		code   int
+		body   any
+	}{
+		"ok: hello": {
+			method: "GET",
+			path:   "/",
+			code:   http.StatusOK,
+			body:   map[string]any{"message": "Hello, world!"},
+		},
+		"ng: unknown path": {
+			method: "GET",
+			path:   "/does-not-exist",
+			code:   http.StatusNotFound,
+			body:   map[string]any{"error": "not found", "status": float64(404)},
+		},
+		"ng: unknown path under /": {
+			method: "POST",
+			path:   "/",
+			code:   http.StatusNotFound,
+			body:   map[string]any{"error": "not found", "status": float64(404)},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d", tt.code, rr.Code)
+			}
+			var got map[string]any
+			if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if diff := cmp.Diff(tt.body, got); diff != "" {
+				t.Errorf("unexpected response (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHealth(t *testing.T) {
+	t.Parallel()
+
+	type wants struct {
+		code int
+		body HealthResponse
+	}
+	cases := map[string]struct {
+		injector func(m *MockItemRepository)
+		wants
+	}{
+		"ok: database is alive": {
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Ping(gomock.Any()).Return(nil)
+			},
+			wants: wants{
+				code: http.StatusOK,
+				body: HealthResponse{Status: "ok"},
+			},
+		},
+		"ng: database is unavailable": {
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Ping(gomock.Any()).Return(errors.New("database is locked"))
+			},
+			wants: wants{
+				code: http.StatusServiceUnavailable,
+				body: HealthResponse{Status: "unavailable"},
+			},
+		},
+		"ng: ping is cancelled by the timeout": {
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Ping(gomock.Any()).DoAndReturn(func(ctx context.Context) error {
+					// the handler must set a deadline so that a stuck database does not hang the probe
+					if _, ok := ctx.Deadline(); !ok {
+						return errors.New("no deadline")
+					}
+					return context.DeadlineExceeded
+				})
+			},
+			wants: wants{
+				code: http.StatusServiceUnavailable,
+				body: HealthResponse{Status: "unavailable"},
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			tt.injector(mockIR)
+			h := &Handlers{itemRepo: mockIR}
+
+			req := httptest.NewRequest("GET", "/healthz", nil)
+			rr := httptest.NewRecorder()
+			h.Health(rr, req)
+
+			if tt.wants.code != rr.Code {
+				t.Errorf("expected status code %d, got %d", tt.wants.code, rr.Code)
+			}
+			var got HealthResponse
+			if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if diff := cmp.Diff(tt.wants.body, got); diff != "" {
+				t.Errorf("unexpected response (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAddItem(t *testing.T) {
+	// .Parallel: テストフレームワークの一部であり、テスト関数を並行して実行できるように
+	t.Parallel()
+
+	type wants struct {
+		code     int
+		body     string
+		message  string
+		location string
+		item     Item
+	}
+	cases := map[string]struct {
+		args     map[string]string
+		injector func(m *MockItemRepository)
+		wants
+	}{
+		"ok: correctly inserted": {
+			args: map[string]string{
+				"name":     "used iPhone 16e",
+				"category": "phone",
+				"price":    "50000",
+			},
+			// モックオブジェクトの動作を定義するための無名関数
+			injector: func(m *MockItemRepository) {
+				// m.EXPECT() は、モックオブジェクトに対して、特定のメソッドが呼び出されることを期待
+				// .Any() :Insert メソッドが任意の引数で呼び出されることを期待します。
+				// DBと同じように、挿入した商品にidを設定する
+				m.EXPECT().Insert(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, item *Item) error {
+					item.ID = 42
+					return nil
+				})
+				m.EXPECT().CategoriesVersion(gomock.Any()).Return(int64(1), nil)
+			},
+			wants: wants{
+				code:     http.StatusCreated,
+				message:  "item received: used iPhone 16e",
+				location: "/items/42",
+				item:     Item{ID: 42, Name: "used iPhone 16e", Category: "phone", Price: Price{Amount: 50000, Currency: "JPY"}, Seller: "anonymous", Condition: "used", Quantity: 1, ShippingPayer: "seller", ShippingDays: 3},
+			},
+		},
+		"ng: failed to insert": {
+			args: map[string]string{
+				"name":     "used iPhone 16e",
+				"category": "phone",
+				"price":    "50000",
+			},
+			injector: func(m *MockItemRepository) {
+				// STEP 6-3: define mock expectation
+				// failed to insert
+				// テストケース内で新しくエラーを定義して投げてる！？なんでだ
+				// Insertが吐く可能性のあるエラーで検証すべきだった？
+				m.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(errors.New("failed to insert"))
+			},
+			wants: wants{
+				code: http.StatusInternalServerError,
+				body: "failed to insert\n",
+			},
+		},
+		"ng: unknown status": {
+			args: map[string]string{
+				"name":     "used iPhone 16e",
+				"category": "phone",
+				"status":   "reserved",
+				"price":    "50000",
+			},
+			injector: func(m *MockItemRepository) {},
+			wants: wants{
+				code: http.StatusUnprocessableEntity,
+			},
+		},
+		"ng: negative price": {
+			args: map[string]string{
+				"name":     "used iPhone 16e",
+				"category": "phone",
+				"price":    "-100",
+			},
+			injector: func(m *MockItemRepository) {},
+			wants: wants{
+				code: http.StatusUnprocessableEntity,
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+
+			mockIR := NewMockItemRepository(ctrl)
+			tt.injector(mockIR)
+			h := &Handlers{itemRepo: mockIR}
+
+			values := url.Values{}
+			for k, v := range tt.args {
+				values.Set(k, v)
+			}
+			req := httptest.NewRequest("POST", "/items", strings.NewReader(values.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			h.AddItem(rr, req)
+
+			if tt.wants.code != rr.Code {
+				t.Errorf("expected status code %d, got %d", tt.wants.code, rr.Code)
+			}
+			if tt.wants.code >= 400 {
+				return
+			}
+
+			// 画像名と時刻は実行ごとに変わるので、それ以外を比較する
+			var resp AddItemResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Message != tt.wants.message {
+				t.Errorf("expected message %q, got %q", tt.wants.message, resp.Message)
+			}
+			if got := rr.Header().Get("Location"); got != tt.wants.location {
+				t.Errorf("expected Location %q, got %q", tt.wants.location, got)
+			}
+			if resp.Item == nil {
+				t.Fatal("expected the created item in the response")
+			}
+			if diff := cmp.Diff(tt.wants.item, *resp.Item, cmpopts.IgnoreFields(Item{}, "Image", "Status", "CreatedAt", "UpdatedAt")); diff != "" {
+				t.Errorf("unexpected item (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAddItemImageSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	const limit = 1024
+
+	cases := map[string]struct {
+		size     int
+		injector func(m *MockItemRepository)
+		code     int
+	}{
+		"ok: image just under the limit": {
+			size: limit,
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil)
+				m.EXPECT().CategoriesVersion(gomock.Any()).Return(int64(1), nil)
+			},
+			code: http.StatusCreated,
+		},
+		"ng: image just over the limit": {
+			size:     limit + 1,
+			injector: func(m *MockItemRepository) {},
+			code:     http.StatusUnprocessableEntity,
+		},
+		// ボディが上限を超えると、残りの項目を読めないので413
+		"ng: body over the limit": {
+			size:     limit + formOverheadBytes,
+			injector: func(m *MockItemRepository) {},
+			code:     http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			tt.injector(mockIR)
+			h := &Handlers{imgDirPath: t.TempDir(), itemRepo: mockIR, MaxImageBytes: limit}
+
+			body, contentType := newMultipartItem(t, map[string]string{
+				"name":     "jacket",
+				"category": "fashion",
+				"price":    "3000",
+			}, "jacket.jpg", bytes.Repeat([]byte{0xff}, tt.size))
+			req := httptest.NewRequest("POST", "/items", body)
+			req.Header.Set("Content-Type", contentType)
+
+			rr := httptest.NewRecorder()
+			h.AddItem(rr, req)
+
+			if tt.code != rr.Code {
+				t.Errorf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHeadImage(t *testing.T) {
+	t.Parallel()
+
+	dir := setupImageDir(t)
+	image := []byte("test image")
+	hash := fmt.Sprintf("%x", sha256.Sum256(image))
+	if err := os.WriteFile(filepath.Join(dir, hash+".jpg"), image, 0644); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	type wants struct {
+		code          int
+		contentLength string
+		etag          string
+	}
+	cases := map[string]struct {
+		fileName string
+		wants
+	}{
+		"ok: stored image": {
+			fileName: hash + ".jpg",
+			wants: wants{
+				code:          http.StatusOK,
+				contentLength: strconv.Itoa(len(image)),
+				etag:          `"` + hash + `"`,
+			},
+		},
+		"ok: missing image falls back to default": {
+			fileName: "missing.jpg",
+			wants: wants{
+				code: http.StatusOK,
+				etag: "",
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handlers{imgDirPath: dir}
+			req := httptest.NewRequest("HEAD", "/images/"+tt.fileName, nil)
+			req.SetPathValue("filename", tt.fileName)
+			rr := httptest.NewRecorder()
+			h.HeadImage(rr, req)
+
+			if tt.wants.code != rr.Code {
+				t.Errorf("expected status code %d, got %d", tt.wants.code, rr.Code)
+			}
+			if rr.Body.Len() != 0 {
+				t.Errorf("expected empty body, got %d bytes", rr.Body.Len())
+			}
+			if tt.wants.contentLength != "" && rr.Header().Get("Content-Length") != tt.wants.contentLength {
+				t.Errorf("expected Content-Length %s, got %s", tt.wants.contentLength, rr.Header().Get("Content-Length"))
+			}
+			if rr.Header().Get("Last-Modified") == "" {
+				t.Errorf("expected Last-Modified header to be set")
+			}
+			if got := rr.Header().Get("ETag"); got != tt.wants.etag {
+				t.Errorf("expected ETag %q, got %q", tt.wants.etag, got)
+			}
+		})
+	}
+}
+
+func TestStoreImageVerifyDedupe(t *testing.T) {
+	t.Parallel()
+
+	image := []byte("original image")
+	fileName := fmt.Sprintf("%x.jpg", sha256.Sum256(image))
+
+	cases := map[string]struct {
+		verifyDedupe bool
+		want         []byte
+	}{
+		"ok: corrupted file is trusted when verification is off": {
+			verifyDedupe: false,
+			want:         []byte("truncated"),
+		},
+		"ok: corrupted file is rewritten when verification is on": {
+			verifyDedupe: true,
+			want:         image,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			// 同じハッシュ名で壊れたファイルを置いておく
+			if err := os.WriteFile(filepath.Join(dir, fileName), []byte("truncated"), 0644); err != nil {
+				t.Fatalf("failed to write corrupted image: %v", err)
+			}
+
+			flags := NewFlags(flagSpecs)
+			if _, _, err := flags.Set(flagVerifyDedupe, json.RawMessage(strconv.FormatBool(tt.verifyDedupe))); err != nil {
+				t.Fatalf("failed to set flag: %v", err)
+			}
+			h := &Handlers{imgDirPath: dir, flags: flags}
+			path, err := h.storeImage(image)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read stored image: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("expected stored image %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStoreImageConcurrent(t *testing.T) {
+	t.Parallel()
+
+	// 1回のwriteで書き終わらない大きさにする
+	image := bytes.Repeat([]byte("concurrent image "), 1<<16)
+	dir := t.TempDir()
+	h := &Handlers{imgDirPath: dir, flags: NewFlags(flagSpecs)}
+
+	// 同じ新しい画像を並行して保存する
+	const n = 16
+	paths := make(chan string, n)
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path, err := h.storeImage(image)
+			if err != nil {
+				errs <- err
+				return
+			}
+			paths <- path
+		}()
+	}
+	wg.Wait()
+	close(paths)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("failed to store image: %v", err)
+	}
+	want := filepath.ToSlash(filepath.Join(dir, fmt.Sprintf("%x.jpg", sha256.Sum256(image))))
+	for path := range paths {
+		if path != want {
+			t.Errorf("expected path %q, got %q", want, path)
+		}
+	}
+	got, err := os.ReadFile(want)
+	if err != nil {
+		t.Fatalf("failed to read stored image: %v", err)
+	}
+	if !bytes.Equal(got, image) {
+		t.Errorf("expected the stored image to be complete, got %d of %d bytes", len(got), len(image))
+	}
+	// 一時ファイルは残らない
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read image dir: %v", err)
+	}
+	if len(entries) != 1 {
+		names := []string{}
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("expected only the stored image, got %v", names)
+	}
+}
+
+func TestGetImageCaching(t *testing.T) {
+	t.Parallel()
+
+	dir := setupImageDir(t)
+	image := []byte("test image")
+	hash := fmt.Sprintf("%x", sha256.Sum256(image))
+	if err := os.WriteFile(filepath.Join(dir, hash+".jpg"), image, 0644); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	type wants struct {
+		code         int
+		etag         string
+		cacheControl string
+	}
+	cases := map[string]struct {
+		fileName    string
+		ifNoneMatch string
+		wants
+	}{
+		"ok: stored image is cacheable": {
+			fileName: hash + ".jpg",
+			wants: wants{
+				code:         http.StatusOK,
+				etag:         `"` + hash + `"`,
+				cacheControl: "public, max-age=31536000, immutable",
+			},
+		},
+		"ok: matching If-None-Match returns 304": {
+			fileName:    hash + ".jpg",
+			ifNoneMatch: `"` + hash + `"`,
+			wants: wants{
+				code:         http.StatusNotModified,
+				etag:         `"` + hash + `"`,
+				cacheControl: "public, max-age=31536000, immutable",
+			},
+		},
+		"ok: stale If-None-Match returns the image": {
+			fileName:    hash + ".jpg",
+			ifNoneMatch: `"stale"`,
+			wants: wants{
+				code:         http.StatusOK,
+				etag:         `"` + hash + `"`,
+				cacheControl: "public, max-age=31536000, immutable",
+			},
+		},
+		"ok: default image is not cached for long": {
+			fileName: "missing.jpg",
+			wants: wants{
+				code: http.StatusOK,
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handlers{imgDirPath: dir}
+			req := httptest.NewRequest("GET", "/images/"+tt.fileName, nil)
+			req.SetPathValue("filename", tt.fileName)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rr := httptest.NewRecorder()
+			h.GetImage(rr, req)
+
+			if tt.wants.code != rr.Code {
+				t.Errorf("expected status code %d, got %d", tt.wants.code, rr.Code)
+			}
+			if got := rr.Header().Get("ETag"); got != tt.wants.etag {
+				t.Errorf("expected ETag %q, got %q", tt.wants.etag, got)
+			}
+			if got := rr.Header().Get("Cache-Control"); got != tt.wants.cacheControl {
+				t.Errorf("expected Cache-Control %q, got %q", tt.wants.cacheControl, got)
+			}
+			if tt.wants.code == http.StatusNotModified && rr.Body.Len() != 0 {
+				t.Errorf("expected empty body for 304, got %d bytes", rr.Body.Len())
+			}
+		})
+	}
+}
+
+// newMultipartItem builds a multipart/form-data body with the given fields and image.
+func newMultipartItem(t *testing.T, fields map[string]string, fileName string, image []byte) (*bytes.Buffer, string) {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			t.Fatalf("failed to write field: %v", err)
+		}
+	}
+	if fileName != "" {
+		fw, err := mw.CreateFormFile("image", fileName)
+		if err != nil {
+			t.Fatalf("failed to create form file: %v", err)
+		}
+		if _, err := fw.Write(image); err != nil {
+			t.Fatalf("failed to write image: %v", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("failed to close multipart writer: %v", err)
+	}
+	return body, mw.FormDataContentType()
+}
+
+// setupImageDir creates a temporary image directory containing default.jpg.
+func setupImageDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	img, err := os.ReadFile("default.jpg")
+	if err != nil {
+		t.Fatalf("failed to read default image: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "default.jpg"), img, 0644); err != nil {
+		t.Fatalf("failed to write default image: %v", err)
+	}
+	return dir
+}
+
+func TestGetItemsStatusFilterE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "jacket", Category: "fashion", Image: "default.jpg"},
+		{Name: "jeans", Category: "fashion", Image: "default.jpg", Status: itemStatusSold},
+	} {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+
+	cases := map[string]struct {
+		target string
+		code   int
+		names  []string
+	}{
+		"ok: all items": {
+			target: "/items",
+			code:   http.StatusOK,
+			names:  []string{"jacket", "jeans"},
+		},
+		"ok: on_sale only": {
+			target: "/items?status=on_sale",
+			code:   http.StatusOK,
+			names:  []string{"jacket"},
+		},
+		"ok: sold only": {
+			target: "/items?status=sold",
+			code:   http.StatusOK,
+			names:  []string{"jeans"},
+		},
+		"ng: unknown status": {
+			target: "/items?status=reserved",
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := &Handlers{itemRepo: repo}
+			req := httptest.NewRequest("GET", tt.target, nil)
+			rr := httptest.NewRecorder()
+			h.GetItems(rr, req)
+
+			if tt.code != rr.Code {
+				t.Fatalf("expected status code %d, got %d", tt.code, rr.Code)
+			}
+			if tt.code >= 400 {
+				return
+			}
+			var resp GetItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var names []string
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetItemsPagingE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "jacket", Category: "fashion", Image: "default.jpg"},
+		{Name: "jeans", Category: "fashion", Image: "default.jpg", Status: itemStatusSold},
+		{Name: "hat", Category: "fashion", Image: "default.jpg"},
+		{Name: "shirt", Category: "fashion", Image: "default.jpg"},
+		{Name: "coat", Category: "fashion", Image: "default.jpg", Status: itemStatusSold},
+	} {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+
+	type wants struct {
+		code  int
+		names []string
+		page  PaginationMeta
+	}
+	cases := map[string]struct {
+		target string
+		wants
+	}{
+		"ok: default limit": {
+			target: "/items",
+			wants: wants{code: http.StatusOK, names: []string{"jacket", "jeans", "hat", "shirt", "coat"},
+				page: PaginationMeta{Total: 5, Limit: defaultItemsLimit, Page: 1, TotalPages: 1, HasMore: false}},
+		},
+		"ok: first page": {
+			target: "/items?limit=2",
+			wants: wants{code: http.StatusOK, names: []string{"jacket", "jeans"},
+				page: PaginationMeta{Total: 5, Limit: 2, Page: 1, TotalPages: 3, HasMore: true}},
+		},
+		"ok: second page": {
+			target: "/items?limit=2&offset=2",
+			wants: wants{code: http.StatusOK, names: []string{"hat", "shirt"},
+				page: PaginationMeta{Total: 5, Limit: 2, Offset: 2, Page: 2, TotalPages: 3, HasMore: true}},
+		},
+		"ok: last page": {
+			target: "/items?limit=2&offset=4",
+			wants: wants{code: http.StatusOK, names: []string{"coat"},
+				page: PaginationMeta{Total: 5, Limit: 2, Offset: 4, Page: 3, TotalPages: 3, HasMore: false}},
+		},
+		"ok: past the last page": {
+			target: "/items?limit=2&offset=10",
+			wants: wants{code: http.StatusOK, names: nil,
+				page: PaginationMeta{Total: 5, Limit: 2, Offset: 10, Page: 6, TotalPages: 3, HasMore: false}},
+		},
+		"ok: total reflects the status filter": {
+			target: "/items?status=on_sale&limit=2",
+			wants: wants{code: http.StatusOK, names: []string{"jacket", "hat"},
+				page: PaginationMeta{Total: 3, Limit: 2, Page: 1, TotalPages: 2, HasMore: true}},
+		},
+		// ページの途中から始まるoffsetは、そのoffsetを含むページとして数える
+		"ok: ids are paged in the given order": {
+			target: "/items?ids=5,4,3,1&limit=2&offset=1",
+			wants: wants{code: http.StatusOK, names: []string{"shirt", "hat"},
+				page: PaginationMeta{Total: 4, Limit: 2, Offset: 1, Page: 1, TotalPages: 2, HasMore: true}},
+		},
+		"ng: limit too large": {
+			target: "/items?limit=1000",
+			wants:  wants{code: http.StatusBadRequest},
+		},
+		"ng: negative offset": {
+			target: "/items?offset=-1",
+			wants:  wants{code: http.StatusBadRequest},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := &Handlers{itemRepo: repo}
+			req := httptest.NewRequest("GET", tt.target, nil)
+			rr := httptest.NewRecorder()
+			h.GetItems(rr, req)
+
+			if tt.wants.code != rr.Code {
+				t.Fatalf("expected status code %d, got %d", tt.wants.code, rr.Code)
+			}
+			if tt.wants.code >= 400 {
+				return
+			}
+			var resp GetItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var names []string
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.wants.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wants.page, resp.PaginationMeta); diff != "" {
+				t.Errorf("unexpected page metadata (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetItemsByIDsE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, name := range []string{"jacket", "jeans", "shirt"} {
+		if err := repo.Insert(t.Context(), &Item{Name: name, Category: "fashion", Image: "default.jpg"}); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+
+	cases := map[string]struct {
+		target string
+		code   int
+		names  []string
+	}{
+		"ok: requested order": {
+			target: "/items?ids=3,1",
+			code:   http.StatusOK,
+			names:  []string{"shirt", "jacket"},
+		},
+		"ok: missing ids are absent": {
+			target: "/items?ids=2,99",
+			code:   http.StatusOK,
+			names:  []string{"jeans"},
+		},
+		"ok: no matching ids": {
+			target: "/items?ids=99",
+			code:   http.StatusOK,
+			names:  nil,
+		},
+		"ng: malformed id": {
+			target: "/items?ids=1,abc",
+			code:   http.StatusBadRequest,
+		},
+		"ng: too many ids": {
+			target: "/items?ids=" + strings.Repeat("1,", maxItemIDs) + "1",
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := &Handlers{itemRepo: repo}
+			req := httptest.NewRequest("GET", tt.target, nil)
+			rr := httptest.NewRecorder()
+			h.GetItems(rr, req)
+
+			if tt.code != rr.Code {
+				t.Fatalf("expected status code %d, got %d", tt.code, rr.Code)
+			}
+			if tt.code >= 400 {
+				return
+			}
+			var resp GetItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var names []string
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetItemByIdExpandE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "jacket", Category: "fashion"},
+		{Name: "jeans", Category: "fashion"},
+		{Name: "shirt", Category: "fashion"},
+		{Name: "iPhone", Category: "phone"},
+		{Name: "hat", Category: "fashion"},
+	} {
+		item.Image = "default.jpg"
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	// 削除済みの商品は含めない
+	if err := repo.SoftDelete(t.Context(), "5"); err != nil {
+		t.Fatalf("failed to delete item: %v", err)
+	}
+
+	cases := map[string]struct {
+		target string
+		id     string
+		code   int
+		// names is nil when category_items must be absent.
+		names []string
+	}{
+		"ok: without expand": {
+			target: "/items/1",
+			id:     "1",
+			code:   http.StatusOK,
+		},
+		"ok: category items exclude the item": {
+			target: "/items/1?expand=category_items",
+			id:     "1",
+			code:   http.StatusOK,
+			names:  []string{"jeans", "shirt"},
+		},
+		"ok: limit": {
+			target: "/items/3?expand=category_items&limit=1",
+			id:     "3",
+			code:   http.StatusOK,
+			names:  []string{"jacket"},
+		},
+		"ok: only item of the category": {
+			target: "/items/4?expand=category_items",
+			id:     "4",
+			code:   http.StatusOK,
+			names:  []string{},
+		},
+		"ng: item not found": {
+			target: "/items/99?expand=category_items",
+			id:     "99",
+			code:   http.StatusNotFound,
+		},
+		"ng: unknown expand": {
+			target: "/items/1?expand=seller",
+			id:     "1",
+			code:   http.StatusBadRequest,
+		},
+		"ng: limit over the cap": {
+			target: fmt.Sprintf("/items/1?expand=category_items&limit=%d", maxCategoryItems+1),
+			id:     "1",
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := &Handlers{itemRepo: repo}
+			req := httptest.NewRequest("GET", tt.target, nil)
+			req.SetPathValue("item_id", tt.id)
+			rr := httptest.NewRecorder()
+			h.GetItemById(rr, req)
+
+			if tt.code != rr.Code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code >= 400 {
+				return
+			}
+			var resp struct {
+				ID            int     `json:"id"`
+				CategoryItems *[]Item `json:"category_items"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if want, _ := strconv.Atoi(tt.id); resp.ID != want {
+				t.Errorf("expected item %d, got %d", want, resp.ID)
+			}
+			if tt.names == nil {
+				if resp.CategoryItems != nil {
+					t.Errorf("expected no category_items, got %v", *resp.CategoryItems)
+				}
+				return
+			}
+			if resp.CategoryItems == nil {
+				t.Fatal("expected category_items")
+			}
+			names := []string{}
+			for _, item := range *resp.CategoryItems {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected category items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSampleItemsE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	counts := map[string]int{"fashion": 5, "phone": 2, "books": 3}
+	for category, n := range counts {
+		for i := 0; i < n; i++ {
+			if err := repo.Insert(t.Context(), &Item{Name: fmt.Sprintf("%s %d", category, i), Category: category, Image: "default.jpg"}); err != nil {
+				t.Fatalf("failed to insert item: %v", err)
+			}
+		}
+	}
+
+	h := &Handlers{itemRepo: repo}
+	req := httptest.NewRequest("GET", "/items/sample?per_category=3", nil)
+	rr := httptest.NewRecorder()
+	h.SampleItems(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	var resp SampleItemsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	got := map[string]int{}
+	for _, c := range resp.Categories {
+		for _, item := range c.Items {
+			if item.Category != c.Category {
+				t.Errorf("item %q of category %q is grouped under %q", item.Name, item.Category, c.Category)
+			}
+		}
+		got[c.Category] = len(c.Items)
+	}
+	want := map[string]int{"fashion": 3, "phone": 2, "books": 3}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected number of items per category (-want +got):\n%s", diff)
+	}
+}
+
+func TestPurchaseItemE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	if err := repo.Insert(t.Context(), &Item{Name: "jacket", Category: "fashion", Image: "default.jpg"}); err != nil {
+		t.Fatalf("failed to insert item: %v", err)
+	}
+	h := &Handlers{itemRepo: repo}
 
-	var response HelloResponse
-	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
-		t.Errorf("failed to decode response: %v", err)
+	purchase := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/items/"+id+"/purchase", nil)
+		req.SetPathValue("item_id", id)
+		rr := httptest.NewRecorder()
+		h.PurchaseItem(rr, req)
+		return rr
 	}
 
-	if diff := cmp.Diff(expectedRes, response); diff != "" {
-		t.Errorf("unexpected response (-want +got):\n%s", diff)
+	// 同じ商品を並行して購入しても、成功するのは1件だけ
+	const n = 2
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- purchase("1").Code
+		}()
 	}
-}
+	wg.Wait()
+	close(codes)
 
-func TestAddItem(t *testing.T) {
-	// .Parallel: テストフレームワークの一部であり、テスト関数を並行して実行できるように
-	t.Parallel()
+	got := map[int]int{}
+	for code := range codes {
+		got[code]++
+	}
+	want := map[int]int{http.StatusOK: 1, http.StatusConflict: 1}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected status codes (-want +got):\n%s", diff)
+	}
 
-	type wants struct {
-		code int
-		body string
+	item, err := repo.GetItemById(t.Context(), "1")
+	if err != nil {
+		t.Fatalf("failed to get item: %v", err)
 	}
-	cases := map[string]struct {
-		args     map[string]string
-		injector func(m *MockItemRepository)
-		wants
-	}{
-		"ok: correctly inserted": {
-			args: map[string]string{
-				"name":     "used iPhone 16e",
-				"category": "phone",
-			},
-			// モックオブジェクトの動作を定義するための無名関数
-			injector: func(m *MockItemRepository) {
-				// m.EXPECT() は、モックオブジェクトに対して、特定のメソッドが呼び出されることを期待
-				// .Any() :Insert メソッドが任意の引数で呼び出されることを期待します。
-				m.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil)
-			},
-			wants: wants{
-				code: http.StatusOK,
-				body: `{"message":"item received: used iPhone 16e"}` + "\n",
-			},
-		},
-		"ng: failed to insert": {
-			args: map[string]string{
-				"name":     "used iPhone 16e",
-				"category": "phone",
-			},
-			injector: func(m *MockItemRepository) {
-				// STEP 6-3: define mock expectation
-				// failed to insert
-				// テストケース内で新しくエラーを定義して投げてる！？なんでだ
-				// Insertが吐く可能性のあるエラーで検証すべきだった？
-				m.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(errors.New("failed to insert"))
-			},
-			wants: wants{
-				code: http.StatusInternalServerError,
-				body: "failed to insert\n",
-			},
-		},
+	if item.Status != itemStatusSold {
+		t.Errorf("expected status %s, got %s", itemStatusSold, item.Status)
 	}
 
-	for name, tt := range cases {
-		t.Run(name, func(t *testing.T) {
-			t.Parallel()
+	if rr := purchase("99"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status code %d for a missing item, got %d", http.StatusNotFound, rr.Code)
+	}
+}
 
-			ctrl := gomock.NewController(t)
+func TestPurchaseStockE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
 
-			mockIR := NewMockItemRepository(ctrl)
-			tt.injector(mockIR)
-			h := &Handlers{itemRepo: mockIR}
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
 
-			values := url.Values{}
-			for k, v := range tt.args {
-				values.Set(k, v)
-			}
-			req := httptest.NewRequest("POST", "/items", strings.NewReader(values.Encode()))
-			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	const (
+		stock  = 5
+		buyers = 30
+	)
+	repo := &itemRepository{db: db}
+	if err := repo.Insert(t.Context(), &Item{Name: "t-shirt", Category: "fashion", Image: "default.jpg", Quantity: stock}); err != nil {
+		t.Fatalf("failed to insert item: %v", err)
+	}
+	h := &Handlers{itemRepo: repo}
 
+	// 在庫より多くの購入が同時に来ても、売れるのは在庫の数だけ
+	codes := make(chan int, buyers)
+	var wg sync.WaitGroup
+	for range buyers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/items/1/purchase", nil)
+			req.SetPathValue("item_id", "1")
 			rr := httptest.NewRecorder()
-			h.AddItem(rr, req)
+			h.PurchaseItem(rr, req)
+			codes <- rr.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
 
-			if tt.wants.code != rr.Code {
-				t.Errorf("expected status code %d, got %d", tt.wants.code, rr.Code)
-			}
-			if tt.wants.code >= 400 {
-				return
-			}
+	got := map[int]int{}
+	for code := range codes {
+		got[code]++
+	}
+	want := map[int]int{http.StatusOK: stock, http.StatusConflict: buyers - stock}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected status codes (-want +got):\n%s", diff)
+	}
 
-			// レスポンスボディ全体を比較するように変更
-			// たしか、テキストは完全に同じ(バイト変換しても同じだったはず)なのにこのもとのテストが失敗していたから変えた記憶ある
-			if !bytes.Equal(rr.Body.Bytes(), []byte(tt.wants.body)) {
-				// fmt.Printf("expected: %x\n", []byte(tt.wants.body))
-				// fmt.Printf("got:      %x\n", rr.Body.Bytes())
-				t.Errorf("expected response body %s, got %s", tt.wants.body, rr.Body.String())
-			}
-		})
+	item, err := repo.GetItemById(t.Context(), "1")
+	if err != nil {
+		t.Fatalf("failed to get item: %v", err)
+	}
+	if item.Quantity != 0 || item.Status != itemStatusSold {
+		t.Errorf("expected quantity 0 and status %s, got quantity %d and status %s", itemStatusSold, item.Quantity, item.Status)
 	}
 }
 
@@ -240,27 +1773,28 @@ func TestAddItemE2e(t *testing.T) {
 			args: map[string]string{
 				"name":     "used iPhone 16e",
 				"category": "phone",
+				"price":    "50000",
 			},
 			wants: wants{
-				code: http.StatusOK,
-				body: `{"message":"item received: used iPhone 16e"}` + "\n",
+				code: http.StatusCreated,
 			},
 		},
-		"ng: failed to insert": {
+		"ng: missing name": {
 			args: map[string]string{
 				"name":     "",
 				"category": "phone",
+				"price":    "50000",
 			},
 			wants: wants{
-				code: http.StatusInternalServerError,
-				body: "failed to insert\n",
+				code: http.StatusUnprocessableEntity,
+				body: `{"errors":{"name":"required"}}` + "\n",
 			},
 		},
 	}
 
 	for name, tt := range cases {
 		t.Run(name, func(t *testing.T) {
-			h := &Handlers{itemRepo: &itemRepository{db: db}}
+			h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: &itemRepository{db: db}}
 
 			values := url.Values{}
 			for k, v := range tt.args {
@@ -294,13 +1828,13 @@ func TestAddItemE2e(t *testing.T) {
 
 			var item Item
 			query := `
-					SELECT items.id, items.name, categories.name AS category, items.image_name
+					SELECT items.id, items.name, categories.name AS category, items.image_name, items.price
 					FROM items 
 					INNER JOIN categories ON items.category_id = categories.id
 					ORDER BY items.id DESC
 					LIMIT 1
 					`
-			err = DB.QueryRow(query).Scan(&item.ID, &item.Name, &item.Category, &item.Image)
+			err = DB.QueryRow(query).Scan(&item.ID, &item.Name, &item.Category, &item.Image, &item.Price.Amount)
 			if err != nil {
 				// エラー発生時にロールバック
 				DB.Rollback()
@@ -309,6 +1843,21 @@ func TestAddItemE2e(t *testing.T) {
 			if item.Name != tt.args["name"] || item.Category != tt.args["category"] {
 				t.Errorf("expected item (name: %s, category: %s), got (name: %s, category: %s)", tt.args["name"], tt.args["category"], item.Name, item.Category)
 			}
+			if got := strconv.Itoa(item.Price.Amount); got != tt.args["price"] {
+				t.Errorf("expected price %s, got %s", tt.args["price"], got)
+			}
+			// レスポンスの商品とLocationはDBに入ったidを指す
+			var resp AddItemResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				DB.Rollback()
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Item == nil || resp.Item.ID != item.ID {
+				t.Errorf("expected item with id %d in the response, got %+v", item.ID, resp.Item)
+			}
+			if want, got := fmt.Sprintf("/items/%d", item.ID), rr.Header().Get("Location"); got != want {
+				t.Errorf("expected Location %q, got %q", want, got)
+			}
 			err = DB.Commit()
 			if err != nil {
 				t.Fatalf("failed to commit transaction: %v", err)
@@ -317,7 +1866,209 @@ func TestAddItemE2e(t *testing.T) {
 	}
 }
 
-func setupDB(t *testing.T) (db *sql.DB, closers []func(), e error) {
+func TestGetCategoryHealthE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	if err := repo.Insert(t.Context(), &Item{Name: "jacket", Category: "fashion", Image: "default.jpg"}); err != nil {
+		t.Fatalf("failed to insert item: %v", err)
+	}
+	// アプリを通さずに直接SQLで不整合なデータを作る
+	if _, err := db.Exec(`INSERT INTO items (id, name, category_id, image_name) VALUES (100, 'orphan', 999, 'default.jpg')`); err != nil {
+		t.Fatalf("failed to insert orphaned item: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO categories (id, name) VALUES (50, 'empty')`); err != nil {
+		t.Fatalf("failed to insert empty category: %v", err)
+	}
+
+	h := &Handlers{itemRepo: repo}
+	req := httptest.NewRequest("GET", "/admin/category-health", nil)
+	rr := httptest.NewRecorder()
+	h.GetCategoryHealth(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	var got CategoryHealthResponse
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := CategoryHealthResponse{OrphanedItems: []int{100}, EmptyCategories: []int{50}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected response (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetItemsSortByCreatedAtE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	clock := apptest.NewFakeClock(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	repo := &itemRepository{db: db, clock: clock}
+	for _, name := range []string{"first", "second", "third"} {
+		if err := repo.Insert(t.Context(), &Item{Name: name, Category: "test", Image: "default.jpg"}); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+		clock.Advance(24 * time.Hour)
+	}
+
+	h := &Handlers{itemRepo: repo}
+	req := httptest.NewRequest("GET", "/items?sort=created_at", nil)
+	rr := httptest.NewRecorder()
+	h.GetItems(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	// タイムスタンプがRFC3339の文字列で返ることも確認する
+	type item struct {
+		Name      string `json:"name"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+	}
+	var got struct {
+		Items []item `json:"items"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	want := []item{
+		{Name: "third", CreatedAt: "2025-04-03T09:00:00Z", UpdatedAt: "2025-04-03T09:00:00Z"},
+		{Name: "second", CreatedAt: "2025-04-02T09:00:00Z", UpdatedAt: "2025-04-02T09:00:00Z"},
+		{Name: "first", CreatedAt: "2025-04-01T09:00:00Z", UpdatedAt: "2025-04-01T09:00:00Z"},
+	}
+	if diff := cmp.Diff(want, got.Items); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+}
+
+func TestSoftDeleteE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, name := range []string{"jacket", "jeans"} {
+		if err := repo.Insert(t.Context(), &Item{Name: name, Category: "fashion", Image: "default.jpg"}); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	h := &Handlers{itemRepo: repo}
+
+	// serve sends a request to the handler with the item_id path value set.
+	serve := func(handler http.HandlerFunc, method, target, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if id != "" {
+			req.SetPathValue("item_id", id)
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+	// listNames returns the names of the items returned by GET /items.
+	listNames := func(target string) []string {
+		rr := serve(h.GetItems, "GET", target, "")
+		var resp GetItemsResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		var names []string
+		for _, item := range resp.Items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+
+	if rr := serve(h.DeleteItem, "DELETE", "/items/1", "1"); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status code %d for delete, got %d", http.StatusNoContent, rr.Code)
+	}
+	if diff := cmp.Diff([]string{"jeans"}, listNames("/items")); diff != "" {
+		t.Errorf("deleted item should be hidden (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"jacket", "jeans"}, listNames("/items?include_deleted=true")); diff != "" {
+		t.Errorf("deleted item should be revealed with include_deleted (-want +got):\n%s", diff)
+	}
+	if rr := serve(h.GetItemById, "GET", "/items/1", "1"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status code %d for deleted item, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := serve(h.DeleteItem, "DELETE", "/items/1", "1"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status code %d for deleting twice, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	if rr := serve(h.RestoreItem, "POST", "/items/1/restore", "1"); rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d for restore, got %d", http.StatusOK, rr.Code)
+	}
+	if diff := cmp.Diff([]string{"jacket", "jeans"}, listNames("/items")); diff != "" {
+		t.Errorf("restored item should be listed (-want +got):\n%s", diff)
+	}
+	if rr := serve(h.RestoreItem, "POST", "/items/2/restore", "2"); rr.Code != http.StatusOK {
+		t.Errorf("expected status code %d for restoring a non-deleted item, got %d", http.StatusOK, rr.Code)
+	}
+	if rr := serve(h.RestoreItem, "POST", "/items/99/restore", "99"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status code %d for restoring a missing item, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestConfigureDB(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.sqlite3"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := (Server{}).configureDB(db); err != nil {
+		t.Fatalf("failed to configure database: %v", err)
+	}
+
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		t.Fatalf("failed to query journal_mode: %v", err)
+	}
+	if mode != "wal" {
+		t.Errorf("expected journal_mode wal, got %s", mode)
+	}
+	if got := db.Stats().MaxOpenConnections; got != defaultDBMaxOpenConns {
+		t.Errorf("expected max open connections %d, got %d", defaultDBMaxOpenConns, got)
+	}
+}
+
+func setupDB(t testing.TB) (db *sql.DB, closers []func(), e error) {
 	t.Helper()
 
 	defer func() {
@@ -339,7 +2090,8 @@ func setupDB(t *testing.T) (db *sql.DB, closers []func(), e error) {
 	})
 
 	// set up tables
-	db, err = sql.Open("sqlite3", f.Name())
+	// 同時に書き込むテストのために、ロック待ちのタイムアウトを設定しておく
+	db, err = sql.Open("sqlite3", f.Name()+"?_busy_timeout=5000")
 	if err != nil {
 		return nil, nil, err
 	}
@@ -347,25 +2099,101 @@ func setupDB(t *testing.T) (db *sql.DB, closers []func(), e error) {
 		db.Close()
 	})
 
-	// TODO: replace it with real SQL statements.
-	cmd := `
-				CREATE TABLE IF NOT EXISTS items (
-					id INTEGER PRIMARY KEY AUTOINCREMENT,
-					name TEXT NOT NULL,
-					category_id INTEGER,
-					image_name TEXT NOT NULL,
-					FOREIGN KEY (category_id) REFERENCES categories(id)
-				);
-
-				CREATE TABLE IF NOT EXISTS categories (
-					id INTEGER PRIMARY KEY AUTOINCREMENT,
-					name TEXT NOT NULL UNIQUE
-				);
-			`
-	_, err = db.Exec(cmd)
+	// set up tables with the real schema
+	schema, err := os.ReadFile("../db/items.sql")
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := initSchema(db, string(schema), time.Now()); err != nil {
+		return nil, nil, err
+	}
 
 	return db, closers, nil
 }
+
+func TestItemConditionE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	h := &Handlers{imgDirPath: "../images", itemRepo: repo}
+
+	add := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.AddItem(rr, req)
+		return rr
+	}
+	for _, body := range []string{
+		`{"name":"jacket","category":"fashion","image_name":"default.jpg","price":3000,"condition":"new"}`,
+		`{"name":"camera","category":"electronics","image_name":"default.jpg","price":20000,"condition":"junk"}`,
+		`{"name":"pen","category":"stationery","image_name":"default.jpg","price":100}`,
+	} {
+		if rr := add(body); rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+	}
+
+	// 不正な値には、使える値の一覧を返す
+	rr := add(`{"name":"hat","category":"fashion","image_name":"default.jpg","price":100,"condition":"broken"}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status code %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	var verr FieldErrorsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&verr); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := map[string]string{"condition": "must be one of new, like_new, used, junk"}
+	if diff := cmp.Diff(want, verr.Errors); diff != "" {
+		t.Errorf("unexpected errors (-want +got):\n%s", diff)
+	}
+
+	cases := map[string]struct {
+		query string
+		code  int
+		names []string
+	}{
+		"ok: used":           {query: "?condition=used", code: http.StatusOK, names: []string{"pen"}},
+		"ok: new":            {query: "?condition=new", code: http.StatusOK, names: []string{"jacket"}},
+		"ok: all conditions": {query: "", code: http.StatusOK, names: []string{"jacket", "camera", "pen"}},
+		"ng: unknown":        {query: "?condition=broken", code: http.StatusBadRequest},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.GetItems(rr, httptest.NewRequest("GET", "/items"+tt.query, nil))
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				if !strings.Contains(rr.Body.String(), "new, like_new, used, junk") {
+					t.Errorf("expected the valid conditions in the error, got %q", rr.Body.String())
+				}
+				return
+			}
+			var resp GetItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			names := []string{}
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 配送料の負担と発送までの日数
+// 指定しなければ送料込み (出品者負担) で、3日以内に発送する
+
+var errInvalidShippingPayer = errors.New("invalid shipping payer")
+var errInvalidShippingDays = errors.New("invalid shipping days")
+
+// Shipping payers. The seller pays unless the item says otherwise.
+const (
+	shippingPayerSeller = "seller"
+	shippingPayerBuyer  = "buyer"
+)
+
+// shippingPayers are the valid shipping payers in the order shown to clients.
+var shippingPayers = []string{shippingPayerSeller, shippingPayerBuyer}
+
+const (
+	// defaultShippingDays is the days to ship of items added without them.
+	defaultShippingDays = 3
+	// minShippingDays and maxShippingDays bound the days to ship.
+	minShippingDays = 1
+	maxShippingDays = 30
+)
+
+// validateShippingPayer returns errInvalidShippingPayer unless payer is one of shippingPayers.
+func validateShippingPayer(payer string) error {
+	if slices.Contains(shippingPayers, payer) {
+		return nil
+	}
+	return fmt.Errorf("%w: %q (must be one of %s)", errInvalidShippingPayer, payer, strings.Join(shippingPayers, ", "))
+}
+
+// validateShippingDays returns errInvalidShippingDays unless days is between minShippingDays and maxShippingDays.
+func validateShippingDays(days int) error {
+	if days < minShippingDays || days > maxShippingDays {
+		return fmt.Errorf("%w: %d (must be between %d and %d)", errInvalidShippingDays, days, minShippingDays, maxShippingDays)
+	}
+	return nil
+}
+
+// parseShippingDays parses the days to ship. It defaults to defaultShippingDays.
+func parseShippingDays(v string) (int, error) {
+	if v == "" {
+		return defaultShippingDays, nil
+	}
+	days, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not an integer", errInvalidShippingDays, v)
+	}
+	if err := validateShippingDays(days); err != nil {
+		return 0, err
+	}
+	return days, nil
+}
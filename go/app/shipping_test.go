@@ -0,0 +1,193 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseShippingDays(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		days string
+		want int
+		err  error
+	}{
+		"ok: default":     {days: "", want: defaultShippingDays},
+		"ok: one day":     {days: "1", want: 1},
+		"ok: max":         {days: "30", want: maxShippingDays},
+		"ng: zero":        {days: "0", err: errInvalidShippingDays},
+		"ng: too many":    {days: "31", err: errInvalidShippingDays},
+		"ng: not integer": {days: "two", err: errInvalidShippingDays},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseShippingDays(tt.days)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestShippingE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	h := &Handlers{imgDirPath: setupImageDir(t), itemRepo: repo}
+
+	// 更新するのは1番目の商品なので、登録の順番を保つ
+	addCases := []struct {
+		name   string
+		body   string
+		code   int
+		fields []string
+	}{
+		{
+			name: "ok: defaults",
+			body: `{"name":"jacket","category":"fashion","image_name":"default.jpg","price":3000}`,
+			code: http.StatusCreated,
+		},
+		{
+			name: "ok: paid by buyer",
+			body: `{"name":"sofa","category":"furniture","image_name":"default.jpg","price":20000,"shipping_payer":"buyer","shipping_days":14}`,
+			code: http.StatusCreated,
+		},
+		{
+			name:   "ng: invalid shipping",
+			body:   `{"name":"desk","category":"furniture","image_name":"default.jpg","price":5000,"shipping_payer":"courier","shipping_days":31}`,
+			code:   http.StatusUnprocessableEntity,
+			fields: []string{"shipping_days", "shipping_payer"},
+		},
+	}
+	for _, tt := range addCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/items", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			h.AddItem(rr, req)
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.fields == nil {
+				return
+			}
+			var resp FieldErrorsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			fields := slices.Sorted(maps.Keys(resp.Errors))
+			if diff := cmp.Diff(tt.fields, fields); diff != "" {
+				t.Errorf("unexpected fields (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	want := map[string]Item{
+		"jacket": {ShippingPayer: shippingPayerSeller, ShippingDays: defaultShippingDays},
+		"sofa":   {ShippingPayer: shippingPayerBuyer, ShippingDays: 14},
+	}
+	items, err := repo.GetAll(t.Context(), ItemListOptions{})
+	if err != nil {
+		t.Fatalf("failed to get items: %v", err)
+	}
+	for _, item := range items {
+		if item.ShippingPayer != want[item.Name].ShippingPayer || item.ShippingDays != want[item.Name].ShippingDays {
+			t.Errorf("%s: expected shipping (%s, %d), got (%s, %d)", item.Name,
+				want[item.Name].ShippingPayer, want[item.Name].ShippingDays, item.ShippingPayer, item.ShippingDays)
+		}
+	}
+
+	listCases := map[string]struct {
+		target string
+		code   int
+		names  []string
+	}{
+		"ok: paid by seller": {target: "/items?shipping_payer=seller", code: http.StatusOK, names: []string{"jacket"}},
+		"ok: paid by buyer":  {target: "/items?shipping_payer=buyer", code: http.StatusOK, names: []string{"sofa"}},
+		"ng: unknown payer":  {target: "/items?shipping_payer=courier", code: http.StatusBadRequest},
+	}
+	for name, tt := range listCases {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.GetItems(rr, httptest.NewRequest("GET", tt.target, nil))
+			if rr.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var resp GetItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			names := []string{}
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	updateCases := []struct {
+		name    string
+		body    string
+		code    int
+		updated []string
+	}{
+		{name: "change shipping", body: `{"shipping_payer":"buyer","shipping_days":7}`, code: http.StatusOK, updated: []string{"shipping_payer", "shipping_days"}},
+		{name: "invalid payer", body: `{"shipping_payer":"courier"}`, code: http.StatusBadRequest},
+		{name: "invalid days", body: `{"shipping_days":0}`, code: http.StatusBadRequest},
+	}
+	for _, step := range updateCases {
+		req := httptest.NewRequest("PATCH", "/items/1", strings.NewReader(step.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("item_id", "1")
+		rr := httptest.NewRecorder()
+		h.UpdateItem(rr, req)
+		if rr.Code != step.code {
+			t.Fatalf("%s: expected status code %d, got %d: %s", step.name, step.code, rr.Code, rr.Body.String())
+		}
+		if step.code != http.StatusOK {
+			continue
+		}
+		var resp UpdateItemResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", step.name, err)
+		}
+		if diff := cmp.Diff(step.updated, resp.Updated); diff != "" {
+			t.Errorf("%s: unexpected updated fields (-want +got):\n%s", step.name, diff)
+		}
+		if resp.Item.ShippingPayer != shippingPayerBuyer || resp.Item.ShippingDays != 7 {
+			t.Errorf("%s: expected shipping (buyer, 7), got (%s, %d)", step.name, resp.Item.ShippingPayer, resp.Item.ShippingDays)
+		}
+	}
+}
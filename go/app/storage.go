@@ -0,0 +1,90 @@
+package app
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultStorageStatsTTL is how long the result of walking the image directory is reused.
+const defaultStorageStatsTTL = 30 * time.Second
+
+// StorageFile is a single file in the image directory.
+type StorageFile struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+}
+
+// StorageStats is the storage footprint of the image directory.
+type StorageStats struct {
+	ImageCount  int          `json:"image_count"`
+	TotalBytes  int64        `json:"total_bytes"`
+	LargestFile *StorageFile `json:"largest_file"`
+}
+
+// storageStatsCache caches StorageStats for a short time,
+// since walking a large image directory on every request is expensive.
+type storageStatsCache struct {
+	mu        sync.Mutex
+	stats     StorageStats
+	fetchedAt time.Time
+	// fetched is false until the first walk, so that the zero fetchedAt is not mistaken for a fresh result.
+	fetched bool
+}
+
+// get returns the cached stats of dir, walking the directory again when the cache is older than ttl at now.
+// ttl is given on each call so that a change of the flag takes effect immediately.
+func (c *storageStatsCache) get(dir string, now time.Time, ttl time.Duration) (StorageStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.fetched && now.Before(c.fetchedAt.Add(ttl)) {
+		return c.stats, nil
+	}
+	stats, err := collectStorageStats(dir)
+	if err != nil {
+		return StorageStats{}, err
+	}
+	c.stats = stats
+	c.fetchedAt = now
+	c.fetched = true
+	return stats, nil
+}
+
+// collectStorageStats walks dir and sums up the sizes of the image files in it.
+func collectStorageStats(dir string) (StorageStats, error) {
+	var stats StorageStats
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isImageFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stats.ImageCount++
+		stats.TotalBytes += info.Size()
+		if stats.LargestFile == nil || info.Size() > stats.LargestFile.Bytes {
+			stats.LargestFile = &StorageFile{Name: d.Name(), Bytes: info.Size()}
+		}
+		return nil
+	})
+	if err != nil {
+		return StorageStats{}, err
+	}
+	return stats, nil
+}
+
+// isImageFile reports whether name looks like a stored image (hidden and temporary files are skipped).
+func isImageFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".jpg" || ext == ".jpeg"
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mercari-build-training/app/apptest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCollectStorageStats(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		files map[string]int
+		want  StorageStats
+	}{
+		"ok: empty directory": {
+			files: map[string]int{},
+			want:  StorageStats{},
+		},
+		"ok: images are summed and other files are skipped": {
+			files: map[string]int{
+				"a.jpg":      10,
+				"b.jpg":      30,
+				"c.jpeg":     20,
+				".gitignore": 100,
+				"notes.txt":  100,
+			},
+			want: StorageStats{
+				ImageCount:  3,
+				TotalBytes:  60,
+				LargestFile: &StorageFile{Name: "b.jpg", Bytes: 30},
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for fileName, size := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, fileName), make([]byte, size), 0644); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
+			}
+
+			got, err := collectStorageStats(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected stats (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStorageStatsCache(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.jpg"), make([]byte, 10), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	clock := apptest.NewFakeClock(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	c := &storageStatsCache{}
+	if _, err := c.get(dir, clock.Now(), time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.jpg"), make([]byte, 10), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	// キャッシュの有効期間内は、ファイルが増えても前の結果を返す
+	clock.Advance(time.Minute - time.Second)
+	got, err := c.get(dir, clock.Now(), time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ImageCount != 1 {
+		t.Errorf("expected cached image count 1, got %d", got.ImageCount)
+	}
+
+	// 有効期間が過ぎたら、ディレクトリを見直す
+	clock.Advance(time.Second)
+	got, err = c.get(dir, clock.Now(), time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ImageCount != 2 {
+		t.Errorf("expected refreshed image count 2, got %d", got.ImageCount)
+	}
+}
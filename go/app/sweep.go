@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 使われなくなった画像の定期的な掃除
+// 画像ディレクトリのファイルと商品の image_name を突き合わせて、どの商品も使っていない画像を縮小版と一緒に消す
+// アップロードされてから商品が登録されるまでの間の画像を消さないように、更新日時が猶予期間より新しいファイルは残す
+// (storeImage は既存の画像を使い直すときに更新日時を新しくする)
+
+const (
+	// defaultImageSweepInterval is the default of the image_sweep_interval flag.
+	defaultImageSweepInterval = time.Hour
+	// defaultImageSweepGrace is the default of the image_sweep_grace flag.
+	defaultImageSweepGrace = 24 * time.Hour
+	// tempFilePrefix is the prefix of the temporary files of writeFileAtomic, left behind only by a crash.
+	tempFilePrefix = ".tmp-"
+)
+
+// sweepOrphanImages removes the images no item references that were last modified before grace ago, with their
+// scaled versions, as well as scaled versions and temporary files left without their image. default.jpg is never removed.
+// It returns the number of files removed.
+func (s *Handlers) sweepOrphanImages(ctx context.Context, grace time.Duration) (int, error) {
+	names, err := s.itemRepo.GetImageNames(ctx)
+	if err != nil {
+		return 0, err
+	}
+	referenced := make(map[string]bool, len(names))
+	for _, name := range names {
+		referenced[filepath.Base(name)] = true
+	}
+	cutoff := s.now().Add(-grace)
+
+	removed := 0
+	var errs []error
+	remove := func(path string) {
+		if err := os.Remove(path); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			return
+		}
+		removed++
+	}
+
+	// 先に画像を消し、縮小版は元の画像が残っているかで判断する
+	entries, err := os.ReadDir(s.imgDirPath)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !oldFile(e, cutoff) {
+			continue
+		}
+		name := e.Name()
+		switch {
+		case strings.HasPrefix(name, tempFilePrefix):
+			remove(filepath.Join(s.imgDirPath, name))
+		case isImageFile(name) && name != defaultImageName && !referenced[name]:
+			remove(filepath.Join(s.imgDirPath, name))
+		}
+	}
+
+	variants, err := os.ReadDir(filepath.Join(s.imgDirPath, variantDirName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return removed, err
+	}
+	for _, e := range variants {
+		if e.IsDir() || !oldFile(e, cutoff) {
+			continue
+		}
+		name := e.Name()
+		if strings.HasPrefix(name, tempFilePrefix) || !s.hasOriginalImage(name) {
+			remove(filepath.Join(s.imgDirPath, variantDirName, name))
+		}
+	}
+
+	return removed, errors.Join(errs...)
+}
+
+// hasOriginalImage reports whether the image a scaled version, named "<name>_w<width>.jpg", was made from still exists.
+func (s *Handlers) hasOriginalImage(variantName string) bool {
+	base := strings.TrimSuffix(variantName, filepath.Ext(variantName))
+	i := strings.LastIndex(base, "_w")
+	if i < 0 {
+		return false
+	}
+	for _, ext := range []string{".jpg", ".jpeg"} {
+		if _, err := os.Stat(filepath.Join(s.imgDirPath, base[:i]+ext)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// oldFile reports whether the file was last modified before cutoff. Files that cannot be inspected are not old.
+func oldFile(e os.DirEntry, cutoff time.Time) bool {
+	info, err := e.Info()
+	return err == nil && info.ModTime().Before(cutoff)
+}
+
+// runImageSweeper sweeps orphaned images every interval until ctx is done.
+// The grace period is read from the image_sweep_grace flag on every sweep.
+func (s *Handlers) runImageSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			removed, err := s.sweepOrphanImages(ctx, s.flags.Duration(flagImageSweepGrace))
+			if err != nil {
+				slog.Error("failed to sweep orphaned images: ", "error", err, "removed", removed)
+				continue
+			}
+			slog.Info("swept orphaned images", "removed", removed)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
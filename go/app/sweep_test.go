@@ -0,0 +1,140 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mercari-build-training/app/apptest"
+)
+
+func TestSweepOrphanImagesE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "jacket", Category: "fashion", Image: "used.jpg"},
+		{Name: "coat", Category: "fashion", Image: "deleted.jpg"},
+		{Name: "pen", Category: "stationery", Image: defaultImageName},
+	} {
+		if err := repo.Insert(t.Context(), item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+	// 論理削除された商品も画像を使っている
+	if err := repo.SoftDelete(t.Context(), "2"); err != nil {
+		t.Fatalf("failed to delete item: %v", err)
+	}
+
+	now := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	old := now.Add(-defaultImageSweepGrace - time.Minute)
+	fresh := now.Add(-time.Minute)
+
+	dir := setupImageDir(t)
+	// ファイル名 -> 更新日時と、掃除の後に残っているべきか
+	files := map[string]struct {
+		modTime time.Time
+		kept    bool
+	}{
+		"used.jpg":                   {modTime: old, kept: true},
+		"deleted.jpg":                {modTime: old, kept: true},
+		"orphan.jpg":                 {modTime: old, kept: false},
+		"orphan.jpeg":                {modTime: old, kept: false},
+		"uploading.jpg":              {modTime: fresh, kept: true},
+		defaultImageName:             {modTime: old, kept: true},
+		"notes.txt":                  {modTime: old, kept: true},
+		".tmp-123":                   {modTime: old, kept: false},
+		".tmp-456":                   {modTime: fresh, kept: true},
+		"sub/orphan.jpg":             {modTime: old, kept: true},
+		".variants/used_w100.jpg":    {modTime: old, kept: true},
+		".variants/default_w100.jpg": {modTime: old, kept: true},
+		".variants/orphan_w100.jpg":  {modTime: old, kept: false},
+		".variants/gone_w300.jpg":    {modTime: old, kept: false},
+		".variants/gone_w400.jpg":    {modTime: fresh, kept: true},
+	}
+	for name, f := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if name != defaultImageName {
+			if err := os.WriteFile(path, []byte("image"), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+		}
+		if err := os.Chtimes(path, f.modTime, f.modTime); err != nil {
+			t.Fatalf("failed to set the modification time: %v", err)
+		}
+	}
+
+	h := &Handlers{imgDirPath: dir, itemRepo: repo, clock: apptest.NewFakeClock(now)}
+	removed, err := h.sweepOrphanImages(t.Context(), defaultImageSweepGrace)
+	if err != nil {
+		t.Fatalf("failed to sweep images: %v", err)
+	}
+
+	want := 0
+	for name, f := range files {
+		if !f.kept {
+			want++
+		}
+		_, err := os.Stat(filepath.Join(dir, name))
+		if got := err == nil; got != f.kept {
+			t.Errorf("expected %s to be kept %v, got %v", name, f.kept, got)
+		}
+	}
+	if removed != want {
+		t.Errorf("expected %d files to be removed, got %d", want, removed)
+	}
+
+	// 2回目は何も消さない
+	removed, err = h.sweepOrphanImages(t.Context(), defaultImageSweepGrace)
+	if err != nil {
+		t.Fatalf("failed to sweep images again: %v", err)
+	}
+	if removed != 0 {
+		t.Errorf("expected nothing to be removed by the second sweep, got %d", removed)
+	}
+}
+
+func TestStoreImageTouchesReusedImage(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	h := &Handlers{imgDirPath: t.TempDir(), clock: apptest.NewFakeClock(now)}
+	image := []byte("image")
+
+	path, err := h.storeImage(image)
+	if err != nil {
+		t.Fatalf("failed to store image: %v", err)
+	}
+	old := now.Add(-defaultImageSweepGrace - time.Minute)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("failed to set the modification time: %v", err)
+	}
+
+	// 同じ画像をもう一度アップロードすると、掃除の猶予期間がやり直しになる
+	if _, err := h.storeImage(image); err != nil {
+		t.Fatalf("failed to store image again: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat image: %v", err)
+	}
+	if !info.ModTime().Equal(now) {
+		t.Errorf("expected the modification time %v, got %v", now, info.ModTime())
+	}
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 商品のタグ ("vintage", "limited" など)
+// カテゴリと違って1つの商品に複数付けられる。小文字に揃えて重複を除く
+
+const (
+	// maxTagsPerItem is the maximum number of tags of an item.
+	maxTagsPerItem = 10
+	// maxTagChars is the maximum length of a tag in characters.
+	maxTagChars = 30
+)
+
+// normalizeTag lowercases and trims a tag.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// parseTags parses a comma-separated list of tags such as "Vintage, limited".
+// The tags are normalized, empty ones and duplicates are dropped, and the rest is sorted.
+// Problems are recorded on v as errors of the tags field. It returns nil when there are no tags.
+func parseTags(v *validator, raw string) []string {
+	var tags []string
+	for _, tag := range strings.Split(raw, ",") {
+		tag = normalizeTag(tag)
+		if tag == "" || slices.Contains(tags, tag) {
+			continue
+		}
+		switch {
+		case !utf8.ValidString(tag):
+			v.add("tags", "must be valid UTF-8", nil)
+			return nil
+		case utf8.RuneCountInString(tag) > maxTagChars:
+			v.add("tags", fmt.Sprintf("each tag must be at most %d characters", maxTagChars), nil)
+			return nil
+		case strings.ContainsFunc(tag, unicode.IsControl):
+			v.add("tags", "must not contain control characters", nil)
+			return nil
+		}
+		tags = append(tags, tag)
+	}
+	if len(tags) > maxTagsPerItem {
+		v.add("tags", fmt.Sprintf("must have at most %d tags", maxTagsPerItem), nil)
+		return nil
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// splitTags splits the tags aggregated with GROUP_CONCAT and sorts them. It never returns nil.
+func splitTags(concat string) []string {
+	if concat == "" {
+		return []string{}
+	}
+	tags := strings.Split(concat, ",")
+	slices.Sort(tags)
+	return tags
+}
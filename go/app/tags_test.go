@@ -0,0 +1,156 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseTags(t *testing.T) {
+	t.Parallel()
+
+	many := make([]string, maxTagsPerItem+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("tag%d", i)
+	}
+
+	cases := map[string]struct {
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		"ok: empty": {
+			raw:  "",
+			want: nil,
+		},
+		"ok: normalized and deduplicated": {
+			raw:  " Vintage,limited,, VINTAGE ,レア",
+			want: []string{"limited", "vintage", "レア"},
+		},
+		"ok: duplicates do not count toward the limit": {
+			raw:  strings.Repeat("vintage,", maxTagsPerItem+5),
+			want: []string{"vintage"},
+		},
+		"ng: too many tags": {
+			raw:     strings.Join(many, ","),
+			wantErr: true,
+		},
+		"ng: too long tag": {
+			raw:     strings.Repeat("a", maxTagChars+1),
+			wantErr: true,
+		},
+		"ng: control character": {
+			raw:     "vin\ttage",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var v validator
+			got := parseTags(&v, tt.raw)
+			if err := v.err(); (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected tags (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestItemTagsE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	h := &Handlers{imgDirPath: "../images", itemRepo: repo}
+
+	add := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.AddItem(rr, req)
+		return rr
+	}
+	for _, body := range []string{
+		`{"name":"jacket","category":"fashion","image_name":"default.jpg","price":3000,"tags":"Vintage, limited"}`,
+		`{"name":"camera","category":"electronics","image_name":"default.jpg","price":20000,"tags":"vintage"}`,
+		`{"name":"pen","category":"stationery","image_name":"default.jpg","price":100}`,
+	} {
+		if rr := add(body); rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+	}
+	many := "a,b,c,d,e,f,g,h,i,j,k"
+	if rr := add(`{"name":"hat","category":"fashion","image_name":"default.jpg","price":100,"tags":"` + many + `"}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status code %d for too many tags, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+
+	list := func(query string) map[string][]string {
+		rr := httptest.NewRecorder()
+		h.GetItems(rr, httptest.NewRequest("GET", "/items"+query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp GetItemsResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		got := map[string][]string{}
+		for _, item := range resp.Items {
+			got[item.Name] = item.Tags
+		}
+		return got
+	}
+
+	cases := map[string]struct {
+		query string
+		want  map[string][]string
+	}{
+		"ok: all items with tags": {
+			query: "",
+			want:  map[string][]string{"jacket": {"limited", "vintage"}, "camera": {"vintage"}, "pen": {}},
+		},
+		"ok: filter by tag": {
+			query: "?tag=vintage",
+			want:  map[string][]string{"jacket": {"limited", "vintage"}, "camera": {"vintage"}},
+		},
+		"ok: filter is case-insensitive": {
+			query: "?tag=LIMITED",
+			want:  map[string][]string{"jacket": {"limited", "vintage"}},
+		},
+		"ok: unknown tag": {
+			query: "?tag=rare",
+			want:  map[string][]string{},
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, list(tt.query)); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
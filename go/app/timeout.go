@@ -0,0 +1,255 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// リクエストのDB呼び出しに時間制限をかける
+// SQLiteのクエリが詰まっても、goroutineがずっと待ち続けないようにする
+
+// defaultDBTimeout is the default of the db_timeout flag.
+const defaultDBTimeout = 5 * time.Second
+
+// timeoutItemRepository is an ItemRepository that cancels each call after a timeout.
+// Methods added to ItemRepository must also be overridden here, otherwise they run without a timeout.
+type timeoutItemRepository struct {
+	ItemRepository
+	// timeout returns the current limit. 0 or less disables it.
+	timeout func() time.Duration
+}
+
+// newTimeoutItemRepository wraps repo so that each call is cancelled when it takes longer than timeout().
+func newTimeoutItemRepository(repo ItemRepository, timeout func() time.Duration) *timeoutItemRepository {
+	return &timeoutItemRepository{ItemRepository: repo, timeout: timeout}
+}
+
+// withTimeout returns ctx with the current timeout applied.
+func (t *timeoutItemRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	d := t.timeout()
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// errorStatus returns the status code for an unexpected error of a handler:
+// 503 when the database did not answer in time, and 500 otherwise.
+func errorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func (t *timeoutItemRepository) Insert(ctx context.Context, item *Item) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.Insert(ctx, item)
+}
+
+func (t *timeoutItemRepository) InsertMany(ctx context.Context, items []*Item) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.InsertMany(ctx, items)
+}
+
+func (t *timeoutItemRepository) GetAll(ctx context.Context, opts ItemListOptions) ([]Item, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.GetAll(ctx, opts)
+}
+
+func (t *timeoutItemRepository) Count(ctx context.Context, opts ItemListOptions) (int, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.Count(ctx, opts)
+}
+
+func (t *timeoutItemRepository) GetPage(ctx context.Context, opts ItemListOptions) ([]Item, int, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.GetPage(ctx, opts)
+}
+
+func (t *timeoutItemRepository) GetItemById(ctx context.Context, item_id string) (Item, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.GetItemById(ctx, item_id)
+}
+
+func (t *timeoutItemRepository) GetItemsAfter(ctx context.Context, afterID, limit int) ([]Item, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.GetItemsAfter(ctx, afterID, limit)
+}
+
+func (t *timeoutItemRepository) GetItemByImage(ctx context.Context, imageName string) (Item, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.GetItemByImage(ctx, imageName)
+}
+
+func (t *timeoutItemRepository) CountItemsUsingImage(ctx context.Context, imageName string) (int, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.CountItemsUsingImage(ctx, imageName)
+}
+
+func (t *timeoutItemRepository) GetImageNames(ctx context.Context) ([]string, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.GetImageNames(ctx)
+}
+
+func (t *timeoutItemRepository) GetCategoryItems(ctx context.Context, category string, excludeID, limit int) ([]Item, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.GetCategoryItems(ctx, category, excludeID, limit)
+}
+
+func (t *timeoutItemRepository) GetRelatedItems(ctx context.Context, item_id string, limit int) ([]Item, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.GetRelatedItems(ctx, item_id, limit)
+}
+
+func (t *timeoutItemRepository) GetItemsBySeller(ctx context.Context, seller string) ([]Item, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.GetItemsBySeller(ctx, seller)
+}
+
+func (t *timeoutItemRepository) GetRecentItems(ctx context.Context, limit int) ([]Item, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.GetRecentItems(ctx, limit)
+}
+
+func (t *timeoutItemRepository) SearchItemsByKeyword(ctx context.Context, filter SearchFilter, fn func(Item) error) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.SearchItemsByKeyword(ctx, filter, fn)
+}
+
+func (t *timeoutItemRepository) CountItemsByKeyword(ctx context.Context, filter SearchFilter) (int, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.CountItemsByKeyword(ctx, filter)
+}
+
+func (t *timeoutItemRepository) CheckCategoryHealth(ctx context.Context) ([]HealthIssue, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.CheckCategoryHealth(ctx)
+}
+
+func (t *timeoutItemRepository) SoftDelete(ctx context.Context, item_id string) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.SoftDelete(ctx, item_id)
+}
+
+func (t *timeoutItemRepository) Restore(ctx context.Context, item_id string) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.Restore(ctx, item_id)
+}
+
+func (t *timeoutItemRepository) Publish(ctx context.Context, item_id string) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.Publish(ctx, item_id)
+}
+
+func (t *timeoutItemRepository) Purge(ctx context.Context, item_id string) (string, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.Purge(ctx, item_id)
+}
+
+func (t *timeoutItemRepository) Ping(ctx context.Context) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.Ping(ctx)
+}
+
+func (t *timeoutItemRepository) SampleByCategory(ctx context.Context, perCategory int) ([]Item, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.SampleByCategory(ctx, perCategory)
+}
+
+func (t *timeoutItemRepository) Purchase(ctx context.Context, item_id string) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.Purchase(ctx, item_id)
+}
+
+func (t *timeoutItemRepository) Update(ctx context.Context, item_id string, patch ItemPatch) (Item, Item, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.Update(ctx, item_id, patch)
+}
+
+func (t *timeoutItemRepository) GetItemHistory(ctx context.Context, item_id string) ([]ItemEvent, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.GetItemHistory(ctx, item_id)
+}
+
+func (t *timeoutItemRepository) AddFavorite(ctx context.Context, item_id string, clientToken string) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.AddFavorite(ctx, item_id, clientToken)
+}
+
+func (t *timeoutItemRepository) RemoveFavorite(ctx context.Context, item_id string, clientToken string) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.RemoveFavorite(ctx, item_id, clientToken)
+}
+
+func (t *timeoutItemRepository) GetFavorites(ctx context.Context, clientToken string) ([]Item, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.GetFavorites(ctx, clientToken)
+}
+
+func (t *timeoutItemRepository) AddViews(ctx context.Context, views map[int]int) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.AddViews(ctx, views)
+}
+
+func (t *timeoutItemRepository) Reorder(ctx context.Context, ids []int) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.Reorder(ctx, ids)
+}
+
+func (t *timeoutItemRepository) Swap(ctx context.Context, a, b int) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.Swap(ctx, a, b)
+}
+
+func (t *timeoutItemRepository) GetCategories(ctx context.Context) ([]Category, int64, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.GetCategories(ctx)
+}
+
+func (t *timeoutItemRepository) DeleteCategory(ctx context.Context, id int, fallback string) (int, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.DeleteCategory(ctx, id, fallback)
+}
+
+func (t *timeoutItemRepository) CategoriesVersion(ctx context.Context) (int64, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return t.ItemRepository.CategoriesVersion(ctx)
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestTimeoutItemRepository(t *testing.T) {
+	t.Parallel()
+
+	// ctxがキャンセルされるまで返らない、詰まったクエリの代わり
+	block := func(ctx context.Context, _ string) (Item, error) {
+		<-ctx.Done()
+		return Item{}, ctx.Err()
+	}
+
+	cases := map[string]struct {
+		timeout  time.Duration
+		injector func(m *MockItemRepository)
+		code     int
+	}{
+		"ok: answered in time": {
+			timeout: time.Second,
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().GetItemById(gomock.Any(), "1").Return(Item{ID: 1, Name: "jacket"}, nil)
+			},
+			code: http.StatusOK,
+		},
+		"ng: timed out": {
+			timeout: 10 * time.Millisecond,
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().GetItemById(gomock.Any(), "1").DoAndReturn(block)
+			},
+			code: http.StatusServiceUnavailable,
+		},
+		"ok: disabled": {
+			timeout: 0,
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().GetItemById(gomock.Any(), "1").DoAndReturn(func(ctx context.Context, _ string) (Item, error) {
+					if _, ok := ctx.Deadline(); ok {
+						t.Error("expected no deadline when the timeout is disabled")
+					}
+					return Item{ID: 1, Name: "jacket"}, nil
+				})
+			},
+			code: http.StatusOK,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			tt.injector(mockIR)
+			repo := newTimeoutItemRepository(mockIR, func() time.Duration { return tt.timeout })
+			h := &Handlers{itemRepo: repo}
+
+			req := httptest.NewRequest("GET", "/items/1", nil)
+			req.SetPathValue("item_id", "1")
+			rr := httptest.NewRecorder()
+			h.GetItemById(rr, req)
+
+			if rr.Code != tt.code {
+				t.Errorf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		err  error
+		want int
+	}{
+		"deadline exceeded": {err: context.DeadlineExceeded, want: http.StatusServiceUnavailable},
+		"wrapped deadline":  {err: &writeErr{context.DeadlineExceeded}, want: http.StatusServiceUnavailable},
+		"canceled":          {err: context.Canceled, want: http.StatusInternalServerError},
+		"other":             {err: errItemSold, want: http.StatusInternalServerError},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := errorStatus(tt.err); got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+// writeErr wraps an error like the database driver does.
+type writeErr struct{ err error }
+
+func (e *writeErr) Error() string { return "write failed: " + e.err.Error() }
+func (e *writeErr) Unwrap() error { return e.err }
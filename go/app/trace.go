@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// リクエストごとの処理時間の内訳(チェックポイント)を記録する仕組み
+// 遅いリクエストのときだけログに出力し、速いリクエストでは捨てる
+
+// span is a single timing checkpoint recorded during a request.
+type span struct {
+	Name     string
+	Duration time.Duration
+}
+
+// requestTrace collects the checkpoints and the db query names of a request.
+// It is pooled so that fast requests do not allocate a new one each time.
+// mu guards spans and queries, since a handler may run queries in parallel goroutines.
+type requestTrace struct {
+	mu      sync.Mutex
+	start   time.Time
+	last    time.Time
+	spans   []span
+	queries []string
+}
+
+var tracePool = sync.Pool{
+	New: func() any { return &requestTrace{} },
+}
+
+type traceKey struct{}
+
+// startTrace attaches a pooled requestTrace to the context.
+// The returned trace must be released with releaseTrace when the request is done.
+func startTrace(ctx context.Context) (context.Context, *requestTrace) {
+	t := tracePool.Get().(*requestTrace)
+	now := time.Now()
+	t.start = now
+	t.last = now
+	return context.WithValue(ctx, traceKey{}, t), t
+}
+
+// releaseTrace resets the trace and returns it to the pool.
+// スライスは長さだけ0に戻して、確保済みの容量を再利用する
+func releaseTrace(t *requestTrace) {
+	t.spans = t.spans[:0]
+	t.queries = t.queries[:0]
+	tracePool.Put(t)
+}
+
+// checkpoint records the time elapsed since the previous checkpoint under the given name.
+// It does nothing if the context has no trace (e.g. in unit tests calling handlers directly).
+func checkpoint(ctx context.Context, name string) {
+	t, ok := ctx.Value(traceKey{}).(*requestTrace)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.spans = append(t.spans, span{Name: name, Duration: now.Sub(t.last)})
+	t.last = now
+}
+
+// traceQuery records the name of a db query executed during the request.
+func traceQuery(ctx context.Context, name string) {
+	t, ok := ctx.Value(traceKey{}).(*requestTrace)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	t.queries = append(t.queries, name)
+	t.mu.Unlock()
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+)
+
+// setLogOutput replaces the default logger for the duration of the test.
+// slog.SetDefault is global, so tests using this helper must not run in parallel.
+func setLogOutput(t *testing.T, h slog.Handler) {
+	t.Helper()
+
+	prev := slog.Default()
+	slog.SetDefault(slog.New(h))
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+	})
+}
+
+func TestSlowRequestBreakdown(t *testing.T) {
+	cases := map[string]struct {
+		delay time.Duration
+		wants bool
+	}{
+		"ok: slow request emits breakdown": {
+			delay: 50 * time.Millisecond,
+			wants: true,
+		},
+		"ok: fast request discards breakdown": {
+			delay: 0,
+			wants: false,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			setLogOutput(t, slog.NewJSONHandler(&buf, nil))
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			// sleeping mock repository
+			mockIR.EXPECT().GetPage(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, _ ItemListOptions) ([]Item, int, error) {
+				time.Sleep(tt.delay)
+				traceQuery(ctx, "items.get_all")
+				return []Item{}, 0, nil
+			})
+			h := &Handlers{itemRepo: mockIR}
+
+			handler := simpleLoggerMiddleware(http.HandlerFunc(h.GetItems), func() time.Duration { return 20 * time.Millisecond })
+			req := httptest.NewRequest("GET", "/items", nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+			}
+
+			logs := buf.String()
+			if got := strings.Contains(logs, `"msg":"slow request"`); got != tt.wants {
+				t.Fatalf("expected slow request log to be %v, got logs: %s", tt.wants, logs)
+			}
+			if !tt.wants {
+				return
+			}
+			for _, want := range []string{`"name":"db"`, `"name":"encode"`, `"queries":["items.get_all"]`} {
+				if !strings.Contains(logs, want) {
+					t.Errorf("expected breakdown to contain %s, got logs: %s", want, logs)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckpointAllocs(t *testing.T) {
+	ctx, trace := startTrace(context.Background())
+	defer releaseTrace(trace)
+
+	// スライスの容量が確保された後は、チェックポイントを記録してもアロケーションが発生しない
+	allocs := testing.AllocsPerRun(100, func() {
+		checkpoint(ctx, "parse")
+		checkpoint(ctx, "db")
+		traceQuery(ctx, "items.get_all")
+		checkpoint(ctx, "encode")
+		trace.spans = trace.spans[:0]
+		trace.queries = trace.queries[:0]
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations per request, got %v", allocs)
+	}
+}
+
+func BenchmarkSimpleLoggerMiddlewareFastPath(b *testing.B) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	b.Cleanup(func() {
+		slog.SetDefault(prev)
+	})
+
+	handler := simpleLoggerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checkpoint(r.Context(), "parse")
+		traceQuery(r.Context(), "items.get_all")
+		checkpoint(r.Context(), "db")
+		checkpoint(r.Context(), "encode")
+	}), func() time.Duration { return time.Hour })
+	req := httptest.NewRequest("GET", "/items", nil)
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		handler.ServeHTTP(w, req)
+	}
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 商品の部分更新 (PATCH /items/{item_id})
+// 指定された項目のうち、実際に変わったものと変わらなかったものをレスポンスで返す
+
+// updateItemJSONRequest is the body of PATCH /items/{item_id}. Omitted fields are left as they are.
+type updateItemJSONRequest struct {
+	Name     *string `json:"name"`
+	Category *string `json:"category"`
+	Status   *string `json:"status"`
+	// json.Numberにしておき、価格の検証はPOST /itemsと同じparsePriceで行う
+	Price         *json.Number `json:"price"`
+	ShippingPayer *string      `json:"shipping_payer"`
+	ShippingDays  *json.Number `json:"shipping_days"`
+}
+
+type UpdateItemResponse struct {
+	Item Item `json:"item"`
+	// Updated and Unchanged are the requested fields whose value changed and did not change.
+	Updated   []string `json:"updated"`
+	Unchanged []string `json:"unchanged"`
+}
+
+// updatableFields are the fields of PATCH /items/{item_id} in the order of the response,
+// with the value of each field to compare before and after the update.
+var updatableFields = []struct {
+	name  string
+	value func(Item) string
+}{
+	{"name", func(item Item) string { return item.Name }},
+	{"category", func(item Item) string { return item.Category }},
+	{"status", func(item Item) string { return item.Status }},
+	{"price", func(item Item) string { return strconv.Itoa(item.Price.Amount) }},
+	{"shipping_payer", func(item Item) string { return item.ShippingPayer }},
+	{"shipping_days", func(item Item) string { return strconv.Itoa(item.ShippingDays) }},
+}
+
+// parseUpdateItemRequest parses and validates the body of PATCH /items/{item_id}.
+// It returns the patch and the names of the fields it sets.
+func parseUpdateItemRequest(r *http.Request) (ItemPatch, []string, error) {
+	var body updateItemJSONRequest
+	if err := decodeJSONBody(r, defaultJSONLimits, &body); err != nil {
+		return ItemPatch{}, nil, err
+	}
+
+	var patch ItemPatch
+	var fields []string
+	var v validator
+	if body.Name != nil {
+		v.text("name", *body.Name, maxItemNameChars)
+		patch.Name = body.Name
+		fields = append(fields, "name")
+	}
+	if body.Category != nil {
+		v.text("category", *body.Category, maxCategoryChars)
+		patch.Category = body.Category
+		fields = append(fields, "category")
+	}
+	if body.Status != nil {
+		if err := validateItemStatus(*body.Status); err != nil {
+			v.add("status", fmt.Sprintf("must be %s or %s", itemStatusOnSale, itemStatusSold), err)
+		}
+		patch.Status = body.Status
+		fields = append(fields, "status")
+	}
+	if body.Price != nil {
+		if p, err := parsePrice(body.Price.String()); err != nil {
+			v.add("price", "must be a non-negative integer", err)
+		} else {
+			patch.Price = &p
+		}
+		fields = append(fields, "price")
+	}
+	if body.ShippingPayer != nil {
+		if err := validateShippingPayer(*body.ShippingPayer); err != nil {
+			v.add("shipping_payer", "must be one of "+strings.Join(shippingPayers, ", "), err)
+		}
+		patch.ShippingPayer = body.ShippingPayer
+		fields = append(fields, "shipping_payer")
+	}
+	if body.ShippingDays != nil {
+		// 更新では省略の意味がないので、空は既定値にせずエラーにする
+		if d, err := strconv.Atoi(body.ShippingDays.String()); err != nil || validateShippingDays(d) != nil {
+			v.add("shipping_days", fmt.Sprintf("must be an integer between %d and %d", minShippingDays, maxShippingDays), errInvalidShippingDays)
+		} else {
+			patch.ShippingDays = &d
+		}
+		fields = append(fields, "shipping_days")
+	}
+	if len(fields) == 0 {
+		v.add("body", "at least one of name, category, status, price, shipping_payer and shipping_days is required", nil)
+	}
+	if err := v.err(); err != nil {
+		return ItemPatch{}, nil, err
+	}
+	return patch, fields, nil
+}
+
+// changedFields splits the requested fields into the ones whose value differs between before and after and the others.
+func changedFields(before, after Item, requested []string) (updated, unchanged []string) {
+	updated, unchanged = []string{}, []string{}
+	for _, f := range updatableFields {
+		if !slices.Contains(requested, f.name) {
+			continue
+		}
+		if f.value(before) != f.value(after) {
+			updated = append(updated, f.name)
+		} else {
+			unchanged = append(unchanged, f.name)
+		}
+	}
+	return updated, unchanged
+}
+
+// UpdateItem is a handler to change some fields of an item for PATCH /items/{item_id} .
+// The response tells which of the requested fields changed, compared within the update transaction.
+// The changes are recorded in the history of the item as made by the X-Actor header.
+func (s *Handlers) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGetItemByIdRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	patch, fields, err := parseUpdateItemRequest(r)
+	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			writeValidationError(w, validationErr)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	actor, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkpoint(r.Context(), "parse")
+
+	before, after, err := s.itemRepo.Update(withActor(r.Context(), actor), req.Id, patch)
+	if err != nil {
+		if errors.Is(err, errItemNotFound) {
+			slog.Warn("item not exist: ", "error", err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to update item: ", "error", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	checkpoint(r.Context(), "db")
+
+	updated, unchanged := changedFields(before, after, fields)
+	slog.Info("item updated", "id", req.Id, "updated", updated)
+	s.setCategoriesVersionHeader(r.Context(), w)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(UpdateItemResponse{Item: after, Updated: updated, Unchanged: unchanged}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checkpoint(r.Context(), "encode")
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"mercari-build-training/app/apptest"
+)
+
+func TestUpdateItemE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	created := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	clock := apptest.NewFakeClock(created)
+	repo := &itemRepository{db: db, clock: clock}
+	if err := repo.Insert(t.Context(), &Item{Name: "jacket", Category: "fashion", Image: "default.jpg", Price: Price{Amount: 3000}}); err != nil {
+		t.Fatalf("failed to insert item: %v", err)
+	}
+	h := &Handlers{itemRepo: repo}
+
+	patch := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/items/"+id, strings.NewReader(body))
+		req.SetPathValue("item_id", id)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.UpdateItem(rr, req)
+		return rr
+	}
+	decode := func(rr *httptest.ResponseRecorder) UpdateItemResponse {
+		t.Helper()
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp UpdateItemResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	// nameだけが変わり、categoryは同じ値
+	clock.Advance(time.Hour)
+	resp := decode(patch("1", `{"name":"coat","category":"fashion"}`))
+	if diff := cmp.Diff([]string{"name"}, resp.Updated); diff != "" {
+		t.Errorf("unexpected updated fields (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"category"}, resp.Unchanged); diff != "" {
+		t.Errorf("unexpected unchanged fields (-want +got):\n%s", diff)
+	}
+	if resp.Item.Name != "coat" || resp.Item.Category != "fashion" || resp.Item.Price.Amount != 3000 {
+		t.Errorf("unexpected item: %+v", resp.Item)
+	}
+	if want := created.Add(time.Hour); !resp.Item.UpdatedAt.Equal(want) {
+		t.Errorf("expected updated_at %v, got %v", want, resp.Item.UpdatedAt)
+	}
+
+	// 何も変わらなければupdated_atも変えない
+	clock.Advance(time.Hour)
+	resp = decode(patch("1", `{"name":"coat","price":3000}`))
+	if diff := cmp.Diff(UpdateItemResponse{Item: resp.Item, Updated: []string{}, Unchanged: []string{"name", "price"}}, resp); diff != "" {
+		t.Errorf("unexpected response (-want +got):\n%s", diff)
+	}
+	if want := created.Add(time.Hour); !resp.Item.UpdatedAt.Equal(want) {
+		t.Errorf("expected updated_at to stay %v, got %v", want, resp.Item.UpdatedAt)
+	}
+
+	// 新しいカテゴリにも変えられる
+	resp = decode(patch("1", `{"category":"outer","status":"sold","price":2500}`))
+	if diff := cmp.Diff([]string{"category", "status", "price"}, resp.Updated); diff != "" {
+		t.Errorf("unexpected updated fields (-want +got):\n%s", diff)
+	}
+	item, err := repo.GetItemById(t.Context(), "1")
+	if err != nil {
+		t.Fatalf("failed to get item: %v", err)
+	}
+	if item.Category != "outer" || item.Status != itemStatusSold || item.Price.Amount != 2500 {
+		t.Errorf("unexpected item after update: %+v", item)
+	}
+
+	cases := map[string]struct {
+		id   string
+		body string
+		code int
+	}{
+		"ng: missing item":   {id: "99", body: `{"name":"coat"}`, code: http.StatusNotFound},
+		"ng: no fields":      {id: "1", body: `{}`, code: http.StatusBadRequest},
+		"ng: blank name":     {id: "1", body: `{"name":" "}`, code: http.StatusBadRequest},
+		"ng: negative price": {id: "1", body: `{"price":-1}`, code: http.StatusBadRequest},
+		"ng: unknown status": {id: "1", body: `{"status":"reserved"}`, code: http.StatusBadRequest},
+		"ng: unknown field":  {id: "1", body: `{"seller":"alice"}`, code: http.StatusBadRequest},
+		"ng: malformed body": {id: "1", body: `{"name":`, code: http.StatusBadRequest},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			if rr := patch(tt.id, tt.body); rr.Code != tt.code {
+				t.Errorf("expected status code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 文字数の上限 (バイト数ではなく文字数で数える)
+// params.go のバイト数の上限は、これらの文字数が収まるように決めている
+const (
+	maxItemNameChars = 120
+	maxCategoryChars = 50
+	maxSellerChars   = 50
+	// maxKeywordChars bounds the keyword of GET /search.
+	maxKeywordChars = 100
+)
+
+// FieldError is a problem with a field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+
+	// cause is the sentinel error such as errInvalidPrice, so that errors.Is keeps working.
+	cause error
+}
+
+// ValidationError lists every problem found in a request, so that clients can fix them at once.
+// Handlers respond to it with 400 and the JSON {"errors":[{"field":...,"message":...}]},
+// except POST /items, which responds with 422 and the problems keyed by field (see writeFieldErrors).
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the causes of the field errors.
+func (e *ValidationError) Unwrap() []error {
+	var errs []error
+	for _, fe := range e.Errors {
+		if fe.cause != nil {
+			errs = append(errs, fe.cause)
+		}
+	}
+	return errs
+}
+
+// validator collects field errors instead of stopping at the first one.
+type validator struct {
+	errs []FieldError
+}
+
+// add records a problem with the field. cause may be nil.
+func (v *validator) add(field, message string, cause error) {
+	v.errs = append(v.errs, FieldError{Field: field, Message: message, cause: cause})
+}
+
+// text checks a required text field: not blank, valid UTF-8, at most maxChars characters and no control characters.
+func (v *validator) text(field, value string, maxChars int) {
+	switch {
+	case strings.TrimSpace(value) == "":
+		v.add(field, "required", nil)
+	case !utf8.ValidString(value):
+		v.add(field, "must be valid UTF-8", nil)
+	case utf8.RuneCountInString(value) > maxChars:
+		v.add(field, fmt.Sprintf("must be at most %d characters", maxChars), nil)
+	case strings.ContainsFunc(value, unicode.IsControl):
+		v.add(field, "must not contain control characters", nil)
+	}
+}
+
+// err returns a *ValidationError with the collected problems, or nil if there are none.
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errs}
+}
+
+// writeValidationError responds 400 with the JSON of the validation error.
+func writeValidationError(w http.ResponseWriter, err *ValidationError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(err)
+}
+
+// FieldErrorsResponse is the body of 422 responses of POST /items: the problems keyed by field,
+// such as {"errors":{"name":"required","category":"required"}}.
+type FieldErrorsResponse struct {
+	Errors map[string]string `json:"errors"`
+}
+
+// writeFieldErrors responds 422 with the problems of the validation error keyed by field.
+// Several problems with the same field are joined with "; ".
+func writeFieldErrors(w http.ResponseWriter, err *ValidationError) {
+	resp := FieldErrorsResponse{Errors: make(map[string]string, len(err.Errors))}
+	for _, fe := range err.Errors {
+		if msg, ok := resp.Errors[fe.Field]; ok {
+			resp.Errors[fe.Field] = msg + "; " + fe.Message
+			continue
+		}
+		resp.Errors[fe.Field] = fe.Message
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(resp)
+}
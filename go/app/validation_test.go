@@ -0,0 +1,176 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAddItemValidation(t *testing.T) {
+	t.Parallel()
+
+	// form builds a urlencoded request.
+	form := func(values url.Values) func(t *testing.T) *http.Request {
+		return func(t *testing.T) *http.Request {
+			req := httptest.NewRequest("POST", "/items", strings.NewReader(values.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return req
+		}
+	}
+
+	cases := map[string]struct {
+		request func(t *testing.T) *http.Request
+		errors  map[string]string
+	}{
+		"ng: empty form": {
+			request: form(url.Values{}),
+			errors: map[string]string{
+				"name":     "required",
+				"category": "required",
+				"price":    "required",
+			},
+		},
+		"ng: every problem is reported": {
+			request: form(url.Values{
+				"name":     {""},
+				"category": {strings.Repeat("a", maxCategoryChars+1)},
+				"status":   {"reserved"},
+				"price":    {"-1"},
+			}),
+			errors: map[string]string{
+				"name":     "required",
+				"category": "must be at most 50 characters",
+				"status":   "must be on_sale or sold",
+				"price":    "must be a non-negative integer",
+			},
+		},
+		"ng: length is counted in characters": {
+			request: form(url.Values{
+				"name":     {strings.Repeat("あ", maxItemNameChars+1)},
+				"category": {"fashion"},
+				"price":    {"100"},
+			}),
+			errors: map[string]string{
+				"name": "must be at most 120 characters",
+			},
+		},
+		"ng: blank and control characters": {
+			request: form(url.Values{
+				"name":     {"   "},
+				"category": {"fashion\x00"},
+			}),
+			errors: map[string]string{
+				"name":     "required",
+				"category": "must not contain control characters",
+				"price":    "required",
+			},
+		},
+		"ng: image is not a jpg": {
+			request: func(t *testing.T) *http.Request {
+				body, contentType := newMultipartItem(t, map[string]string{
+					"category": "fashion",
+					"price":    "100",
+				}, "jacket.png", []byte("png"))
+				req := httptest.NewRequest("POST", "/items", body)
+				req.Header.Set("Content-Type", contentType)
+				return req
+			},
+			errors: map[string]string{
+				"image": "must be a .jpg or .jpeg file",
+				"name":  "required",
+			},
+		},
+		// 大きすぎる画像も、他の項目の問題と一緒に返す
+		"ng: too large image": {
+			request: func(t *testing.T) *http.Request {
+				body, contentType := newMultipartItem(t, map[string]string{
+					"name":  "jacket",
+					"price": "100",
+				}, "jacket.jpg", bytes.Repeat([]byte{0xff}, 1025))
+				req := httptest.NewRequest("POST", "/items", body)
+				req.Header.Set("Content-Type", contentType)
+				return req
+			},
+			errors: map[string]string{
+				"image":    "must be at most 1024 bytes",
+				"category": "required",
+			},
+		},
+		"ng: several problems with one field": {
+			request: func(t *testing.T) *http.Request {
+				body, contentType := newMultipartItem(t, map[string]string{
+					"name":     "jacket",
+					"category": "fashion",
+					"price":    "100",
+				}, "jacket.png", bytes.Repeat([]byte{0xff}, 1025))
+				req := httptest.NewRequest("POST", "/items", body)
+				req.Header.Set("Content-Type", contentType)
+				return req
+			},
+			errors: map[string]string{
+				"image": "must be a .jpg or .jpeg file; must be at most 1024 bytes",
+			},
+		},
+		"ng: json": {
+			request: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest("POST", "/items", strings.NewReader(`{"name":"jacket","category":"","price":-1}`))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			errors: map[string]string{
+				"category": "required",
+				"price":    "must be a non-negative integer",
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handlers{imgDirPath: setupImageDir(t), MaxImageBytes: 1024}
+			rr := httptest.NewRecorder()
+			h.AddItem(rr, tt.request(t))
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected status code %d, got %d: %s", http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+			}
+			if got := rr.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("expected JSON response, got %q", got)
+			}
+			var resp FieldErrorsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if diff := cmp.Diff(tt.errors, resp.Errors); diff != "" {
+				t.Errorf("unexpected errors (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidationErrorUnwrap(t *testing.T) {
+	t.Parallel()
+
+	values := url.Values{"name": {"jacket"}, "category": {"fashion"}, "status": {"reserved"}, "price": {"abc"}}
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err := parseAddItemRequest(req, defaultMaxImageBytes)
+
+	// 全ての原因をerrors.Isで確認できる
+	for _, want := range []error{errInvalidItemStatus, errInvalidPrice} {
+		if !errors.Is(err, want) {
+			t.Errorf("expected %v to wrap %v", err, want)
+		}
+	}
+	if want := "status: must be on_sale or sold; price: must be a non-negative integer"; err == nil || err.Error() != want {
+		t.Errorf("expected error %q, got %v", want, err)
+	}
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// 画像の幅を制限したバージョン (srcset用) を作ってキャッシュする
+// 幅は100px単位に丸めて、キャッシュされるファイルの種類が増えすぎないようにする
+
+const (
+	// variantDirName is the directory under the image directory where scaled images are cached.
+	// ドットで始まるので、ストレージの集計には含まれない
+	variantDirName = ".variants"
+	// variantWidthStep is the unit the requested width is rounded to.
+	variantWidthStep = 100
+	// maxVariantWidth caps the requested width, so that the number of cached variants stays bounded.
+	maxVariantWidth = 4000
+	// variantJPEGQuality is the quality of the scaled images.
+	variantJPEGQuality = 85
+)
+
+// variantWidth rounds the requested maximum width to the nearest variantWidthStep, within [variantWidthStep, maxVariantWidth].
+func variantWidth(maxWidth int) int {
+	w := (maxWidth + variantWidthStep/2) / variantWidthStep * variantWidthStep
+	return min(max(w, variantWidthStep), maxVariantWidth)
+}
+
+// variantPath returns the cache path of the variant of imgPath with the given width.
+func (s *Handlers) variantPath(imgPath string, width int) string {
+	name := strings.TrimSuffix(filepath.Base(imgPath), filepath.Ext(imgPath))
+	return filepath.Join(s.imgDirPath, variantDirName, name+"_w"+strconv.Itoa(width)+".jpg")
+}
+
+// imageVariant returns the path of a copy of imgPath scaled down to the given width, creating it on first use.
+// If the image is not wider than width, it returns imgPath itself.
+func (s *Handlers) imageVariant(imgPath string, width int) (string, error) {
+	path := s.variantPath(imgPath, width)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	data, err := os.ReadFile(imgPath)
+	if err != nil {
+		return "", err
+	}
+	// 先にサイズだけ読んで、縮小が不要ならデコードしない
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image config: %w", err)
+	}
+	if cfg.Width <= width {
+		return imgPath, nil
+	}
+
+	src, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	height := max(1, (cfg.Height*width+cfg.Width/2)/cfg.Width)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: variantJPEGQuality}); err != nil {
+		return "", fmt.Errorf("failed to encode image: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	// 同時に同じ幅が要求されても、途中まで書かれたファイルを返さないようにする
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return "", fmt.Errorf("failed to write image variant: %w", err)
+	}
+	return path, nil
+}
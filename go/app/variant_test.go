@@ -0,0 +1,121 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVariantWidth(t *testing.T) {
+	t.Parallel()
+
+	cases := map[int]int{
+		1:     100,
+		149:   100,
+		150:   200,
+		349:   300,
+		300:   300,
+		99999: maxVariantWidth,
+	}
+	for in, want := range cases {
+		if got := variantWidth(in); got != want {
+			t.Errorf("variantWidth(%d): expected %d, got %d", in, want, got)
+		}
+	}
+}
+
+func TestGetImageMaxWidth(t *testing.T) {
+	t.Parallel()
+
+	// 800x400の画像を、ハッシュのファイル名で保存しておく
+	dir := t.TempDir()
+	src := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	for x := range 800 {
+		for y := range 400 {
+			src.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256(buf.Bytes()))
+	if err := os.WriteFile(filepath.Join(dir, hash+".jpg"), buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	type wants struct {
+		code   int
+		width  int
+		height int
+		etag   string
+	}
+	cases := map[string]struct {
+		query string
+		wants
+	}{
+		"ok: original": {
+			query: "",
+			wants: wants{code: http.StatusOK, width: 800, height: 400, etag: `"` + hash + `"`},
+		},
+		"ok: scaled": {
+			query: "?max_width=300",
+			wants: wants{code: http.StatusOK, width: 300, height: 150, etag: `"` + hash + `_w300"`},
+		},
+		"ok: rounded to 100px": {
+			query: "?max_width=349",
+			wants: wants{code: http.StatusOK, width: 300, height: 150, etag: `"` + hash + `_w300"`},
+		},
+		"ok: wider than the original": {
+			query: "?max_width=1000",
+			wants: wants{code: http.StatusOK, width: 800, height: 400, etag: `"` + hash + `"`},
+		},
+		"ng: invalid max_width": {
+			query: "?max_width=-1",
+			wants: wants{code: http.StatusBadRequest},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handlers{imgDirPath: dir}
+			req := httptest.NewRequest("GET", "/images/"+hash+".jpg"+tt.query, nil)
+			req.SetPathValue("filename", hash+".jpg")
+			rr := httptest.NewRecorder()
+			h.GetImage(rr, req)
+
+			if rr.Code != tt.wants.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.wants.code, rr.Code, rr.Body.String())
+			}
+			if tt.wants.code >= 400 {
+				return
+			}
+			cfg, err := jpeg.DecodeConfig(rr.Body)
+			if err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if cfg.Width != tt.wants.width || cfg.Height != tt.wants.height {
+				t.Errorf("expected %dx%d, got %dx%d", tt.wants.width, tt.wants.height, cfg.Width, cfg.Height)
+			}
+			if got := rr.Header().Get("ETag"); got != tt.wants.etag {
+				t.Errorf("expected ETag %s, got %s", tt.wants.etag, got)
+			}
+			// 縮小版は幅ごとにキャッシュされる
+			if tt.wants.width < 800 {
+				if _, err := os.Stat(filepath.Join(dir, variantDirName, fmt.Sprintf("%s_w%d.jpg", hash, tt.wants.width))); err != nil {
+					t.Errorf("expected the variant to be cached: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// 商品詳細の表示回数 (view_count) を数える
+// 表示のたびに書き込むと読み込みが遅くなるので、メモリ上でまとめてから定期的に書き込む
+
+// defaultViewFlushInterval is how often the counted views are written to the database.
+const defaultViewFlushInterval = 5 * time.Second
+
+// viewsStore is where a viewCounter writes the counted views. ItemRepository implements it.
+type viewsStore interface {
+	AddViews(ctx context.Context, views map[int]int) error
+}
+
+// viewCounter counts item views in memory and adds them to the store every interval and on Close.
+// A nil *viewCounter counts nothing.
+type viewCounter struct {
+	store    viewsStore
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[int]int
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// newViewCounter starts the goroutine flushing the views every interval.
+// Close must be called to stop it and flush the remaining views.
+func newViewCounter(store viewsStore, interval time.Duration) *viewCounter {
+	c := &viewCounter{
+		store:    store,
+		interval: interval,
+		pending:  map[int]int{},
+		done:     make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go c.run()
+	return c
+}
+
+// Add counts a view of the item.
+func (c *viewCounter) Add(id int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[id]++
+}
+
+// Flush adds the views counted so far to the store.
+// If the store fails, the views are kept and retried on the next flush.
+func (c *viewCounter) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	views := c.pending
+	c.pending = map[int]int{}
+	c.mu.Unlock()
+	if len(views) == 0 {
+		return nil
+	}
+
+	if err := c.store.AddViews(ctx, views); err != nil {
+		// 書き込めなかった分は、その間に数えた分と合わせて次回に回す
+		c.mu.Lock()
+		for id, n := range c.pending {
+			views[id] += n
+		}
+		c.pending = views
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// run flushes the views every interval until Close is called.
+func (c *viewCounter) run() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Flush(context.Background()); err != nil {
+				slog.Error("failed to flush item views: ", "error", err)
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Close stops the goroutine and flushes the remaining views.
+func (c *viewCounter) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	c.wg.Wait()
+	return c.Flush(context.Background())
+}
@@ -0,0 +1,185 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeViewsStore records the flushed views and fails while err is set.
+type fakeViewsStore struct {
+	mu      sync.Mutex
+	err     error
+	flushed []map[int]int
+}
+
+func (f *fakeViewsStore) AddViews(ctx context.Context, views map[int]int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.flushed = append(f.flushed, views)
+	return nil
+}
+
+func (f *fakeViewsStore) setErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *fakeViewsStore) flushes() []map[int]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]map[int]int(nil), f.flushed...)
+}
+
+func TestViewCounter(t *testing.T) {
+	t.Parallel()
+
+	// 定期的な書き込みが起きないように間隔を長くして、Flushを直接呼ぶ
+	store := &fakeViewsStore{}
+	c := startViewCounter(t, store, time.Hour)
+
+	// 何も数えていなければ書き込まない
+	if err := c.Flush(t.Context()); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	if got := store.flushes(); len(got) != 0 {
+		t.Fatalf("expected no flush without views, got %v", got)
+	}
+
+	// 同じ商品はまとめて1回で書き込む
+	for _, id := range []int{1, 2, 1, 1} {
+		c.Add(id)
+	}
+	if err := c.Flush(t.Context()); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	if diff := cmp.Diff([]map[int]int{{1: 3, 2: 1}}, store.flushes()); diff != "" {
+		t.Errorf("unexpected flushes (-want +got):\n%s", diff)
+	}
+
+	// 書き込みに失敗した分は、その後に数えた分と合わせて次回に書き込む
+	store.setErr(errors.New("database is locked"))
+	c.Add(1)
+	if err := c.Flush(t.Context()); err == nil {
+		t.Fatal("expected an error from the store")
+	}
+	store.setErr(nil)
+	c.Add(1)
+	c.Add(3)
+	if err := c.Flush(t.Context()); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	if diff := cmp.Diff([]map[int]int{{1: 3, 2: 1}, {1: 2, 3: 1}}, store.flushes()); diff != "" {
+		t.Errorf("unexpected flushes after a failure (-want +got):\n%s", diff)
+	}
+}
+
+func TestViewCounterFlushesOnClose(t *testing.T) {
+	t.Parallel()
+
+	store := &fakeViewsStore{}
+	c := newViewCounter(store, time.Hour)
+	c.Add(5)
+	if err := c.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if diff := cmp.Diff([]map[int]int{{5: 1}}, store.flushes()); diff != "" {
+		t.Errorf("unexpected flushes (-want +got):\n%s", diff)
+	}
+	// 2回閉じても問題ない
+	if err := c.Close(); err != nil {
+		t.Fatalf("failed to close twice: %v", err)
+	}
+}
+
+func TestViewCounterFlushesPeriodically(t *testing.T) {
+	t.Parallel()
+
+	store := &fakeViewsStore{}
+	c := startViewCounter(t, store, 10*time.Millisecond)
+	c.Add(7)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(store.flushes()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the views to be flushed by the ticker")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if diff := cmp.Diff([]map[int]int{{7: 1}}, store.flushes()); diff != "" {
+		t.Errorf("unexpected flushes (-want +got):\n%s", diff)
+	}
+}
+
+func TestViewCountE2e(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	repo := &itemRepository{db: db}
+	if err := repo.Insert(t.Context(), &Item{Name: "jacket", Category: "fashion", Image: "default.jpg"}); err != nil {
+		t.Fatalf("failed to insert item: %v", err)
+	}
+	views := startViewCounter(t, repo, time.Hour)
+	h := &Handlers{itemRepo: repo, views: views}
+
+	get := func(id string) int {
+		req := httptest.NewRequest("GET", "/items/"+id, nil)
+		req.SetPathValue("item_id", id)
+		rr := httptest.NewRecorder()
+		h.GetItemById(rr, req)
+		return rr.Code
+	}
+	for range 3 {
+		if code := get("1"); code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, code)
+		}
+	}
+	// 見つからない商品や不正なidは数えない
+	if code := get("99"); code != http.StatusNotFound {
+		t.Fatalf("expected status code %d, got %d", http.StatusNotFound, code)
+	}
+	if code := get("abc"); code == http.StatusOK {
+		t.Fatalf("expected an error status code for an invalid id, got %d", code)
+	}
+
+	// 書き込むまでは増えない
+	item, err := repo.GetItemById(t.Context(), "1")
+	if err != nil {
+		t.Fatalf("failed to get item: %v", err)
+	}
+	if item.ViewCount != 0 {
+		t.Errorf("expected view_count 0 before flushing, got %d", item.ViewCount)
+	}
+
+	if err := views.Flush(t.Context()); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	item, err = repo.GetItemById(t.Context(), "1")
+	if err != nil {
+		t.Fatalf("failed to get item: %v", err)
+	}
+	if item.ViewCount != 3 {
+		t.Errorf("expected view_count 3, got %d", item.ViewCount)
+	}
+}
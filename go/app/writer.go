@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// SQLiteは同時に1つしか書き込めないため、書き込みを1つのgoroutineに集めて順番に実行する
+// 読み込みは別のコネクションプールで並行して実行できる (WALモード)
+
+var errWriterClosed = errors.New("item writer is closed")
+
+// writeRequest is a write operation queued to the writer goroutine.
+type writeRequest struct {
+	fn     func() error
+	result chan error
+}
+
+// serializedItemRepository is an ItemRepository whose writes are executed one at a time
+// by a single goroutine, while reads go directly to the embedded repository.
+// Write methods added to ItemRepository must also be overridden here, otherwise they bypass the writer.
+type serializedItemRepository struct {
+	// ItemRepository serves the reads.
+	ItemRepository
+	// writes executes the writes. It should own a dedicated connection (MaxOpenConns=1).
+	writes ItemRepository
+
+	reqs      chan writeRequest
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewSerializedItemRepository starts the writer goroutine.
+// reads and writes are usually repositories on separate *sql.DB of the same database file.
+// Close must be called to stop the goroutine.
+func NewSerializedItemRepository(reads, writes ItemRepository) *serializedItemRepository {
+	s := &serializedItemRepository{
+		ItemRepository: reads,
+		writes:         writes,
+		reqs:           make(chan writeRequest),
+		done:           make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+// run executes the queued writes in order until Close is called.
+func (s *serializedItemRepository) run() {
+	defer s.wg.Done()
+	for {
+		select {
+		case req := <-s.reqs:
+			req.result <- req.fn()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Close stops the writer goroutine. Writes after Close fail with errWriterClosed.
+func (s *serializedItemRepository) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	s.wg.Wait()
+}
+
+// do queues fn to the writer goroutine and waits for its result.
+func (s *serializedItemRepository) do(ctx context.Context, fn func() error) error {
+	// resultはバッファ付きにして、呼び出し側が先に諦めても writer goroutine が止まらないようにする
+	req := writeRequest{fn: fn, result: make(chan error, 1)}
+	select {
+	case s.reqs <- req:
+	case <-s.done:
+		return errWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	// 実行が始まったら、ctxのキャンセルはfnの中のクエリに任せる
+	return <-req.result
+}
+
+func (s *serializedItemRepository) Insert(ctx context.Context, item *Item) error {
+	return s.do(ctx, func() error { return s.writes.Insert(ctx, item) })
+}
+
+func (s *serializedItemRepository) InsertMany(ctx context.Context, items []*Item) error {
+	return s.do(ctx, func() error { return s.writes.InsertMany(ctx, items) })
+}
+
+func (s *serializedItemRepository) SoftDelete(ctx context.Context, item_id string) error {
+	return s.do(ctx, func() error { return s.writes.SoftDelete(ctx, item_id) })
+}
+
+func (s *serializedItemRepository) Restore(ctx context.Context, item_id string) error {
+	return s.do(ctx, func() error { return s.writes.Restore(ctx, item_id) })
+}
+
+func (s *serializedItemRepository) Publish(ctx context.Context, item_id string) error {
+	return s.do(ctx, func() error { return s.writes.Publish(ctx, item_id) })
+}
+
+func (s *serializedItemRepository) Purge(ctx context.Context, item_id string) (string, error) {
+	var image string
+	err := s.do(ctx, func() error {
+		var err error
+		image, err = s.writes.Purge(ctx, item_id)
+		return err
+	})
+	return image, err
+}
+
+func (s *serializedItemRepository) Purchase(ctx context.Context, item_id string) error {
+	return s.do(ctx, func() error { return s.writes.Purchase(ctx, item_id) })
+}
+
+func (s *serializedItemRepository) AddFavorite(ctx context.Context, item_id string, clientToken string) error {
+	return s.do(ctx, func() error { return s.writes.AddFavorite(ctx, item_id, clientToken) })
+}
+
+func (s *serializedItemRepository) RemoveFavorite(ctx context.Context, item_id string, clientToken string) error {
+	return s.do(ctx, func() error { return s.writes.RemoveFavorite(ctx, item_id, clientToken) })
+}
+
+func (s *serializedItemRepository) AddViews(ctx context.Context, views map[int]int) error {
+	return s.do(ctx, func() error { return s.writes.AddViews(ctx, views) })
+}
+
+func (s *serializedItemRepository) DeleteCategory(ctx context.Context, id int, fallback string) (int, error) {
+	var moved int
+	err := s.do(ctx, func() error {
+		var err error
+		moved, err = s.writes.DeleteCategory(ctx, id, fallback)
+		return err
+	})
+	return moved, err
+}
+
+func (s *serializedItemRepository) Update(ctx context.Context, item_id string, patch ItemPatch) (Item, Item, error) {
+	var before, after Item
+	err := s.do(ctx, func() error {
+		var err error
+		before, after, err = s.writes.Update(ctx, item_id, patch)
+		return err
+	})
+	return before, after, err
+}
+
+func (s *serializedItemRepository) Reorder(ctx context.Context, ids []int) error {
+	return s.do(ctx, func() error { return s.writes.Reorder(ctx, ids) })
+}
+
+func (s *serializedItemRepository) Swap(ctx context.Context, a, b int) error {
+	return s.do(ctx, func() error { return s.writes.Swap(ctx, a, b) })
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestSerializedItemRepositoryStress(t *testing.T) {
+	t.Parallel()
+
+	const (
+		writers         = 20
+		insertsPerWrite = 10
+		readers         = 4
+	)
+
+	// ロック待ちのタイムアウトなしで開き、書き込み同士がぶつかればすぐにエラーになるようにする
+	path := filepath.Join(t.TempDir(), "stress.sqlite3")
+	openDB := func(maxOpen int) *sql.DB {
+		db, err := sql.Open("sqlite3", path)
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if err := (Server{DBMaxOpenConns: maxOpen, DBMaxIdleConns: maxOpen}).configureDB(db); err != nil {
+			t.Fatalf("failed to configure database: %v", err)
+		}
+		return db
+	}
+	readDB := openDB(readers)
+	writeDB := openDB(1)
+
+	schema, err := os.ReadFile("../db/items.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if err := initSchema(writeDB, string(schema), time.Now()); err != nil {
+		t.Fatalf("failed to init schema: %v", err)
+	}
+
+	repo := startSerializedItemRepository(t, &itemRepository{db: readDB}, &itemRepository{db: writeDB})
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*insertsPerWrite+readers)
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range insertsPerWrite {
+				item := &Item{Name: fmt.Sprintf("item %d-%d", w, i), Category: "fashion", Image: "default.jpg", Status: itemStatusOnSale}
+				if err := repo.Insert(ctx, item); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	// 書き込み中も読み込みが並行して行える
+	for range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range insertsPerWrite {
+				if _, err := repo.GetAll(ctx, ItemListOptions{}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	items, err := repo.GetAll(ctx, ItemListOptions{})
+	if err != nil {
+		t.Fatalf("failed to get items: %v", err)
+	}
+	if want := writers * insertsPerWrite; len(items) != want {
+		t.Errorf("expected %d items, got %d", want, len(items))
+	}
+}
+
+func TestSerializedItemRepositoryClosed(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	reads := NewMockItemRepository(ctrl)
+	writes := NewMockItemRepository(ctrl)
+	// 読み込みはClose後も埋め込んだリポジトリに委譲される
+	reads.EXPECT().Ping(gomock.Any()).Return(nil)
+
+	repo := NewSerializedItemRepository(reads, writes)
+	repo.Close()
+
+	if err := repo.Insert(context.Background(), &Item{}); !errors.Is(err, errWriterClosed) {
+		t.Errorf("expected %v, got %v", errWriterClosed, err)
+	}
+	if err := repo.Ping(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -2,8 +2,24 @@
 CREATE TABLE IF NOT EXISTS items (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL,
+	name_normalized TEXT NOT NULL DEFAULT '', -- 検索用にnameを正規化したもの (NFKCと小文字化, アプリ側で設定する)
     category_id INTEGER NOT NULL,
 	image_name TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'on_sale' CHECK (status IN ('on_sale', 'sold')),
+	price INTEGER NOT NULL DEFAULT 0 CHECK (price >= 0), -- 通貨の最小単位 (整数)
+	price_currency TEXT NOT NULL DEFAULT 'JPY', -- ISO 4217 (JPY, USD, EUR)
+	sort_order INTEGER, -- 手動の並び順 (小さいほど前)
+	created_at TEXT, -- RFC3339 (UTC), アプリ側で設定する
+	updated_at TEXT, -- RFC3339 (UTC), アプリ側で設定する
+	deleted_at TEXT, -- 論理削除された日時 (削除されていなければNULL)
+	view_count INTEGER NOT NULL DEFAULT 0, -- 詳細ページが表示された回数
+	seller TEXT NOT NULL DEFAULT 'anonymous', -- 出品者のID (ログインの仕組みができるまではクライアントが指定する)
+	condition TEXT NOT NULL DEFAULT 'used' CHECK (condition IN ('new', 'like_new', 'used', 'junk')), -- 商品の状態
+	quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity >= 0), -- 在庫数 (購入のたびに1減り、0で売り切れ)
+	brand TEXT NOT NULL DEFAULT '', -- ブランド (空白を正規化したもの, なければ空文字)
+	shipping_payer TEXT NOT NULL DEFAULT 'seller' CHECK (shipping_payer IN ('seller', 'buyer')), -- 送料を負担する人
+	shipping_days INTEGER NOT NULL DEFAULT 3 CHECK (shipping_days BETWEEN 1 AND 30), -- 発送までの日数
+	is_published INTEGER NOT NULL DEFAULT 1 CHECK (is_published IN (0, 1)), -- 0なら下書き (一覧と検索に出さない)
 	FOREIGN KEY (category_id) REFERENCES categories(id)
 );
 
@@ -11,4 +27,53 @@ CREATE TABLE IF NOT EXISTS items (
 CREATE TABLE IF NOT EXISTS categories (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL UNIQUE
-);
\ No newline at end of file
+);
+
+-- metaテーブルの定義 (1行だけ)
+-- categories_version はカテゴリを変更するトランザクションの中で必ず増やす
+CREATE TABLE IF NOT EXISTS meta (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    categories_version INTEGER NOT NULL DEFAULT 0
+);
+INSERT OR IGNORE INTO meta (id) VALUES (1);
+
+-- favoritesテーブルの定義
+-- ログインの仕組みができるまでは、クライアントが送るトークン (X-Client-Token) ごとに記録する
+CREATE TABLE IF NOT EXISTS favorites (
+    item_id INTEGER NOT NULL,
+    client_token TEXT NOT NULL,
+    created_at TEXT, -- RFC3339 (UTC), アプリ側で設定する
+    PRIMARY KEY (item_id, client_token),
+    FOREIGN KEY (item_id) REFERENCES items(id)
+);
+CREATE INDEX IF NOT EXISTS idx_favorites_client_token ON favorites (client_token);
+
+-- tagsテーブルの定義 (小文字に揃えた自由なタグ)
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+-- item_tagsテーブルの定義 (商品とタグの多対多)
+CREATE TABLE IF NOT EXISTS item_tags (
+    item_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (item_id, tag_id),
+    FOREIGN KEY (item_id) REFERENCES items(id),
+    FOREIGN KEY (tag_id) REFERENCES tags(id)
+);
+CREATE INDEX IF NOT EXISTS idx_item_tags_tag_id ON item_tags (tag_id);
+
+-- item_eventsテーブルの定義 (商品の変更履歴)
+-- 商品の作成・更新と同じトランザクションで記録する
+CREATE TABLE IF NOT EXISTS item_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_id INTEGER NOT NULL,
+    field TEXT NOT NULL, -- created, または変わった項目 (name, category, status, price)
+    old_value TEXT, -- createdではNULL
+    new_value TEXT, -- createdではNULL
+    created_at TEXT NOT NULL, -- RFC3339 (UTC), アプリ側で設定する
+    actor TEXT NOT NULL, -- 変更した人 (X-Actor, なければanonymous)
+    FOREIGN KEY (item_id) REFERENCES items(id)
+);
+CREATE INDEX IF NOT EXISTS idx_item_events_item_id ON item_events (item_id);